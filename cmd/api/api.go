@@ -4,17 +4,44 @@ import (
 	"flag"
 	current "github.com/ercross/grabjobs/cmd/api/v1" // simply change import path if current api version changes
 	"github.com/ercross/grabjobs/internal/db"
+	"github.com/ercross/grabjobs/internal/models"
 	"log"
+	"strings"
+	"time"
 )
 
 func main() {
 	app := new(current.App)
 	app.Config = initConfig()
-	repo, err := db.Initialize(app.Config.LocationDataFilePath)
+	var titleSynonyms [][]string
+	if app.Config.TitleSynonymsFilePath != "" {
+		var err error
+		if titleSynonyms, err = db.ReadTitleSynonyms(app.Config.TitleSynonymsFilePath); err != nil {
+			log.Fatalf("failed to read title synonyms: %v", err)
+		}
+	}
+	repo, err := db.Initialize(app.Config.LocationDataFilePath, db.Options{
+		TitleCasing:     app.Config.TitleCasing,
+		SearchWorkers:   app.Config.SearchWorkers,
+		WatchFile:       app.Config.WatchDataFile,
+		RefreshInterval: app.Config.RefreshInterval,
+		RequireData:     app.Config.RequireData,
+		DefaultRadius:   app.Config.DefaultRadius,
+		EarthRadiusKm:   app.Config.EarthRadiusKm,
+		MaxJobs:         app.Config.MaxJobs,
+		TitleSynonyms:   titleSynonyms,
+		WarmQueries:     app.Config.WarmQueries,
+		Verify:          app.Config.VerifySearches,
+	})
 	if err != nil {
 		log.Fatalf("failed to initialize database: %v", err)
 	}
-	app.Routes = current.Routes(repo)
+	for name, path := range app.Config.DatasetFilePaths {
+		if err := repo.ReloadDataset(name, path); err != nil {
+			log.Fatalf("failed to load dataset %v: %v", name, err)
+		}
+	}
+	app.Routes = current.Routes(repo, app.Config)
 	if err := app.StartServer(); err != nil {
 		log.Fatalf("error encountered starting server: %v", err)
 	}
@@ -24,6 +51,102 @@ func initConfig() current.Config {
 	var config current.Config
 	flag.IntVar(&config.Port, "port", 4046, "port the server listens on")
 	flag.StringVar(&config.LocationDataFilePath, "db", "empty", "api endpoint")
+	caseSensitiveTitles := flag.Bool("case-sensitive-titles", false, "deprecated: use -title-casing separate")
+	titleCasing := flag.String("title-casing", "merge", "indexing of titles differing only in case, merge, first (merged into the casing first seen) or separate")
+	flag.BoolVar(&config.RequireData, "require-data", true, "fail at startup if the db file holds no valid job")
+	flag.BoolVar(&config.WatchDataFile, "watch", false, "reload jobs whenever the db file changes")
+	flag.DurationVar(&config.RefreshInterval, "refresh-interval", 0, "reload jobs from the db file on this schedule, e.g. 5m, 0 to disable")
+	flag.StringVar(&config.TitleSynonymsFilePath, "title-synonyms", "", "csv file of title synonyms, each line listing titles matching each other")
+	warmQueries := flag.String("warm-queries", "", "semicolon-separated lat,lon[,radius] list, ordered as coord-order, of nearby searches computed whenever jobs are indexed")
+	verify := flag.String("verify", "off", "check nearby searches against a scan of every job, off, log (log mismatches) or fallback (also return the jobs scanned)")
+	flag.IntVar(&config.MaxJobs, "max-jobs", 0, "maximum number of jobs held, evicting the oldest above it, 0 for no limit")
+	flag.IntVar(&config.SearchWorkers, "search-workers", 0, "goroutines computing distances in large searches, 0 for GOMAXPROCS")
+	flag.StringVar(&config.UnixSocket, "socket", "", "unix domain socket to listen on instead of port")
+	flag.BoolVar(&config.StrictQueryParams, "strict", false, "reject requests with unknown query parameters")
+	flag.Float64Var(&config.DefaultRadius.Value, "default-radius", 5, "nearby search radius if a request specifies none")
+	coordOrder := flag.String("coord-order", "latlng", "order of coordinate pairs, i.e., the coordinates query parameter and warm-queries, latlng or lnglat")
+	fieldNaming := flag.String("field-naming", "camel", "case of JSON field names in responses, camel or snake")
+	antimeridian := flag.String("antimeridian", "split", "handling of boxes whose minLon exceeds maxLon, i.e., crossing the antimeridian, split (search either side) or reject")
+	trailingSlash := flag.String("trailing-slash", "strict", "handling of paths not found for their trailing slash, strict, strip or redirect")
+	flag.BoolVar(&config.CaseInsensitivePaths, "case-insensitive-paths", false, "route paths not found in lower case")
+	datasets := flag.String("datasets", "", "comma-separated name=path list of db files loaded aside the live db, selected with the X-Dataset header")
+	defaultRadiusUnit := flag.String("default-radius-unit", "km", "unit of default-radius, km or mi")
+	flag.Float64Var(&config.EarthRadiusKm, "earth-radius", 0, "radius in km of the sphere distances are computed on, 0 for the earth")
+	flag.Float64Var(&config.MaxRadiusKm, "max-radius", 100, "maximum nearby search radius in km, 0 for no limit")
+	flag.Float64Var(&config.WalkSpeedKmh, "walk-speed", 5, "average walking speed in km/h travel times are estimated with")
+	flag.Float64Var(&config.DriveSpeedKmh, "drive-speed", 40, "average driving speed in km/h travel times are estimated with")
+	flag.BoolVar(&config.ClampRadius, "clamp-radius", false, "clamp radius above max-radius instead of rejecting the request")
+	flag.Float64Var(&config.RadiusStepKm, "radius-step", 0, "round search radii to the nearest multiple of this step in km, 0 to search radii as requested")
+	flag.IntVar(&config.MaxGeometryVertices, "max-vertices", 1000, "maximum number of locations in a request geometry, 0 for no limit")
+	flag.Float64Var(&config.MaxBoxAreaKm2, "max-box-area", 0, "maximum area in square km of the bounding box of a request geometry, 0 for no limit")
+	flag.IntVar(&config.MaxAvailableTitles, "max-available-titles", 0, "maximum number of titles listed at once by the available endpoint, 0 for no limit")
+	distributionBounds := flag.String("distribution-bounds", "1,5,10,50,100", "comma-separated ascending upper bounds of the buckets of the title distribution by number of jobs")
+	flag.IntVar(&config.MetricsTopTitles, "metrics-top-titles", 20, "number of titles having the most jobs exposed with a gauge by the metrics endpoint")
+	flag.IntVar(&config.MaxResultCount, "max-results", 0, "maximum number of jobs a radius search returns, 0 for no limit")
+	flag.BoolVar(&config.TruncateResults, "truncate-results", false, "truncate results above max-results instead of rejecting the request")
+	flag.BoolVar(&config.DevMode, "dev", false, "report the cost of queries in responses")
+	flag.DurationVar(&config.IdempotencyKeyTTL, "idempotency-ttl", 24*time.Hour, "time the response to a job insertion is replayed for retries having the same Idempotency-Key")
+	flag.BoolVar(&config.UnavailableDuringRebuild, "unavailable-during-rebuild", false, "reject queries with 503 while the index is rebuilt instead of serving the jobs before the rebuild")
+	flag.DurationVar(&config.RequestTimeout, "request-timeout", 0, "time budget of a request, e.g. 2s, 0 for no limit")
 	flag.Parse()
+
+	unit, ok := models.ParseDistanceUnit(*defaultRadiusUnit)
+	if !ok {
+		log.Fatalf("unknown default-radius-unit %q, expected km or mi", *defaultRadiusUnit)
+	}
+	config.DefaultRadius.Unit = unit
+
+	if config.TitleCasing, ok = db.ParseTitleCasing(*titleCasing); !ok {
+		log.Fatalf("unknown title-casing %q, expected merge, first or separate", *titleCasing)
+	}
+	if *caseSensitiveTitles {
+		titleCasingSet := false
+		flag.Visit(func(f *flag.Flag) { titleCasingSet = titleCasingSet || f.Name == "title-casing" })
+		if titleCasingSet && config.TitleCasing != db.SeparateTitleCasings {
+			log.Fatalf("case-sensitive-titles conflicts with title-casing %q", *titleCasing)
+		}
+		log.Printf("case-sensitive-titles is deprecated, use -title-casing separate")
+		config.TitleCasing = db.SeparateTitleCasings
+	}
+
+	if config.VerifySearches, ok = db.ParseVerifyMode(*verify); !ok {
+		log.Fatalf("unknown verify %q, expected off, log or fallback", *verify)
+	}
+
+	if config.FieldNaming, ok = current.ParseFieldNaming(*fieldNaming); !ok {
+		log.Fatalf("unknown field-naming %q, expected camel or snake", *fieldNaming)
+	}
+
+	if config.CoordinateOrder, ok = models.ParseCoordinateOrder(*coordOrder); !ok {
+		log.Fatalf("unknown coord-order %q, expected latlng or lnglat", *coordOrder)
+	}
+
+	if config.Antimeridian, ok = current.ParseAntimeridianPolicy(*antimeridian); !ok {
+		log.Fatalf("unknown antimeridian %q, expected split or reject", *antimeridian)
+	}
+
+	if config.TrailingSlash, ok = current.ParseTrailingSlashPolicy(*trailingSlash); !ok {
+		log.Fatalf("unknown trailing-slash %q, expected strict, strip or redirect", *trailingSlash)
+	}
+
+	var err error
+	if config.DistributionBounds, err = current.ParseCountBounds(*distributionBounds); err != nil {
+		log.Fatalf("invalid distribution-bounds %q: %v", *distributionBounds, err)
+	}
+
+	if config.WarmQueries, err = db.ParseWarmQueries(*warmQueries, config.CoordinateOrder); err != nil {
+		log.Fatalf("invalid warm-queries: %v", err)
+	}
+
+	if *datasets != "" {
+		config.DatasetFilePaths = make(map[string]string)
+		for _, dataset := range strings.Split(*datasets, ",") {
+			name, path, found := strings.Cut(dataset, "=")
+			if !found || name == "" || path == "" || name == db.LiveDataset {
+				log.Fatalf("invalid dataset %q, expected name=path with a name other than %v", dataset, db.LiveDataset)
+			}
+			config.DatasetFilePaths[name] = path
+		}
+	}
 	return config
 }
@@ -0,0 +1,155 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/ercross/grabjobs/internal/db"
+	"github.com/ercross/grabjobs/internal/models"
+	"net/http"
+	"strconv"
+)
+
+// deleteJobs deletes jobs matching a title within some radius of a location.
+// A zero radius deletes jobs at the location, give or take a meter
+// of rounding in the coordinates
+// Request Method: DELETE
+// Query Parameters:
+//
+//	title 		string
+//	latitude 	decimal/float
+//	longitude 	decimal/float
+//	radius 		decimal/float
+//
+// Response Type: application/json
+func (app *App) deleteJobs(w http.ResponseWriter, r *http.Request) {
+
+	title := r.URL.Query().Get("title")
+	if notValidString(title) {
+		app.sendFailedValidationResponse(w, validationError("title", codeRequired, "title is not a valid text"))
+		return
+	}
+
+	center, ok := app.readLocation(w, r)
+	if !ok {
+		return
+	}
+
+	radius, err := strconv.ParseFloat(r.URL.Query().Get("radius"), 64)
+	if err != nil || radius < 0 {
+		app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius not a valid non-negative decimal/float"))
+		return
+	}
+
+	deleted, err := app.repository(r).DeleteJobs(title, center, radius)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error deleting %v jobs within a radius of %f around %v: %v", title, radius, center, err))
+		return
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("Deleted %d %v jobs", deleted, title),
+	}, map[string]int{"deleted": deleted})
+}
+
+// getSpatialJoin fetches pairs of jobs titled titleA and titleB within some radius of each other,
+// e.g., cafés within 0.5km of offices
+// Request Method: GET
+// Query Parameters:
+//
+//	titleA 		string
+//	titleB 		string
+//	radius 		decimal/float (in km), capped at Config.MaxRadiusKm
+//
+// Response Type: application/json
+func (app *App) getSpatialJoin(w http.ResponseWriter, r *http.Request) {
+
+	titleA, titleB := r.URL.Query().Get("titleA"), r.URL.Query().Get("titleB")
+	if notValidString(titleA) {
+		app.sendFailedValidationResponse(w, validationError("titleA", codeRequired, "titleA is not a valid text"))
+		return
+	}
+	if notValidString(titleB) {
+		app.sendFailedValidationResponse(w, validationError("titleB", codeRequired, "titleB is not a valid text"))
+		return
+	}
+
+	radius, err := strconv.ParseFloat(r.URL.Query().Get("radius"), 64)
+	if err != nil || radius < 0 {
+		app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius not a valid non-negative decimal/float"))
+		return
+	}
+	radius, withinLimit := app.capRadius(radius)
+	if !withinLimit {
+		app.sendFailedValidationResponse(w, validationError("radius", codeOutOfRange,
+			fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+		return
+	}
+
+	pairs := app.repository(r).SpatialJoin(titleA, titleB, models.Distance{Unit: models.Kilometer, Value: radius})
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("%v jobs near %v jobs", titleA, titleB),
+	}
+	keep, withinBudget := app.checkResultBudget(args, len(pairs))
+	if !withinBudget {
+		return
+	}
+	app.sendJSONResponse(args, pairs[:keep])
+}
+
+// getDatasetDiff fetches the jobs added, removed and moved from one dataset to another,
+// e.g., to review a candidate dataset before it replaces the live one.
+// Jobs of both datasets having the same title and company are moved, as paired by db.DiffDatasets.
+// Request Method: GET
+// Query Parameters:
+//
+//	from 		optional string, name of a dataset, default live
+//	to 		string, name of a dataset
+//
+// Response Type: application/json
+func (app *App) getDatasetDiff(w http.ResponseWriter, r *http.Request) {
+
+	datasets, ok := app.repo.(datasetRepository)
+	if !ok {
+		app.sendFailedValidationResponse(w, validationError("to", codeInvalid, "datasets other than live are not supported"))
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	if notValidString(from) {
+		from = db.LiveDataset
+	}
+	a, ok := datasets.Dataset(from)
+	if !ok {
+		app.sendFailedValidationResponse(w, validationError("from", codeInvalid, fmt.Sprintf("unknown dataset %q", from)))
+		return
+	}
+
+	to := r.URL.Query().Get("to")
+	if notValidString(to) {
+		app.sendFailedValidationResponse(w, validationError("to", codeRequired, "to is not a valid text"))
+		return
+	}
+	b, ok := datasets.Dataset(to)
+	if !ok {
+		app.sendFailedValidationResponse(w, validationError("to", codeInvalid, fmt.Sprintf("unknown dataset %q", to)))
+		return
+	}
+
+	diff := db.DiffDatasets(a, b)
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("Jobs changed from %v to %v", from, to),
+	}
+	args.addMeta("added", len(diff.Added))
+	args.addMeta("removed", len(diff.Removed))
+	args.addMeta("moved", len(diff.Moved))
+	app.sendJSONResponse(args, diff)
+}
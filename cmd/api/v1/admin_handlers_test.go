@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/db"
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestDatasetDiff(t *testing.T) {
+	repo := newTestDataset(t, db.Options{}, "Nurse,103.85,1.29", "Cook,103.86,1.29", "Tailor,103.87,1.29")
+	candidate := filepath.Join(t.TempDir(), "candidate.csv")
+	if err := os.WriteFile(candidate, []byte("title,longitude,latitude\nNurse,103.85,1.29\nCook,103.861,1.29\nWelder,103.88,1.29\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ReloadDataset("candidate", candidate); err != nil {
+		t.Fatal(err)
+	}
+	routes := Routes(repo, Config{})
+
+	status, response := serve(t, routes, http.MethodGet, "/api/v1/admin/datasets/diff?to=candidate", "")
+	if status != http.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+	var diff models.DatasetDiff
+	decodeData(t, response, &diff)
+	if len(diff.Added) != 1 || diff.Added[0].Title != "Welder" ||
+		len(diff.Removed) != 1 || diff.Removed[0].Title != "Tailor" ||
+		len(diff.Moved) != 1 || diff.Moved[0].From.Title != "Cook" {
+		t.Errorf("diff %+v, want Welder added, Tailor removed and Cook moved", diff)
+	}
+	for _, field := range []string{"added", "removed", "moved"} {
+		if response.Meta[field] != 1.0 {
+			t.Errorf("meta.%s = %v, want 1", field, response.Meta[field])
+		}
+	}
+
+	_, response = serve(t, routes, http.MethodGet, "/api/v1/admin/datasets/diff?from=candidate&to=live", "")
+	decodeData(t, response, &diff)
+	if len(diff.Added) != 1 || diff.Added[0].Title != "Tailor" || len(diff.Removed) != 1 || diff.Removed[0].Title != "Welder" {
+		t.Errorf("reverse diff %+v, want Tailor added and Welder removed", diff)
+	}
+
+	for _, query := range []string{"", "?to=unknown", "?from=unknown&to=candidate"} {
+		if status, _ := serve(t, routes, http.MethodGet, "/api/v1/admin/datasets/diff"+query, ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("%q: status %d, want 422", query, status)
+		}
+	}
+}
@@ -1,10 +1,18 @@
 package v1
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/ercross/grabjobs/internal/db"
 	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	"go.opentelemetry.io/otel/trace"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"time"
 )
 
@@ -12,29 +20,314 @@ type repository interface {
 	// TitleJobs fetches a mapping of title to available jobs
 	TitleJobs() (map[string][]models.Job, error)
 
-	// FindJobsNearby finds jobs within radius of location.
-	// If radius is zero, a default radius of 5km is used.
+	// TitleJobsPage fetches up to limit titles, with their jobs, following the title
+	// keyed after in a deterministic order. An empty after starts from the first title.
+	// next is passed as after to fetch the following page, and is empty on the last page.
+	// Any error returned is an internal error
+	TitleJobsPage(after string, limit int) (page map[string][]models.Job, next string, err error)
+
+	// JobsWithTitle fetches the jobs having title, matched as in SearchJobsByTitleAndLocation.
+	// JobsWithTitle returns an empty slice if no job has title.
+	// Any error returned is an internal error
+	JobsWithTitle(title string) ([]models.Job, error)
+
+	// Stats fetches the size and coverage of the jobs dataset
+	Stats() (models.Stats, error)
+
+	// Rebuilding reports whether the index is being rebuilt,
+	// queries being served from the jobs before the rebuild meanwhile
+	Rebuilding() bool
+
+	// DefaultRadius is the radius of nearby searches not specifying one
+	DefaultRadius() models.Distance
+
+	// Sphere is the sphere distances between jobs are computed on
+	Sphere() models.Sphere
+
+	// FindJobsNearby finds jobs within radius of location, jobs exactly at radius
+	// included unless boundary is rtree.Exclusive. If radius is zero, DefaultRadius is used.
 	// FindJobsNearby returns an empty slice if no job is found within radius of location.
+	// Any error returned is an internal error or ctx.Err()
+	FindJobsNearby(ctx context.Context, location models.Location, radius float64, boundary rtree.Boundary) ([]models.Job, error)
+
+	// FindJobsNearbyApproximately finds every job within radius of location,
+	// quickly but along with jobs near them which may lie outside radius.
+	// If radius is zero, DefaultRadius is used.
+	// Any error returned is an internal error or ctx.Err()
+	FindJobsNearbyApproximately(ctx context.Context, location models.Location, radius float64) ([]models.Job, error)
+
+	// CountJobsNearbyApprox counts the jobs within radius of location quickly,
+	// though possibly counting some jobs near them which lie outside radius.
+	// Jobs exactly at radius are counted unless boundary is rtree.Exclusive.
+	// If radius is zero, DefaultRadius is used.
+	// Any error returned is an internal error or ctx.Err()
+	CountJobsNearbyApprox(ctx context.Context, location models.Location, radius float64, boundary rtree.Boundary) (int, error)
+
+	// TitleCountsNearby counts the jobs of each title within radius of location,
+	// titles being keyed as in TitleJobs.
+	// Any error returned is an internal error or ctx.Err()
+	TitleCountsNearby(ctx context.Context, location models.Location, radius float64) (map[string]int, error)
+
+	// TitleCountsWithPrefix counts the jobs of each title starting with prefix,
+	// titles and prefix being keyed as in TitleJobs.
 	// Any error returned is an internal error
-	FindJobsNearby(location models.Location, radius float64) ([]models.Job, error)
+	TitleCountsWithPrefix(prefix string) (map[string]int, error)
+
+	// FindJobsNearAny finds jobs within radius of any of centers,
+	// jobs exactly at radius included unless boundary is rtree.Exclusive.
+	// Each job is annotated with its distance to the nearest center,
+	// and jobs are ordered from the nearest.
+	// Any error returned is an internal error or ctx.Err()
+	FindJobsNearAny(ctx context.Context, centers []models.Location, radius float64, boundary rtree.Boundary) ([]models.JobWithDistance, error)
+
+	// FindNearestJobs finds up to k jobs nearest to center, each annotated with
+	// its distance to center, from the nearest. If box is not nil, only jobs
+	// located within box are found, even if jobs outside box are nearer.
+	// If after is not nil, only jobs following after in the order of models.SortNearestFirst
+	// are found, e.g., the next page of jobs following the last job of a previous page.
+	// Any error returned is an internal error or ctx.Err()
+	FindNearestJobs(ctx context.Context, center models.Location, k int, box *models.Box, after *models.NearestPosition) ([]models.JobWithDistance, error)
+
+	// NeighborsOf finds up to k jobs nearest to the job with id, but that job itself,
+	// each annotated with its distance to that job, from the nearest.
+	// No job is found if no job has id.
+	NeighborsOf(id string, k int) []models.JobWithDistance
+
+	// NearestPerTitle finds up to k jobs nearest to center for each of titles, each annotated
+	// with its distance to center, from the nearest, titles matching as in SearchJobsByTitleAndLocation.
+	NearestPerTitle(center models.Location, titles []string, k int) map[string][]models.JobWithDistance
+
+	// FindEntriesInBox finds jobs located within box along with
+	// their minimum bounding rectangles on the index.
+	// Any error returned is an internal error or ctx.Err()
+	FindEntriesInBox(ctx context.Context, box models.Box) ([]rtree.EntryView, error)
+
+	// CountJobsInBox counts the jobs located within box.
+	// Any error returned is an internal error or ctx.Err()
+	CountJobsInBox(ctx context.Context, box models.Box) (int, error)
 
-	// SearchJobsByTitleAndLocation finds jobs matching title within 5km
-	// radius of location.
-	// If no job is found matching title within the default radius,
+	// CountJobsInGrid counts the jobs located within each cell of grid.
+	// Any error returned is an internal error or ctx.Err()
+	CountJobsInGrid(ctx context.Context, grid *models.Grid) error
+
+	// Hotspot finds the job location having the most other jobs within radius.
+	// ok is false if there are no jobs.
+	// Any error returned is an internal error or ctx.Err()
+	Hotspot(ctx context.Context, radius float64) (hotspot models.Hotspot, ok bool, err error)
+
+	// SearchJobsByTitleAndLocation finds jobs matching title and company within
+	// DefaultRadius of location. An empty title or company matches any.
+	// Companies are matched case-insensitively.
+	// If no job is found matching title and company within the default radius,
 	// an empty slice of Models.Job is returned.
+	// Any error returned is an internal error or ctx.Err()
+	SearchJobsByTitleAndLocation(ctx context.Context, title, company string, location models.Location) ([]models.Job, error)
+
+	// JobByID finds the job with id.
+	// ok is false if no job has id.
+	JobByID(id string) (job models.Job, ok bool)
+
+	// AddJob adds job to the available jobs, setting job.ID.
+	// Any error returned is an internal error
+	AddJob(job *models.Job) error
+
+	// AddJobs adds jobs to the available jobs in a single batch,
+	// setting the ID of each of jobs in place.
+	// Any error returned is an internal error
+	AddJobs(jobs []models.Job) error
+
+	// DeleteJobs deletes jobs matching title within radius of center,
+	// and returns the number of jobs deleted.
+	// Any error returned is an internal error
+	DeleteJobs(title string, center models.Location, radius float64) (int, error)
+
+	// SpatialJoin pairs each job titled titleA with every job titled titleB within distance of it.
+	// A job is never paired with itself.
+	SpatialJoin(titleA, titleB string, within models.Distance) []models.JobPair
+
+	// SuggestTitles finds up to limit available titles closest in spelling to title.
+	// SuggestTitles returns an empty slice if no title is close enough.
 	// Any error returned is an internal error
-	SearchJobsByTitleAndLocation(title string, location models.Location) ([]models.Job, error)
+	SuggestTitles(title string, limit int) ([]string, error)
 }
 
 type Config struct {
 	LocationDataFilePath string
 	Port                 int
+
+	// UnixSocket is the path of a unix domain socket the server listens on.
+	// If empty, the server listens on Port over TCP.
+	UnixSocket string
+
+	// TitleCasing specifies how titles differing only in case are indexed,
+	// i.e., merged, merged into the casing first seen, or kept separate
+	TitleCasing db.TitleCasing
+
+	// WarmQueries are nearby searches whose results are computed whenever jobs are indexed,
+	// e.g., popular areas, so that they are served without searching the index.
+	// Their results are precomputed once per index, not cached by the searches served
+	WarmQueries []db.WarmQuery
+
+	// VerifySearches checks the jobs found by nearby searches against a scan of every job,
+	// logging mismatches, and returning the jobs scanned instead with db.VerifyAndFallback.
+	// By default, searches are not verified.
+	VerifySearches db.VerifyMode
+
+	// RequireData fails the server at startup if no valid job is read from LocationDataFilePath
+	RequireData bool
+
+	// WatchDataFile reloads jobs whenever the file on LocationDataFilePath changes
+	WatchDataFile bool
+
+	// RefreshInterval reloads jobs from the file on LocationDataFilePath on this schedule,
+	// with jitter, backing off while reloading fails. Zero disables refreshing.
+	RefreshInterval time.Duration
+
+	// DatasetFilePaths are the paths to the files of datasets loaded aside the live dataset, by name,
+	// e.g., a candidate dataset served to requests selecting it with the X-Dataset header
+	DatasetFilePaths map[string]string
+
+	// TitleSynonymsFilePath is the path to a csv file of title synonyms, each line listing titles
+	// matching each other when searching jobs by title. If empty, titles only match themselves.
+	TitleSynonymsFilePath string
+
+	// MaxJobs caps the number of jobs held in memory, evicting the oldest jobs
+	// above the cap. Zero disables the cap.
+	MaxJobs int
+
+	// SearchWorkers is the number of goroutines computing distances in a large search.
+	// Zero defaults to runtime.GOMAXPROCS
+	SearchWorkers int
+
+	// StrictQueryParams rejects requests having query parameters
+	// unknown to the requested endpoint, e.g., a misspelled raduis.
+	// By default, unknown query parameters are ignored.
+	StrictQueryParams bool
+
+	// FieldNaming is the case of the JSON field names of response data and meta.
+	// By default, field names are in camel case, e.g., travelMinutes.
+	FieldNaming FieldNaming
+
+	// CoordinateOrder is the order of latitude and longitude in coordinate pairs,
+	// i.e., the coordinates query parameter, WarmQueries and locations in messages.
+	// By default, latitude is first, e.g., 1.29,103.85.
+	CoordinateOrder models.CoordinateOrder
+
+	// Antimeridian specifies how a box whose minLon exceeds its maxLon, i.e., crossing
+	// the antimeridian, is handled. By default, it is searched as two boxes either side of it.
+	// Radius searches find jobs across the antimeridian and the poles whatever the policy.
+	Antimeridian AntimeridianPolicy
+
+	// TrailingSlash specifies how a path not found only for its trailing slash,
+	// e.g., /api/v1/jobs/nearby/, is handled. By default, it is not found.
+	TrailingSlash TrailingSlashPolicy
+
+	// CaseInsensitivePaths routes a path not found, e.g., /API/v1/Jobs/nearby,
+	// as its lower case version, handling it as with TrailingSlash.
+	// Path parameters, e.g., geofence names, are then lower cased too.
+	CaseInsensitivePaths bool
+
+	// DefaultRadius is the radius of a nearby search not specifying one.
+	// The zero Distance defaults to 5km
+	DefaultRadius models.Distance
+
+	// EarthRadiusKm is the radius in kilometers of the sphere distances are computed on,
+	// e.g., for a game world. Zero defaults to the radius of the earth
+	EarthRadiusKm float64
+
+	// WalkSpeedKmh and DriveSpeedKmh are the average speeds in kilometers per hour
+	// travel times are estimated with in either travel mode.
+	// Zero defaults to defaultWalkSpeedKmh and defaultDriveSpeedKmh respectively
+	WalkSpeedKmh  float64
+	DriveSpeedKmh float64
+
+	// MaxRadiusKm is the largest radius in kilometers a nearby search may cover.
+	// Zero disables the limit.
+	MaxRadiusKm float64
+
+	// RadiusStepKm is the step in kilometers the radius of searches is rounded to,
+	// e.g., 0.5 searches 5.2 km as 5 km, so that clients asking near-identical radii
+	// get the same results, served from WarmQueries whenever one of them matches.
+	// meta.radiusKm reports the radius searched.
+	// Zero searches radii as requested
+	RadiusStepKm float64
+
+	// ClampRadius specifies how a radius above MaxRadiusKm is handled.
+	// If true, the radius is clamped to MaxRadiusKm,
+	// else the request is rejected with 422 Unprocessable Entity
+	ClampRadius bool
+
+	// MaxGeometryVertices is the largest number of locations a request geometry,
+	// e.g., the centers of a near-any search, may have.
+	// Zero disables the limit.
+	MaxGeometryVertices int
+
+	// MaxBoxAreaKm2 is the largest area in square kilometers the bounding box of a request
+	// geometry, e.g., a box searched or the centers of a near-any search, may cover.
+	// Zero disables the limit.
+	MaxBoxAreaKm2 float64
+
+	// MaxAvailableTitles is the largest number of titles listed at once with their available jobs.
+	// Listing more titles is paginated. Zero disables the limit.
+	MaxAvailableTitles int
+
+	// DistributionBounds are the upper bounds, in ascending order, of the buckets titles
+	// are distributed into by number of jobs. Empty defaults to defaultDistributionBounds
+	DistributionBounds []int
+
+	// MetricsTopTitles is the number of titles, from those having the most jobs,
+	// the metrics endpoint exposes a gauge of jobs for. Zero defaults to defaultMetricsTopTitles
+	MetricsTopTitles int
+
+	// MaxResultCount is the largest number of jobs a radius search may return.
+	// Zero disables the limit.
+	MaxResultCount int
+
+	// TruncateResults specifies how a radius search matching more than MaxResultCount
+	// jobs is handled. If true, results are truncated to MaxResultCount and the response
+	// meta reports truncated:true with the total count, else the request fails
+	// with 413 Request Entity Too Large
+	TruncateResults bool
+
+	// IdempotencyKeyTTL is how long the response to a job insertion having an Idempotency-Key
+	// header is replayed for retries. Zero defaults to 24 hours
+	IdempotencyKeyTTL time.Duration
+
+	// UnavailableDuringRebuild rejects queries with 503 Service Unavailable while the index is
+	// being rebuilt, e.g., on reload, instead of serving the jobs before the rebuild
+	UnavailableDuringRebuild bool
+
+	// RequestTimeout is the time budget shared across the whole request,
+	// after which searches are abandoned and the request fails with 503 Service Unavailable.
+	// Zero disables the limit.
+	RequestTimeout time.Duration
+
+	// DevMode reports the cost of queries in responses, e.g., meta.debug on nearby searches.
+	// DevMode must not be set in production, as it exposes internals of the index.
+	DevMode bool
+
+	// Geocoder locates the addresses jobs are searched around.
+	// If nil, searching jobs by address fails with 501 Not Implemented.
+	Geocoder Geocoder
+
+	// TracerProvider traces requests down to the index traversal.
+	// If nil, requests are not traced.
+	TracerProvider trace.TracerProvider
 }
 
 type App struct {
 	repo   repository
 	Routes http.Handler
 	Config Config
+
+	// subscriptions are notified of the jobs added through the api
+	subscriptions *subscriptions
+
+	// geofences are the areas registered by clients to find jobs inside of
+	geofences *geofences
+
+	// idempotencyKeys replay the responses to job insertions for their retries
+	idempotencyKeys *idempotencyKeys
 }
 
 func (app *App) StartServer() error {
@@ -45,6 +338,19 @@ func (app *App) StartServer() error {
 		WriteTimeout:      60 * time.Second,
 		MaxHeaderBytes:    2048,
 	}
-	log.Printf("Server started and listening on port %d", app.Config.Port)
-	return server.ListenAndServe()
+	if app.Config.UnixSocket == "" {
+		log.Printf("Server started and listening on port %d", app.Config.Port)
+		return server.ListenAndServe()
+	}
+
+	// remove socket file left behind by a previous run
+	if err := os.Remove(app.Config.UnixSocket); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error removing stale socket %s: %v", app.Config.UnixSocket, err)
+	}
+	listener, err := net.Listen("unix", app.Config.UnixSocket)
+	if err != nil {
+		return fmt.Errorf("error listening on socket %s: %v", app.Config.UnixSocket, err)
+	}
+	log.Printf("Server started and listening on socket %s", app.Config.UnixSocket)
+	return server.Serve(listener)
 }
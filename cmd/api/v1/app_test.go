@@ -0,0 +1,108 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ercross/grabjobs/internal/db/memory"
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// check that the in-memory fixture stays usable in place of db.DB
+var _ repository = (*memory.MemoryRepository)(nil)
+
+// testResponse is the body of a response of the api, successful or not
+type testResponse struct {
+	Status  bool                   `json:"status"`
+	Message string                 `json:"message"`
+	Data    json.RawMessage        `json:"data"`
+	Meta    map[string]interface{} `json:"meta"`
+	Errors  []ValidationError      `json:"errors"`
+}
+
+// newTestRoutes returns the routes of the api serving jobs from a memory.MemoryRepository
+func newTestRoutes(config Config, jobs ...models.Job) http.Handler {
+	return Routes(memory.NewMemoryRepository(jobs...), config)
+}
+
+// newRequest returns a request of method to target with a JSON body, empty for none
+func newRequest(method, target, body string) *http.Request {
+	if body == "" {
+		return httptest.NewRequest(method, target, nil)
+	}
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	return request
+}
+
+// serve serves a request of method to target with body, empty for none, and decodes its response
+func serve(t *testing.T, routes http.Handler, method, target, body string) (int, testResponse) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	routes.ServeHTTP(recorder, newRequest(method, target, body))
+	return recorder.Code, decodeResponse(t, recorder)
+}
+
+// decodeResponse decodes the response recorded by recorder
+func decodeResponse(t *testing.T, recorder *httptest.ResponseRecorder) testResponse {
+	t.Helper()
+	var response testResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response not JSON: %v\n%s", err, recorder.Body.String())
+	}
+	return response
+}
+
+// decodeData decodes the data of response into dst
+func decodeData(t *testing.T, response testResponse, dst interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(response.Data, dst); err != nil {
+		t.Fatalf("data not decoded: %v\n%s", err, response.Data)
+	}
+}
+
+func TestStartServerOnUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "grabjobs.sock")
+
+	// a stale socket file is replaced
+	if err := os.WriteFile(socket, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &App{Config: Config{UnixSocket: socket}}
+	app.Routes = newTestRoutes(app.Config)
+	go func() {
+		if err := app.StartServer(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}}
+	var response *http.Response
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if response, err = client.Get("http://unix/healthz"); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("GET /healthz on %s: %v", socket, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("GET /healthz on %s: status %d, want 200", socket, response.StatusCode)
+	}
+}
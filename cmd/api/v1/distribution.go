@@ -0,0 +1,72 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/ercross/grabjobs/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultDistributionBounds are the upper bounds of the buckets of the title distribution
+// if neither Config nor the request sets any
+var defaultDistributionBounds = []int{1, 5, 10, 50, 100}
+
+// ParseCountBounds parses a comma-separated list of bucket upper bounds, e.g., 1,5,10,
+// which must be positive integers in strictly ascending order
+func ParseCountBounds(list string) ([]int, error) {
+	values := strings.Split(list, ",")
+	bounds := make([]int, len(values))
+	for i, value := range values {
+		bound, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || bound < 1 {
+			return nil, fmt.Errorf("bound %q is not a positive integer", value)
+		}
+		if i > 0 && bound <= bounds[i-1] {
+			return nil, fmt.Errorf("bounds must be in ascending order, %d follows %d", bound, bounds[i-1])
+		}
+		bounds[i] = bound
+	}
+	return bounds, nil
+}
+
+// getTitleDistribution fetches a histogram of titles bucketed by their number of jobs,
+// e.g., how many titles have 1 job, 2 to 5 jobs, and so on, along with the jobs of each bucket.
+// Request Method: GET
+// Query Parameters:
+//
+//	bounds 		optional comma-separated list of ascending bucket upper bounds, e.g., 1,5,10,
+//			default Config.DistributionBounds. The last bucket has no upper bound
+//
+// Response Type: application/json
+func (app *App) getTitleDistribution(w http.ResponseWriter, r *http.Request) {
+
+	bounds := app.Config.DistributionBounds
+	if len(bounds) == 0 {
+		bounds = defaultDistributionBounds
+	}
+	if value := r.URL.Query().Get("bounds"); !notValidString(value) {
+		var err error
+		if bounds, err = ParseCountBounds(value); err != nil {
+			app.sendFailedValidationResponse(w, validationError("bounds", codeInvalid, err.Error()))
+			return
+		}
+	}
+
+	titleJobs, err := app.repository(r).TitleJobs()
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error fetching title to jobs map: %v", err))
+		return
+	}
+	counts := make([]int, 0, len(titleJobs))
+	for _, jobs := range titleJobs {
+		counts = append(counts, len(jobs))
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("Distribution of %d titles by number of jobs", len(counts)),
+	}, models.Distribution(counts, bounds))
+}
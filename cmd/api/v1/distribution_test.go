@@ -0,0 +1,56 @@
+package v1
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestTitleDistribution(t *testing.T) {
+	// titles having 1, 1, 3, 6 and 12 jobs
+	var jobs []models.Job
+	for title, count := range map[string]int{"Cook": 1, "Tailor": 1, "Welder": 3, "Driver": 6, "Nurse": 12} {
+		for i := 0; i < count; i++ {
+			jobs = append(jobs, models.Job{Title: title, Location: models.Location{Longitude: 103.85, Latitude: 1.29}})
+		}
+	}
+
+	tests := []struct {
+		config Config
+		query  string
+		// buckets lists the minimum, titles and jobs of each bucket
+		buckets [][3]int
+	}{
+		{query: "", buckets: [][3]int{{1, 2, 2}, {2, 1, 3}, {6, 1, 6}, {11, 1, 12}, {51, 0, 0}, {101, 0, 0}}},
+		{config: Config{DistributionBounds: []int{2, 10}}, query: "", buckets: [][3]int{{1, 2, 2}, {3, 2, 9}, {11, 1, 12}}},
+		{config: Config{DistributionBounds: []int{2, 10}}, query: "?bounds=1,3", buckets: [][3]int{{1, 2, 2}, {2, 1, 3}, {4, 2, 18}}},
+		{query: "?bounds=100", buckets: [][3]int{{1, 5, 23}, {101, 0, 0}}},
+	}
+	for _, test := range tests {
+		status, response := serve(t, newTestRoutes(test.config, jobs...), http.MethodGet, "/api/v1/jobs/distribution"+test.query, "")
+		if status != http.StatusOK {
+			t.Errorf("%v %q: status %d, want 200", test.config.DistributionBounds, test.query, status)
+			continue
+		}
+		var found []models.CountBucket
+		decodeData(t, response, &found)
+		buckets := make([][3]int, len(found))
+		for i, bucket := range found {
+			buckets[i] = [3]int{bucket.Min, bucket.Titles, bucket.Jobs}
+			if (bucket.Max == nil) != (i == len(found)-1) {
+				t.Errorf("%v %q: bucket %d bounded by %v, want only the last unbounded", test.config.DistributionBounds, test.query, i, bucket.Max)
+			}
+		}
+		if !reflect.DeepEqual(buckets, test.buckets) {
+			t.Errorf("%v %q: buckets %v, want %v", test.config.DistributionBounds, test.query, buckets, test.buckets)
+		}
+	}
+
+	for _, bounds := range []string{"0", "5,2", "1,1", "a"} {
+		if status, _ := serve(t, newTestRoutes(Config{}, jobs...), http.MethodGet, "/api/v1/jobs/distribution?bounds="+bounds, ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("bounds=%s: status %d, want 422", bounds, status)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/ercross/grabjobs/internal/models"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// exportColumns is the title line of an export,
+// which the DB reads back as with any location.csv file
+var exportColumns = []string{"title", "longitude", "latitude", "company", "altitude", "labels"}
+
+// exportJobs downloads every available job as a csv file, ordered by title.
+// Downloads are resumable: a Range header is honored with 206 Partial Content,
+// and If-Range with the ETag of a previous response only resumes if the jobs haven't changed since.
+// Request Method: GET
+// Query Parameters: None
+// Response Type: text/csv
+func (app *App) exportJobs(w http.ResponseWriter, r *http.Request) {
+
+	titleJobs, err := app.repository(r).TitleJobs()
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error fetching jobs to export: %v", err))
+		return
+	}
+
+	content, err := writeJobsCSV(titleJobs)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error exporting jobs: %v", err))
+		return
+	}
+
+	hash := fnv.New64a()
+	hash.Write(content)
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="jobs.csv"`)
+	w.Header().Set("ETag", fmt.Sprintf(`"%016x"`, hash.Sum64()))
+	http.ServeContent(w, r, "jobs.csv", time.Time{}, bytes.NewReader(content))
+}
+
+// writeJobsCSV writes the jobs of titleJobs in csv, titles in ascending order
+func writeJobsCSV(titleJobs map[string][]models.Job) ([]byte, error) {
+	titles := make([]string, 0, len(titleJobs))
+	for title := range titleJobs {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if err := writer.Write(exportColumns); err != nil {
+		return nil, err
+	}
+	for _, title := range titles {
+		for _, job := range titleJobs[title] {
+			altitude := ""
+			if job.Location.Altitude != nil {
+				altitude = strconv.FormatFloat(*job.Location.Altitude, 'f', -1, 64)
+			}
+			labels := ""
+			if len(job.Labels) != 0 {
+				encoded, err := json.Marshal(job.Labels)
+				if err != nil {
+					return nil, err
+				}
+				labels = string(encoded)
+			}
+			line := []string{
+				job.Title,
+				strconv.FormatFloat(job.Location.Longitude, 'f', -1, 64),
+				strconv.FormatFloat(job.Location.Latitude, 'f', -1, 64),
+				job.Company,
+				altitude,
+				labels,
+			}
+			if err := writer.Write(line); err != nil {
+				return nil, err
+			}
+		}
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
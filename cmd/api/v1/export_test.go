@@ -0,0 +1,80 @@
+package v1
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// export serves a GET of the export of routes with header, returning the recorded response
+func export(routes http.Handler, header http.Header) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/export", nil)
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	recorder := httptest.NewRecorder()
+	routes.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestExportRange(t *testing.T) {
+	var jobs []models.Job
+	for i := 0; i < 50; i++ {
+		jobs = append(jobs, models.Job{
+			Title:    fmt.Sprintf("Title %d", i%5),
+			Location: models.Location{Longitude: 103.85 + float64(i)*0.001, Latitude: 1.29},
+		})
+	}
+	routes := newTestRoutes(Config{}, jobs...)
+
+	whole := export(routes, nil)
+	if whole.Code != http.StatusOK || whole.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("status %d and Accept-Ranges %q, want 200 and bytes", whole.Code, whole.Header().Get("Accept-Ranges"))
+	}
+	content := whole.Body.String()
+	if !strings.HasPrefix(content, "title,longitude,latitude") {
+		t.Fatalf("export starts with %.40q, want the title line", content)
+	}
+
+	ranges := []struct {
+		header     string
+		start, end int
+	}{
+		{header: "bytes=0-9", start: 0, end: 10},
+		{header: "bytes=100-299", start: 100, end: 300},
+		{header: fmt.Sprintf("bytes=%d-", len(content)-25), start: len(content) - 25, end: len(content)},
+		{header: "bytes=-25", start: len(content) - 25, end: len(content)},
+	}
+	for _, test := range ranges {
+		partial := export(routes, http.Header{"Range": {test.header}})
+		if partial.Code != http.StatusPartialContent {
+			t.Errorf("%s: status %d, want 206", test.header, partial.Code)
+			continue
+		}
+		if want := fmt.Sprintf("bytes %d-%d/%d", test.start, test.end-1, len(content)); partial.Header().Get("Content-Range") != want {
+			t.Errorf("%s: Content-Range %q, want %q", test.header, partial.Header().Get("Content-Range"), want)
+		}
+		if body, _ := io.ReadAll(partial.Body); string(body) != content[test.start:test.end] {
+			t.Errorf("%s: body %q, want %q", test.header, body, content[test.start:test.end])
+		}
+	}
+
+	if unsatisfiable := export(routes, http.Header{"Range": {fmt.Sprintf("bytes=%d-", len(content))}}); unsatisfiable.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("range past the end: status %d, want 416", unsatisfiable.Code)
+	}
+
+	// a resumed download only gets the range if the export is unchanged
+	etag := whole.Header().Get("ETag")
+	if resumed := export(routes, http.Header{"Range": {"bytes=10-19"}, "If-Range": {etag}}); resumed.Code != http.StatusPartialContent {
+		t.Errorf("If-Range of the current ETag: status %d, want 206", resumed.Code)
+	}
+	if restarted := export(routes, http.Header{"Range": {"bytes=10-19"}, "If-Range": {`"stale"`}}); restarted.Code != http.StatusOK ||
+		restarted.Body.String() != content {
+		t.Errorf("If-Range of a stale ETag: status %d, want 200 and the whole export", restarted.Code)
+	}
+}
@@ -0,0 +1,108 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Geocoder resolves addresses into locations, e.g., through a geocoding service.
+// Geocoder must be safe for concurrent use.
+type Geocoder interface {
+
+	// Geocode resolves address into its location.
+	// Geocode returns ErrAddressNotFound if address matches no location,
+	// any other error being a failure of the geocoder itself
+	Geocode(address string) (models.Location, error)
+}
+
+// ErrAddressNotFound is returned by a Geocoder for an address matching no location
+var ErrAddressNotFound = errors.New("address not found")
+
+// getJobsNearAddress fetches jobs some radius around an address, geocoded with Config.Geocoder,
+// each annotated with its distance to the address, from the nearest.
+// Fails with 501 Not Implemented if no geocoder is configured.
+// Request Method: GET
+// Query Parameters:
+//
+//	address 	string
+//	radius 		optional decimal/float, default Config.DefaultRadius, capped at Config.MaxRadiusKm.
+//			meta.radiusKm reports the radius searched, and meta.location the location of address
+//	fields 		optional comma-separated list of title, location, distance, company, labels
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//
+// Response Type: application/json
+func (app *App) getJobsNearAddress(w http.ResponseWriter, r *http.Request) {
+
+	if app.Config.Geocoder == nil {
+		app.sendJSONErrorResponse(w, http.StatusNotImplemented, "no geocoder is configured to search jobs by address", nil)
+		return
+	}
+
+	address := r.URL.Query().Get("address")
+	if notValidString(address) {
+		app.sendFailedValidationResponse(w, validationError("address", codeRequired, "address is not a valid text"))
+		return
+	}
+
+	var radius float64
+	if value := r.URL.Query().Get("radius"); !notValidString(value) {
+		var err error
+		radius, err = strconv.ParseFloat(value, 64)
+		if err != nil || radius < 0 {
+			app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius not a valid non-negative decimal/float"))
+			return
+		}
+	}
+	if radius == 0 {
+		radius = app.repository(r).DefaultRadius().Kilometers()
+	}
+	radius, withinLimit := app.capRadius(radius)
+	if !withinLimit {
+		app.sendFailedValidationResponse(w, validationError("radius", codeOutOfRange,
+			fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+		return
+	}
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	center, err := app.Config.Geocoder.Geocode(address)
+	switch {
+	case errors.Is(err, ErrAddressNotFound):
+		app.sendFailedValidationResponse(w, validationError("address", codeInvalid, fmt.Sprintf("address %q could not be located", address)))
+		return
+	case err != nil:
+		log.Printf("error geocoding address %q: %v", address, err)
+		app.sendJSONErrorResponse(w, http.StatusBadGateway, "the address could not be geocoded, retry later", nil)
+		return
+	}
+
+	jobs, err := app.repository(r).FindJobsNearby(r.Context(), center, radius, rtree.Inclusive)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding jobs within a radius of %f around %v", radius, center))
+		return
+	}
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("Jobs around %v", address),
+	}
+	args.addMeta("location", center)
+	args.addMeta("radiusKm", radius)
+	keep, withinBudget := app.checkResultBudget(args, len(jobs))
+	if !withinBudget {
+		return
+	}
+
+	app.sendJSONResponse(args, fields.projectJobsWithDistance(models.NearestFirst(app.repository(r).Sphere(), center, jobs)[:keep]))
+}
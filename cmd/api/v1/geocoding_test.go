@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// stubGeocoder locates the addresses it maps, failing with err if set
+type stubGeocoder struct {
+	locations map[string]models.Location
+	err       error
+}
+
+func (g stubGeocoder) Geocode(address string) (models.Location, error) {
+	if g.err != nil {
+		return models.Location{}, g.err
+	}
+	location, ok := g.locations[address]
+	if !ok {
+		return models.Location{}, ErrAddressNotFound
+	}
+	return location, nil
+}
+
+func TestJobsNearAddress(t *testing.T) {
+	jobs := []models.Job{
+		{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}},
+		{Title: "Driver", Location: models.Location{Longitude: 103.86, Latitude: 1.29}},
+		{Title: "Cook", Location: models.Location{Longitude: 103.95, Latitude: 1.35}},
+	}
+	geocoder := stubGeocoder{locations: map[string]models.Location{
+		"1 Raffles Place": {Longitude: 103.85, Latitude: 1.29},
+		"Changi Airport":  {Longitude: 103.99, Latitude: 1.36},
+	}}
+	routes := newTestRoutes(Config{Geocoder: geocoder}, jobs...)
+	near := func(address, radius string) (int, testResponse) {
+		return serve(t, routes, http.MethodGet, "/api/v1/jobs/near-address?address="+url.QueryEscape(address)+"&radius="+radius, "")
+	}
+
+	tests := []struct {
+		address string
+		radius  string
+		titles  []string
+	}{
+		{address: "1 Raffles Place", radius: "2", titles: []string{"Nurse", "Driver"}},
+		{address: "1 Raffles Place", radius: "20", titles: []string{"Nurse", "Driver", "Cook"}},
+		{address: "Changi Airport", radius: "5", titles: []string{"Cook"}},
+		{address: "Changi Airport", radius: "1", titles: []string{}},
+	}
+	for _, test := range tests {
+		status, response := near(test.address, test.radius)
+		if status != http.StatusOK {
+			t.Errorf("%s within %skm: status %d, want 200", test.address, test.radius, status)
+			continue
+		}
+		var found []models.JobWithDistance
+		decodeData(t, response, &found)
+		titles := make([]string, 0, len(found))
+		for _, job := range found {
+			titles = append(titles, job.Title)
+		}
+		if !reflect.DeepEqual(titles, test.titles) || !sort.SliceIsSorted(found, func(i, j int) bool { return found[i].Distance < found[j].Distance }) {
+			t.Errorf("%s within %skm: found %v, want %v from the nearest", test.address, test.radius, titles, test.titles)
+		}
+
+		var location models.Location
+		decodeData(t, testResponse{Data: mustMarshal(t, response.Meta["location"])}, &location)
+		if want := geocoder.locations[test.address]; location != want {
+			t.Errorf("%s: meta.location %v, want %v", test.address, location, want)
+		}
+	}
+
+	if status, _ := near("Nowhere", "2"); status != http.StatusUnprocessableEntity {
+		t.Errorf("address not found: status %d, want 422", status)
+	}
+	if status, _ := near("", "2"); status != http.StatusUnprocessableEntity {
+		t.Errorf("no address: status %d, want 422", status)
+	}
+
+	failing := newTestRoutes(Config{Geocoder: stubGeocoder{err: errors.New("service down")}}, jobs...)
+	if status, _ := serve(t, failing, http.MethodGet, "/api/v1/jobs/near-address?address=Changi", ""); status != http.StatusBadGateway {
+		t.Errorf("failing geocoder: status %d, want 502", status)
+	}
+
+	none := newTestRoutes(Config{}, jobs...)
+	if status, _ := serve(t, none, http.MethodGet, "/api/v1/jobs/near-address?address=Changi", ""); status != http.StatusNotImplemented {
+		t.Errorf("no geocoder: status %d, want 501", status)
+	}
+}
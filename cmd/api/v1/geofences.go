@@ -0,0 +1,225 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"sync"
+)
+
+// minPolygonVertices is the least number of vertices of a polygonal geofence
+const minPolygonVertices = 3
+
+// geofence is a named area jobs are checked to fall inside of,
+// either a circle around Center or a polygon of Vertices
+type geofence struct {
+	Name string `json:"name"`
+
+	// Center and RadiusKm are set on a circular geofence only
+	Center   *models.Location `json:"center,omitempty"`
+	RadiusKm float64          `json:"radiusKm,omitempty"`
+
+	// Vertices are set on a polygonal geofence only
+	Vertices []models.Location `json:"vertices,omitempty"`
+}
+
+// geofences holds the geofences registered by clients for as long as the server runs
+type geofences struct {
+	lock   sync.RWMutex
+	byName map[string]geofence
+}
+
+func newGeofences() *geofences {
+	return &geofences{byName: make(map[string]geofence)}
+}
+
+// add registers fence. ok is false if a geofence named as fence already exists
+func (g *geofences) add(fence geofence) (ok bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if _, exists := g.byName[fence.Name]; exists {
+		return false
+	}
+	g.byName[fence.Name] = fence
+	return true
+}
+
+func (g *geofences) get(name string) (fence geofence, ok bool) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	fence, ok = g.byName[name]
+	return fence, ok
+}
+
+func (app *App) geofencesRouter() chi.Router {
+	router := chi.NewRouter()
+
+	router.With(app.allowQueryParams()).Post("/", app.createGeofence)
+	router.With(app.allowQueryParams("fields", "projection")).Get("/{name}/jobs", app.getGeofenceJobs)
+
+	return router
+}
+
+// createGeofence registers a named circular or polygonal geofence,
+// to later fetch the jobs inside it with getGeofenceJobs.
+// Either of center or vertices must be set.
+// Request Method: POST
+// Request Body: application/json
+//
+//	{
+//		"name": string,
+//		"center": {"longitude": decimal/float, "latitude": decimal/float},
+//		"radius": decimal/float (in km, capped at Config.MaxRadiusKm),
+//		"vertices": [{"longitude": decimal/float, "latitude": decimal/float}] (at least 3)
+//	}
+//
+// Response Type: application/json
+// If a geofence with the same name exists, a failed validation response is sent to client.
+func (app *App) createGeofence(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Name     string            `json:"name"`
+		Center   *models.Location  `json:"center"`
+		Radius   float64           `json:"radius"`
+		Vertices []models.Location `json:"vertices"`
+	}
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.sendBadRequestResponse(w, err)
+		return
+	}
+
+	if notValidString(input.Name) {
+		app.sendFailedValidationResponse(w, validationError("name", codeRequired, "name is not a valid text"))
+		return
+	}
+
+	fence := geofence{Name: input.Name}
+	switch {
+	case input.Center != nil && input.Vertices != nil:
+		app.sendFailedValidationResponse(w, validationError("vertices", codeConflict, "either of center or vertices must be set, not both"))
+		return
+
+	case input.Center != nil:
+		if errors := validateLocation("center", *input.Center); len(errors) != 0 {
+			app.sendFailedValidationResponse(w, errors...)
+			return
+		}
+		if input.Radius <= 0 {
+			app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius must be a positive decimal/float"))
+			return
+		}
+		radius, withinLimit := app.capRadius(input.Radius)
+		if !withinLimit {
+			app.sendFailedValidationResponse(w, validationError("radius", codeOutOfRange,
+				fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+			return
+		}
+		fence.Center, fence.RadiusKm = input.Center, radius
+
+	case input.Vertices != nil:
+		if len(input.Vertices) < minPolygonVertices {
+			app.sendFailedValidationResponse(w, validationError("vertices", codeInvalid,
+				fmt.Sprintf("vertices must have at least %d locations", minPolygonVertices)))
+			return
+		}
+		var errors []ValidationError
+		for i, vertex := range input.Vertices {
+			errors = append(errors, validateLocation(fmt.Sprintf("vertices[%d]", i), vertex)...)
+		}
+		if len(errors) != 0 {
+			app.sendFailedValidationResponse(w, errors...)
+			return
+		}
+		if !app.checkGeometry(w, r, "vertices", len(input.Vertices), models.BoxAround(input.Vertices)) {
+			return
+		}
+		fence.Vertices = input.Vertices
+
+	default:
+		app.sendFailedValidationResponse(w, validationError("center", codeRequired, "either of center or vertices must be set"))
+		return
+	}
+
+	if !app.geofences.add(fence) {
+		app.sendFailedValidationResponse(w, validationError("name", codeConflict, fmt.Sprintf("geofence %v already exists", fence.Name)))
+		return
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: http.StatusCreated,
+		status:     true,
+		message:    "Geofence created",
+	}, fence)
+}
+
+// getGeofenceJobs fetches the jobs currently inside a geofence.
+// If no geofence has name, a 404 not found is sent to client
+// Request Method: GET
+// Path Parameters:
+//
+//	name: string
+//
+// Query Parameters:
+//
+//	fields 		optional comma-separated list of title, location
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//
+// Response Type: application/json
+func (app *App) getGeofenceJobs(w http.ResponseWriter, r *http.Request) {
+
+	fence, ok := app.geofences.get(chi.URLParam(r, "name"))
+	if !ok {
+		app.sendNotFoundResponse(w, r)
+		return
+	}
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	var jobs []models.Job
+	var err error
+	if fence.Center != nil {
+		jobs, err = app.repository(r).FindJobsNearby(r.Context(), *fence.Center, fence.RadiusKm, rtree.Inclusive)
+	} else {
+		jobs, err = app.findJobsInPolygon(r, fence.Vertices)
+	}
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding jobs inside geofence %v: %v", fence.Name, err))
+		return
+	}
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("Jobs inside geofence %v", fence.Name),
+	}
+	keep, withinBudget := app.checkResultBudget(args, len(jobs))
+	if !withinBudget {
+		return
+	}
+	app.sendJSONResponse(args, fields.projectJobs(jobs[:keep]))
+}
+
+// findJobsInPolygon finds the jobs inside the polygon having vertices,
+// among the jobs within the bounding box of the polygon
+func (app *App) findJobsInPolygon(r *http.Request, vertices []models.Location) ([]models.Job, error) {
+	entries, err := app.repository(r).FindEntriesInBox(r.Context(), models.BoxAround(vertices))
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]models.Job, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Job.Location.InPolygon(vertices) {
+			jobs = append(jobs, entry.Job)
+		}
+	}
+	return jobs, nil
+}
@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestGeofenceJobs(t *testing.T) {
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}},
+		models.Job{Title: "Driver", Location: models.Location{Longitude: 103.87, Latitude: 1.29}}, // ~2.2km east
+		models.Job{Title: "Cook", Location: models.Location{Longitude: 103.88, Latitude: 1.33}},   // in the triangle's box, not in it
+		models.Job{Title: "Welder", Location: models.Location{Longitude: 104.05, Latitude: 1.29}}, // ~22km east
+	)
+
+	fences := []string{
+		`{"name": "cbd", "center": {"longitude": 103.85, "latitude": 1.29}, "radius": 3}`,
+		`{"name": "triangle", "vertices": [{"longitude": 103.84, "latitude": 1.28}, {"longitude": 103.9, "latitude": 1.28}, {"longitude": 103.84, "latitude": 1.34}]}`,
+	}
+	for _, fence := range fences {
+		if status, response := serve(t, routes, http.MethodPost, "/api/v1/geofences", fence); status != http.StatusCreated {
+			t.Fatalf("status %d creating geofence %s, want 201: %+v", status, fence, response.Errors)
+		}
+	}
+	if status, _ := serve(t, routes, http.MethodPost, "/api/v1/geofences", fences[0]); status != http.StatusUnprocessableEntity {
+		t.Errorf("status %d creating a geofence of the same name, want 422", status)
+	}
+
+	tests := []struct {
+		name   string
+		status int
+		titles []string
+	}{
+		{name: "cbd", status: http.StatusOK, titles: []string{"Driver", "Nurse"}},
+		{name: "triangle", status: http.StatusOK, titles: []string{"Driver", "Nurse"}},
+		{name: "unknown", status: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		status, response := serve(t, routes, http.MethodGet, "/api/v1/geofences/"+test.name+"/jobs", "")
+		if status != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, status, test.status)
+			continue
+		}
+		if status != http.StatusOK {
+			continue
+		}
+		var jobs []models.Job
+		decodeData(t, response, &jobs)
+		titles := make([]string, len(jobs))
+		for i, job := range jobs {
+			titles[i] = job.Title
+		}
+		sort.Strings(titles)
+		if !reflect.DeepEqual(titles, test.titles) {
+			t.Errorf("%s: jobs inside %v, want %v", test.name, titles, test.titles)
+		}
+	}
+}
+
+func TestCreateGeofenceValidation(t *testing.T) {
+	routes := newTestRoutes(Config{MaxRadiusKm: 10})
+	for _, fence := range []string{
+		`{"center": {"longitude": 103.85, "latitude": 1.29}, "radius": 3}`,
+		`{"name": "none"}`,
+		`{"name": "both", "center": {"longitude": 103.85, "latitude": 1.29}, "radius": 3, "vertices": []}`,
+		`{"name": "flat", "center": {"longitude": 103.85, "latitude": 1.29}, "radius": 0}`,
+		`{"name": "wide", "center": {"longitude": 103.85, "latitude": 1.29}, "radius": 11}`,
+		`{"name": "line", "vertices": [{"longitude": 103.84, "latitude": 1.28}, {"longitude": 103.9, "latitude": 1.28}]}`,
+	} {
+		if status, _ := serve(t, routes, http.MethodPost, "/api/v1/geofences", fence); status != http.StatusUnprocessableEntity {
+			t.Errorf("status %d creating geofence %s, want 422", status, fence)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package v1
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/ercross/grabjobs/internal/models"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type responseWriterArgs struct {
@@ -14,6 +20,18 @@ type responseWriterArgs struct {
 	// status specifies if the request is successful
 	status  bool
 	message string
+
+	// meta holds optional information about data,
+	// e.g., search suggestions. meta is omitted if empty.
+	meta map[string]interface{}
+}
+
+// addMeta adds key with value to args.meta
+func (args *responseWriterArgs) addMeta(key string, value interface{}) {
+	if args.meta == nil {
+		args.meta = make(map[string]interface{})
+	}
+	args.meta[key] = value
 }
 
 // sendJSONResponse writes JSON-formatted response to client.
@@ -21,14 +39,17 @@ type responseWriterArgs struct {
 // Response content-type default to text/html and status code is sent in header
 func (app *App) sendJSONResponse(args *responseWriterArgs, data interface{}) {
 
+	data, meta := app.nameFields(data, args.meta)
 	response := struct {
-		Status  bool        `json:"status"`
-		Message string      `json:"message"`
-		Data    interface{} `json:"data,omitempty"`
+		Status  bool                   `json:"status"`
+		Message string                 `json:"message"`
+		Data    interface{}            `json:"data,omitempty"`
+		Meta    map[string]interface{} `json:"meta,omitempty"`
 	}{
 		Status:  args.status,
 		Message: args.message,
 		Data:    data,
+		Meta:    meta,
 	}
 
 	// Encode the data to JSON, returning the error if there was one.
@@ -40,6 +61,7 @@ func (app *App) sendJSONResponse(args *responseWriterArgs, data interface{}) {
 
 	args.writer.Header().Add("Content-Type", "application/json")
 	args.writer.Header().Add("Access-Control-Allow-Origin", "*")
+	args.writer.WriteHeader(args.statusCode)
 	_, err = args.writer.Write(apiResponse)
 	if err != nil {
 		args.writer.WriteHeader(500)
@@ -49,12 +71,12 @@ func (app *App) sendJSONResponse(args *responseWriterArgs, data interface{}) {
 
 // sendJSONErrorResponse() method is a generic helper for sending JSON-formatted error
 // messages to the client with a given status code.
-func (app *App) sendJSONErrorResponse(w http.ResponseWriter, status int, message string, errors map[string]string) {
+func (app *App) sendJSONErrorResponse(w http.ResponseWriter, status int, message string, errors []ValidationError) {
 
 	response := struct {
 		Status  bool              `json:"status"`
 		Message string            `json:"message"`
-		Errors  map[string]string `json:"errors,omitempty"`
+		Errors  []ValidationError `json:"errors,omitempty"`
 	}{
 		Status:  false,
 		Message: message,
@@ -68,10 +90,10 @@ func (app *App) sendJSONErrorResponse(w http.ResponseWriter, status int, message
 		return
 	}
 
-	// set header values and send
+	// set header values before WriteHeader, as headers set afterwards are not sent
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.WriteHeader(status)
-	w.Header().Add("Content-Type", "application/json")
-	w.Header().Add("Access-Control-Allow-Origin", "*")
 	_, err = w.Write(apiResponse)
 	if err != nil {
 		w.WriteHeader(500)
@@ -98,17 +120,395 @@ func (app *App) sendMethodNotAllowedResponse(w http.ResponseWriter, r *http.Requ
 	app.sendJSONErrorResponse(w, http.StatusMethodNotAllowed, message, nil)
 }
 
+// sendTooManyResultsResponse sends a 413 Request Entity Too Large to client
+// when a search matches more than Config.MaxResultCount results.
+func (app *App) sendTooManyResultsResponse(w http.ResponseWriter, count int) {
+	message := fmt.Sprintf("too many results (%d), narrow your search to at most %d results", count, app.Config.MaxResultCount)
+	app.sendJSONErrorResponse(w, http.StatusRequestEntityTooLarge, message, nil)
+}
+
+// sendRebuildingResponse sends a 503 Service Unavailable to client while the index is being rebuilt,
+// asking client to retry after rebuildRetryAfter
+func (app *App) sendRebuildingResponse(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", rebuildRetryAfter)
+	message := "the jobs are being reindexed, retry shortly"
+	app.sendJSONErrorResponse(w, http.StatusServiceUnavailable, message, nil)
+}
+
 // badRequestResponse method will be used to send a 400 Bad Request status code
 // and JSON response to the client.
 func (app *App) sendBadRequestResponse(w http.ResponseWriter, err error) {
 	app.sendJSONErrorResponse(w, http.StatusBadRequest, err.Error(), nil)
 }
 
-// sendFailedValidationResponse method sends a 422 Unprocessable Entity to client.
-func (app *App) sendFailedValidationResponse(w http.ResponseWriter, errors map[string]string) {
+// sendFailedValidationResponse method sends a 422 Unprocessable Entity to client,
+// listing every validation error of the request.
+func (app *App) sendFailedValidationResponse(w http.ResponseWriter, errors ...ValidationError) {
 	app.sendJSONErrorResponse(w, http.StatusUnprocessableEntity, "failed validation", errors)
 }
 
+// maxRequestBodyBytes is the largest request body readJSON accepts
+const maxRequestBodyBytes = 1 << 20
+
+// readJSON decodes the JSON request body of r into dst.
+// Any error returned is suitable to be sent to client.
+func (app *App) readJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return fmt.Errorf("request body is not valid JSON: %v", err)
+	}
+
+	if decoder.More() {
+		return errors.New("request body must contain a single JSON value")
+	}
+	return nil
+}
+
+// formatLocation formats location as a coordinate pair ordered as Config.CoordinateOrder,
+// e.g., in response messages, so that clients may pass it back as the coordinates query parameter
+func (app *App) formatLocation(location models.Location) string {
+	return models.FormatCoordinates(location, app.Config.CoordinateOrder)
+}
+
+// readLocation reads the location in the latitude and longitude query parameters of r,
+// or in the coordinates query parameter, a coordinate pair ordered as Config.CoordinateOrder,
+// e.g., coordinates=1.29,103.85 with latitude first.
+// If either is not a valid decimal/float, a failed validation response
+// listing both is sent to client and ok is false, as it is if both forms are given.
+func (app *App) readLocation(w http.ResponseWriter, r *http.Request) (location models.Location, ok bool) {
+	query := r.URL.Query()
+	if query.Has("coordinates") {
+		if query.Has("latitude") || query.Has("longitude") {
+			app.sendFailedValidationResponse(w, validationError("coordinates", codeConflict,
+				"coordinates cannot be given along with latitude and longitude"))
+			return location, false
+		}
+		location, err := models.ParseCoordinates(query.Get("coordinates"), app.Config.CoordinateOrder)
+		if err != nil {
+			app.sendFailedValidationResponse(w, validationError("coordinates", codeInvalid, err.Error()))
+			return location, false
+		}
+		return location, true
+	}
+
+	var errors []ValidationError
+	latitude, err := strconv.ParseFloat(query.Get("latitude"), 32)
+	if err != nil {
+		errors = append(errors, validationError("latitude", codeInvalid, "latitude not a valid decimal/float"))
+	}
+
+	longitude, err := strconv.ParseFloat(query.Get("longitude"), 32)
+	if err != nil {
+		errors = append(errors, validationError("longitude", codeInvalid, "longitude not a valid decimal/float"))
+	}
+
+	if len(errors) != 0 {
+		app.sendFailedValidationResponse(w, errors...)
+		return location, false
+	}
+
+	return models.Location{
+		Longitude: longitude,
+		Latitude:  latitude,
+	}, true
+}
+
+// AntimeridianPolicy is how a box whose minLon exceeds its maxLon is handled,
+// i.e., a box crossing the antimeridian, e.g., from 170 to -170 over Fiji
+type AntimeridianPolicy string
+
+const (
+	// AntimeridianSplit searches the box across the antimeridian,
+	// as two boxes either side of it
+	AntimeridianSplit AntimeridianPolicy = ""
+
+	// AntimeridianReject rejects the box, as its minLon exceeds its maxLon
+	AntimeridianReject AntimeridianPolicy = "reject"
+)
+
+// ParseAntimeridianPolicy parses policy, split or reject.
+// ok is false if policy is neither.
+func ParseAntimeridianPolicy(policy string) (antimeridian AntimeridianPolicy, ok bool) {
+	switch antimeridian = AntimeridianPolicy(strings.ToLower(policy)); antimeridian {
+	case "split":
+		return AntimeridianSplit, true
+	case AntimeridianReject:
+		return antimeridian, true
+	}
+	return antimeridian, false
+}
+
+// readSortOrder reads the field jobs are sorted by in the sortBy query parameter of r,
+// distance if not set, and whether from the highest value in the order query parameter,
+// asc or desc, defaulting to the order of the field as with models.SortField.DescendingByDefault.
+// If either is not valid, a failed validation response is sent to client and ok is false.
+func (app *App) readSortOrder(w http.ResponseWriter, r *http.Request) (sortBy models.SortField, descending bool, ok bool) {
+	sortBy = models.SortByDistance
+	if value := r.URL.Query().Get("sortBy"); !notValidString(value) {
+		if sortBy, ok = models.ParseSortField(value); !ok {
+			app.sendFailedValidationResponse(w, validationError("sortBy", codeInvalid, "sortBy must be one of distance, salary, recent"))
+			return sortBy, false, false
+		}
+	}
+
+	descending = sortBy.DescendingByDefault()
+	switch strings.ToLower(r.URL.Query().Get("order")) {
+	case "":
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	default:
+		app.sendFailedValidationResponse(w, validationError("order", codeInvalid, "order must be asc or desc"))
+		return sortBy, false, false
+	}
+	return sortBy, descending, true
+}
+
+// readBox reads the box in the minLat, minLon, maxLat and maxLon query parameters of r.
+// A minLon exceeding maxLon is a box crossing the antimeridian, handled under Config.Antimeridian.
+// If any is not a valid decimal/float, minLat exceeds maxLat, the box crosses the antimeridian
+// under AntimeridianReject, or the box covers more than Config.MaxBoxAreaKm2,
+// a failed validation response is sent to client and ok is false.
+func (app *App) readBox(w http.ResponseWriter, r *http.Request) (box models.Box, ok bool) {
+	var errors []ValidationError
+	bounds := make(map[string]float64, 4)
+	for _, param := range []string{"minLat", "minLon", "maxLat", "maxLon"} {
+		value, err := strconv.ParseFloat(r.URL.Query().Get(param), 64)
+		if err != nil {
+			errors = append(errors, validationError(param, codeInvalid, param+" not a valid decimal/float"))
+			continue
+		}
+		bounds[param] = value
+	}
+	if len(errors) != 0 {
+		app.sendFailedValidationResponse(w, errors...)
+		return box, false
+	}
+
+	box = models.Box{
+		Min: models.Location{Longitude: bounds["minLon"], Latitude: bounds["minLat"]},
+		Max: models.Location{Longitude: bounds["maxLon"], Latitude: bounds["maxLat"]},
+	}
+	if box.Min.Latitude > box.Max.Latitude ||
+		box.CrossesAntimeridian() && app.Config.Antimeridian == AntimeridianReject {
+		app.sendFailedValidationResponse(w, validationError("box", codeOutOfRange, "minLat and minLon must not exceed maxLat and maxLon"))
+		return box, false
+	}
+	return box, app.checkGeometry(w, r, "box", 0, box)
+}
+
+// checkGeometry applies Config.MaxGeometryVertices and Config.MaxBoxAreaKm2
+// to the geometry in field of r, having vertices locations within box.
+// If either limit is exceeded, a failed validation response listing both
+// is sent to client and withinLimits is false.
+func (app *App) checkGeometry(w http.ResponseWriter, r *http.Request, field string, vertices int, box models.Box) (withinLimits bool) {
+	var errors []ValidationError
+	if app.Config.MaxGeometryVertices > 0 && vertices > app.Config.MaxGeometryVertices {
+		errors = append(errors, validationError(field, codeOutOfRange,
+			fmt.Sprintf("%s must not have more than %d locations", field, app.Config.MaxGeometryVertices)))
+	}
+	if app.Config.MaxBoxAreaKm2 > 0 && app.repository(r).Sphere().Area(box) > app.Config.MaxBoxAreaKm2 {
+		errors = append(errors, validationError(field, codeOutOfRange,
+			fmt.Sprintf("%s must not cover more than %v square km", field, app.Config.MaxBoxAreaKm2)))
+	}
+
+	if len(errors) != 0 {
+		app.sendFailedValidationResponse(w, errors...)
+		return false
+	}
+	return true
+}
+
+// defaultWalkSpeedKmh and defaultDriveSpeedKmh are the average speeds
+// in kilometers per hour of either travel mode if Config sets none
+const (
+	defaultWalkSpeedKmh  = 5
+	defaultDriveSpeedKmh = 40
+)
+
+// travelSpeed returns the average speed in kilometers per hour of mode under Config
+func (app *App) travelSpeed(mode models.TravelMode) float64 {
+	speed, defaultSpeed := app.Config.DriveSpeedKmh, float64(defaultDriveSpeedKmh)
+	if mode == models.Walk {
+		speed, defaultSpeed = app.Config.WalkSpeedKmh, defaultWalkSpeedKmh
+	}
+	if speed <= 0 {
+		return defaultSpeed
+	}
+	return speed
+}
+
+// readJobFields reads the job fields selected by the fields query parameter of r,
+// along with the coordinate projection of their locations selected by the projection parameter.
+// If either is invalid, a failed validation response is sent to client and ok is false.
+func (app *App) readJobFields(w http.ResponseWriter, r *http.Request) (fields jobFields, ok bool) {
+	fields, err := parseJobFields(r.URL.Query().Get("fields"))
+	if err != nil {
+		app.sendFailedValidationResponse(w, validationError("fields", codeInvalid, err.Error()))
+		return fields, false
+	}
+
+	if fields.projection, ok = parseCoordinateProjection(r.URL.Query().Get("projection")); !ok {
+		app.sendFailedValidationResponse(w, validationError("projection", codeInvalid,
+			fmt.Sprintf("projection must be %v (WGS84) or %v (Web Mercator)", wgs84, webMercator)))
+		return fields, false
+	}
+	return fields, true
+}
+
+// readCompleteOnly reads the completeOnly query parameter of r, false if absent.
+// If completeOnly is not a boolean, a failed validation response is sent to client and ok is false.
+func (app *App) readCompleteOnly(w http.ResponseWriter, r *http.Request) (completeOnly, ok bool) {
+	value := r.URL.Query().Get("completeOnly")
+	if notValidString(value) {
+		return false, true
+	}
+	completeOnly, err := strconv.ParseBool(value)
+	if err != nil {
+		app.sendFailedValidationResponse(w, validationError("completeOnly", codeInvalid, "completeOnly must be true or false"))
+		return false, false
+	}
+	return completeOnly, true
+}
+
+// readLabels reads the labels in the label query parameters of r, each as key:value, if any.
+// If a label is not a key:value pair, or a key is given different values,
+// a failed validation response is sent to client and ok is false.
+func (app *App) readLabels(w http.ResponseWriter, r *http.Request) (labels map[string]string, ok bool) {
+	labels = make(map[string]string)
+	for _, label := range r.URL.Query()["label"] {
+		key, value, found := strings.Cut(label, ":")
+		if key = strings.TrimSpace(key); !found || key == "" {
+			app.sendFailedValidationResponse(w, validationError("label", codeInvalid, fmt.Sprintf("label %q must be key:value", label)))
+			return nil, false
+		}
+		if previous, ok := labels[key]; ok && previous != strings.TrimSpace(value) {
+			app.sendFailedValidationResponse(w, validationError("label", codeConflict, fmt.Sprintf("label %v is given different values", key)))
+			return nil, false
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	return labels, true
+}
+
+// titleJobsFilter filters the jobs listed by title
+type titleJobsFilter struct {
+
+	// completeOnly keeps the complete jobs of each title only
+	completeOnly bool
+
+	// minCount keeps the titles having at least minCount jobs only
+	minCount int
+}
+
+// apply returns the jobs of each title of titleJobs passing f,
+// leaving out titles without any job passing f
+func (f titleJobsFilter) apply(titleJobs map[string][]models.Job) map[string][]models.Job {
+	if !f.completeOnly && f.minCount <= 1 {
+		return titleJobs
+	}
+
+	filtered := make(map[string][]models.Job, len(titleJobs))
+	for title, jobs := range titleJobs {
+		if f.completeOnly {
+			jobs = models.CompleteJobs(jobs)
+		}
+		if len(jobs) != 0 && len(jobs) >= f.minCount {
+			filtered[title] = jobs
+		}
+	}
+	return filtered
+}
+
+// capRadius rounds radius to Config.RadiusStepKm then applies Config.MaxRadiusKm to it.
+// withinLimit is false if radius exceeds Config.MaxRadiusKm and
+// Config.ClampRadius is not set, otherwise capped is the radius to search.
+// radius must not be negative, as callers reject negative radii before capping them.
+func (app *App) capRadius(radius float64) (capped float64, withinLimit bool) {
+	radius = app.bucketRadius(radius)
+	if app.Config.MaxRadiusKm <= 0 || radius <= app.Config.MaxRadiusKm {
+		return radius, true
+	}
+
+	if app.Config.ClampRadius {
+		return app.Config.MaxRadiusKm, true
+	}
+	return radius, false
+}
+
+// bucketRadius rounds radius to the nearest multiple of Config.RadiusStepKm,
+// so that searches of near-identical radii search the same radius, hence match
+// the same of Config.WarmQueries rather than traversing the index.
+// A positive radius is rounded to at least one step
+func (app *App) bucketRadius(radius float64) float64 {
+	step := app.Config.RadiusStepKm
+	if step <= 0 || radius <= 0 {
+		return radius
+	}
+	return math.Max(math.Round(radius/step), 1) * step
+}
+
+// checkResultBudget checks count search results against Config.MaxResultCount.
+// keep is the number of results to send to client.
+// If count exceeds the budget and Config.TruncateResults is set, keep is
+// Config.MaxResultCount and args.meta reports the truncation and total count,
+// otherwise 413 is sent to client and withinBudget is false.
+func (app *App) checkResultBudget(args *responseWriterArgs, count int) (keep int, withinBudget bool) {
+	if app.Config.MaxResultCount <= 0 || count <= app.Config.MaxResultCount {
+		return count, true
+	}
+
+	if !app.Config.TruncateResults {
+		app.sendTooManyResultsResponse(args.writer, count)
+		return 0, false
+	}
+
+	args.addMeta("truncated", true)
+	args.addMeta("total", count)
+	return app.Config.MaxResultCount, true
+}
+
+// encodeCursor encodes the position of the last item of a page
+// into an opaque cursor for the next page
+func encodeCursor(position string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(position))
+}
+
+// decodeCursor decodes a cursor encoded with encodeCursor into its position
+func decodeCursor(cursor string) (string, error) {
+	position, err := base64.RawURLEncoding.DecodeString(cursor)
+	return string(position), err
+}
+
+// validateJob checks that job has a title and coordinates within range.
+// Each failed check is returned as an error on the offending field prefixed by prefix,
+// e.g., jobs[2].title. No error is returned if job is valid.
+func validateJob(prefix string, job models.Job) []ValidationError {
+	var errors []ValidationError
+	if notValidString(job.Title) {
+		errors = append(errors, validationError(prefix+".title", codeRequired, "title must not be empty"))
+	}
+	return append(errors, validateLocation(prefix+".location", job.Location)...)
+}
+
+// validateLocation checks that the coordinates of location are within range.
+// Each failed check is returned as an error on the offending coordinate prefixed by prefix,
+// e.g., location.latitude. No error is returned if location is valid.
+func validateLocation(prefix string, location models.Location) []ValidationError {
+	var errors []ValidationError
+	if location.Latitude < -90 || location.Latitude > 90 {
+		errors = append(errors, validationError(prefix+".latitude", codeOutOfRange, "latitude must be between -90 and 90"))
+	}
+	if location.Longitude < -180 || location.Longitude > 180 {
+		errors = append(errors, validationError(prefix+".longitude", codeOutOfRange, "longitude must be between -180 and 180"))
+	}
+	return errors
+}
+
 // notValidString generically validates that text is not a valid string.
 // notValidString can be further expanded with more validation logic
 func notValidString(text string) bool {
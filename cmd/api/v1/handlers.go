@@ -3,21 +3,33 @@ package v1
 import (
 	"fmt"
 	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
 	"github.com/go-chi/chi/v5"
 	"net/http"
+	"sort"
 	"strconv"
 )
 
-func Routes(repo repository) http.Handler {
+func Routes(repo repository, config Config) http.Handler {
 	mux := chi.NewMux()
 	app := new(App)
 	app.repo = repo
+	app.Config = config
+	app.subscriptions = newSubscriptions(repo.Sphere())
+	app.geofences = newGeofences()
+	app.idempotencyKeys = newIdempotencyKeys()
 
+	mux.Use(app.normalizePath(mux), app.trace, app.timeout, app.selectDataset)
 	mux.MethodNotAllowed(app.sendMethodNotAllowedResponse)
 	mux.NotFound(app.sendNotFoundResponse)
 
+	mux.Get("/healthz", app.healthCheck)
+	mux.Get("/metrics", app.getMetrics)
 	mux.Route("/api/v1", func(r chi.Router) {
+		r.Use(app.unavailableDuringRebuild)
 		r.Mount("/jobs", app.jobsRouter())
+		r.Mount("/geofences", app.geofencesRouter())
+		r.Mount("/admin", app.adminRouter())
 	})
 
 	return mux
@@ -26,69 +38,1555 @@ func Routes(repo repository) http.Handler {
 func (app *App) jobsRouter() chi.Router {
 	router := chi.NewRouter()
 
-	router.Get("/available", app.getTitleJobs)
-	router.Get("/nearby", app.getJobsNearby)
-	router.Get("/top-jobs/around-me", app.getTopTitleJobsAround)
+	router.With(app.allowQueryParams(), app.idempotent).Post("/", app.createJob)
+	router.With(app.allowQueryParams("partial"), app.idempotent).Post("/bulk", app.createJobs)
+	router.With(app.allowQueryParams("fields", "projection", "cursor", "limit", "sort", "completeOnly", "minCount")).Get("/available", app.getTitleJobs)
+	router.With(app.allowQueryParams()).Get("/stats", app.getStats)
+	router.With(app.allowQueryParams("bounds")).Get("/distribution", app.getTitleDistribution)
+	router.With(app.allowQueryParams()).Get("/export", app.exportJobs)
+	router.With(app.allowQueryParams()).Get("/bootstrap", app.getBootstrap)
+	router.With(app.allowQueryParams("prefix", "limit")).Get("/autocomplete", app.getTitleAutocomplete)
+	router.With(app.allowQueryParams()).Get("/id/{id}", app.getJobByID)
+	router.With(app.allowQueryParams("k", "fields", "projection")).Get("/id/{id}/neighbors", app.getJobNeighbors)
+	router.With(app.allowQueryParams()).Get("/subscribe", app.subscribeJobs)
+	router.With(app.allowQueryParams("latitude", "longitude", "radius", "fields", "projection", "direction", "bearingTolerance", "summary",
+		"includeDistance", "approximate", "includeCoverage", "mode", "completeOnly", "label", "inclusive", "sortBy", "order")).
+		Get("/nearby", app.getJobsNearby)
+	router.With(app.allowQueryParams("latitude", "longitude", "minutes", "mode", "fields", "projection", "label")).
+		Get("/reachable", app.getReachableJobs)
+	router.With(app.allowQueryParams("fields", "projection")).Post("/nearby/more", app.getMoreJobsNearby)
+	router.With(app.allowQueryParams("latitude", "longitude", "radius")).Get("/nearby/title-counts", app.getTitleCountsNearby)
+	router.With(app.allowQueryParams("latitude", "longitude", "radius", "approximate")).Get("/nearby/count", app.getJobCountNearby)
+	router.With(app.allowQueryParams("fields", "projection", "label")).Post("/near-any", app.getJobsNearAny)
+	router.With(app.allowQueryParams("fields", "projection", "label")).Post("/route", app.getJobsAlongRoute)
+	router.With(app.allowQueryParams("address", "radius", "fields", "projection")).Get("/near-address", app.getJobsNearAddress)
+	router.With(app.allowQueryParams("latitude", "longitude", "radius", "title", "label", "limit", "cursor", "fields", "projection")).
+		Get("/search/advanced", app.getAdvancedSearch)
+	router.With(app.allowQueryParams("latitude", "longitude", "tolerance")).Get("/at", app.getJobsAt)
+	router.With(app.allowQueryParams("radius")).Get("/hotspot", app.getHotspot)
+	router.With(app.allowQueryParams("latitude", "longitude", "k", "minLat", "minLon", "maxLat", "maxLon", "cursor", "fields", "projection")).
+		Get("/nearest", app.getNearestJobs)
+	router.With(app.allowQueryParams("latitude", "longitude", "title", "k", "fields", "projection")).
+		Get("/nearest/per-title", app.getNearestPerTitle)
+	router.With(app.allowQueryParams("minLat", "minLon", "maxLat", "maxLon")).Get("/entries", app.getEntriesInBox)
+	router.With(app.allowQueryParams("minLat", "minLon", "maxLat", "maxLon", "n")).Get("/sample", app.getSampleInBox)
+	router.With(app.allowQueryParams("minLat", "minLon", "maxLat", "maxLon")).Get("/density", app.getDensityInBox)
+	router.With(app.allowQueryParams("minLat", "minLon", "maxLat", "maxLon", "grid", "limit")).Get("/gaps", app.getGapsInBox)
+	router.With(app.allowQueryParams("title")).Get("/centroid", app.getTitleCentroid)
+	router.With(app.allowQueryParams("latitude", "longitude", "title", "company", "fields", "projection", "completeOnly", "label")).
+		Get("/top-jobs/around-me", app.getTopTitleJobsAround)
 	return router
 }
 
-// getTitleJobs fetches a mapping of title to available jobs
+func (app *App) adminRouter() chi.Router {
+	router := chi.NewRouter()
+
+	router.With(app.allowQueryParams("title", "latitude", "longitude", "radius")).Delete("/jobs", app.deleteJobs)
+	router.With(app.allowQueryParams("titleA", "titleB", "radius")).Get("/spatial-join", app.getSpatialJoin)
+	router.With(app.allowQueryParams("from", "to")).Get("/datasets/diff", app.getDatasetDiff)
+	return router
+}
+
+// healthCheck reports that the server is up and serving requests
+// Request Method: GET
+// Query Parameters: None
+// Response Type: application/json
+func (app *App) healthCheck(w http.ResponseWriter, r *http.Request) {
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "OK",
+	}, nil)
+}
+
+// createJob adds a job to the available jobs.
+// Retries having the same Idempotency-Key header are replied to without adding the job again.
+// Request Method: POST
+// Request Body: application/json
+//
+//	{
+//		"title": string,
+//		"location": {"longitude": decimal/float, "latitude": decimal/float}
+//	}
+//
+// Response Type: application/json
+func (app *App) createJob(w http.ResponseWriter, r *http.Request) {
+
+	var job models.Job
+	if err := app.readJSON(w, r, &job); err != nil {
+		app.sendBadRequestResponse(w, err)
+		return
+	}
+
+	if err := app.repository(r).AddJob(&job); err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error adding job %v: %v", job.Title, err))
+		return
+	}
+	if app.servesLiveDataset(r) {
+		app.subscriptions.publish(job)
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: http.StatusCreated,
+		status:     true,
+		message:    "Job added",
+	}, job)
+}
+
+// createJobs adds a batch of jobs to the available jobs.
+// Each job must have a title and coordinates within range.
+// By default, the batch is rejected if any job is invalid. With partial=true,
+// valid jobs are added and meta.errors reports the invalid ones.
+// Retries having the same Idempotency-Key header are replied to without adding the jobs again.
+// Request Method: POST
+// Query Parameters:
+//
+//	partial: boolean (optional, default false)
+//
+// Request Body: application/json array of jobs as accepted by createJob
+// Response Type: application/json
+func (app *App) createJobs(w http.ResponseWriter, r *http.Request) {
+
+	partial := false
+	if value := r.URL.Query().Get("partial"); value != "" {
+		var err error
+		partial, err = strconv.ParseBool(value)
+		if err != nil {
+			app.sendFailedValidationResponse(w, validationError("partial", codeInvalid, "partial must be true or false"))
+			return
+		}
+	}
+
+	var jobs []models.Job
+	if err := app.readJSON(w, r, &jobs); err != nil {
+		app.sendBadRequestResponse(w, err)
+		return
+	}
+
+	var validationErrors []ValidationError
+	valid := make([]models.Job, 0, len(jobs))
+	for i, job := range jobs {
+		errors := validateJob(fmt.Sprintf("jobs[%d]", i), job)
+		if len(errors) == 0 {
+			valid = append(valid, job)
+		}
+		validationErrors = append(validationErrors, errors...)
+	}
+	if len(validationErrors) != 0 && (!partial || len(valid) == 0) {
+		app.sendFailedValidationResponse(w, validationErrors...)
+		return
+	}
+
+	if err := app.repository(r).AddJobs(valid); err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error adding %d jobs: %v", len(valid), err))
+		return
+	}
+	if app.servesLiveDataset(r) {
+		app.subscriptions.publish(valid...)
+	}
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: http.StatusCreated,
+		status:     true,
+		message:    "Jobs added",
+	}
+	if len(validationErrors) != 0 {
+		args.addMeta("errors", validationErrors)
+	}
+	app.sendJSONResponse(args, map[string]int{
+		"added":    len(valid),
+		"rejected": len(jobs) - len(valid),
+	})
+}
+
+// defaultTitlesPageSize and maxTitlesPageSize are the default and maximum
+// number of titles in a page of available jobs
+const (
+	defaultTitlesPageSize = 50
+	maxTitlesPageSize     = 500
+)
+
+// titlesPageSizes returns the default and maximum number of titles in a page
+// of available jobs, neither exceeding Config.MaxAvailableTitles
+func (app *App) titlesPageSizes() (defaultSize, maxSize int) {
+	defaultSize, maxSize = defaultTitlesPageSize, maxTitlesPageSize
+	if limit := app.Config.MaxAvailableTitles; limit > 0 {
+		if defaultSize > limit {
+			defaultSize = limit
+		}
+		if maxSize > limit {
+			maxSize = limit
+		}
+	}
+	return defaultSize, maxSize
+}
+
+// getTitleJobs fetches a mapping of title to available jobs.
+// With sort=title, an array of {title, jobs} ordered alphabetically by title is fetched instead.
+// If either of cursor or limit is set, titles are paginated in a deterministic
+// order and meta.nextCursor fetches the next page, until it is absent on the last page.
+// If more than Config.MaxAvailableTitles titles are listed, the first page of
+// Config.MaxAvailableTitles titles is fetched, as if limit were set to it.
+// Request Method: GET
+// Query Parameters:
+//
+//	fields 		optional comma-separated list of title, location
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//	sort 		optional, title
+//	cursor 		optional string, meta.nextCursor of the previous page
+//	limit 		optional integer number of titles per page, default 50, at most 500 or Config.MaxAvailableTitles
+//	completeOnly 	optional boolean, if true only jobs having every optional field, e.g., company, are listed,
+//			leaving out titles without any such job. Pages may then list fewer than limit titles
+//	minCount 	optional positive integer, only titles having at least minCount jobs are listed.
+//			Pages may then list fewer than limit titles
+//
+// Response Type: application/json
+func (app *App) getTitleJobs(w http.ResponseWriter, r *http.Request) {
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	sortByTitle := false
+	switch r.URL.Query().Get("sort") {
+	case "":
+	case "title":
+		sortByTitle = true
+	default:
+		app.sendFailedValidationResponse(w, validationError("sort", codeInvalid, "sort must be title"))
+		return
+	}
+
+	var filter titleJobsFilter
+	if filter.completeOnly, ok = app.readCompleteOnly(w, r); !ok {
+		return
+	}
+	if value := r.URL.Query().Get("minCount"); !notValidString(value) {
+		var err error
+		filter.minCount, err = strconv.Atoi(value)
+		if err != nil || filter.minCount < 1 {
+			app.sendFailedValidationResponse(w, validationError("minCount", codeInvalid, "minCount must be a positive integer"))
+			return
+		}
+	}
+
+	defaultPageSize, _ := app.titlesPageSizes()
+	if r.URL.Query().Has("cursor") || r.URL.Query().Has("limit") {
+		app.getTitleJobsPage(w, r, fields, sortByTitle, filter, defaultPageSize)
+		return
+	}
+
+	jobs, err := app.repository(r).TitleJobs()
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error searching jobs by title: %v", err))
+		return
+	}
+	jobs = filter.apply(jobs)
+	if limit := app.Config.MaxAvailableTitles; limit > 0 && len(jobs) > limit {
+		app.getTitleJobsPage(w, r, fields, sortByTitle, filter, limit)
+		return
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Available jobs",
+	}, projectAvailableJobs(fields, jobs, sortByTitle))
+}
+
+// projectAvailableJobs projects titleJobs to fields for getTitleJobs,
+// as an array ordered by title if sortByTitle, else as a mapping
+func projectAvailableJobs(fields jobFields, titleJobs map[string][]models.Job, sortByTitle bool) interface{} {
+	if sortByTitle {
+		return fields.listTitleJobs(titleJobs)
+	}
+	return fields.projectTitleJobs(titleJobs)
+}
+
+// getTitleJobsPage fetches a page of title to available jobs for getTitleJobs,
+// of defaultLimit titles unless limit is set
+func (app *App) getTitleJobsPage(w http.ResponseWriter, r *http.Request, fields jobFields, sortByTitle bool, filter titleJobsFilter, defaultLimit int) {
+
+	limit := defaultLimit
+	if value := r.URL.Query().Get("limit"); !notValidString(value) {
+		_, maxLimit := app.titlesPageSizes()
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxLimit {
+			app.sendFailedValidationResponse(w, validationError("limit", codeInvalid,
+				fmt.Sprintf("limit must be an integer between 1 and %d", maxLimit)))
+			return
+		}
+	}
+
+	after, err := decodeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		app.sendFailedValidationResponse(w, validationError("cursor", codeInvalid, "cursor is not valid"))
+		return
+	}
+
+	page, next, err := app.repository(r).TitleJobsPage(after, limit)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error fetching page of %d titles after %q: %v", limit, after, err))
+		return
+	}
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Available jobs",
+	}
+	if next != "" {
+		args.addMeta("nextCursor", encodeCursor(next))
+	}
+	app.sendJSONResponse(args, projectAvailableJobs(fields, filter.apply(page), sortByTitle))
+}
+
+// getStats fetches the total job count, distinct title count
+// and bounding box of the jobs dataset, along with counts of
+// index rebuilds and splits
+// Request Method: GET
+// Query Parameters: None
+// Response Type: application/json
+func (app *App) getStats(w http.ResponseWriter, r *http.Request) {
+
+	stats, err := app.repository(r).Stats()
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error fetching dataset stats: %v", err))
+		return
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Dataset stats",
+	}, stats)
+}
+
+// getHotspot fetches the job location having the most other jobs within radius,
+// along with that count of jobs.
+// On large datasets, only a regularly sampled subset of job locations is considered
+// Request Method: GET
+// Query Parameters:
+//
+//	radius: decimal/float (in km)
+//
+// Response Type: application/json
+func (app *App) getHotspot(w http.ResponseWriter, r *http.Request) {
+
+	radius, err := strconv.ParseFloat(r.URL.Query().Get("radius"), 64)
+	if err != nil || radius <= 0 {
+		app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius must be a positive decimal/float"))
+		return
+	}
+
+	radius, withinLimit := app.capRadius(radius)
+	if !withinLimit {
+		app.sendFailedValidationResponse(w, validationError("radius", codeOutOfRange,
+			fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+		return
+	}
+
+	hotspot, ok, err := app.repository(r).Hotspot(r.Context(), radius)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error finding hotspot: %v", err))
+		return
+	}
+	if !ok {
+		app.sendNotFoundResponse(w, r)
+		return
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Densest cluster of jobs",
+	}, hotspot)
+}
+
+// titleCount is the number of jobs having a title
+type titleCount struct {
+	Title string `json:"title"`
+	Count int    `json:"count"`
+}
+
+// getBootstrap fetches in a single payload what a client needs to start with,
+// i.e., the bounding box of the jobs, the titles with their number of jobs
+// from the most popular, and the default nearby search radius
+// Request Method: GET
+// Query Parameters: None
+// Response Type: application/json
+func (app *App) getBootstrap(w http.ResponseWriter, r *http.Request) {
+
+	stats, err := app.repository(r).Stats()
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error fetching dataset stats: %v", err))
+		return
+	}
+
+	titleJobs, err := app.repository(r).TitleJobs()
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error searching jobs by title: %v", err))
+		return
+	}
+	titles := make([]titleCount, 0, len(titleJobs))
+	for title, jobs := range titleJobs {
+		titles = append(titles, titleCount{Title: title, Count: len(jobs)})
+	}
+	sortMostPopular(titles)
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Bootstrap",
+	}, struct {
+		BoundingBox     models.Box   `json:"boundingBox"`
+		Titles          []titleCount `json:"titles"`
+		DefaultRadiusKm float64      `json:"defaultRadiusKm"`
+	}{
+		BoundingBox:     stats.BoundingBox,
+		Titles:          titles,
+		DefaultRadiusKm: app.repository(r).DefaultRadius().Kilometers(),
+	})
+}
+
+// sortMostPopular orders titles from the one with the most jobs.
+// Titles with as many jobs are ordered alphabetically.
+func sortMostPopular(titles []titleCount) {
+	sort.Slice(titles, func(i, j int) bool {
+		if titles[i].Count != titles[j].Count {
+			return titles[i].Count > titles[j].Count
+		}
+		return titles[i].Title < titles[j].Title
+	})
+}
+
+// defaultAutocompleteLimit and maxAutocompleteLimit are the default and maximum
+// number of titles getTitleAutocomplete completes a prefix with
+const (
+	defaultAutocompleteLimit = 10
+	maxAutocompleteLimit     = 50
+)
+
+// getTitleAutocomplete fetches the titles starting with a prefix, for type-ahead search,
+// from the title with the most jobs, then alphabetically.
+// Titles and prefix are matched as in title searches.
+// Request Method: GET
+// Query Parameters:
+//
+//	prefix 		string
+//	limit 		optional integer number of titles, default 10, at most 50
+//
+// Response Type: application/json
+func (app *App) getTitleAutocomplete(w http.ResponseWriter, r *http.Request) {
+
+	prefix := r.URL.Query().Get("prefix")
+	if notValidString(prefix) {
+		app.sendFailedValidationResponse(w, validationError("prefix", codeRequired, "prefix is not a valid text"))
+		return
+	}
+
+	limit := defaultAutocompleteLimit
+	if value := r.URL.Query().Get("limit"); !notValidString(value) {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxAutocompleteLimit {
+			app.sendFailedValidationResponse(w, validationError("limit", codeInvalid,
+				fmt.Sprintf("limit must be an integer between 1 and %d", maxAutocompleteLimit)))
+			return
+		}
+	}
+
+	counts, err := app.repository(r).TitleCountsWithPrefix(prefix)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error counting jobs of titles starting with %q: %v", prefix, err))
+		return
+	}
+	titles := make([]titleCount, 0, len(counts))
+	for title, count := range counts {
+		titles = append(titles, titleCount{Title: title, Count: count})
+	}
+	sortMostPopular(titles)
+	if len(titles) > limit {
+		titles = titles[:limit]
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("Titles starting with %v", prefix),
+	}, titles)
+}
+
+// getJobByID fetches the job with id.
+// If no job has id, a 404 not found is sent to client
+// Request Method: GET
+// Path Parameters:
+//
+//	id: string
+//
+// Response Type: application/json
+func (app *App) getJobByID(w http.ResponseWriter, r *http.Request) {
+
+	job, ok := app.repository(r).JobByID(chi.URLParam(r, "id"))
+	if !ok {
+		app.sendNotFoundResponse(w, r)
+		return
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Job found",
+	}, job)
+}
+
+// defaultBearingTolerance is the angle in degrees a job's bearing from
+// the search center may deviate from the requested direction
+const defaultBearingTolerance = 45.0
+
+// coverageSegments is the number of sides of the polygon
+// approximating the circle searched by getJobsNearby
+const coverageSegments = 64
+
+// getJobsNearby fetches jobs some radius around current location,
+// each annotated with its distance and ordered from the nearest job.
+// Computing and sorting by distance costs a haversine computation per job found,
+// which clients not needing distances may skip with includeDistance=false,
+// in which case jobs are sent in index order.
+// With approximate=true, jobs are found faster by skipping the distance check of each job,
+// but jobs outside radius, indexed next to jobs within radius, are included too.
+// Distances are then not computed unless includeDistance=true.
+// Request Method: GET
+// Query Parameters:
+//
+//	latitude 	decimal/float
+//	longitude 	decimal/float
+//	radius 		optional non-negative decimal/float, default Config.DefaultRadius, capped at Config.MaxRadiusKm.
+//			Zero is the same as the default. The radius is rounded to Config.RadiusStepKm if set,
+//			near-identical radii being searched alike. meta.radiusKm reports the radius searched
+//	inclusive 	optional boolean, default true. If false, jobs exactly at radius are not found
+//	fields 		optional comma-separated list of title, location, distance
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//	direction 	optional compass direction N, NE, E, SE, S, SW, W or NW
+//	bearingTolerance 	optional decimal/float degrees a job may deviate from direction, default 45
+//	summary 	optional boolean, if true meta.summary reports the count, min/max/mean
+//			distance in km and dominant title of the jobs found
+//	includeDistance 	optional boolean, default true unless approximate
+//	approximate 	optional boolean, default false
+//	includeCoverage 	optional boolean, if true meta.coverage is a GeoJSON Polygon
+//			approximating the circle searched
+//	mode 		optional travel mode walk or drive. If set, each job is annotated with travelMinutes,
+//			the straight-line distance to it traveled at the average speed of mode, and
+//			meta.travelMinutes is the radius traveled likewise. Requires distances
+//	completeOnly 	optional boolean, if true only jobs having every optional field, e.g., company, are found
+//	label 		optional key:value, repeated to only find jobs having every label
+//	sortBy 		optional distance (default), salary, i.e., the number in the salary label,
+//			or recent, i.e., when jobs were added. Jobs lacking a salary come last, and jobs
+//			read from the data file are the oldest. Jobs sorted alike are ordered from the nearest
+//	order 		optional asc or desc, default asc for distance and desc for salary and recent
+//
+// Response Type: application/json
+// With Config.DevMode, meta.debug reports the number of index nodes and entries
+// visited by the search, unless approximate.
+func (app *App) getJobsNearby(w http.ResponseWriter, r *http.Request) {
+
+	// read query paramters
+	center, ok := app.readLocation(w, r)
+	if !ok {
+		return
+	}
+
+	var radius float64
+	var err error
+	if value := r.URL.Query().Get("radius"); !notValidString(value) {
+		radius, err = strconv.ParseFloat(value, 64)
+		if err != nil || radius < 0 {
+			app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius not a valid non-negative decimal/float"))
+			return
+		}
+	}
+	if radius == 0 {
+		radius = app.repository(r).DefaultRadius().Kilometers()
+	}
+
+	inclusive := true
+	if value := r.URL.Query().Get("inclusive"); !notValidString(value) {
+		inclusive, err = strconv.ParseBool(value)
+		if err != nil {
+			app.sendFailedValidationResponse(w, validationError("inclusive", codeInvalid, "inclusive must be true or false"))
+			return
+		}
+	}
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	radius, withinLimit := app.capRadius(radius)
+	if !withinLimit {
+		app.sendFailedValidationResponse(w, validationError("radius", codeOutOfRange,
+			fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+		return
+	}
+
+	var bearing float64
+	direction := r.URL.Query().Get("direction")
+	if !notValidString(direction) {
+		var ok bool
+		if bearing, ok = models.CompassBearing(direction); !ok {
+			app.sendFailedValidationResponse(w, validationError("direction", codeInvalid, "direction must be one of N, NE, E, SE, S, SW, W, NW"))
+			return
+		}
+	}
+
+	includeSummary := false
+	if value := r.URL.Query().Get("summary"); !notValidString(value) {
+		includeSummary, err = strconv.ParseBool(value)
+		if err != nil {
+			app.sendFailedValidationResponse(w, validationError("summary", codeInvalid, "summary must be true or false"))
+			return
+		}
+	}
+
+	completeOnly, ok := app.readCompleteOnly(w, r)
+	if !ok {
+		return
+	}
+
+	labels, ok := app.readLabels(w, r)
+	if !ok {
+		return
+	}
+
+	includeCoverage := false
+	if value := r.URL.Query().Get("includeCoverage"); !notValidString(value) {
+		includeCoverage, err = strconv.ParseBool(value)
+		if err != nil {
+			app.sendFailedValidationResponse(w, validationError("includeCoverage", codeInvalid, "includeCoverage must be true or false"))
+			return
+		}
+	}
+
+	approximate := false
+	if value := r.URL.Query().Get("approximate"); !notValidString(value) {
+		approximate, err = strconv.ParseBool(value)
+		if err != nil {
+			app.sendFailedValidationResponse(w, validationError("approximate", codeInvalid, "approximate must be true or false"))
+			return
+		}
+		if approximate && !inclusive {
+			app.sendFailedValidationResponse(w, validationError("inclusive", codeConflict, "inclusive=false cannot be set with approximate=true"))
+			return
+		}
+	}
+
+	includeDistance := !approximate
+	if value := r.URL.Query().Get("includeDistance"); !notValidString(value) {
+		includeDistance, err = strconv.ParseBool(value)
+		if err != nil {
+			app.sendFailedValidationResponse(w, validationError("includeDistance", codeInvalid, "includeDistance must be true or false"))
+			return
+		}
+	}
+	if !includeDistance && fields.distance {
+		app.sendFailedValidationResponse(w, validationError("fields", codeConflict, "distance cannot be selected with includeDistance=false"))
+		return
+	}
+
+	var mode models.TravelMode
+	if value := r.URL.Query().Get("mode"); !notValidString(value) {
+		var ok bool
+		if mode, ok = models.ParseTravelMode(value); !ok {
+			app.sendFailedValidationResponse(w, validationError("mode", codeInvalid, "mode must be one of walk, drive"))
+			return
+		}
+		if !includeDistance {
+			app.sendFailedValidationResponse(w, validationError("mode", codeConflict, "mode cannot be set with includeDistance=false"))
+			return
+		}
+	}
+
+	bearingTolerance := defaultBearingTolerance
+	if tolerance := r.URL.Query().Get("bearingTolerance"); !notValidString(tolerance) {
+		bearingTolerance, err = strconv.ParseFloat(tolerance, 64)
+		if err != nil || bearingTolerance < 0 || bearingTolerance > 180 {
+			app.sendFailedValidationResponse(w, validationError("bearingTolerance", codeInvalid, "bearingTolerance must be a decimal/float between 0 and 180"))
+			return
+		}
+	}
+
+	sortBy, descending, ok := app.readSortOrder(w, r)
+	if !ok {
+		return
+	}
+	if sortBy == models.SortByDistance && !includeDistance && r.URL.Query().Has("sortBy") {
+		app.sendFailedValidationResponse(w, validationError("sortBy", codeConflict, "jobs cannot be sorted by distance with includeDistance=false"))
+		return
+	}
+
+	var jobs []models.Job
+	var stats *rtree.SearchStats
+	if approximate {
+		jobs, err = app.repository(r).FindJobsNearbyApproximately(r.Context(), center, radius)
+	} else {
+		ctx := r.Context()
+		if app.Config.DevMode {
+			stats = new(rtree.SearchStats)
+			ctx = rtree.WithSearchStats(ctx, stats)
+		}
+		boundary := rtree.Inclusive
+		if !inclusive {
+			boundary = rtree.Exclusive
+		}
+		jobs, err = app.repository(r).FindJobsNearby(ctx, center, radius, boundary)
+	}
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding jobs within a radius of %f", radius))
+		return
+	}
+
+	if !notValidString(direction) {
+		inDirection := make([]models.Job, 0, len(jobs))
+		for _, job := range jobs {
+			if models.BearingDifference(center.BearingTo(job.Location), bearing) <= bearingTolerance {
+				inDirection = append(inDirection, job)
+			}
+		}
+		jobs = inDirection
+	}
+	if completeOnly {
+		jobs = models.CompleteJobs(jobs)
+	}
+	jobs = models.JobsLabeled(jobs, labels)
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Jobs around you",
+	}
+	args.addMeta("radiusKm", radius)
+	if approximate {
+		args.addMeta("approximate", true)
+	}
+	if stats != nil {
+		args.addMeta("debug", stats)
+	}
+	if includeSummary {
+		args.addMeta("summary", models.Summarize(app.repository(r).Sphere(), center, jobs))
+	}
+	if includeCoverage {
+		args.addMeta("coverage", app.repository(r).Sphere().CirclePolygon(center, radius, coverageSegments))
+	}
+	speed := app.travelSpeed(mode)
+	if mode != "" {
+		args.addMeta("mode", mode)
+		args.addMeta("speedKmh", speed)
+		args.addMeta("travelMinutes", models.TravelMinutes(radius, speed))
+	}
+	if r.URL.Query().Has("sortBy") || r.URL.Query().Has("order") {
+		order := "asc"
+		if descending {
+			order = "desc"
+		}
+		args.addMeta("sortBy", sortBy)
+		args.addMeta("order", order)
+	}
+	keep, withinBudget := app.checkResultBudget(args, len(jobs))
+	if !withinBudget {
+		return
+	}
+
+	// jobs are sorted before the result budget keeps the first of them
+	if !includeDistance {
+		models.SortJobsBy(jobs, sortBy, descending)
+		app.sendJSONResponse(args, fields.projectJobs(jobs[:keep]))
+		return
+	}
+	sorted := models.NearestFirst(app.repository(r).Sphere(), center, jobs)
+	if sortBy != models.SortByDistance || descending {
+		models.SortJobsWithDistanceBy(sorted, sortBy, descending)
+	}
+	if mode != "" {
+		app.sendJSONResponse(args, fields.projectJobsWithTravelTime(sorted[:keep], speed))
+		return
+	}
+	app.sendJSONResponse(args, fields.projectJobsWithDistance(sorted[:keep]))
+}
+
+// getReachableJobs fetches jobs reachable from current location within some minutes of travel,
+// the reachable area being approximated as a circle of radius the distance traveled in that time
+// at the average speed of the travel mode, e.g., 2.5 km for 30 minutes of walk at 5 km/h.
+// The jobs found are those a nearby search of that radius finds, each annotated with its distance
+// and travel time, from the nearest. meta.radiusKm reports the radius searched, capped at
+// Config.MaxRadiusKm, and meta.speedKmh the speed it is derived from
+// Request Method: GET
+// Query Parameters:
+//
+//	latitude 	decimal/float
+//	longitude 	decimal/float
+//	minutes 	positive decimal/float
+//	mode 		optional travel mode, walk (default) or drive
+//	fields 		optional comma-separated list of title, location, distance
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//	label 		optional key:value, repeated to only find jobs having every label
+//
+// Response Type: application/json
+func (app *App) getReachableJobs(w http.ResponseWriter, r *http.Request) {
+
+	center, ok := app.readLocation(w, r)
+	if !ok {
+		return
+	}
+
+	minutes, err := strconv.ParseFloat(r.URL.Query().Get("minutes"), 64)
+	if err != nil || minutes <= 0 {
+		app.sendFailedValidationResponse(w, validationError("minutes", codeInvalid, "minutes must be a positive decimal/float"))
+		return
+	}
+
+	mode := models.Walk
+	if value := r.URL.Query().Get("mode"); !notValidString(value) {
+		if mode, ok = models.ParseTravelMode(value); !ok {
+			app.sendFailedValidationResponse(w, validationError("mode", codeInvalid, "mode must be one of walk, drive"))
+			return
+		}
+	}
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	labels, ok := app.readLabels(w, r)
+	if !ok {
+		return
+	}
+
+	speed := app.travelSpeed(mode)
+	radius, withinLimit := app.capRadius(models.TravelDistanceKm(minutes, speed))
+	if !withinLimit {
+		app.sendFailedValidationResponse(w, validationError("minutes", codeOutOfRange,
+			fmt.Sprintf("%v minutes of %v cover more than %v km", minutes, mode, app.Config.MaxRadiusKm)))
+		return
+	}
+
+	jobs, err := app.repository(r).FindJobsNearby(r.Context(), center, radius, rtree.Inclusive)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding jobs within a radius of %f", radius))
+		return
+	}
+	jobs = models.JobsLabeled(jobs, labels)
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("Jobs within %v minutes of %v", minutes, mode),
+	}
+	args.addMeta("radiusKm", radius)
+	args.addMeta("mode", mode)
+	args.addMeta("speedKmh", speed)
+	args.addMeta("minutes", minutes)
+	keep, withinBudget := app.checkResultBudget(args, len(jobs))
+	if !withinBudget {
+		return
+	}
+
+	app.sendJSONResponse(args, fields.projectJobsWithTravelTime(models.NearestFirst(app.repository(r).Sphere(), center, jobs)[:keep], speed))
+}
+
+// getJobsNearAny fetches jobs within radius of any of the centers,
+// each annotated with its distance to the nearest center and
+// ordered from the nearest job.
+// Request Method: POST
+// Request Body: application/json
+//
+//	{
+//		"centers": [{"longitude": decimal/float, "latitude": decimal/float}],
+//		"radius": positive decimal/float, capped at Config.MaxRadiusKm
+//	}
+//
+// Query Parameters:
+//
+//	fields 		optional comma-separated list of title, location, distance
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//	label 		optional key:value, repeated to only find jobs having every label
+//
+// Response Type: application/json
+func (app *App) getJobsNearAny(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Centers []models.Location `json:"centers"`
+		Radius  float64           `json:"radius"`
+	}
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.sendBadRequestResponse(w, err)
+		return
+	}
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	labels, ok := app.readLabels(w, r)
+	if !ok {
+		return
+	}
+
+	if len(input.Centers) == 0 {
+		app.sendFailedValidationResponse(w, validationError("centers", codeRequired, "at least one center must be provided"))
+		return
+	}
+	if !app.checkGeometry(w, r, "centers", len(input.Centers), models.BoxAround(input.Centers)) {
+		return
+	}
+
+	if input.Radius <= 0 {
+		app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius must be a positive decimal/float"))
+		return
+	}
+	radius, withinLimit := app.capRadius(input.Radius)
+	if !withinLimit {
+		app.sendFailedValidationResponse(w, validationError("radius", codeOutOfRange,
+			fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+		return
+	}
+
+	jobs, err := app.repository(r).FindJobsNearAny(r.Context(), input.Centers, radius, rtree.Inclusive)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding jobs within a radius of %f around %v", radius, input.Centers))
+		return
+	}
+	if len(labels) != 0 {
+		labeled := make([]models.JobWithDistance, 0, len(jobs))
+		for _, job := range jobs {
+			if job.HasLabels(labels) {
+				labeled = append(labeled, job)
+			}
+		}
+		jobs = labeled
+	}
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Jobs around your locations",
+	}
+	keep, withinBudget := app.checkResultBudget(args, len(jobs))
+	if !withinBudget {
+		return
+	}
+
+	app.sendJSONResponse(args, fields.projectJobsWithDistance(jobs[:keep]))
+}
+
+// getTitleCountsNearby fetches the number of jobs per title
+// within some radius around current location
+// Request Method: GET
+// Query Parameters:
+//
+//	latitude 	decimal/float
+//	longitude 	decimal/float
+//	radius 		positive decimal/float, capped at Config.MaxRadiusKm
+//
+// Response Type: application/json
+func (app *App) getTitleCountsNearby(w http.ResponseWriter, r *http.Request) {
+
+	center, ok := app.readLocation(w, r)
+	if !ok {
+		return
+	}
+
+	radius, err := strconv.ParseFloat(r.URL.Query().Get("radius"), 64)
+	if err != nil || radius <= 0 {
+		app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius must be a positive decimal/float"))
+		return
+	}
+
+	radius, withinLimit := app.capRadius(radius)
+	if !withinLimit {
+		app.sendFailedValidationResponse(w, validationError("radius", codeOutOfRange,
+			fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+		return
+	}
+
+	counts, err := app.repository(r).TitleCountsNearby(r.Context(), center, radius)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error counting titles within a radius of %f around %v: %v", radius, center, err))
+		return
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Job counts per title around you",
+	}, counts)
+}
+
+// getJobCountNearby fetches the number of jobs within some radius around current location,
+// counted approximately if requested, e.g., for radii too large to count every job quickly
+// Request Method: GET
+// Query Parameters:
+//
+//	latitude 	decimal/float
+//	longitude 	decimal/float
+//	radius 		optional decimal/float, default Config.DefaultRadius, capped at Config.MaxRadiusKm
+//	approximate 	optional boolean, default false. If true, whole parts of the index within radius
+//			are counted at once, along with the few jobs they hold outside radius, if any
+//
+// Response Type: application/json
+func (app *App) getJobCountNearby(w http.ResponseWriter, r *http.Request) {
+
+	center, ok := app.readLocation(w, r)
+	if !ok {
+		return
+	}
+
+	var radius float64
+	if value := r.URL.Query().Get("radius"); !notValidString(value) {
+		var err error
+		radius, err = strconv.ParseFloat(value, 64)
+		if err != nil || radius < 0 {
+			app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius not a valid non-negative decimal/float"))
+			return
+		}
+	}
+	if radius == 0 {
+		radius = app.repository(r).DefaultRadius().Kilometers()
+	}
+	radius, withinLimit := app.capRadius(radius)
+	if !withinLimit {
+		app.sendFailedValidationResponse(w, validationError("radius", codeOutOfRange,
+			fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+		return
+	}
+
+	approximate := false
+	if value := r.URL.Query().Get("approximate"); !notValidString(value) {
+		var err error
+		approximate, err = strconv.ParseBool(value)
+		if err != nil {
+			app.sendFailedValidationResponse(w, validationError("approximate", codeInvalid, "approximate must be true or false"))
+			return
+		}
+	}
+
+	var count int
+	if approximate {
+		var err error
+		count, err = app.repository(r).CountJobsNearbyApprox(r.Context(), center, radius, rtree.Inclusive)
+		if err != nil {
+			app.sendServerErrorResponse(w, fmt.Errorf("error counting jobs within a radius of %f around %v: %v", radius, center, err))
+			return
+		}
+	} else {
+		jobs, err := app.repository(r).FindJobsNearby(r.Context(), center, radius, rtree.Inclusive)
+		if err != nil {
+			app.sendServerErrorResponse(w, fmt.Errorf("error counting jobs within a radius of %f around %v: %v", radius, center, err))
+			return
+		}
+		count = len(jobs)
+	}
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Job count around you",
+	}
+	args.addMeta("radiusKm", radius)
+	args.addMeta("approximate", approximate)
+	app.sendJSONResponse(args, map[string]int{"count": count})
+}
+
+// defaultMoreJobsLimit and maxMoreJobsLimit are the default and maximum
+// number of jobs in a batch fetched by getMoreJobsNearby
+const (
+	defaultMoreJobsLimit = 20
+	maxMoreJobsLimit     = 200
+)
+
+// getMoreJobsNearby fetches the next batch of jobs within radius of center,
+// excluding the jobs client has already seen, ordered from the nearest job.
+// Client adds the IDs of each batch to seen to fetch the following batch,
+// until meta.remaining is zero.
+// Request Method: POST
+// Request Body: application/json
+//
+//	{
+//		"center": {"longitude": decimal/float, "latitude": decimal/float},
+//		"radius": positive decimal/float, capped at Config.MaxRadiusKm,
+//		"seen": [string], IDs of jobs already seen,
+//		"limit": integer (optional, default 20, max 200)
+//	}
+//
+// Query Parameters:
+//
+//	fields 		optional comma-separated list of title, location, distance
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//
+// Response Type: application/json
+func (app *App) getMoreJobsNearby(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Center models.Location `json:"center"`
+		Radius float64         `json:"radius"`
+		Seen   []string        `json:"seen"`
+		Limit  int             `json:"limit"`
+	}
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.sendBadRequestResponse(w, err)
+		return
+	}
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	if input.Limit == 0 {
+		input.Limit = defaultMoreJobsLimit
+	}
+	if input.Limit < 1 || input.Limit > maxMoreJobsLimit {
+		app.sendFailedValidationResponse(w, validationError("limit", codeOutOfRange,
+			fmt.Sprintf("limit must be an integer between 1 and %d", maxMoreJobsLimit)))
+		return
+	}
+
+	if input.Radius <= 0 {
+		app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius must be a positive decimal/float"))
+		return
+	}
+	radius, withinLimit := app.capRadius(input.Radius)
+	if !withinLimit {
+		app.sendFailedValidationResponse(w, validationError("radius", codeOutOfRange,
+			fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+		return
+	}
+
+	jobs, err := app.repository(r).FindJobsNearAny(r.Context(), []models.Location{input.Center}, radius, rtree.Inclusive)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding jobs within a radius of %f around %v", radius, input.Center))
+		return
+	}
+
+	seen := make(map[string]bool, len(input.Seen))
+	for _, id := range input.Seen {
+		seen[id] = true
+	}
+	unseen := make([]models.JobWithDistance, 0, len(jobs))
+	for _, job := range jobs {
+		if !seen[job.ID] {
+			unseen = append(unseen, job)
+		}
+	}
+
+	batch := unseen
+	if len(batch) > input.Limit {
+		batch = batch[:input.Limit]
+	}
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "More jobs around you",
+	}
+	args.addMeta("remaining", len(unseen)-len(batch))
+	app.sendJSONResponse(args, fields.projectJobsWithDistance(batch))
+}
+
+// maxTitleSuggestions is the maximum number of titles suggested
+// when a title search finds no job
+const maxTitleSuggestions = 3
+
+// defaultNearestCount and maxNearestCount are the default and maximum
+// number of jobs getNearestJobs fetches
+const (
+	defaultNearestCount = 10
+	maxNearestCount     = 500
+)
+
+// getNearestJobs fetches the k jobs nearest to a location, each annotated with its
+// distance to the location, from the nearest. If a box is set, e.g., the viewport of a map,
+// only jobs within the box are fetched, even if jobs outside the box are nearer.
+// Jobs are paged through with meta.nextCursor, set if more jobs may follow, e.g., for infinite scroll:
+// the page following a cursor holds the k jobs next nearest after the last job of the previous page,
+// jobs of previous pages being skipped rather than searched and sorted again.
 // Request Method: GET
-// Query Parameters: None
+// Query Parameters:
+//
+//	latitude 	decimal/float
+//	longitude 	decimal/float
+//	k 			optional integer, default 10, at most 500
+//	minLat 		optional decimal/float, along with minLon, maxLat and maxLon
+//	minLon 		optional decimal/float
+//	maxLat 		optional decimal/float
+//	maxLon 		optional decimal/float
+//	cursor 		optional string, meta.nextCursor of the previous page, fetched with the same location and box
+//	fields 		optional comma-separated list of title, location, distance
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//
 // Response Type: application/json
-func (app *App) getTitleJobs(w http.ResponseWriter, r *http.Request) {
+func (app *App) getNearestJobs(w http.ResponseWriter, r *http.Request) {
+
+	center, ok := app.readLocation(w, r)
+	if !ok {
+		return
+	}
+
+	k := defaultNearestCount
+	if value := r.URL.Query().Get("k"); !notValidString(value) {
+		var err error
+		k, err = strconv.Atoi(value)
+		if err != nil || k < 1 || k > maxNearestCount {
+			app.sendFailedValidationResponse(w, validationError("k", codeInvalid,
+				fmt.Sprintf("k must be an integer between 1 and %d", maxNearestCount)))
+			return
+		}
+	}
+
+	var box *models.Box
+	query := r.URL.Query()
+	if query.Has("minLat") || query.Has("minLon") || query.Has("maxLat") || query.Has("maxLon") {
+		viewport, ok := app.readBox(w, r)
+		if !ok {
+			return
+		}
+		box = &viewport
+	}
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	after, ok := app.readPositionCursor(w, r)
+	if !ok {
+		return
+	}
 
-	jobs, err := app.repo.TitleJobs()
+	// one more job than a page is found to tell whether another page follows
+	jobs, err := app.repository(r).FindNearestJobs(r.Context(), center, k+1, box, after)
 	if err != nil {
-		app.sendServerErrorResponse(w, fmt.Errorf("error searching jobs by title: %v", err))
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding %d jobs nearest to %v: %v", k, center, err))
+		return
+	}
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+	}
+	if len(jobs) > k {
+		jobs = jobs[:k]
+		next, err := encodePositionCursor(jobs[k-1])
+		if err != nil {
+			app.sendServerErrorResponse(w, fmt.Errorf("error encoding cursor: %v", err))
+			return
+		}
+		args.addMeta("nextCursor", next)
+	}
+	args.message = fmt.Sprintf("%d jobs nearest to %v", len(jobs), app.formatLocation(center))
+	app.sendJSONResponse(args, fields.projectJobsWithDistance(jobs))
+}
+
+// getJobNeighbors fetches the k jobs nearest to the job with id, but that job itself,
+// each annotated with its distance to that job, from the nearest, e.g., similar jobs nearby.
+// Request Method: GET
+// Query Parameters:
+//
+//	k 			optional integer, default 10, at most 500
+//	fields 		optional comma-separated list of title, location, distance
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//
+// Response Type: application/json
+func (app *App) getJobNeighbors(w http.ResponseWriter, r *http.Request) {
+
+	id := chi.URLParam(r, "id")
+	if _, ok := app.repository(r).JobByID(id); !ok {
+		app.sendNotFoundResponse(w, r)
+		return
+	}
+
+	k := defaultNearestCount
+	if value := r.URL.Query().Get("k"); !notValidString(value) {
+		var err error
+		k, err = strconv.Atoi(value)
+		if err != nil || k < 1 || k > maxNearestCount {
+			app.sendFailedValidationResponse(w, validationError("k", codeInvalid,
+				fmt.Sprintf("k must be an integer between 1 and %d", maxNearestCount)))
+			return
+		}
+	}
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
 		return
 	}
 
+	jobs := app.repository(r).NeighborsOf(id, k)
 	app.sendJSONResponse(&responseWriterArgs{
 		writer:     w,
 		statusCode: 200,
 		status:     true,
-		message:    "Available jobs",
-	}, jobs)
+		message:    fmt.Sprintf("%d jobs nearest to job %s", len(jobs), id),
+	}, fields.projectJobsWithDistance(jobs))
 }
 
-// getJobsNearby fetches jobs some radius around current location
+// defaultNearestPerTitleCount and maxNearestPerTitleCount are the default and maximum number of jobs
+// getNearestPerTitle fetches per title, and maxNearestPerTitleTitles the maximum number of titles
+const (
+	defaultNearestPerTitleCount = 3
+	maxNearestPerTitleCount     = 50
+	maxNearestPerTitleTitles    = 50
+)
+
+// getNearestPerTitle fetches the k jobs nearest to a location for each of several titles,
+// each annotated with its distance to the location, from the nearest, as a mapping of title to jobs.
 // Request Method: GET
 // Query Parameters:
 //
 //	latitude 	decimal/float
 //	longitude 	decimal/float
-//	radius 		decimal/float
+//	title 		string, repeated for each title, at most 50 titles, matched as in top-jobs/around-me
+//	k 		optional integer, default 3, at most 50
+//	fields 		optional comma-separated list of title, location, distance, company, labels
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
 //
 // Response Type: application/json
-func (app *App) getJobsNearby(w http.ResponseWriter, r *http.Request) {
+func (app *App) getNearestPerTitle(w http.ResponseWriter, r *http.Request) {
 
-	// read query paramters
-	latitude, err := strconv.ParseFloat(r.URL.Query().Get("latitude"), 32)
+	center, ok := app.readLocation(w, r)
+	if !ok {
+		return
+	}
+
+	titles := make([]string, 0)
+	for _, title := range r.URL.Query()["title"] {
+		if !notValidString(title) {
+			titles = append(titles, title)
+		}
+	}
+	if len(titles) == 0 {
+		app.sendFailedValidationResponse(w, validationError("title", codeRequired, "at least one title must be a valid text"))
+		return
+	}
+	if len(titles) > maxNearestPerTitleTitles {
+		app.sendFailedValidationResponse(w, validationError("title", codeOutOfRange,
+			fmt.Sprintf("at most %d titles may be given", maxNearestPerTitleTitles)))
+		return
+	}
+
+	k := defaultNearestPerTitleCount
+	if value := r.URL.Query().Get("k"); !notValidString(value) {
+		var err error
+		k, err = strconv.Atoi(value)
+		if err != nil || k < 1 || k > maxNearestPerTitleCount {
+			app.sendFailedValidationResponse(w, validationError("k", codeInvalid,
+				fmt.Sprintf("k must be an integer between 1 and %d", maxNearestPerTitleCount)))
+			return
+		}
+	}
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	nearest := app.repository(r).NearestPerTitle(center, titles, k)
+	projected := make(map[string]interface{}, len(nearest))
+	for title, jobs := range nearest {
+		projected[title] = fields.projectJobsWithDistance(jobs)
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("Up to %d jobs nearest to %v per title", k, app.formatLocation(center)),
+	}, projected)
+}
+
+// defaultAtTolerance and maxAtTolerance are the default and maximum
+// distances in meters a job may be from the location queried by getJobsAt
+const (
+	defaultAtTolerance = 5.0
+	maxAtTolerance     = 1000.0
+)
+
+// getJobsAt fetches jobs at, or within a small tolerance of, a location.
+// A zero tolerance is rejected, as repositories search their default radius for a zero radius.
+// Request Method: GET
+// Query Parameters:
+//
+//	latitude 	decimal/float
+//	longitude 	decimal/float
+//	tolerance 	optional positive decimal/float meters, default 5, at most 1000
+//
+// Response Type: application/json
+func (app *App) getJobsAt(w http.ResponseWriter, r *http.Request) {
+
+	location, ok := app.readLocation(w, r)
+	if !ok {
+		return
+	}
+
+	tolerance := defaultAtTolerance
+	if value := r.URL.Query().Get("tolerance"); !notValidString(value) {
+		var err error
+		tolerance, err = strconv.ParseFloat(value, 64)
+		if err != nil || tolerance <= 0 || tolerance > maxAtTolerance {
+			app.sendFailedValidationResponse(w, validationError("tolerance", codeInvalid,
+				fmt.Sprintf("tolerance must be a positive decimal/float of at most %v meters", maxAtTolerance)))
+			return
+		}
+	}
+
+	jobs, err := app.repository(r).FindJobsNearby(r.Context(), location, tolerance/1000, rtree.Inclusive)
 	if err != nil {
-		app.sendFailedValidationResponse(w, map[string]string{"latitude": "latitude not a valid decimal/float"})
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding jobs at %v: %v", location, err))
+		return
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("Jobs within %v meters of %v", tolerance, app.formatLocation(location)),
+	}, jobs)
+}
+
+// getEntriesInBox fetches jobs within a box along with their
+// minimum bounding rectangles, for clients doing their own spatial grouping.
+// Request Method: GET
+// Query Parameters:
+//
+//	minLat 		decimal/float
+//	minLon 		decimal/float
+//	maxLat 		decimal/float
+//	maxLon 		decimal/float
+//
+// Response Type: application/json
+func (app *App) getEntriesInBox(w http.ResponseWriter, r *http.Request) {
+
+	box, ok := app.readBox(w, r)
+	if !ok {
 		return
 	}
 
-	longitude, err := strconv.ParseFloat(r.URL.Query().Get("longitude"), 32)
+	entries, err := app.repository(r).FindEntriesInBox(r.Context(), box)
 	if err != nil {
-		app.sendFailedValidationResponse(w, map[string]string{"longitude": "longitude not a valid decimal/float"})
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding entries within %v: %v", box, err))
 		return
 	}
 
-	radius, err := strconv.ParseFloat(r.URL.Query().Get("radius"), 64)
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Jobs within box",
+	}, entries)
+}
+
+// maxSampleSize is the largest number of jobs getSampleInBox may be asked for
+const maxSampleSize = 10000
+
+// getSampleInBox fetches at most n jobs spread evenly across a box,
+// one per occupied cell of a grid of about n cells, for heatmap rendering.
+// Request Method: GET
+// Query Parameters:
+//
+//	minLat 		decimal/float
+//	minLon 		decimal/float
+//	maxLat 		decimal/float
+//	maxLon 		decimal/float
+//	n 			integer between 1 and 10000
+//
+// Response Type: application/json
+func (app *App) getSampleInBox(w http.ResponseWriter, r *http.Request) {
+
+	box, ok := app.readBox(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := strconv.Atoi(r.URL.Query().Get("n"))
+	if err != nil || n < 1 || n > maxSampleSize {
+		app.sendFailedValidationResponse(w, validationError("n", codeInvalid,
+			fmt.Sprintf("n must be an integer between 1 and %d", maxSampleSize)))
+		return
+	}
+
+	entries, err := app.repository(r).FindEntriesInBox(r.Context(), box)
 	if err != nil {
-		app.sendFailedValidationResponse(w, map[string]string{"radius": "radius not a valid decimal/float"})
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding entries within %v: %v", box, err))
 		return
 	}
 
-	jobs, err := app.repo.FindJobsNearby(models.Location{
-		Longitude: longitude,
-		Latitude:  latitude,
-	}, radius)
+	jobs := make([]models.Job, len(entries))
+	for i := range entries {
+		jobs[i] = entries[i].Job
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("Sample of %d jobs within box", n),
+	}, models.Sample(box, jobs, n))
+}
+
+// getDensityInBox computes the number of jobs per square kilometer within a box,
+// the area of the box being that of the zone it covers on the sphere, not of its span in degrees.
+// Request Method: GET
+// Query Parameters:
+//
+//	minLat 		decimal/float
+//	minLon 		decimal/float
+//	maxLat 		decimal/float, above minLat
+//	maxLon 		decimal/float, above minLon
+//
+// Response Type: application/json
+func (app *App) getDensityInBox(w http.ResponseWriter, r *http.Request) {
+
+	box, ok := app.readBox(w, r)
+	if !ok {
+		return
+	}
+	if app.repository(r).Sphere().Area(box) == 0 {
+		app.sendFailedValidationResponse(w, validationError("box", codeOutOfRange, "minLat and minLon must be below maxLat and maxLon"))
+		return
+	}
 
+	count, err := app.repository(r).CountJobsInBox(r.Context(), box)
 	if err != nil {
-		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding jobs within a radius of %f", radius))
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered counting jobs within %v: %v", box, err))
 		return
 	}
 
@@ -96,56 +1594,206 @@ func (app *App) getJobsNearby(w http.ResponseWriter, r *http.Request) {
 		writer:     w,
 		statusCode: 200,
 		status:     true,
-		message:    "Jobs around you",
-	}, jobs)
+		message:    "Density of jobs within box",
+	}, models.NewDensity(app.repository(r).Sphere(), box, count))
+}
+
+// defaultGapGrid and maxGapGrid are the default and maximum number of cells
+// getGapsInBox divides each side of a box into
+const (
+	defaultGapGrid = 10
+	maxGapGrid     = 100
+)
+
+// defaultGapCount is the default number of cells getGapsInBox fetches
+const defaultGapCount = 10
+
+// getGapsInBox fetches the cells of a grid laid over a box counting the fewest jobs,
+// from the fewest, e.g., for recruiters to find underserved areas.
+// Cells counting as many jobs are ordered row by row from the south-west corner.
+// meta.emptyCells counts the cells of the grid holding no job.
+// Request Method: GET
+// Query Parameters:
+//
+//	minLat 		decimal/float
+//	minLon 		decimal/float
+//	maxLat 		decimal/float, above minLat
+//	maxLon 		decimal/float, above minLon
+//	grid 		optional integer, the number of cells each side of the box is divided into, default 10, at most 100
+//	limit 		optional integer, the number of cells fetched, default 10, at most grid×grid
+//
+// Response Type: application/json
+func (app *App) getGapsInBox(w http.ResponseWriter, r *http.Request) {
+
+	box, ok := app.readBox(w, r)
+	if !ok {
+		return
+	}
+	if app.repository(r).Sphere().Area(box) == 0 {
+		app.sendFailedValidationResponse(w, validationError("box", codeOutOfRange, "minLat and minLon must be below maxLat and maxLon"))
+		return
+	}
+
+	size := defaultGapGrid
+	if value := r.URL.Query().Get("grid"); !notValidString(value) {
+		var err error
+		size, err = strconv.Atoi(value)
+		if err != nil || size < 1 || size > maxGapGrid {
+			app.sendFailedValidationResponse(w, validationError("grid", codeInvalid,
+				fmt.Sprintf("grid must be an integer between 1 and %d", maxGapGrid)))
+			return
+		}
+	}
+
+	limit := defaultGapCount
+	if value := r.URL.Query().Get("limit"); !notValidString(value) {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > size*size {
+			app.sendFailedValidationResponse(w, validationError("limit", codeInvalid,
+				fmt.Sprintf("limit must be an integer between 1 and %d", size*size)))
+			return
+		}
+	}
+
+	grid := models.NewGrid(box, size, size)
+	if err := app.repository(r).CountJobsInGrid(r.Context(), grid); err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered counting jobs within %v: %v", box, err))
+		return
+	}
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Cells within box having the fewest jobs",
+	}
+	args.addMeta("grid", size)
+	args.addMeta("emptyCells", grid.Empty())
+	app.sendJSONResponse(args, grid.Sparsest(limit))
+}
+
+// getTitleCentroid computes the mean location of the jobs having a title,
+// averaged on the sphere rather than over latitudes and longitudes.
+// Request Method: GET
+// Query Parameters:
+//
+//	title 		string, matched as in top-jobs/around-me
+//
+// Response Type: application/json
+func (app *App) getTitleCentroid(w http.ResponseWriter, r *http.Request) {
+
+	title := r.URL.Query().Get("title")
+	if notValidString(title) {
+		app.sendFailedValidationResponse(w, validationError("title", codeRequired, "title is not a valid text"))
+		return
+	}
+
+	jobs, err := app.repository(r).JobsWithTitle(title)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error fetching jobs titled %v: %v", title, err))
+		return
+	}
+	if len(jobs) == 0 {
+		app.sendNotFoundResponse(w, r)
+		return
+	}
+
+	locations := make([]models.Location, len(jobs))
+	for i, job := range jobs {
+		locations[i] = job.Location
+	}
+
+	app.sendJSONResponse(&responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    fmt.Sprintf("Centroid of %d jobs titled %v", len(jobs), title),
+	}, models.Centroid{Title: title, Count: len(jobs), Location: models.SphericalCentroid(locations)})
 }
 
 // getTopTitleJobsAround fetches up to 5 jobs some arbitrary radius
-// around current location matching the specified title.
+// around current location matching the specified title, company or both.
 // Request Method: GET
 // Query Parameters:
 //
 //	latitude 	decimal/float
 //	longitude 	decimal/float
-//	title 		string
+//	title 		string, optional if company is set
+//	company 	string, optional if title is set
+//	fields 		optional comma-separated list of title, location, company
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//	completeOnly 	optional boolean, if true only jobs having every optional field, e.g., company, are found
+//	label 		optional key:value, repeated to only find jobs having every label
 //
 // Response Type: application/json
+// If no job is found for a title, meta.suggestions lists up to 3 similarly spelled titles.
 func (app *App) getTopTitleJobsAround(w http.ResponseWriter, r *http.Request) {
 
 	// read query paramters
-	latitude, err := strconv.ParseFloat(r.URL.Query().Get("latitude"), 32)
-	if err != nil {
-		app.sendFailedValidationResponse(w, map[string]string{"latitude": "latitude not a valid decimal/float"})
+	center, ok := app.readLocation(w, r)
+	if !ok {
 		return
 	}
 
-	longitude, err := strconv.ParseFloat(r.URL.Query().Get("longitude"), 32)
-	if err != nil {
-		app.sendFailedValidationResponse(w, map[string]string{"longitude": "longitude not a valid decimal/float"})
+	title, company := r.URL.Query().Get("title"), r.URL.Query().Get("company")
+	if notValidString(title) && notValidString(company) {
+		app.sendFailedValidationResponse(w, validationError("title", codeRequired, "either of title or company must be a valid text"))
 		return
 	}
 
-	title := r.URL.Query().Get("title")
-	if notValidString(title) {
-		app.sendFailedValidationResponse(w, map[string]string{"title": "title is not a valid text"})
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	completeOnly, ok := app.readCompleteOnly(w, r)
+	if !ok {
 		return
 	}
 
-	location := models.Location{
-		Longitude: longitude,
-		Latitude:  latitude,
+	labels, ok := app.readLabels(w, r)
+	if !ok {
+		return
 	}
-	jobs, err := app.repo.SearchJobsByTitleAndLocation(title, location)
+
+	jobs, err := app.repository(r).SearchJobsByTitleAndLocation(r.Context(), title, company, center)
 
 	if err != nil {
-		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding %v jobs around %v", title, location))
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding %v jobs at %q around %v", title, company, center))
 		return
 	}
 
-	app.sendJSONResponse(&responseWriterArgs{
+	message := fmt.Sprintf("Top %v Jobs around you", title)
+	switch {
+	case notValidString(title):
+		message = fmt.Sprintf("Top Jobs at %v around you", company)
+	case !notValidString(company):
+		message = fmt.Sprintf("Top %v Jobs at %v around you", title, company)
+	}
+	args := &responseWriterArgs{
 		writer:     w,
 		statusCode: 200,
 		status:     true,
-		message:    fmt.Sprintf("Top %v Jobs around you", title),
-	}, jobs)
+		message:    message,
+	}
+
+	// guide client with similar titles if title matches nothing
+	if len(jobs) == 0 && !notValidString(title) {
+		suggestions, err := app.repository(r).SuggestTitles(title, maxTitleSuggestions)
+		if err != nil {
+			app.sendServerErrorResponse(w, fmt.Errorf("error encountered suggesting titles similar to %v: %v", title, err))
+			return
+		}
+		if len(suggestions) != 0 {
+			args.addMeta("suggestions", suggestions)
+		}
+	}
+
+	if completeOnly {
+		jobs = models.CompleteJobs(jobs)
+	}
+	jobs = models.JobsLabeled(jobs, labels)
+	app.sendJSONResponse(args, fields.projectJobs(jobs))
 }
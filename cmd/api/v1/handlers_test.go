@@ -0,0 +1,1518 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ercross/grabjobs/internal/db"
+	"github.com/ercross/grabjobs/internal/db/memory"
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+)
+
+func TestGetJobsAtTolerance(t *testing.T) {
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}},
+		models.Job{Title: "Cook", Location: models.Location{Longitude: 103.85, Latitude: 1.29}},       // coincident
+		models.Job{Title: "Welder", Location: models.Location{Longitude: 103.85, Latitude: 1.290054}}, // ~6m north
+		models.Job{Title: "Driver", Location: models.Location{Longitude: 103.8501, Latitude: 1.29}},   // ~11m east
+	)
+
+	tests := []struct {
+		tolerance string
+		status    int
+		jobs      int
+	}{
+		{tolerance: "", status: http.StatusOK, jobs: 2},
+		{tolerance: "5.9", status: http.StatusOK, jobs: 2},
+		{tolerance: "6.1", status: http.StatusOK, jobs: 3},
+		{tolerance: "20", status: http.StatusOK, jobs: 4},
+		{tolerance: "0", status: http.StatusUnprocessableEntity},
+		{tolerance: "-1", status: http.StatusUnprocessableEntity},
+		{tolerance: "1001", status: http.StatusUnprocessableEntity},
+	}
+	for _, test := range tests {
+		target := "/api/v1/jobs/at?latitude=1.29&longitude=103.85"
+		if test.tolerance != "" {
+			target += "&tolerance=" + test.tolerance
+		}
+		status, response := serve(t, routes, http.MethodGet, target, "")
+		if status != test.status {
+			t.Errorf("tolerance %q: status %d, want %d", test.tolerance, status, test.status)
+			continue
+		}
+		if status != http.StatusOK {
+			if len(response.Errors) != 1 || response.Errors[0].Field != "tolerance" {
+				t.Errorf("tolerance %q: errors %v, want an error of tolerance", test.tolerance, response.Errors)
+			}
+			continue
+		}
+		var jobs []models.Job
+		decodeData(t, response, &jobs)
+		if len(jobs) != test.jobs {
+			t.Errorf("tolerance %q: found %d jobs, want %d", test.tolerance, len(jobs), test.jobs)
+		}
+	}
+}
+
+func TestMaxRadius(t *testing.T) {
+	jobs := []models.Job{
+		{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}},
+		{Title: "Driver", Location: models.Location{Longitude: 104.05, Latitude: 1.29}}, // ~22km east
+	}
+	for _, clamp := range []bool{true, false} {
+		routes := newTestRoutes(Config{MaxRadiusKm: 10, ClampRadius: clamp}, jobs...)
+		status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=50", "")
+		if !clamp {
+			if status != http.StatusUnprocessableEntity || len(response.Errors) != 1 || response.Errors[0].Code != codeOutOfRange {
+				t.Errorf("reject mode: status %d and errors %v, want 422 with radius out of range", status, response.Errors)
+			}
+			continue
+		}
+		var found []models.Job
+		decodeData(t, response, &found)
+		if status != http.StatusOK || response.Meta["radiusKm"] != 10.0 || len(found) != 1 {
+			t.Errorf("clamp mode: status %d, radius %v and %d jobs, want 200, 10 and 1", status, response.Meta["radiusKm"], len(found))
+		}
+	}
+
+	routes := newTestRoutes(Config{MaxRadiusKm: 10}, jobs...)
+	for _, request := range []struct{ method, target, body string }{
+		{http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=-1", ""},
+		{http.MethodPost, "/api/v1/jobs/near-any", `{"centers": [{"longitude": 103.85, "latitude": 1.29}], "radius": 0}`},
+		{http.MethodPost, "/api/v1/jobs/near-any", `{"centers": [{"longitude": 103.85, "latitude": 1.29}], "radius": -5}`},
+	} {
+		status, response := serve(t, routes, request.method, request.target, request.body)
+		if status != http.StatusUnprocessableEntity || len(response.Errors) != 1 || response.Errors[0].Field != "radius" {
+			t.Errorf("%s %s %s: status %d and errors %v, want 422 with an invalid radius",
+				request.method, request.target, request.body, status, response.Errors)
+		}
+	}
+}
+
+func TestTopTitleJobsSuggestions(t *testing.T) {
+	routes := Routes(newTestDataset(t, db.Options{}, "Software Engineer,103.85,1.29", "Nurse,103.851,1.29"), Config{})
+
+	status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/top-jobs/around-me?latitude=1.29&longitude=103.85&title=Sofware+Engineer", "")
+	suggestions, _ := response.Meta["suggestions"].([]interface{})
+	if status != http.StatusOK || len(suggestions) != 1 || suggestions[0] != "Software Engineer" {
+		t.Errorf("typo'd title: status %d and suggestions %v, want 200 and [Software Engineer]", status, response.Meta["suggestions"])
+	}
+
+	// titles found are not second-guessed
+	status, response = serve(t, routes, http.MethodGet, "/api/v1/jobs/top-jobs/around-me?latitude=1.29&longitude=103.85&title=Nurse", "")
+	if _, ok := response.Meta["suggestions"]; status != http.StatusOK || ok {
+		t.Errorf("title found: status %d and suggestions %v, want 200 and none", status, response.Meta["suggestions"])
+	}
+}
+
+func TestTopJobsByTitleAndCompany(t *testing.T) {
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "Nurse", Company: "St. Mary's", Location: models.Location{Longitude: 103.85, Latitude: 1.29}},
+		models.Job{Title: "Nurse", Company: "Acme", Location: models.Location{Longitude: 103.851, Latitude: 1.29}},
+		models.Job{Title: "Driver", Company: "St. Mary's", Location: models.Location{Longitude: 103.852, Latitude: 1.29}})
+
+	tests := []struct {
+		query  string
+		status int
+		found  int
+	}{
+		{query: "&title=Nurse", status: http.StatusOK, found: 2},
+		{query: "&company=St.+Mary%27s", status: http.StatusOK, found: 2},
+		{query: "&title=Nurse&company=St.+Mary%27s", status: http.StatusOK, found: 1},
+		{query: "", status: http.StatusUnprocessableEntity},
+	}
+	for _, test := range tests {
+		status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/top-jobs/around-me?latitude=1.29&longitude=103.85"+test.query, "")
+		if status != test.status {
+			t.Errorf("%q: status %d, want %d", test.query, status, test.status)
+			continue
+		}
+		if status != http.StatusOK {
+			continue
+		}
+		var jobs []models.Job
+		decodeData(t, response, &jobs)
+		if len(jobs) != test.found {
+			t.Errorf("%q: found %d jobs, want %d", test.query, len(jobs), test.found)
+		}
+	}
+}
+
+func TestMaxResultCount(t *testing.T) {
+	jobs := make([]models.Job, 5)
+	for i := range jobs {
+		jobs[i] = models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85 + float64(i)*0.001, Latitude: 1.29}}
+	}
+	target := "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=5"
+
+	tests := []struct {
+		name     string
+		config   Config
+		status   int
+		jobs     int
+		truncate bool
+	}{
+		{name: "under budget", config: Config{MaxResultCount: 5}, status: http.StatusOK, jobs: 5},
+		{name: "over budget", config: Config{MaxResultCount: 3}, status: http.StatusRequestEntityTooLarge},
+		{name: "over budget truncated", config: Config{MaxResultCount: 3, TruncateResults: true}, status: http.StatusOK, jobs: 3, truncate: true},
+		{name: "no budget", config: Config{}, status: http.StatusOK, jobs: 5},
+	}
+	for _, test := range tests {
+		status, response := serve(t, newTestRoutes(test.config, jobs...), http.MethodGet, target, "")
+		if status != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, status, test.status)
+			continue
+		}
+		if status != http.StatusOK {
+			if response.Status || response.Data != nil && string(response.Data) != "null" {
+				t.Errorf("%s: response %+v, want a failure without data", test.name, response)
+			}
+			continue
+		}
+		var found []models.Job
+		decodeData(t, response, &found)
+		if len(found) != test.jobs {
+			t.Errorf("%s: found %d jobs, want %d", test.name, len(found), test.jobs)
+		}
+		if truncated, _ := response.Meta["truncated"].(bool); truncated != test.truncate {
+			t.Errorf("%s: meta.truncated %v, want %v", test.name, truncated, test.truncate)
+		}
+		if test.truncate && response.Meta["total"] != 5.0 {
+			t.Errorf("%s: meta.total %v, want 5", test.name, response.Meta["total"])
+		}
+	}
+}
+
+func TestJobsNearbyInDirection(t *testing.T) {
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "North", Location: models.Location{Longitude: 103.85, Latitude: 1.33}},
+		models.Job{Title: "East", Location: models.Location{Longitude: 103.89, Latitude: 1.29}},
+		models.Job{Title: "South", Location: models.Location{Longitude: 103.85, Latitude: 1.25}},
+		models.Job{Title: "West", Location: models.Location{Longitude: 103.81, Latitude: 1.29}},
+		models.Job{Title: "NorthEast", Location: models.Location{Longitude: 103.87, Latitude: 1.31}},
+	)
+
+	tests := []struct {
+		direction string
+		tolerance string
+		titles    []string
+	}{
+		{direction: "N", tolerance: "10", titles: []string{"North"}},
+		{direction: "E", tolerance: "10", titles: []string{"East"}},
+		{direction: "S", tolerance: "10", titles: []string{"South"}},
+		{direction: "w", tolerance: "10", titles: []string{"West"}},
+		{direction: "N", titles: []string{"North", "NorthEast"}},
+		{direction: "NE", tolerance: "50", titles: []string{"East", "North", "NorthEast"}},
+	}
+	for _, test := range tests {
+		target := "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=10&direction=" + test.direction
+		if test.tolerance != "" {
+			target += "&bearingTolerance=" + test.tolerance
+		}
+		status, response := serve(t, routes, http.MethodGet, target, "")
+		var jobs []models.Job
+		decodeData(t, response, &jobs)
+		titles := make([]string, len(jobs))
+		for i, job := range jobs {
+			titles[i] = job.Title
+		}
+		sort.Strings(titles)
+		if status != http.StatusOK || !reflect.DeepEqual(titles, test.titles) {
+			t.Errorf("direction %s within %s: status %d and jobs %v, want 200 and %v", test.direction, test.tolerance, status, titles, test.titles)
+		}
+	}
+
+	for _, target := range []string{"direction=up", "direction=N&bearingTolerance=181"} {
+		status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=10&"+target, "")
+		if status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status %d, want 422", target, status)
+		}
+	}
+}
+
+func TestJobsNearbySummary(t *testing.T) {
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.3}},
+		models.Job{Title: "Driver", Location: models.Location{Longitude: 103.85, Latitude: 1.32}},
+	)
+	target := "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=10"
+
+	_, response := serve(t, routes, http.MethodGet, target, "")
+	if _, ok := response.Meta["summary"]; ok {
+		t.Errorf("summary sent without summary=true: %v", response.Meta["summary"])
+	}
+
+	_, response = serve(t, routes, http.MethodGet, target+"&summary=true", "")
+	var summary models.Summary
+	if err := json.Unmarshal(mustMarshal(t, response.Meta["summary"]), &summary); err != nil {
+		t.Fatal(err)
+	}
+	maxDistance := models.Earth.Distance(models.Location{Longitude: 103.85, Latitude: 1.29}, models.Location{Longitude: 103.85, Latitude: 1.32})
+	// coordinates are read from the query at float32 precision, straying under a meter
+	if summary.Count != 3 || summary.MinDistance > 1e-3 || math.Abs(summary.MaxDistance-maxDistance) > 1e-3 || summary.DominantTitle != "Nurse" {
+		t.Errorf("summary %+v, want 3 jobs from 0 to %vkm, mostly Nurse", summary, maxDistance)
+	}
+
+	if _, response = serve(t, routes, http.MethodGet, target+"&summary=1", ""); response.Meta["summary"] == nil {
+		t.Errorf("summary not sent with summary=1")
+	}
+	status, response := serve(t, routes, http.MethodGet, target+"&summary=yes", "")
+	if status != http.StatusUnprocessableEntity || len(response.Errors) != 1 || response.Errors[0].Code != codeInvalid {
+		t.Errorf("summary=yes: status %d and errors %+v, want 422 and an invalid summary", status, response.Errors)
+	}
+}
+
+// mustMarshal marshals v to JSON
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestTitleJobsCursor(t *testing.T) {
+	jobs := make([]models.Job, 0)
+	for _, title := range []string{"Welder", "Nurse", "Cook", "Driver", "Tailor"} {
+		jobs = append(jobs, models.Job{Title: title, Location: models.Location{Longitude: 103.85, Latitude: 1.29}})
+	}
+	routes := newTestRoutes(Config{}, jobs...)
+
+	seen := make([]string, 0)
+	target := "/api/v1/jobs/available?limit=2"
+	for pages := 0; pages < 5; pages++ {
+		status, response := serve(t, routes, http.MethodGet, target, "")
+		if status != http.StatusOK {
+			t.Fatalf("%s: status %d, want 200", target, status)
+		}
+		var page map[string]json.RawMessage
+		decodeData(t, response, &page)
+		keys := make([]string, 0, len(page))
+		for key := range page {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		seen = append(seen, keys...)
+
+		next, _ := response.Meta["nextCursor"].(string)
+		if next == "" {
+			break
+		}
+		target = "/api/v1/jobs/available?limit=2&cursor=" + next
+	}
+	if want := []string{"cook", "driver", "nurse", "tailor", "welder"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("paginated titles %v, want %v", seen, want)
+	}
+
+	if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/available?cursor=%25%25", ""); status != http.StatusUnprocessableEntity {
+		t.Errorf("invalid cursor: status %d, want 422", status)
+	}
+}
+
+func TestTitleJobsSortedByTitle(t *testing.T) {
+	jobs := make([]models.Job, 0)
+	for _, title := range []string{"Welder", "Nurse", "Cook", "Nurse", "Driver"} {
+		jobs = append(jobs, models.Job{Title: title, Location: models.Location{Longitude: 103.85, Latitude: 1.29}})
+	}
+	routes := newTestRoutes(Config{}, jobs...)
+
+	var listed []struct {
+		Title string            `json:"title"`
+		Jobs  []json.RawMessage `json:"jobs"`
+	}
+	for _, target := range []string{"/api/v1/jobs/available?sort=title", "/api/v1/jobs/available?sort=title&limit=10"} {
+		status, response := serve(t, routes, http.MethodGet, target, "")
+		decodeData(t, response, &listed)
+		titles, counts := make([]string, len(listed)), make([]int, len(listed))
+		for i, entry := range listed {
+			titles[i], counts[i] = entry.Title, len(entry.Jobs)
+		}
+		if want := []string{"cook", "driver", "nurse", "welder"}; status != http.StatusOK || !reflect.DeepEqual(titles, want) {
+			t.Errorf("%s: status %d and titles %v, want 200 and %v", target, status, titles, want)
+		}
+		if want := []int{1, 1, 2, 1}; !reflect.DeepEqual(counts, want) {
+			t.Errorf("%s: jobs per title %v, want %v", target, counts, want)
+		}
+	}
+
+	// titles are mapped to their jobs by default
+	var mapped map[string][]json.RawMessage
+	_, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/available", "")
+	decodeData(t, response, &mapped)
+	if len(mapped) != 4 || len(mapped["nurse"]) != 2 {
+		t.Errorf("mapped %d titles and %d nurse jobs, want 4 and 2", len(mapped), len(mapped["nurse"]))
+	}
+
+	if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/available?sort=count", ""); status != http.StatusUnprocessableEntity {
+		t.Errorf("sort=count: status %d, want 422", status)
+	}
+}
+
+func TestTitleJobsLimitAndMinCount(t *testing.T) {
+	jobs := make([]models.Job, 0)
+	for title, count := range map[string]int{"Cook": 1, "Driver": 2, "Nurse": 3, "Tailor": 3, "Welder": 1} {
+		for i := 0; i < count; i++ {
+			jobs = append(jobs, models.Job{Title: title, Location: models.Location{Longitude: 103.85, Latitude: 1.29}})
+		}
+	}
+
+	// listTitles lists the titles of every page from target on, and the number of pages
+	listTitles := func(routes http.Handler, target string) (titles []string, pages int) {
+		t.Helper()
+		next := ""
+		for ; pages == 0 || next != ""; pages++ {
+			page := target
+			if next != "" {
+				page += "&cursor=" + next
+			}
+			status, response := serve(t, routes, http.MethodGet, page, "")
+			if status != http.StatusOK {
+				t.Fatalf("%s: status %d, want 200", page, status)
+			}
+			var titleJobs map[string][]json.RawMessage
+			decodeData(t, response, &titleJobs)
+			keys := make([]string, 0, len(titleJobs))
+			for title := range titleJobs {
+				keys = append(keys, title)
+			}
+			sort.Strings(keys)
+			titles = append(titles, keys...)
+			next, _ = response.Meta["nextCursor"].(string)
+		}
+		return titles, pages
+	}
+
+	tests := []struct {
+		config Config
+		target string
+		titles []string
+		pages  int
+	}{
+		{target: "/api/v1/jobs/available?minCount=1", titles: []string{"cook", "driver", "nurse", "tailor", "welder"}, pages: 1},
+		{target: "/api/v1/jobs/available?minCount=2", titles: []string{"driver", "nurse", "tailor"}, pages: 1},
+		{target: "/api/v1/jobs/available?minCount=3", titles: []string{"nurse", "tailor"}, pages: 1},
+		{target: "/api/v1/jobs/available?limit=2", titles: []string{"cook", "driver", "nurse", "tailor", "welder"}, pages: 3},
+		{target: "/api/v1/jobs/available?limit=2&minCount=2", titles: []string{"driver", "nurse", "tailor"}, pages: 3},
+		{config: Config{MaxAvailableTitles: 3}, target: "/api/v1/jobs/available?minCount=1",
+			titles: []string{"cook", "driver", "nurse", "tailor", "welder"}, pages: 2},
+	}
+	for _, test := range tests {
+		titles, pages := listTitles(newTestRoutes(test.config, jobs...), test.target)
+		if !reflect.DeepEqual(titles, test.titles) || pages != test.pages {
+			t.Errorf("%s: listed %v in %d pages, want %v in %d", test.target, titles, pages, test.titles, test.pages)
+		}
+	}
+
+	for _, query := range []string{"limit=0", "limit=501", "minCount=0", "minCount=many"} {
+		if status, _ := serve(t, newTestRoutes(Config{}, jobs...), http.MethodGet, "/api/v1/jobs/available?"+query, ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status %d, want 422", query, status)
+		}
+	}
+	if status, _ := serve(t, newTestRoutes(Config{MaxAvailableTitles: 3}, jobs...), http.MethodGet, "/api/v1/jobs/available?limit=4", ""); status != http.StatusUnprocessableEntity {
+		t.Errorf("limit over MaxAvailableTitles: status %d, want 422", status)
+	}
+}
+
+func TestCreateJobsValidation(t *testing.T) {
+	const (
+		valid   = `{"title": "Nurse", "location": {"longitude": 103.85, "latitude": 1.29}}`
+		noTitle = `{"title": "", "location": {"longitude": 103.85, "latitude": 1.29}}`
+		outside = `{"title": "Driver", "location": {"longitude": 181, "latitude": 1.29}}`
+	)
+	tests := []struct {
+		name    string
+		batch   string
+		partial bool
+		status  int
+		added   int
+		errors  []string
+	}{
+		{name: "all valid", batch: "[" + valid + "," + valid + "]", status: http.StatusCreated, added: 2},
+		{name: "all valid", batch: "[" + valid + "," + valid + "]", partial: true, status: http.StatusCreated, added: 2},
+		{name: "all invalid", batch: "[" + noTitle + "," + outside + "]", status: http.StatusUnprocessableEntity,
+			errors: []string{"jobs[0].title", "jobs[1].location.longitude"}},
+		{name: "all invalid", batch: "[" + noTitle + "," + outside + "]", partial: true, status: http.StatusUnprocessableEntity,
+			errors: []string{"jobs[0].title", "jobs[1].location.longitude"}},
+		{name: "mixed", batch: "[" + valid + "," + outside + "," + valid + "]", status: http.StatusUnprocessableEntity,
+			errors: []string{"jobs[1].location.longitude"}},
+		{name: "mixed", batch: "[" + valid + "," + outside + "," + valid + "]", partial: true, status: http.StatusCreated, added: 2,
+			errors: []string{"jobs[1].location.longitude"}},
+	}
+	for _, test := range tests {
+		repo := memory.NewMemoryRepository()
+		target := "/api/v1/jobs/bulk"
+		if test.partial {
+			target += "?partial=true"
+		}
+		status, response := serve(t, Routes(repo, Config{}), http.MethodPost, target, test.batch)
+		if status != test.status {
+			t.Errorf("%s, partial %v: status %d, want %d", test.name, test.partial, status, test.status)
+			continue
+		}
+
+		errors := response.Errors
+		if status == http.StatusCreated {
+			errors = nil
+			if reported, ok := response.Meta["errors"]; ok {
+				if err := json.Unmarshal(mustMarshal(t, reported), &errors); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		fields := make([]string, 0, len(errors))
+		for _, err := range errors {
+			fields = append(fields, err.Field)
+		}
+		if len(fields) != len(test.errors) || len(fields) != 0 && !reflect.DeepEqual(fields, test.errors) {
+			t.Errorf("%s, partial %v: errors of %v, want %v", test.name, test.partial, fields, test.errors)
+		}
+
+		// rejected batches leave the repository untouched
+		if stats, _ := repo.Stats(); stats.JobCount != test.added {
+			t.Errorf("%s, partial %v: %d jobs added, want %d", test.name, test.partial, stats.JobCount, test.added)
+		}
+	}
+}
+
+func TestGetJobByID(t *testing.T) {
+	repo := memory.NewMemoryRepository(models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}})
+	titleJobs, _ := repo.TitleJobs()
+	id := titleJobs["nurse"][0].ID
+	routes := Routes(repo, Config{})
+
+	status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/id/"+id, "")
+	var job models.Job
+	decodeData(t, response, &job)
+	if status != http.StatusOK || job.ID != id || job.Title != "Nurse" {
+		t.Errorf("hit: status %d and job %v, want 200 and the job %q", status, job, id)
+	}
+
+	if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/id/missing", ""); status != http.StatusNotFound {
+		t.Errorf("miss: status %d, want 404", status)
+	}
+}
+
+func TestMoreJobsNearby(t *testing.T) {
+	jobs := make([]models.Job, 0)
+	for _, offset := range []float64{0.05, 0.01, 0.03, 0.07, 0.02, 0.06, 0.04, 0.5} { // the last one ~55km away
+		jobs = append(jobs, models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85 + offset, Latitude: 1.29}})
+	}
+	routes := newTestRoutes(Config{}, jobs...)
+
+	seen := make([]string, 0)
+	lastDistance := 0.0
+	remaining := []float64{3, 0}
+	for page := 0; page < 2; page++ {
+		body := mustMarshal(t, map[string]interface{}{
+			"center": models.Location{Longitude: 103.85, Latitude: 1.29},
+			"radius": 10,
+			"seen":   seen,
+			"limit":  4,
+		})
+		status, response := serve(t, routes, http.MethodPost, "/api/v1/jobs/nearby/more", string(body))
+		var batch []models.JobWithDistance
+		decodeData(t, response, &batch)
+		if status != http.StatusOK || len(batch) != 4-page || response.Meta["remaining"] != remaining[page] {
+			t.Fatalf("page %d: status %d, %d jobs and %v remaining, want 200, %d jobs and %v remaining",
+				page, status, len(batch), response.Meta["remaining"], 4-page, remaining[page])
+		}
+		for _, job := range batch {
+			for _, id := range seen {
+				if job.ID == id {
+					t.Errorf("page %d: job %q seen on a previous page", page, job.ID)
+				}
+			}
+			if job.Distance < lastDistance {
+				t.Errorf("page %d: job %q at %vkm after a job at %vkm", page, job.ID, job.Distance, lastDistance)
+			}
+			lastDistance = job.Distance
+			seen = append(seen, job.ID)
+		}
+	}
+	if len(seen) != 7 {
+		t.Errorf("pages covered %d jobs, want the 7 within radius", len(seen))
+	}
+}
+
+func TestIncludeDistance(t *testing.T) {
+	// jobs are stored from the farthest, the order the memory repository finds them in
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "Far", Location: models.Location{Longitude: 103.88, Latitude: 1.29}},
+		models.Job{Title: "Middle", Location: models.Location{Longitude: 103.86, Latitude: 1.29}},
+		models.Job{Title: "Near", Location: models.Location{Longitude: 103.851, Latitude: 1.29}},
+	)
+	target := "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=10"
+
+	tests := []struct {
+		query    string
+		titles   []string
+		distance bool
+	}{
+		{query: "", titles: []string{"Near", "Middle", "Far"}, distance: true},
+		{query: "&includeDistance=true", titles: []string{"Near", "Middle", "Far"}, distance: true},
+		{query: "&includeDistance=false", titles: []string{"Far", "Middle", "Near"}, distance: false},
+	}
+	for _, test := range tests {
+		status, response := serve(t, routes, http.MethodGet, target+test.query, "")
+		var jobs []map[string]json.RawMessage
+		decodeData(t, response, &jobs)
+		titles := make([]string, len(jobs))
+		for i, job := range jobs {
+			if err := json.Unmarshal(job["title"], &titles[i]); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := job["distance"]; ok != test.distance {
+				t.Errorf("%q: job %s has distance %v, want %v", test.query, titles[i], ok, test.distance)
+			}
+		}
+		if status != http.StatusOK || !reflect.DeepEqual(titles, test.titles) {
+			t.Errorf("%q: status %d and jobs %v, want 200 and %v", test.query, status, titles, test.titles)
+		}
+	}
+
+	status, _ := serve(t, routes, http.MethodGet, target+"&includeDistance=false&fields=title,distance", "")
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("distance field without distances: status %d, want 422", status)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	repo := newTestDataset(t, db.Options{DefaultRadius: models.Distance{Unit: models.Kilometer, Value: 7}},
+		"Nurse,103.5,1.25", "Driver,104.25,1.5", "nurse,103.75,2.25", "Cook,103.75,1.5")
+	status, response := serve(t, Routes(repo, Config{}), http.MethodGet, "/api/v1/jobs/bootstrap", "")
+	if status != http.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+
+	var sections map[string]json.RawMessage
+	decodeData(t, response, &sections)
+	for _, section := range []string{"boundingBox", "titles", "defaultRadiusKm"} {
+		if _, ok := sections[section]; !ok {
+			t.Errorf("bootstrap lacks %s", section)
+		}
+	}
+
+	var bootstrap struct {
+		BoundingBox     models.Box   `json:"boundingBox"`
+		Titles          []titleCount `json:"titles"`
+		DefaultRadiusKm float64      `json:"defaultRadiusKm"`
+	}
+	decodeData(t, response, &bootstrap)
+	box := models.Box{Min: models.Location{Longitude: 103.5, Latitude: 1.25}, Max: models.Location{Longitude: 104.25, Latitude: 2.25}}
+	if bootstrap.BoundingBox != box {
+		t.Errorf("bounding box %+v, want %+v", bootstrap.BoundingBox, box)
+	}
+	titles := []titleCount{{Title: "nurse", Count: 2}, {Title: "cook", Count: 1}, {Title: "driver", Count: 1}}
+	if !reflect.DeepEqual(bootstrap.Titles, titles) {
+		t.Errorf("titles %v, want %v", bootstrap.Titles, titles)
+	}
+	if bootstrap.DefaultRadiusKm != 7 {
+		t.Errorf("default radius %vkm, want 7km", bootstrap.DefaultRadiusKm)
+	}
+}
+
+func TestZeroRadiusSearchesDefault(t *testing.T) {
+	repo := newTestDataset(t, db.Options{DefaultRadius: models.Distance{Unit: models.Kilometer, Value: 3}},
+		"Nurse,103.85,1.29", "Driver,103.87,1.29", "Cook,103.9,1.29") // ~2.2km and ~5.6km east
+	routes := Routes(repo, Config{})
+
+	for _, query := range []string{"", "&radius=0"} {
+		status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85"+query, "")
+		var jobs []models.Job
+		decodeData(t, response, &jobs)
+		if status != http.StatusOK || len(jobs) != 2 || response.Meta["radiusKm"] != 3.0 {
+			t.Errorf("%q: status %d, %d jobs within %vkm, want 200 and 2 jobs within the default 3km",
+				query, status, len(jobs), response.Meta["radiusKm"])
+		}
+	}
+}
+
+func TestSampleInBox(t *testing.T) {
+	var jobs []models.Job
+	for i := 0; i < 400; i++ {
+		jobs = append(jobs, models.Job{
+			ID:       strconv.Itoa(i),
+			Title:    "Nurse",
+			Location: models.Location{Longitude: 103.5 + float64(i%20)*0.05, Latitude: 1 + float64(i/20)*0.05},
+		})
+	}
+	routes := newTestRoutes(Config{}, jobs...)
+
+	const box = "/api/v1/jobs/sample?minLat=1&minLon=103.5&maxLat=2&maxLon=104.5"
+	for _, n := range []int{1, 9, 25} {
+		status, response := serve(t, routes, http.MethodGet, box+"&n="+strconv.Itoa(n), "")
+		var sample []models.Job
+		decodeData(t, response, &sample)
+		if status != http.StatusOK || len(sample) != n {
+			t.Errorf("n=%d: status %d, %d jobs sampled, want 200 and %d", n, status, len(sample), n)
+		}
+	}
+
+	for _, n := range []string{"0", "10001", "many"} {
+		if status, _ := serve(t, routes, http.MethodGet, box+"&n="+n, ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("n=%s: status %d, want %d", n, status, http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+func TestTitleAutocomplete(t *testing.T) {
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.86, Latitude: 1.29}},
+		models.Job{Title: "Nutritionist", Location: models.Location{Longitude: 103.87, Latitude: 1.29}},
+		models.Job{Title: "Nursery Teacher", Location: models.Location{Longitude: 103.88, Latitude: 1.29}},
+		models.Job{Title: "Driver", Location: models.Location{Longitude: 103.89, Latitude: 1.29}})
+
+	tests := []struct {
+		query  string
+		titles []titleCount
+	}{
+		{query: "prefix=Nu", titles: []titleCount{{Title: "nurse", Count: 2}, {Title: "nursery teacher", Count: 1}, {Title: "nutritionist", Count: 1}}},
+		{query: "prefix=nu&limit=2", titles: []titleCount{{Title: "nurse", Count: 2}, {Title: "nursery teacher", Count: 1}}},
+		{query: "prefix=pilot", titles: []titleCount{}},
+	}
+	for _, test := range tests {
+		status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/autocomplete?"+test.query, "")
+		var titles []titleCount
+		decodeData(t, response, &titles)
+		if status != http.StatusOK || !reflect.DeepEqual(titles, test.titles) {
+			t.Errorf("%q: status %d and titles %v, want 200 and %v", test.query, status, titles, test.titles)
+		}
+	}
+
+	for _, query := range []string{"", "prefix=nu&limit=0", "prefix=nu&limit=51"} {
+		if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/autocomplete?"+query, ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("%q: status %d, want %d", query, status, http.StatusUnprocessableEntity)
+		}
+	}
+}
+
+func TestGeometryLimits(t *testing.T) {
+	routes := newTestRoutes(Config{MaxGeometryVertices: 5, MaxBoxAreaKm2: 1000},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}})
+
+	// polygon returns a geofence named name of the locations at every angle of step degrees
+	// on a circle of radius degrees around the job
+	polygon := func(name string, radius float64, step int) string {
+		var vertices []models.Location
+		for angle := 0; angle < 360; angle += step {
+			radians := float64(angle) * math.Pi / 180
+			vertices = append(vertices, models.Location{
+				Longitude: 103.85 + radius*math.Cos(radians),
+				Latitude:  1.29 + radius*math.Sin(radians),
+			})
+		}
+		return string(mustMarshal(t, map[string]interface{}{"name": name, "vertices": vertices}))
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		status   int
+		exceeded int
+	}{
+		{name: "valid polygon", method: http.MethodPost, target: "/api/v1/geofences", body: polygon("valid", 0.05, 90), status: http.StatusCreated},
+		{name: "polygon of many vertices", method: http.MethodPost, target: "/api/v1/geofences", body: polygon("many", 0.05, 10),
+			status: http.StatusUnprocessableEntity, exceeded: 1},
+		{name: "wide polygon", method: http.MethodPost, target: "/api/v1/geofences", body: polygon("wide", 1, 90),
+			status: http.StatusUnprocessableEntity, exceeded: 1},
+		{name: "wide polygon of many vertices", method: http.MethodPost, target: "/api/v1/geofences", body: polygon("both", 1, 10),
+			status: http.StatusUnprocessableEntity, exceeded: 2},
+		{name: "valid box", method: http.MethodGet, target: "/api/v1/jobs/entries?minLat=1.28&minLon=103.84&maxLat=1.3&maxLon=103.86", status: http.StatusOK},
+		{name: "wide box", method: http.MethodGet, target: "/api/v1/jobs/entries?minLat=1&minLon=103&maxLat=2&maxLon=104",
+			status: http.StatusUnprocessableEntity, exceeded: 1},
+	}
+	for _, test := range tests {
+		status, response := serve(t, routes, test.method, test.target, test.body)
+		if status != test.status || len(response.Errors) != test.exceeded {
+			t.Errorf("%s: status %d and %d errors, want %d and %d", test.name, status, len(response.Errors), test.status, test.exceeded)
+		}
+		for _, err := range response.Errors {
+			if err.Code != codeOutOfRange {
+				t.Errorf("%s: error code %q, want %q", test.name, err.Code, codeOutOfRange)
+			}
+		}
+	}
+}
+
+func TestNearestJobsInBox(t *testing.T) {
+	routes := Routes(newTestDataset(t, db.Options{}, "Nurse,103.851,1.29", "Driver,103.9,1.3", "Cook,103.95,1.3", "Welder,104.2,1.3"), Config{})
+	target := "/api/v1/jobs/nearest?latitude=1.29&longitude=103.85&k=2"
+
+	status, response := serve(t, routes, http.MethodGet, target+"&minLat=1.28&minLon=103.88&maxLat=1.32&maxLon=104", "")
+	var jobs []models.JobWithDistance
+	decodeData(t, response, &jobs)
+	titles := make([]string, len(jobs))
+	for i, job := range jobs {
+		titles[i] = job.Title
+	}
+	if want := []string{"Driver", "Cook"}; status != http.StatusOK || !reflect.DeepEqual(titles, want) {
+		t.Errorf("status %d and nearest jobs in box %v, want 200 and %v", status, titles, want)
+	}
+
+	if status, _ := serve(t, routes, http.MethodGet, target+"&minLat=1.28&minLon=103.88", ""); status != http.StatusUnprocessableEntity {
+		t.Errorf("half a box: status %d, want 422", status)
+	}
+}
+
+// nearestPages returns the IDs of the jobs found at target, following every page of k jobs
+func nearestPages(t *testing.T, routes http.Handler, target string, k int) []string {
+	t.Helper()
+	var ids []string
+	cursor := ""
+	for pages := 0; pages <= 500; pages++ {
+		page := fmt.Sprintf("%s&k=%d", target, k)
+		if cursor != "" {
+			page += "&cursor=" + url.QueryEscape(cursor)
+		}
+		status, response := serve(t, routes, http.MethodGet, page, "")
+		if status != http.StatusOK {
+			t.Fatalf("%s: status %d, want 200", page, status)
+		}
+		var jobs []models.JobWithDistance
+		decodeData(t, response, &jobs)
+		for _, job := range jobs {
+			ids = append(ids, job.ID)
+		}
+		if cursor, _ = response.Meta["nextCursor"].(string); cursor == "" {
+			return ids
+		}
+	}
+	t.Fatalf("%s: more than 500 pages of %d jobs", target, k)
+	return nil
+}
+
+func TestNearestJobsPages(t *testing.T) {
+	random := rand.New(rand.NewSource(1454))
+	var jobs []models.Job
+	for i := 0; i < 300; i++ {
+		jobs = append(jobs, models.Job{
+			Title:    fmt.Sprintf("Title %d", i%5),
+			Location: models.Location{Longitude: 103.8 + random.Float64()*0.1, Latitude: 1.25 + random.Float64()*0.1},
+		})
+	}
+	// co-located jobs are ordered by title, then by ID, across pages
+	for i := 0; i < 41; i++ {
+		jobs = append(jobs, models.Job{Title: fmt.Sprintf("Title %d", i%3), Location: models.Location{Longitude: 103.85, Latitude: 1.3}})
+	}
+	lines := make([]string, len(jobs))
+	for i, job := range jobs {
+		lines[i] = fmt.Sprintf("%s,%f,%f", job.Title, job.Location.Longitude, job.Location.Latitude)
+	}
+	repositories := map[string]http.Handler{
+		"db":     Routes(newTestDataset(t, db.Options{}, lines...), Config{}),
+		"memory": newTestRoutes(Config{}, jobs...),
+	}
+
+	for name, routes := range repositories {
+		for _, target := range []string{
+			"/api/v1/jobs/nearest?latitude=1.3&longitude=103.851",
+			"/api/v1/jobs/nearest?latitude=1.3&longitude=103.851&minLat=1.27&minLon=103.82&maxLat=1.33&maxLon=103.88",
+		} {
+			want := nearestPages(t, routes, target, maxNearestCount)
+			if len(want) == 0 {
+				t.Fatalf("%s %s: no jobs found", name, target)
+			}
+			for _, k := range []int{1, 3, 7, 50} {
+				if got := nearestPages(t, routes, target, k); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s %s: pages of %d found %d jobs, want the %d of a single call in order", name, target, k, len(got), len(want))
+				}
+			}
+		}
+
+		if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearest?latitude=1.3&longitude=103.851&cursor=nonsense", ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: invalid cursor: status %d, want 422", name, status)
+		}
+	}
+}
+
+func TestAntimeridian(t *testing.T) {
+	lines := []string{"East,179.9,0", "West,-179.9,0.1", "Middle,0,0", "Pole,100,89.95", "Opposite,-80,89.95"}
+
+	for _, policy := range []AntimeridianPolicy{AntimeridianSplit, AntimeridianReject} {
+		routes := Routes(newTestDataset(t, db.Options{}, lines...), Config{Antimeridian: policy})
+
+		// radius searches straddling the antimeridian or near a pole find jobs across them whatever the policy
+		for target, want := range map[string][]string{
+			"/api/v1/jobs/nearby?latitude=0&longitude=179.99&radius=30":   {"East", "West"},
+			"/api/v1/jobs/nearby?latitude=0&longitude=-179.99&radius=30":  {"East", "West"},
+			"/api/v1/jobs/nearby?latitude=89.99&longitude=0&radius=20":    {"Opposite", "Pole"},
+			"/api/v1/jobs/nearby?latitude=89.9&longitude=100&radius=10":   {"Pole"},
+			"/api/v1/jobs/nearby?latitude=0&longitude=179.99&radius=0.5":  nil,
+			"/api/v1/jobs/nearby?latitude=89.97&longitude=-100&radius=10": {"Opposite", "Pole"},
+		} {
+			status, response := serve(t, routes, http.MethodGet, target, "")
+			var jobs []models.Job
+			decodeData(t, response, &jobs)
+			var titles []string
+			for _, job := range jobs {
+				titles = append(titles, job.Title)
+			}
+			sort.Strings(titles)
+			if status != http.StatusOK || !reflect.DeepEqual(titles, want) {
+				t.Errorf("%q: %s: status %d and jobs %v, want 200 and %v", policy, target, status, titles, want)
+			}
+		}
+
+		// a box whose minLon exceeds its maxLon crosses the antimeridian
+		status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/entries?minLat=-1&minLon=179.5&maxLat=1&maxLon=-179.5", "")
+		if policy == AntimeridianReject {
+			if status != http.StatusUnprocessableEntity {
+				t.Errorf("%q: status %d for a box crossing the antimeridian, want 422", policy, status)
+			}
+			continue
+		}
+		var entries []rtree.EntryView
+		decodeData(t, response, &entries)
+		var titles []string
+		for _, entry := range entries {
+			titles = append(titles, entry.Job.Title)
+		}
+		sort.Strings(titles)
+		if want := []string{"East", "West"}; status != http.StatusOK || !reflect.DeepEqual(titles, want) {
+			t.Errorf("%q: status %d and jobs %v in a box crossing the antimeridian, want 200 and %v", policy, status, titles, want)
+		}
+	}
+
+	for policy, want := range map[string]AntimeridianPolicy{"split": AntimeridianSplit, "reject": AntimeridianReject} {
+		if parsed, ok := ParseAntimeridianPolicy(policy); !ok || parsed != want {
+			t.Errorf("ParseAntimeridianPolicy(%q) = %q, %v, want %q", policy, parsed, ok, want)
+		}
+	}
+	if _, ok := ParseAntimeridianPolicy("wrap"); ok {
+		t.Error("ParseAntimeridianPolicy(wrap) succeeded, want it rejected")
+	}
+}
+
+func TestNearbySortBy(t *testing.T) {
+	created := func(minutes int) *time.Time {
+		at := time.Date(2026, 10, 17, 9, minutes, 0, 0, time.UTC)
+		return &at
+	}
+	// nearest first: Nurse, Driver, Cook, Welder, then Porter
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.851, Latitude: 1.29}, Labels: map[string]string{"salary": "3000"}, CreatedAt: created(0)},
+		models.Job{Title: "Driver", Location: models.Location{Longitude: 103.852, Latitude: 1.29}, Labels: map[string]string{"salary": "5000"}, CreatedAt: created(2)},
+		models.Job{Title: "Cook", Location: models.Location{Longitude: 103.853, Latitude: 1.29}, Labels: map[string]string{"salary": "4000"}, CreatedAt: created(1)},
+		models.Job{Title: "Welder", Location: models.Location{Longitude: 103.854, Latitude: 1.29}},
+		models.Job{Title: "Porter", Location: models.Location{Longitude: 103.855, Latitude: 1.29}, Labels: map[string]string{"salary": "6000"}},
+	)
+	const target = "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=5"
+
+	tests := []struct {
+		query  string
+		titles []string
+		order  string
+	}{
+		{query: "", titles: []string{"Nurse", "Driver", "Cook", "Welder", "Porter"}},
+		{query: "&sortBy=distance", titles: []string{"Nurse", "Driver", "Cook", "Welder", "Porter"}, order: "asc"},
+		{query: "&sortBy=distance&order=desc", titles: []string{"Porter", "Welder", "Cook", "Driver", "Nurse"}, order: "desc"},
+		{query: "&sortBy=salary", titles: []string{"Porter", "Driver", "Cook", "Nurse", "Welder"}, order: "desc"},
+		{query: "&sortBy=salary&order=asc", titles: []string{"Nurse", "Cook", "Driver", "Porter", "Welder"}, order: "asc"},
+		{query: "&sortBy=recent", titles: []string{"Driver", "Cook", "Nurse", "Welder", "Porter"}, order: "desc"},
+		{query: "&sortBy=recent&order=asc", titles: []string{"Welder", "Porter", "Nurse", "Cook", "Driver"}, order: "asc"},
+		{query: "&sortBy=salary&includeDistance=false", titles: []string{"Porter", "Driver", "Cook", "Nurse", "Welder"}, order: "desc"},
+		{query: "&sortBy=recent&order=asc&includeDistance=false", titles: []string{"Welder", "Porter", "Nurse", "Cook", "Driver"}, order: "asc"},
+	}
+	for _, test := range tests {
+		status, response := serve(t, routes, http.MethodGet, target+test.query, "")
+		var jobs []models.Job
+		decodeData(t, response, &jobs)
+		titles := make([]string, len(jobs))
+		for i, job := range jobs {
+			titles[i] = job.Title
+		}
+		if status != http.StatusOK || !reflect.DeepEqual(titles, test.titles) {
+			t.Errorf("%q: status %d and jobs %v, want 200 and %v", test.query, status, titles, test.titles)
+		}
+		if order, _ := response.Meta["order"].(string); order != test.order {
+			t.Errorf("%q: meta.order %q, want %q", test.query, order, test.order)
+		}
+	}
+
+	for _, query := range []string{"&sortBy=title", "&order=up", "&sortBy=distance&includeDistance=false"} {
+		if status, _ := serve(t, routes, http.MethodGet, target+query, ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("%q: status %d, want 422", query, status)
+		}
+	}
+}
+
+func TestNearbyCoverage(t *testing.T) {
+	routes := newTestRoutes(Config{}, models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}})
+	target := "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=3"
+
+	_, response := serve(t, routes, http.MethodGet, target+"&includeCoverage=true", "")
+	var coverage models.Polygon
+	if err := json.Unmarshal(mustMarshal(t, response.Meta["coverage"]), &coverage); err != nil {
+		t.Fatal(err)
+	}
+	if len(coverage.Coordinates) != 1 || len(coverage.Coordinates[0]) != coverageSegments+1 {
+		t.Fatalf("coverage %+v, want a ring of %d positions", coverage, coverageSegments+1)
+	}
+	center := models.Location{Longitude: 103.85, Latitude: 1.29}
+	for _, position := range coverage.Coordinates[0] {
+		if distance := models.Earth.Distance(center, models.Location{Longitude: position[0], Latitude: position[1]}); math.Abs(distance-3) > 1e-3 {
+			t.Errorf("coverage vertex %v lies %vkm away of the center, want 3km", position, distance)
+		}
+	}
+
+	if _, response := serve(t, routes, http.MethodGet, target, ""); response.Meta["coverage"] != nil {
+		t.Errorf("coverage %v not asked for", response.Meta["coverage"])
+	}
+}
+
+func TestNearbyTravelTime(t *testing.T) {
+	jobs := []models.Job{
+		{Title: "Nurse", Location: models.Location{Longitude: 103.86, Latitude: 1.29}},
+		{Title: "Driver", Location: models.Location{Longitude: 103.9, Latitude: 1.3}},
+	}
+	tests := []struct {
+		config Config
+		mode   string
+		speed  float64
+	}{
+		{mode: "walk", speed: defaultWalkSpeedKmh},
+		{mode: "drive", speed: defaultDriveSpeedKmh},
+		{config: Config{WalkSpeedKmh: 4}, mode: "walk", speed: 4},
+		{config: Config{DriveSpeedKmh: 60}, mode: "Drive", speed: 60},
+	}
+	for _, test := range tests {
+		status, response := serve(t, newTestRoutes(test.config, jobs...), http.MethodGet,
+			"/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=10&mode="+test.mode, "")
+		if status != http.StatusOK || response.Meta["speedKmh"] != test.speed {
+			t.Errorf("mode=%s: status %d and speed %vkm/h, want 200 and %vkm/h", test.mode, status, response.Meta["speedKmh"], test.speed)
+			continue
+		}
+		if minutes := response.Meta["travelMinutes"]; minutes != 10/test.speed*60 {
+			t.Errorf("mode=%s: radius traveled in %v minutes, want %v", test.mode, minutes, 10/test.speed*60)
+		}
+
+		var found []struct {
+			Distance      float64 `json:"distance"`
+			TravelMinutes float64 `json:"travelMinutes"`
+		}
+		decodeData(t, response, &found)
+		if len(found) != len(jobs) {
+			t.Fatalf("mode=%s: found %d jobs, want %d", test.mode, len(found), len(jobs))
+		}
+		for _, job := range found {
+			if want := job.Distance / test.speed * 60; job.Distance == 0 || math.Abs(job.TravelMinutes-want) > 1e-9 {
+				t.Errorf("mode=%s: job %vkm away reached in %v minutes, want %v", test.mode, job.Distance, job.TravelMinutes, want)
+			}
+		}
+	}
+
+	if status, _ := serve(t, newTestRoutes(Config{}, jobs...), http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&mode=fly", ""); status != http.StatusUnprocessableEntity {
+		t.Errorf("mode=fly: status %d, want 422", status)
+	}
+}
+
+func TestReachableJobs(t *testing.T) {
+	var jobs []models.Job
+	for i := 0; i < 200; i++ {
+		jobs = append(jobs, models.Job{
+			Title:    "Nurse",
+			Location: models.Location{Longitude: 103.85 + float64(i%20-10)*0.01, Latitude: 1.29 + float64(i/20-5)*0.01},
+		})
+	}
+	routes := newTestRoutes(Config{}, jobs...)
+	ids := func(response testResponse) []string {
+		var found []struct {
+			ID string `json:"id"`
+		}
+		decodeData(t, response, &found)
+		ids := make([]string, len(found))
+		for i, job := range found {
+			ids[i] = job.ID
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	tests := []struct {
+		mode    string
+		minutes float64
+		speed   float64
+	}{
+		{mode: "walk", minutes: 10, speed: defaultWalkSpeedKmh},
+		{mode: "walk", minutes: 45, speed: defaultWalkSpeedKmh},
+		{mode: "drive", minutes: 3, speed: defaultDriveSpeedKmh},
+		{mode: "drive", minutes: 0.5, speed: defaultDriveSpeedKmh},
+	}
+	for _, test := range tests {
+		status, reachable := serve(t, routes, http.MethodGet,
+			fmt.Sprintf("/api/v1/jobs/reachable?latitude=1.29&longitude=103.85&minutes=%v&mode=%s&fields=title", test.minutes, test.mode), "")
+		radius := test.speed * test.minutes / 60
+		if status != http.StatusOK || reachable.Meta["radiusKm"] != radius {
+			t.Errorf("%v minutes of %s: status %d and radius %vkm, want 200 and %vkm", test.minutes, test.mode, status, reachable.Meta["radiusKm"], radius)
+			continue
+		}
+
+		_, nearby := serve(t, routes, http.MethodGet,
+			fmt.Sprintf("/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=%v&inclusive=true&fields=title", radius), "")
+		if got, want := ids(reachable), ids(nearby); len(got) == 0 || len(got) == len(jobs) || !reflect.DeepEqual(got, want) {
+			t.Errorf("%v minutes of %s: reached %d jobs, want the %d within %vkm", test.minutes, test.mode, len(got), len(want), radius)
+		}
+	}
+
+	for _, minutes := range []string{"0", "-5", "soon"} {
+		if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/reachable?latitude=1.29&longitude=103.85&minutes="+minutes, ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("minutes=%s: status %d, want 422", minutes, status)
+		}
+	}
+}
+
+func TestLabelFilter(t *testing.T) {
+	at := models.Location{Longitude: 103.85, Latitude: 1.29}
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "Nurse", Location: at, Labels: map[string]string{"shift": "night", "remote": "false"}},
+		models.Job{Title: "Driver", Location: at, Labels: map[string]string{"shift": "night", "remote": "true"}},
+		models.Job{Title: "Cook", Location: at, Labels: map[string]string{"shift": "day"}},
+		models.Job{Title: "Tailor", Location: at},
+	)
+	tests := []struct {
+		labels string
+		titles []string
+	}{
+		{labels: "", titles: []string{"Cook", "Driver", "Nurse", "Tailor"}},
+		{labels: "&label=shift:night", titles: []string{"Driver", "Nurse"}},
+		{labels: "&label=shift:day", titles: []string{"Cook"}},
+		{labels: "&label=shift:night&label=remote:true", titles: []string{"Driver"}},
+		{labels: "&label=shift:day&label=remote:true", titles: []string{}},
+		{labels: "&label=shift:night&label=shift:night", titles: []string{"Driver", "Nurse"}},
+	}
+	for _, test := range tests {
+		status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=1"+test.labels, "")
+		if status != http.StatusOK {
+			t.Errorf("%q: status %d, want 200", test.labels, status)
+			continue
+		}
+		var found []models.Job
+		decodeData(t, response, &found)
+		titles := make([]string, 0, len(found))
+		for _, job := range found {
+			titles = append(titles, job.Title)
+		}
+		sort.Strings(titles)
+		if !reflect.DeepEqual(titles, test.titles) {
+			t.Errorf("%q: found %v, want %v", test.labels, titles, test.titles)
+		}
+	}
+
+	for _, labels := range []string{"&label=night", "&label=:night", "&label=shift:night&label=shift:day"} {
+		if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=1"+labels, ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("%q: status %d, want 422", labels, status)
+		}
+	}
+}
+
+func TestNearestPerTitle(t *testing.T) {
+	var jobs []models.Job
+	for i := 0; i < 6; i++ {
+		jobs = append(jobs,
+			models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85 + float64(6-i)*0.01, Latitude: 1.29}},
+			models.Job{Title: "Driver", Location: models.Location{Longitude: 103.85, Latitude: 1.29 + float64(i)*0.02}})
+	}
+	routes := newTestRoutes(Config{}, jobs...)
+
+	status, response := serve(t, routes, http.MethodGet,
+		"/api/v1/jobs/nearest/per-title?latitude=1.29&longitude=103.85&title=Nurse&title=Driver&title=Cook&k=4", "")
+	if status != http.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+	var nearest map[string][]models.JobWithDistance
+	decodeData(t, response, &nearest)
+	for title, want := range map[string]int{"Nurse": 4, "Driver": 4, "Cook": 0} {
+		found := nearest[title]
+		if len(found) != want {
+			t.Errorf("%d %s jobs, want %d", len(found), title, want)
+		}
+		for i, job := range found {
+			if job.Title != title || i > 0 && job.Distance <= found[i-1].Distance {
+				t.Errorf("%s job %d titled %s at %vkm, want the next nearest %s", title, i, job.Title, job.Distance, title)
+			}
+		}
+	}
+
+	_, response = serve(t, routes, http.MethodGet, "/api/v1/jobs/nearest/per-title?latitude=1.29&longitude=103.85&title=Nurse", "")
+	decodeData(t, response, &nearest)
+	if len(nearest["Nurse"]) != defaultNearestPerTitleCount {
+		t.Errorf("%d nurse jobs by default, want %d", len(nearest["Nurse"]), defaultNearestPerTitleCount)
+	}
+
+	for _, query := range []string{"", "&title=Nurse&k=0", "&title=Nurse&k=51", "&title=Nurse&k=few"} {
+		if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearest/per-title?latitude=1.29&longitude=103.85"+query, ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("%q: status %d, want 422", query, status)
+		}
+	}
+}
+
+func TestJobNeighbors(t *testing.T) {
+	var jobs []models.Job
+	for i := 0; i < 8; i++ {
+		jobs = append(jobs, models.Job{Title: fmt.Sprintf("Job %d", i), Location: models.Location{Longitude: 103.85 + float64(i)*0.01, Latitude: 1.29}})
+	}
+	jobs = append(jobs, models.Job{Title: "Twin", Location: jobs[3].Location})
+	repo := memory.NewMemoryRepository(jobs...)
+	routes := Routes(repo, Config{})
+	titleJobs, _ := repo.TitleJobs()
+	id := titleJobs["job 3"][0].ID
+
+	status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/id/"+id+"/neighbors?k=4", "")
+	if status != http.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+	var neighbors []models.JobWithDistance
+	decodeData(t, response, &neighbors)
+	titles := make([]string, len(neighbors))
+	for i, neighbor := range neighbors {
+		titles[i] = neighbor.Title
+		if neighbor.ID == id {
+			t.Errorf("job among its own neighbors")
+		}
+		if i > 0 && neighbor.Distance < neighbors[i-1].Distance {
+			t.Errorf("neighbor %d at %vkm follows one at %vkm", i, neighbor.Distance, neighbors[i-1].Distance)
+		}
+	}
+	var others []models.Job
+	for _, jobs := range titleJobs {
+		for _, job := range jobs {
+			if job.ID != id {
+				others = append(others, job)
+			}
+		}
+	}
+	want := make([]string, 4)
+	for i, job := range models.NearestFirst(models.Earth, jobs[3].Location, others)[:4] {
+		want[i] = job.Title
+	}
+	if !reflect.DeepEqual(titles, want) || titles[0] != "Twin" {
+		t.Errorf("neighbors %v, want %v from the twin sharing the location of the job", titles, want)
+	}
+
+	_, response = serve(t, routes, http.MethodGet, "/api/v1/jobs/id/"+id+"/neighbors", "")
+	decodeData(t, response, &neighbors)
+	if len(neighbors) != len(jobs)-1 {
+		t.Errorf("%d neighbors by default, want every other job", len(neighbors))
+	}
+
+	if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/id/unknown/neighbors", ""); status != http.StatusNotFound {
+		t.Errorf("unknown job: status %d, want 404", status)
+	}
+	for _, k := range []string{"0", "-1", "501", "few"} {
+		if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/id/"+id+"/neighbors?k="+k, ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("k=%s: status %d, want 422", k, status)
+		}
+	}
+}
+
+func TestCoordinatesParameter(t *testing.T) {
+	job := models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}}
+
+	tests := []struct {
+		order models.CoordinateOrder
+		pair  string
+	}{
+		{order: models.LatLng, pair: "1.29,103.85"},
+		{order: models.LngLat, pair: "103.85,1.29"},
+	}
+	for _, test := range tests {
+		routes := newTestRoutes(Config{CoordinateOrder: test.order}, job)
+
+		// the pair and the latitude and longitude parameters find the same jobs, whatever the order
+		for _, query := range []string{"coordinates=" + test.pair, "latitude=1.29&longitude=103.85"} {
+			status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?radius=5&"+query, "")
+			var found []models.Job
+			decodeData(t, response, &found)
+			if status != http.StatusOK || len(found) != 1 || found[0].Title != "Nurse" {
+				t.Errorf("%q, %s: status %d and found %v, want 200 and the Nurse job", test.order, query, status, found)
+			}
+		}
+
+		// locations in messages are pairs in the same order
+		_, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearest?k=1&coordinates="+test.pair, "")
+		if want := "1 jobs nearest to " + models.FormatCoordinates(job.Location, test.order); response.Message != want {
+			t.Errorf("%q: message %q, want %q", test.order, response.Message, want)
+		}
+
+		for _, query := range []string{"coordinates=1.29", "coordinates=1.29,east", "coordinates=" + test.pair + "&latitude=1.29"} {
+			if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?"+query, ""); status != http.StatusUnprocessableEntity {
+				t.Errorf("%q, %s: status %d, want 422", test.order, query, status)
+			}
+		}
+	}
+}
+
+func TestGapsInBox(t *testing.T) {
+	// jobs fill a 5x5 grid over the box but a 2x2 hole in rows 1 and 2, columns 2 and 3
+	var jobs []models.Job
+	var lines []string
+	for row := 0; row < 5; row++ {
+		for col := 0; col < 5; col++ {
+			if row >= 1 && row <= 2 && col >= 2 && col <= 3 {
+				continue
+			}
+			location := models.Location{Longitude: 103.8 + (float64(col)+0.5)*0.02, Latitude: 1.25 + (float64(row)+0.5)*0.02}
+			jobs = append(jobs, models.Job{Title: "Nurse", Location: location})
+			lines = append(lines, fmt.Sprintf("Nurse,%f,%f", location.Longitude, location.Latitude))
+		}
+	}
+	repositories := map[string]http.Handler{
+		"db":     Routes(newTestDataset(t, db.Options{}, lines...), Config{}),
+		"memory": newTestRoutes(Config{}, jobs...),
+	}
+	const target = "/api/v1/jobs/gaps?minLat=1.25&minLon=103.8&maxLat=1.35&maxLon=103.9"
+
+	for name, routes := range repositories {
+		status, response := serve(t, routes, http.MethodGet, target+"&grid=5&limit=5", "")
+		var cells []models.GridCell
+		decodeData(t, response, &cells)
+		at := make([][2]int, len(cells))
+		for i, cell := range cells {
+			at[i] = [2]int{cell.Row, cell.Col}
+		}
+		// the hole comes first, then cells of one job row by row
+		if want := [][2]int{{1, 2}, {1, 3}, {2, 2}, {2, 3}, {0, 0}}; status != http.StatusOK || !reflect.DeepEqual(at, want) {
+			t.Errorf("%s: status %d and sparsest cells %v, want 200 and %v", name, status, at, want)
+		}
+		if len(cells) == 5 && (cells[0].Count != 0 || cells[4].Count != 1) {
+			t.Errorf("%s: sparsest cells count %d and %d jobs, want 0 in the hole and 1 beyond", name, cells[0].Count, cells[4].Count)
+		}
+		if response.Meta["emptyCells"] != 4.0 || response.Meta["grid"] != 5.0 {
+			t.Errorf("%s: meta %v, want 4 empty cells of a grid of 5", name, response.Meta)
+		}
+
+		// a single cell spans the box, hole included
+		_, response = serve(t, routes, http.MethodGet, target+"&grid=1", "")
+		decodeData(t, response, &cells)
+		if len(cells) != 1 || cells[0].Count != len(jobs) || response.Meta["emptyCells"] != 0.0 {
+			t.Errorf("%s: a grid of 1 cell returned %+v, want a cell counting all %d jobs", name, cells, len(jobs))
+		}
+
+		for _, invalid := range []string{
+			target + "&grid=0",
+			target + "&grid=101",
+			target + "&grid=5&limit=26",
+			target + "&limit=0",
+			"/api/v1/jobs/gaps?minLat=1.25&minLon=103.8&maxLat=1.25&maxLon=103.9",
+		} {
+			if status, _ := serve(t, routes, http.MethodGet, invalid, ""); status != http.StatusUnprocessableEntity {
+				t.Errorf("%s: %s: status %d, want 422", name, invalid, status)
+			}
+		}
+	}
+}
+
+func TestDensityInBox(t *testing.T) {
+	var jobs []models.Job
+	for i := 0; i < 10; i++ {
+		jobs = append(jobs, models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85 + float64(i)*0.001, Latitude: 1.29}})
+	}
+	routes := newTestRoutes(Config{}, jobs...)
+
+	// density returns the density of jobs within box of corners min and max
+	density := func(minLat, minLon, maxLat, maxLon string) models.Density {
+		status, response := serve(t, routes, http.MethodGet,
+			"/api/v1/jobs/density?minLat="+minLat+"&minLon="+minLon+"&maxLat="+maxLat+"&maxLon="+maxLon, "")
+		if status != http.StatusOK {
+			t.Fatalf("status %d, want 200", status)
+		}
+		var density models.Density
+		decodeData(t, response, &density)
+		return density
+	}
+
+	small := density("1.28", "103.84", "1.3", "103.86")
+	large := density("1.19", "103.75", "1.39", "103.95")
+	if small.Count != 10 || large.Count != 10 {
+		t.Fatalf("counted %d and %d jobs in the small and large box, want 10 and 10", small.Count, large.Count)
+	}
+	if small.JobsPerKm2 <= large.JobsPerKm2 {
+		t.Errorf("small box density %v/km², want over the large box density %v/km²", small.JobsPerKm2, large.JobsPerKm2)
+	}
+
+	// the large box spans 10 times the width and height of the small box
+	if ratio := small.JobsPerKm2 / large.JobsPerKm2; math.Abs(ratio-100) > 0.1 {
+		t.Errorf("small box is %v times as dense as the large box, want 100", ratio)
+	}
+	if ratio := large.AreaKm2 / small.AreaKm2; math.Abs(ratio-100) > 0.1 {
+		t.Errorf("large box covers %v times the area of the small box, want 100", ratio)
+	}
+}
+
+func TestNearbySearchStats(t *testing.T) {
+	// 400 jobs 0.01 degree apart, filling over a dozen leaves
+	lines := make([]string, 0, 400)
+	for i := 0; i < 400; i++ {
+		lines = append(lines, fmt.Sprintf("Nurse,%f,%f", 103.7+float64(i%20)*0.01, 1.2+float64(i/20)*0.01))
+	}
+	repo := newTestDataset(t, db.Options{}, lines...)
+	const target = "/api/v1/jobs/nearby?latitude=1.25&longitude=103.75&radius=1.5"
+
+	status, response := serve(t, Routes(repo, Config{DevMode: true}), http.MethodGet, target, "")
+	var jobs []models.Job
+	decodeData(t, response, &jobs)
+	var stats rtree.SearchStats
+	if err := json.Unmarshal(mustMarshal(t, response.Meta["debug"]), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusOK || len(jobs) == 0 || stats.FullScan {
+		t.Fatalf("status %d, %d jobs found and full scan %v, want 200, jobs found and the tree descended", status, len(jobs), stats.FullScan)
+	}
+
+	// the root and a leaf at least are visited, out of the few leaves around center.
+	// Every split adds a node to the root, hence the tree has more nodes than splits
+	indexStats, _ := repo.Stats()
+	nodes := int(indexStats.Index.LeafSplits+indexStats.Index.NodeSplits) + 1
+	if stats.NodesVisited < 2 || stats.NodesVisited >= nodes {
+		t.Errorf("visited %d nodes, want at least 2 and fewer than all %d", stats.NodesVisited, nodes)
+	}
+	if stats.EntriesVisited < len(jobs) || stats.EntriesVisited >= len(lines) {
+		t.Errorf("visited %d entries finding %d jobs, want at least those and fewer than all %d", stats.EntriesVisited, len(jobs), len(lines))
+	}
+
+	if _, response := serve(t, Routes(repo, Config{}), http.MethodGet, target, ""); response.Meta["debug"] != nil {
+		t.Errorf("debug %v reported out of dev mode", response.Meta["debug"])
+	}
+}
+
+func TestCompleteOnly(t *testing.T) {
+	altitude := 30.0
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "Nurse", Company: "Clinic", Location: models.Location{Longitude: 103.85, Latitude: 1.29, Altitude: &altitude}},
+		models.Job{Title: "Nurse", Company: "Clinic", Location: models.Location{Longitude: 103.851, Latitude: 1.29}},
+		models.Job{Title: "Driver", Location: models.Location{Longitude: 103.852, Latitude: 1.29, Altitude: &altitude}},
+		models.Job{Title: "Cook", Location: models.Location{Longitude: 103.853, Latitude: 1.29}})
+
+	tests := []struct {
+		target string
+		jobs   int
+	}{
+		{target: "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85", jobs: 4},
+		{target: "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&completeOnly=false", jobs: 4},
+		{target: "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&completeOnly=true", jobs: 1},
+		{target: "/api/v1/jobs/top-jobs/around-me?latitude=1.29&longitude=103.85&title=nurse&completeOnly=true", jobs: 1},
+	}
+	for _, test := range tests {
+		status, response := serve(t, routes, http.MethodGet, test.target, "")
+		var jobs []models.Job
+		decodeData(t, response, &jobs)
+		if status != http.StatusOK || len(jobs) != test.jobs {
+			t.Errorf("%s: status %d and %d jobs, want 200 and %d", test.target, status, len(jobs), test.jobs)
+		}
+		for _, job := range jobs {
+			if strings.Contains(test.target, "completeOnly=true") && (job.Company == "" || job.Location.Altitude == nil) {
+				t.Errorf("%s: found sparse job %+v", test.target, job)
+			}
+		}
+	}
+
+	// titles of sparse jobs only are not listed
+	status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/available?completeOnly=true", "")
+	var available map[string][]models.Job
+	decodeData(t, response, &available)
+	if status != http.StatusOK || len(available) != 1 || len(available["nurse"]) != 1 {
+		t.Errorf("status %d and available jobs %v, want 200 and a complete nurse job", status, available)
+	}
+
+	if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&completeOnly=maybe", ""); status != http.StatusUnprocessableEntity {
+		t.Errorf("invalid completeOnly: status %d, want 422", status)
+	}
+}
+
+func TestBucketRadius(t *testing.T) {
+	app := &App{Config: Config{RadiusStepKm: 0.5}}
+	tests := []struct {
+		radius, bucket float64
+	}{
+		{radius: 5.001, bucket: 5},
+		{radius: 5.002, bucket: 5},
+		{radius: 4.9, bucket: 5},
+		{radius: 5.26, bucket: 5.5},
+		{radius: 0.1, bucket: 0.5},
+		{radius: 0, bucket: 0},
+	}
+	for _, test := range tests {
+		if bucket := app.bucketRadius(test.radius); bucket != test.bucket {
+			t.Errorf("bucketRadius(%v) = %v, want %v", test.radius, bucket, test.bucket)
+		}
+	}
+	if radius := (&App{}).bucketRadius(5.001); radius != 5.001 {
+		t.Errorf("bucketRadius(5.001) = %v without a step, want 5.001", radius)
+	}
+}
+
+func TestCloseRadiiHitWarmQuery(t *testing.T) {
+	repo := newTestDataset(t, db.Options{WarmQueries: []db.WarmQuery{{Center: models.Location{Longitude: 103.85, Latitude: 1.29}, RadiusKm: 5}}},
+		"Nurse,103.85,1.29", "Driver,103.87,1.29")
+	target := "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius="
+
+	// searches served from the warm query neither visit any node of the index nor scan it
+	for _, test := range []struct {
+		config Config
+		radius string
+		warmed bool
+	}{
+		{config: Config{DevMode: true, RadiusStepKm: 0.5}, radius: "5.001", warmed: true},
+		{config: Config{DevMode: true, RadiusStepKm: 0.5}, radius: "5.002", warmed: true},
+		{config: Config{DevMode: true, RadiusStepKm: 0.5}, radius: "4.9", warmed: true},
+		{config: Config{DevMode: true}, radius: "5.001", warmed: false},
+	} {
+		status, response := serve(t, Routes(repo, test.config), http.MethodGet, target+test.radius, "")
+		var stats rtree.SearchStats
+		if err := json.Unmarshal(mustMarshal(t, response.Meta["debug"]), &stats); err != nil {
+			t.Fatal(err)
+		}
+		var jobs []models.Job
+		decodeData(t, response, &jobs)
+		if status != http.StatusOK || len(jobs) != 2 {
+			t.Fatalf("radius %s: status %d and %d jobs, want 200 and 2", test.radius, status, len(jobs))
+		}
+		if warmed := stats.NodesVisited == 0 && !stats.FullScan; warmed != test.warmed {
+			t.Errorf("radius %s under step %v: visited %d nodes and full scan %v, want warm query hit %v",
+				test.radius, test.config.RadiusStepKm, stats.NodesVisited, stats.FullScan, test.warmed)
+		}
+		if test.warmed && response.Meta["radiusKm"] != 5.0 {
+			t.Errorf("radius %s searched as %vkm, want 5km", test.radius, response.Meta["radiusKm"])
+		}
+	}
+}
+
+func TestTitleCentroid(t *testing.T) {
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 9, Latitude: -1}},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 11, Latitude: -1}},
+		models.Job{Title: "nurse", Location: models.Location{Longitude: 9, Latitude: 1}},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 11, Latitude: 1}},
+		models.Job{Title: "Driver", Location: models.Location{Longitude: 50, Latitude: 50}})
+
+	status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/centroid?title=Nurse", "")
+	var centroid models.Centroid
+	decodeData(t, response, &centroid)
+	want := models.Location{Longitude: 10, Latitude: 0}
+	if status != http.StatusOK || centroid.Count != 4 || models.Earth.Distance(centroid.Location, want) > 1e-6 {
+		t.Errorf("status %d and centroid of %d jobs at %v, want 200 and 4 jobs at %v", status, centroid.Count, centroid.Location, want)
+	}
+
+	if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/centroid?title=Pilot", ""); status != http.StatusNotFound {
+		t.Errorf("title without jobs: status %d, want 404", status)
+	}
+	if status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/centroid", ""); status != http.StatusUnprocessableEntity {
+		t.Errorf("no title: status %d, want 422", status)
+	}
+}
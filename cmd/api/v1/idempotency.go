@@ -0,0 +1,163 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotencyKeyHeader is the request header identifying a request across retries
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentReplayedHeader is set on a response replayed for a retried request
+const idempotentReplayedHeader = "Idempotent-Replayed"
+
+// defaultIdempotencyKeyTTL is how long a response is replayed if Config sets no TTL
+const defaultIdempotencyKeyTTL = 24 * time.Hour
+
+// idempotentResponse is the response to the first request having an idempotency key,
+// replayed for retries of the request
+type idempotentResponse struct {
+
+	// requestHash tells a retry from another request reusing the key
+	requestHash [sha256.Size]byte
+
+	// done is closed once the first request is served,
+	// after which status, header and body are set
+	done   chan struct{}
+	status int
+	header http.Header
+	body   []byte
+
+	expires time.Time
+}
+
+// idempotencyKeys holds the responses to requests having an idempotency key, until they expire
+type idempotencyKeys struct {
+	lock      sync.Mutex
+	responses map[string]*idempotentResponse
+}
+
+func newIdempotencyKeys() *idempotencyKeys {
+	return &idempotencyKeys{responses: make(map[string]*idempotentResponse)}
+}
+
+// reserve returns the response to the request first having key, and true if the
+// caller made the first request, i.e., it must serve it and call complete.
+// Expired responses are dropped beforehand.
+func (k *idempotencyKeys) reserve(key string, requestHash [sha256.Size]byte, ttl time.Duration) (response *idempotentResponse, first bool) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	now := time.Now()
+	for reserved, stale := range k.responses {
+		if !stale.expires.IsZero() && now.After(stale.expires) {
+			delete(k.responses, reserved)
+		}
+	}
+
+	if response, ok := k.responses[key]; ok {
+		return response, false
+	}
+	response = &idempotentResponse{requestHash: requestHash, done: make(chan struct{})}
+	k.responses[key] = response
+	return response, true
+}
+
+// complete records the response served to the first request having key, replayed until ttl elapses.
+// Server errors are not recorded, so that retries are served anew.
+func (k *idempotencyKeys) complete(key string, response *idempotentResponse, recorder *responseRecorder, ttl time.Duration) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	response.status, response.header, response.body = recorder.status, recorder.Header().Clone(), recorder.body.Bytes()
+	response.expires = time.Now().Add(ttl)
+	if response.status >= http.StatusInternalServerError {
+		delete(k.responses, key)
+	}
+	close(response.done)
+}
+
+// responseRecorder records the response written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// idempotent returns a middleware serving requests having an Idempotency-Key header at most once.
+// The response to the first request having a key is replayed for retries with the same key,
+// method, path, dataset and body for Config.IdempotencyKeyTTL, and a retry sent while the first
+// request is in progress waits for its response. A key reused for a different request is rejected
+// with 422 Unprocessable Entity. Requests without the header are served as usual.
+func (app *App) idempotent(next http.Handler) http.Handler {
+	ttl := app.Config.IdempotencyKeyTTL
+	if ttl <= 0 {
+		ttl = defaultIdempotencyKeyTTL
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodyBytes+1))
+		if err != nil {
+			app.sendBadRequestResponse(w, fmt.Errorf("error reading request body: %v", err))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key = fmt.Sprintf("%s %s %s %s", r.Method, r.URL.Path, r.Header.Get(datasetHeader), key)
+		response, first := app.idempotencyKeys.reserve(key, sha256.Sum256(body), ttl)
+		if first {
+			recorder := &responseRecorder{ResponseWriter: w}
+			defer func() { app.idempotencyKeys.complete(key, response, recorder, ttl) }()
+			next.ServeHTTP(recorder, r)
+			return
+		}
+
+		if response.requestHash != sha256.Sum256(body) {
+			app.sendFailedValidationResponse(w, validationError(idempotencyKeyHeader, codeConflict,
+				idempotencyKeyHeader+" was used for a different request"))
+			return
+		}
+		select {
+		case <-response.done:
+		case <-r.Context().Done():
+			return
+		}
+		if response.status >= http.StatusInternalServerError {
+			app.sendServerErrorResponse(w, fmt.Errorf("error serving the request first having %v %v", idempotencyKeyHeader, key))
+			return
+		}
+
+		for name, values := range response.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set(idempotentReplayedHeader, "true")
+		w.WriteHeader(response.status)
+		_, _ = w.Write(response.body)
+	})
+}
@@ -0,0 +1,102 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ercross/grabjobs/internal/db/memory"
+)
+
+// postJob posts body to routes as a new job, with key as its Idempotency-Key unless empty
+func postJob(routes http.Handler, key, body string) *httptest.ResponseRecorder {
+	request := newRequest(http.MethodPost, "/api/v1/jobs", body)
+	if key != "" {
+		request.Header.Set(idempotencyKeyHeader, key)
+	}
+	recorder := httptest.NewRecorder()
+	routes.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	const (
+		nurse  = `{"title": "Nurse", "location": {"longitude": 103.85, "latitude": 1.29}}`
+		driver = `{"title": "Driver", "location": {"longitude": 103.86, "latitude": 1.29}}`
+	)
+	repo := memory.NewMemoryRepository()
+	routes := Routes(repo, Config{})
+	count := func() int {
+		stats, _ := repo.Stats()
+		return stats.JobCount
+	}
+
+	first := postJob(routes, "retry-1", nurse)
+	retry := postJob(routes, "retry-1", nurse)
+	if first.Code != http.StatusCreated || retry.Code != http.StatusCreated {
+		t.Fatalf("status %d and %d, want 201 for either", first.Code, retry.Code)
+	}
+	if retry.Body.String() != first.Body.String() || retry.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Errorf("retry replied %s (replayed %q), want the first reply %s replayed",
+			retry.Body, retry.Header().Get(idempotentReplayedHeader), first.Body)
+	}
+	if first.Header().Get(idempotentReplayedHeader) != "" {
+		t.Error("first reply marked as replayed")
+	}
+	if count() != 1 {
+		t.Errorf("%d jobs added for a retried request, want 1", count())
+	}
+
+	// a key reused for another request is rejected, other keys and requests without a key are served
+	if reused := postJob(routes, "retry-1", driver); reused.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused for another job: status %d, want 422", reused.Code)
+	}
+	postJob(routes, "retry-2", nurse)
+	postJob(routes, "", nurse)
+	postJob(routes, "", nurse)
+	if count() != 4 {
+		t.Errorf("%d jobs added, want 4", count())
+	}
+}
+
+func TestIdempotencyKeyConcurrentRetries(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	routes := Routes(repo, Config{})
+
+	var wg sync.WaitGroup
+	replies := make([]string, 10)
+	for i := range replies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			replies[i] = postJob(routes, "concurrent", `{"title": "Nurse", "location": {"longitude": 103.85, "latitude": 1.29}}`).Body.String()
+		}(i)
+	}
+	wg.Wait()
+
+	if stats, _ := repo.Stats(); stats.JobCount != 1 {
+		t.Errorf("%d jobs added for concurrent retries, want 1", stats.JobCount)
+	}
+	for _, reply := range replies {
+		if reply != replies[0] {
+			t.Errorf("retry replied %s, want %s", reply, replies[0])
+		}
+	}
+}
+
+func TestIdempotencyKeyExpires(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	routes := Routes(repo, Config{IdempotencyKeyTTL: time.Millisecond})
+
+	const nurse = `{"title": "Nurse", "location": {"longitude": 103.85, "latitude": 1.29}}`
+	postJob(routes, "expiring", nurse)
+	time.Sleep(5 * time.Millisecond)
+	if retry := postJob(routes, "expiring", nurse); retry.Header().Get(idempotentReplayedHeader) != "" {
+		t.Error("reply replayed after its key expired")
+	}
+	if stats, _ := repo.Stats(); stats.JobCount != 2 {
+		t.Errorf("%d jobs added, want the job added again once its key expired", stats.JobCount)
+	}
+}
@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// defaultMetricsTopTitles is the number of titles having a gauge if Config sets none
+const defaultMetricsTopTitles = 20
+
+// metricsContentType is the content type of the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricLabelEscaper escapes a label value in the Prometheus text exposition format
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// getMetrics exposes gauges of the available jobs in the Prometheus text exposition format:
+// the total number of jobs and of distinct titles, and the number of jobs of each of the
+// Config.MetricsTopTitles titles having the most jobs, titles being keyed as in jobs/available.
+// Only the most popular titles have a gauge, so that the number of series stays bounded.
+// Gauges are read from the current jobs, hence follow insertions, deletions and reloads.
+// Request Method: GET
+// Query Parameters: None
+// Response Type: text/plain
+func (app *App) getMetrics(w http.ResponseWriter, r *http.Request) {
+
+	stats, err := app.repository(r).Stats()
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error fetching dataset stats: %v", err))
+		return
+	}
+
+	titleJobs, err := app.repository(r).TitleJobs()
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error fetching title to jobs map: %v", err))
+		return
+	}
+
+	topTitles := app.Config.MetricsTopTitles
+	if topTitles <= 0 {
+		topTitles = defaultMetricsTopTitles
+	}
+	counts := make([]titleCount, 0, len(titleJobs))
+	for title, jobs := range titleJobs {
+		counts = append(counts, titleCount{Title: title, Count: len(jobs)})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Title < counts[j].Title
+	})
+	if len(counts) > topTitles {
+		counts = counts[:topTitles]
+	}
+
+	var body bytes.Buffer
+	writeGauge(&body, "grabjobs_jobs", "Number of available jobs.")
+	fmt.Fprintf(&body, "grabjobs_jobs %d\n", stats.JobCount)
+	writeGauge(&body, "grabjobs_titles", "Number of distinct titles of the available jobs.")
+	fmt.Fprintf(&body, "grabjobs_titles %d\n", stats.TitleCount)
+	writeGauge(&body, "grabjobs_title_jobs", fmt.Sprintf("Number of available jobs of each of the %d titles having the most jobs.", topTitles))
+	for _, count := range counts {
+		fmt.Fprintf(&body, "grabjobs_title_jobs{title=\"%s\"} %d\n", metricLabelEscaper.Replace(count.Title), count.Count)
+	}
+
+	w.Header().Set("Content-Type", metricsContentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body.Bytes())
+}
+
+// writeGauge writes the HELP and TYPE lines of the gauge name to body
+func writeGauge(body *bytes.Buffer, name, help string) {
+	fmt.Fprintf(body, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+}
@@ -0,0 +1,112 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/db/memory"
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// scrapeMetrics returns the samples exposed at /metrics keyed by series
+func scrapeMetrics(t *testing.T, routes http.Handler) map[string]string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	routes.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", recorder.Code)
+	}
+	if got := recorder.Header().Get("Content-Type"); got != metricsContentType {
+		t.Fatalf("content type %q, want %q", got, metricsContentType)
+	}
+
+	samples := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(recorder.Body.String()), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		samples[line[:i]] = line[i+1:]
+	}
+	return samples
+}
+
+func TestMetrics(t *testing.T) {
+	repo := memory.NewMemoryRepository()
+	routes := Routes(repo, Config{MetricsTopTitles: 2})
+	insert := func(title string, n int) {
+		for i := 0; i < n; i++ {
+			body := fmt.Sprintf(`{"title": %q, "location": {"longitude": 103.85, "latitude": %v}}`, title, 1.29+float64(i)/100)
+			if recorder := postJob(routes, "", body); recorder.Code != http.StatusCreated {
+				t.Fatalf("status %d inserting %s, want 201", recorder.Code, title)
+			}
+		}
+	}
+	check := func(want map[string]string) {
+		t.Helper()
+		samples := scrapeMetrics(t, routes)
+		gauges := 0
+		for series := range samples {
+			if strings.HasPrefix(series, "grabjobs_title_jobs{") {
+				gauges++
+			}
+		}
+		if gauges > 2 {
+			t.Errorf("%d title gauges, want at most 2: %v", gauges, samples)
+		}
+		for series, value := range want {
+			if samples[series] != value {
+				t.Errorf("%s = %q, want %q", series, samples[series], value)
+			}
+		}
+	}
+
+	insert("Nurse", 3)
+	insert("Driver", 2)
+	insert("Cook", 1)
+	check(map[string]string{
+		"grabjobs_jobs":                       "6",
+		"grabjobs_titles":                     "3",
+		`grabjobs_title_jobs{title="nurse"}`:  "3",
+		`grabjobs_title_jobs{title="driver"}`: "2",
+		`grabjobs_title_jobs{title="cook"}`:   "",
+	})
+
+	// cooks outnumbering drivers take their gauge
+	insert("Cook", 2)
+	check(map[string]string{
+		"grabjobs_jobs":                       "8",
+		`grabjobs_title_jobs{title="nurse"}`:  "3",
+		`grabjobs_title_jobs{title="cook"}`:   "3",
+		`grabjobs_title_jobs{title="driver"}`: "",
+	})
+
+	if _, err := repo.DeleteJobs("Nurse", models.Location{Longitude: 103.85, Latitude: 1.29}, 10000); err != nil {
+		t.Fatal(err)
+	}
+	check(map[string]string{
+		"grabjobs_jobs":                       "5",
+		"grabjobs_titles":                     "2",
+		`grabjobs_title_jobs{title="cook"}`:   "3",
+		`grabjobs_title_jobs{title="driver"}`: "2",
+		`grabjobs_title_jobs{title="nurse"}`:  "",
+	})
+}
+
+func TestMetricsLabelEscaping(t *testing.T) {
+	routes := newTestRoutes(Config{}, models.Job{Title: `Say "Hi"`, Location: models.Location{Longitude: 103.85, Latitude: 1.29}})
+
+	samples := scrapeMetrics(t, routes)
+	for series, value := range samples {
+		if strings.HasPrefix(series, "grabjobs_title_jobs{") {
+			if !strings.Contains(series, `\"`) || value != "1" {
+				t.Errorf("gauge %s %s, want an escaped quoted title counting 1", series, value)
+			}
+			return
+		}
+	}
+	t.Errorf("no title gauge in %v", samples)
+}
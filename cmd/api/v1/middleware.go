@@ -0,0 +1,277 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ercross/grabjobs/internal/db"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// tracerName identifies the spans started by the api
+const tracerName = "github.com/ercross/grabjobs/cmd/api/v1"
+
+// trace returns a middleware that starts a span for every request
+// with Config.TracerProvider, as the parent of the spans of repository queries.
+// Spans are no-ops if Config.TracerProvider is nil.
+func (app *App) trace(next http.Handler) http.Handler {
+	provider := app.Config.TracerProvider
+	if provider == nil {
+		provider = trace.NewNoopTracerProvider()
+	}
+	tracer := provider.Tracer(tracerName)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracer.Start(r.Context(), r.Method+" "+r.URL.Path, trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(
+			attribute.String("http.method", r.Method),
+			attribute.String("http.target", r.URL.RequestURI()),
+			attribute.Int("http.status_code", status),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// timeoutMessage is sent to the client when a request exceeds Config.RequestTimeout
+const timeoutMessage = "the server could not process your request in time"
+
+// timeout returns a middleware that shares Config.RequestTimeout across the whole request.
+// The request context is cancelled once the deadline is hit, so searches stop early,
+// and a 503 Service Unavailable JSON response is sent to client in place of the handler's.
+// A zero Config.RequestTimeout disables the middleware.
+// Websocket upgrades are long-lived, hence not timed out.
+func (app *App) timeout(next http.Handler) http.Handler {
+	if app.Config.RequestTimeout <= 0 {
+		return next
+	}
+
+	body, _ := json.MarshalIndent(struct {
+		Status  bool   `json:"status"`
+		Message string `json:"message"`
+	}{
+		Status:  false,
+		Message: timeoutMessage,
+	}, "", "\t")
+	handler := http.TimeoutHandler(next, app.Config.RequestTimeout, string(body))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// http.TimeoutHandler only writes its body on timeout,
+		// while handler responses set their own content type
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// rebuildRetryAfter is the Retry-After header, in seconds, of queries rejected during a rebuild
+const rebuildRetryAfter = "1"
+
+// unavailableDuringRebuild returns a middleware rejecting queries, i.e., GET and HEAD requests,
+// with 503 Service Unavailable while the index of the selected dataset is being rebuilt.
+// Other requests wait for the rebuild as usual. Unless Config.UnavailableDuringRebuild is set,
+// queries are served from the jobs before the rebuild instead, and the middleware is disabled.
+func (app *App) unavailableDuringRebuild(next http.Handler) http.Handler {
+	if !app.Config.UnavailableDuringRebuild {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method == http.MethodGet || r.Method == http.MethodHead) && app.repository(r).Rebuilding() {
+			app.sendRebuildingResponse(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowQueryParams returns a middleware that rejects requests with query parameters
+// other than params, listing the unknown parameters in a 422 Unprocessable Entity response.
+// Requests are only checked if Config.StrictQueryParams is set, so that misspelled
+// parameters like raduis are reported instead of silently ignored.
+// Requests allowed a latitude are allowed coordinates too, as read by readLocation.
+func (app *App) allowQueryParams(params ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(params))
+	for _, param := range params {
+		allowed[param] = true
+	}
+	allowed["coordinates"] = allowed["latitude"]
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !app.Config.StrictQueryParams {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var unknown []ValidationError
+			for param := range r.URL.Query() {
+				if !allowed[param] {
+					unknown = append(unknown, validationError(param, codeUnknown, "unknown query parameter"))
+				}
+			}
+			if len(unknown) != 0 {
+				sort.Slice(unknown, func(i, j int) bool {
+					return unknown[i].Field < unknown[j].Field
+				})
+				app.sendFailedValidationResponse(w, unknown...)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// TrailingSlashPolicy is how a path not found only for its trailing slash is handled
+type TrailingSlashPolicy string
+
+const (
+	// TrailingSlashStrict sends 404 not found
+	TrailingSlashStrict TrailingSlashPolicy = ""
+
+	// TrailingSlashStrip serves the path without its trailing slash
+	TrailingSlashStrip TrailingSlashPolicy = "strip"
+
+	// TrailingSlashRedirect sends a 308 Permanent Redirect to the path without its trailing slash,
+	// which, unlike 301, keeps the request method and body
+	TrailingSlashRedirect TrailingSlashPolicy = "redirect"
+)
+
+// ParseTrailingSlashPolicy parses policy such as strip or redirect, or strict for TrailingSlashStrict.
+// ok is false if policy is not a known TrailingSlashPolicy.
+func ParseTrailingSlashPolicy(policy string) (trailingSlash TrailingSlashPolicy, ok bool) {
+	switch trailingSlash = TrailingSlashPolicy(strings.ToLower(policy)); trailingSlash {
+	case "strict":
+		return TrailingSlashStrict, true
+	case TrailingSlashStrip, TrailingSlashRedirect:
+		return trailingSlash, true
+	}
+	return trailingSlash, false
+}
+
+// normalizePath returns a middleware that routes a request whose path is not found on mux
+// to its path without the trailing slash, or in lower case with Config.CaseInsensitivePaths,
+// if found on mux. The request is then served or redirected following Config.TrailingSlash.
+// Paths found on mux as they are are never changed.
+func (app *App) normalizePath(mux *chi.Mux) func(http.Handler) http.Handler {
+	found := func(r *http.Request, path string) bool {
+		return mux.Match(chi.NewRouteContext(), r.Method, path)
+	}
+
+	return func(next http.Handler) http.Handler {
+		if app.Config.TrailingSlash == TrailingSlashStrict && !app.Config.CaseInsensitivePaths {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := r.URL.Path
+			if found(r, path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			candidates := make([]string, 0, 3)
+			stripped := strings.TrimRight(path, "/")
+			if app.Config.TrailingSlash != TrailingSlashStrict && stripped != path && stripped != "" {
+				candidates = append(candidates, stripped)
+			}
+			if app.Config.CaseInsensitivePaths {
+				candidates = append(candidates, strings.ToLower(path))
+				if len(candidates) == 2 {
+					candidates = append(candidates, strings.ToLower(stripped))
+				}
+			}
+
+			for _, candidate := range candidates {
+				if !found(r, candidate) {
+					continue
+				}
+				if app.Config.TrailingSlash == TrailingSlashRedirect {
+					location := *r.URL
+					location.Path, location.RawPath = candidate, ""
+					http.Redirect(w, r, location.String(), http.StatusPermanentRedirect)
+					return
+				}
+				chi.RouteContext(r.Context()).RoutePath = candidate
+				break
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// datasetHeader is the request header selecting the dataset a request is served from,
+// echoed in the response
+const datasetHeader = "X-Dataset"
+
+// datasetRepository is a repository holding named datasets aside the live dataset, as db.DB does
+type datasetRepository interface {
+	Dataset(name string) (dataset *db.DB, ok bool)
+}
+
+// datasetKey is the context key of the repository of the dataset selected by a request
+type datasetKey struct{}
+
+// selectDataset returns a middleware serving a request from the dataset named in its
+// datasetHeader, e.g., to route a fraction of traffic to a candidate dataset, db.LiveDataset by default.
+// If the repository holds no dataset so named, a failed validation response is sent to client.
+func (app *App) selectDataset(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.Header.Get(datasetHeader)
+		if name == "" || name == db.LiveDataset {
+			w.Header().Set(datasetHeader, db.LiveDataset)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		datasets, ok := app.repo.(datasetRepository)
+		if !ok {
+			app.sendFailedValidationResponse(w, validationError(datasetHeader, codeInvalid, "datasets other than live are not supported"))
+			return
+		}
+		dataset, ok := datasets.Dataset(name)
+		if !ok {
+			app.sendFailedValidationResponse(w, validationError(datasetHeader, codeInvalid, fmt.Sprintf("unknown dataset %q", name)))
+			return
+		}
+		w.Header().Set(datasetHeader, name)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), datasetKey{}, repository(dataset))))
+	})
+}
+
+// repository returns the repository of the dataset r is served from, as selected by selectDataset
+func (app *App) repository(r *http.Request) repository {
+	if dataset, ok := r.Context().Value(datasetKey{}).(repository); ok {
+		return dataset
+	}
+	return app.repo
+}
+
+// servesLiveDataset checks that r is served from the live dataset
+func (app *App) servesLiveDataset(r *http.Request) bool {
+	_, ok := r.Context().Value(datasetKey{}).(repository)
+	return !ok
+}
@@ -0,0 +1,274 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ercross/grabjobs/internal/db"
+	"github.com/ercross/grabjobs/internal/db/memory"
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// testDatasets serves the live dataset from a memory.MemoryRepository, and other datasets from a db.DB
+type testDatasets struct {
+	*memory.MemoryRepository
+	datasets map[string]*db.DB
+}
+
+func (d testDatasets) Dataset(name string) (dataset *db.DB, ok bool) {
+	dataset, ok = d.datasets[name]
+	return dataset, ok
+}
+
+// newTestDataset initializes a db.DB from a data file holding lines, following the title line
+func newTestDataset(t *testing.T, options db.Options, lines ...string) *db.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "location.csv")
+	data := "title,longitude,latitude\n"
+	for _, line := range lines {
+		data += line + "\n"
+	}
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dataset, err := db.Initialize(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dataset
+}
+
+func TestSelectedDatasetDefaultRadius(t *testing.T) {
+	// the Driver job lies about 3.3km east of the center searched,
+	// within the 5km default radius of the live dataset, but not the 2km of the candidate
+	candidate := newTestDataset(t, db.Options{DefaultRadius: models.Distance{Unit: models.Kilometer, Value: 2}},
+		"Nurse,103.85,1.29", "Driver,103.88,1.29")
+	routes := Routes(testDatasets{
+		MemoryRepository: memory.NewMemoryRepository(),
+		datasets:         map[string]*db.DB{"candidate": candidate},
+	}, Config{})
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/bootstrap", nil)
+	request.Header.Set(datasetHeader, "candidate")
+	recorder := httptest.NewRecorder()
+	routes.ServeHTTP(recorder, request)
+	var bootstrap struct {
+		DefaultRadiusKm float64 `json:"defaultRadiusKm"`
+	}
+	decodeData(t, decodeResponse(t, recorder), &bootstrap)
+	if bootstrap.DefaultRadiusKm != 2 {
+		t.Errorf("bootstrap of the candidate dataset reports a default radius of %vkm, want 2km", bootstrap.DefaultRadiusKm)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85", nil)
+	request.Header.Set(datasetHeader, "candidate")
+	recorder = httptest.NewRecorder()
+	routes.ServeHTTP(recorder, request)
+	var jobs []models.Job
+	decodeData(t, decodeResponse(t, recorder), &jobs)
+	if len(jobs) != 1 {
+		t.Errorf("found %d jobs within the default radius of the candidate dataset, want 1", len(jobs))
+	}
+}
+
+func TestStrictQueryParams(t *testing.T) {
+	jobs := []models.Job{
+		{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}},
+		{Title: "Driver", Location: models.Location{Longitude: 104.05, Latitude: 1.29}}, // ~22km east
+	}
+	target := "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&raduis=50&feilds=title"
+
+	// lenient mode ignores raduis, searching the default radius
+	status, response := serve(t, newTestRoutes(Config{}, jobs...), http.MethodGet, target, "")
+	var found []models.Job
+	decodeData(t, response, &found)
+	if status != http.StatusOK || len(found) != 1 {
+		t.Errorf("lenient mode: status %d and %d jobs, want 200 and 1", status, len(found))
+	}
+
+	status, response = serve(t, newTestRoutes(Config{StrictQueryParams: true}, jobs...), http.MethodGet, target, "")
+	if status != http.StatusUnprocessableEntity || len(response.Errors) != 2 ||
+		response.Errors[0].Field != "feilds" || response.Errors[1].Field != "raduis" || response.Errors[0].Code != codeUnknown {
+		t.Errorf("strict mode: status %d and errors %v, want 422 listing feilds and raduis", status, response.Errors)
+	}
+
+	// parameters spelled right are served in strict mode
+	status, _ = serve(t, newTestRoutes(Config{StrictQueryParams: true}, jobs...), http.MethodGet,
+		"/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=50&fields=title", "")
+	if status != http.StatusOK {
+		t.Errorf("strict mode: status %d for known parameters, want 200", status)
+	}
+}
+
+// slowRepository searches nearby jobs until the search context is done,
+// as a pathological query would
+type slowRepository struct {
+	*memory.MemoryRepository
+	cancelled chan struct{}
+}
+
+func (s slowRepository) FindJobsNearby(ctx context.Context, location models.Location, radius float64, boundary rtree.Boundary) ([]models.Job, error) {
+	<-ctx.Done()
+	close(s.cancelled)
+	return []models.Job{}, nil
+}
+
+func TestRequestTimeout(t *testing.T) {
+	repo := slowRepository{MemoryRepository: memory.NewMemoryRepository(), cancelled: make(chan struct{})}
+	routes := Routes(repo, Config{RequestTimeout: 50 * time.Millisecond})
+
+	status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=5", "")
+	if status != http.StatusServiceUnavailable || response.Status || response.Message != timeoutMessage {
+		t.Errorf("slow search: status %d and response %+v, want 503 with %q", status, response, timeoutMessage)
+	}
+	select {
+	case <-repo.cancelled:
+	case <-time.After(time.Second):
+		t.Error("slow search not cancelled once timed out")
+	}
+
+	// requests served within the budget are left untouched
+	status, _ = serve(t, routes, http.MethodGet, "/api/v1/jobs/stats", "")
+	if status != http.StatusOK {
+		t.Errorf("fast request: status %d, want 200", status)
+	}
+}
+
+func TestTraceNearbyRequest(t *testing.T) {
+	lines := make([]string, 0, 400)
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 20; j++ {
+			lines = append(lines, fmt.Sprintf("Nurse,%f,%f", 103.6+float64(i)*0.02, 1.2+float64(j)*0.02))
+		}
+	}
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	routes := Routes(newTestDataset(t, db.Options{}, lines...), Config{TracerProvider: provider})
+
+	status, _ := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?latitude=1.3&longitude=103.7&radius=3", "")
+	if status != http.StatusOK {
+		t.Fatalf("status %d, want 200", status)
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	request, query, traversal := spans["GET /api/v1/jobs/nearby"], spans["DB.FindJobsNearby"], spans["RTree.FindJobs"]
+	if !request.SpanContext.IsValid() || !query.SpanContext.IsValid() || !traversal.SpanContext.IsValid() {
+		t.Fatalf("spans %v, want request, query and traversal spans", exporter.GetSpans())
+	}
+	if query.Parent.SpanID() != request.SpanContext.SpanID() || traversal.Parent.SpanID() != query.SpanContext.SpanID() {
+		t.Errorf("traversal span child of %v, query span child of %v, want traversal within query within request %v",
+			traversal.Parent.SpanID(), query.Parent.SpanID(), request.SpanContext.SpanID())
+	}
+	if request.SpanContext.TraceID() != traversal.SpanContext.TraceID() {
+		t.Error("request and traversal spans of different traces")
+	}
+
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range traversal.Attributes {
+		attributes[kv.Key] = kv.Value
+	}
+	if visited, ok := attributes["rtree.nodes_visited"]; !ok || visited.AsInt64() < 1 {
+		t.Errorf("traversal span attributes %v, want the count of nodes visited", traversal.Attributes)
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	const query = "?latitude=1.29&longitude=103.85"
+	tests := []struct {
+		config   Config
+		path     string
+		status   int
+		location string
+	}{
+		{path: "/api/v1/jobs/nearby", status: http.StatusOK},
+		{path: "/api/v1/jobs/nearby/", status: http.StatusNotFound},
+		{path: "/API/v1/Jobs/nearby", status: http.StatusNotFound},
+		{config: Config{TrailingSlash: TrailingSlashStrip}, path: "/api/v1/jobs/nearby/", status: http.StatusOK},
+		{config: Config{TrailingSlash: TrailingSlashStrip}, path: "/api/v1/jobs/nearby//", status: http.StatusOK},
+		{config: Config{TrailingSlash: TrailingSlashStrip}, path: "/API/v1/Jobs/nearby", status: http.StatusNotFound},
+		{config: Config{TrailingSlash: TrailingSlashRedirect}, path: "/api/v1/jobs/nearby/",
+			status: http.StatusPermanentRedirect, location: "/api/v1/jobs/nearby" + query},
+		{config: Config{CaseInsensitivePaths: true}, path: "/API/v1/Jobs/nearby", status: http.StatusOK},
+		{config: Config{CaseInsensitivePaths: true}, path: "/API/v1/Jobs/nearby/", status: http.StatusNotFound},
+		{config: Config{CaseInsensitivePaths: true, TrailingSlash: TrailingSlashStrip}, path: "/API/v1/Jobs/Nearby/", status: http.StatusOK},
+		{config: Config{CaseInsensitivePaths: true, TrailingSlash: TrailingSlashRedirect}, path: "/API/v1/Jobs/Nearby/",
+			status: http.StatusPermanentRedirect, location: "/api/v1/jobs/nearby" + query},
+		{config: Config{CaseInsensitivePaths: true, TrailingSlash: TrailingSlashStrip}, path: "/api/v1/jobs/unknown/", status: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		routes := newTestRoutes(test.config, models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}})
+		recorder := httptest.NewRecorder()
+		routes.ServeHTTP(recorder, newRequest(http.MethodGet, test.path+query, ""))
+		if recorder.Code != test.status || recorder.Header().Get("Location") != test.location {
+			t.Errorf("%s under %+v: status %d and location %q, want %d and %q",
+				test.path, test.config, recorder.Code, recorder.Header().Get("Location"), test.status, test.location)
+			continue
+		}
+		if test.status == http.StatusOK {
+			var jobs []models.Job
+			decodeData(t, decodeResponse(t, recorder), &jobs)
+			if len(jobs) != 1 {
+				t.Errorf("%s under %+v: found %d jobs nearby, want 1", test.path, test.config, len(jobs))
+			}
+		}
+	}
+}
+
+func TestSelectDataset(t *testing.T) {
+	repo := newTestDataset(t, db.Options{}, "Nurse,103.85,1.29")
+	candidate := filepath.Join(t.TempDir(), "candidate.csv")
+	if err := os.WriteFile(candidate, []byte("title,longitude,latitude\nDriver,103.85,1.29\nCook,103.85,1.29\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ReloadDataset("candidate", candidate); err != nil {
+		t.Fatal(err)
+	}
+	routes := Routes(repo, Config{})
+
+	tests := []struct {
+		dataset string
+		status  int
+		jobs    int
+	}{
+		{dataset: "", status: http.StatusOK, jobs: 1},
+		{dataset: db.LiveDataset, status: http.StatusOK, jobs: 1},
+		{dataset: "candidate", status: http.StatusOK, jobs: 2},
+		{dataset: "unknown", status: http.StatusUnprocessableEntity},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85", nil)
+		if test.dataset != "" {
+			request.Header.Set(datasetHeader, test.dataset)
+		}
+		recorder := httptest.NewRecorder()
+		routes.ServeHTTP(recorder, request)
+		if recorder.Code != test.status {
+			t.Errorf("dataset %q: status %d, want %d", test.dataset, recorder.Code, test.status)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		var jobs []models.Job
+		decodeData(t, decodeResponse(t, recorder), &jobs)
+		if len(jobs) != test.jobs {
+			t.Errorf("dataset %q: found %d jobs, want %d", test.dataset, len(jobs), test.jobs)
+		}
+		if want := test.dataset; want != "" && recorder.Header().Get(datasetHeader) != want {
+			t.Errorf("dataset %q echoed as %q", want, recorder.Header().Get(datasetHeader))
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package v1
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// FieldNaming is the case JSON field names are sent in
+type FieldNaming string
+
+const (
+	// CamelCaseFields sends field names as tagged on response types, e.g., travelMinutes
+	CamelCaseFields FieldNaming = ""
+
+	// SnakeCaseFields sends field names in snake case, e.g., travel_minutes
+	SnakeCaseFields FieldNaming = "snake"
+)
+
+// ParseFieldNaming parses naming, camel or snake.
+// ok is false if naming is none of them.
+func ParseFieldNaming(naming string) (fieldNaming FieldNaming, ok bool) {
+	switch fieldNaming = FieldNaming(strings.ToLower(naming)); fieldNaming {
+	case "camel":
+		return CamelCaseFields, true
+	case SnakeCaseFields:
+		return fieldNaming, true
+	}
+	return fieldNaming, false
+}
+
+// snakeCase converts name from camel case to snake case, e.g., radiusKm to radius_km.
+// Runs of capitals are kept together, e.g., jobID to job_id.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var snake strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) && runes[i-1] != '_' {
+				snake.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		snake.WriteRune(r)
+	}
+	return snake.String()
+}
+
+// jsonField is a field of an object encoded by orderedObject
+type jsonField struct {
+	name  string
+	value interface{}
+}
+
+// orderedObject is a JSON object encoded with its fields in order,
+// as encoding/json does for structs
+type orderedObject []jsonField
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		name, _ := json.Marshal(field.name)
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	interfaceType     = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// nameFields returns data and meta with their field names in the case of Config.FieldNaming.
+// Unlike the keys of data, those of meta are field names, hence named alike.
+func (app *App) nameFields(data interface{}, meta map[string]interface{}) (interface{}, map[string]interface{}) {
+	if app.Config.FieldNaming != SnakeCaseFields {
+		return data, meta
+	}
+	var named map[string]interface{}
+	if meta != nil {
+		named = make(map[string]interface{}, len(meta))
+		for key, value := range meta {
+			named[snakeCase(key)] = snakeCaseFields(value)
+		}
+	}
+	return snakeCaseFields(data), named
+}
+
+// snakeCaseFields returns a value encoded as v by encoding/json, but for the names
+// of struct fields converted with snakeCase. Map keys are data, e.g., titles or labels,
+// hence left as they are, as are values encoding themselves, e.g., with MarshalJSON.
+func snakeCaseFields(v interface{}) interface{} {
+	return snakeCaseValue(reflect.ValueOf(v))
+}
+
+func snakeCaseValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return snakeCaseValue(v.Elem())
+
+	case reflect.Struct:
+		return appendSnakeCaseFields(make(orderedObject, 0, v.NumField()), v, nil)
+
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		converted := reflect.MakeMapWithSize(reflect.MapOf(v.Type().Key(), interfaceType), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			// a nil value is set as a nil interface, as the zero reflect.Value would delete its key
+			value := reflect.Zero(interfaceType)
+			if element := snakeCaseValue(iter.Value()); element != nil {
+				value = reflect.ValueOf(element)
+			}
+			converted.SetMapIndex(iter.Key(), value)
+		}
+		return converted.Interface()
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// byte slices are encoded in base64
+			return v.Interface()
+		}
+		converted := make([]interface{}, v.Len())
+		for i := range converted {
+			converted[i] = snakeCaseValue(v.Index(i))
+		}
+		return converted
+	}
+	return v.Interface()
+}
+
+// appendSnakeCaseFields appends the fields of struct v to object in order, as encoding/json encodes them,
+// but for fields named in shadowed. Fields of embedded structs are promoted unless v has a field of the same name.
+func appendSnakeCaseFields(object orderedObject, v reflect.Value, shadowed map[string]bool) orderedObject {
+	type structField struct {
+		name     string
+		value    reflect.Value
+		embedded bool
+		omitted  bool
+	}
+
+	t := v.Type()
+	fields := make([]structField, 0, t.NumField())
+	own := make(map[string]bool, len(shadowed)+t.NumField())
+	for name := range shadowed {
+		own[name] = true
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			if value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				fields = append(fields, structField{value: value, embedded: true})
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		name = snakeCase(name)
+		fields = append(fields, structField{
+			name:    name,
+			value:   value,
+			omitted: shadowed[name] || strings.Contains(options, "omitempty") && isEmptyValue(value),
+		})
+		own[name] = true
+	}
+
+	for _, field := range fields {
+		switch {
+		case field.embedded:
+			object = appendSnakeCaseFields(object, field.value, own)
+		case !field.omitted:
+			object = append(object, jsonField{name: field.name, value: snakeCaseValue(field.value)})
+		}
+	}
+	return object
+}
+
+// isEmptyValue checks that v is omitted by encoding/json if tagged omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
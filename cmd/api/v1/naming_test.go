@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"title":         "title",
+		"radiusKm":      "radius_km",
+		"travelMinutes": "travel_minutes",
+		"jobID":         "job_id",
+		"IDs":           "i_ds",
+		"HTTPStatus":    "http_status",
+		"nextCursor":    "next_cursor",
+		"already_snake": "already_snake",
+		"Title":         "title",
+	} {
+		if got := snakeCase(name); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSnakeCaseFields(t *testing.T) {
+	altitude, created := 12.5, time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	job := models.JobWithDistance{
+		Job: models.Job{
+			ID: "1", Title: "Nurse", Company: "Acme", CreatedAt: &created,
+			Location: models.Location{Longitude: 103.85, Latitude: 1.29, Altitude: &altitude},
+			Labels:   map[string]string{"shiftType": "nightShift"},
+		},
+		Distance: 1.5,
+	}
+	encoded, err := json.Marshal(snakeCaseFields(map[string]interface{}{"nurseJobs": []models.JobWithDistance{job}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// encoded as encoding/json does, in the same order, but for snake case field names
+	want, _ := json.Marshal(map[string]interface{}{"nurseJobs": []models.JobWithDistance{job}})
+	if got := string(encoded); got != strings.Replace(string(want), `"createdAt"`, `"created_at"`, 1) {
+		t.Errorf("encoded %s, want %s", got, want)
+	}
+
+	type travel struct {
+		TravelMinutes float64 `json:"travelMinutes"`
+		SpeedKmh      float64 `json:"speedKmh,omitempty"`
+		NoTag         int
+		Skipped       int `json:"-"`
+		models.Job
+	}
+	encoded, _ = json.Marshal(snakeCaseFields(travel{TravelMinutes: 3, NoTag: 1, Skipped: 2, Job: models.Job{ID: "2", Title: "Cook"}}))
+	if want := `{"travel_minutes":3,"no_tag":1,"id":"2","title":"Cook","location":{"longitude":0,"latitude":0}}`; string(encoded) != want {
+		t.Errorf("encoded %s, want %s", encoded, want)
+	}
+}
+
+func TestFieldNaming(t *testing.T) {
+	jobs := []models.Job{
+		{Title: "Nurse", Location: models.Location{Longitude: 103.86, Latitude: 1.29}, Labels: map[string]string{"shiftType": "night"}},
+	}
+	tests := []struct {
+		naming FieldNaming
+		want   []string
+		absent []string
+	}{
+		{naming: CamelCaseFields,
+			want:   []string{`"travelMinutes":`, `"speedKmh":`, `"radiusKm":`, `"shiftType":"night"`, `"status":`, `"message":`, `"data":`},
+			absent: []string{`"travel_minutes"`, `"speed_kmh"`, `"radius_km"`}},
+		{naming: SnakeCaseFields,
+			want:   []string{`"travel_minutes":`, `"speed_kmh":`, `"radius_km":`, `"shiftType":"night"`, `"status":`, `"message":`, `"data":`},
+			absent: []string{`"travelMinutes"`, `"speedKmh"`, `"radiusKm"`, `"shift_type"`}},
+	}
+	for _, test := range tests {
+		routes := newTestRoutes(Config{FieldNaming: test.naming}, jobs...)
+		recorder := httptest.NewRecorder()
+		routes.ServeHTTP(recorder, newRequest(http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=5&mode=walk", ""))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("naming %q: status %d, want 200", test.naming, recorder.Code)
+		}
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, recorder.Body.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		body := compacted.String()
+		for _, field := range test.want {
+			if !strings.Contains(body, field) {
+				t.Errorf("naming %q: %s not in %s", test.naming, field, body)
+			}
+		}
+		for _, field := range test.absent {
+			if strings.Contains(body, field) {
+				t.Errorf("naming %q: %s in %s", test.naming, field, body)
+			}
+		}
+	}
+
+	// map keys of data are titles, left as they are
+	routes := newTestRoutes(Config{FieldNaming: SnakeCaseFields}, jobs...)
+	_, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearest/per-title?latitude=1.29&longitude=103.85&title=NurseAssistant&title=Nurse", "")
+	var nearest map[string]json.RawMessage
+	decodeData(t, response, &nearest)
+	var titles []string
+	for title := range nearest {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+	if !reflect.DeepEqual(titles, []string{"Nurse", "NurseAssistant"}) {
+		t.Errorf("titles %v, want NurseAssistant and Nurse as given", titles)
+	}
+
+	for naming, ok := range map[string]bool{"camel": true, "Snake": true, "kebab": false} {
+		if _, parsed := ParseFieldNaming(naming); parsed != ok {
+			t.Errorf("ParseFieldNaming(%q) ok = %v, want %v", naming, parsed, ok)
+		}
+	}
+}
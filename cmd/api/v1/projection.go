@@ -0,0 +1,252 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/ercross/grabjobs/internal/models"
+	"math"
+	"sort"
+	"strings"
+)
+
+// coordinateProjection is the coordinate reference system job locations are sent in
+type coordinateProjection string
+
+const (
+	// wgs84 sends locations as longitude and latitude in degrees, as they are stored
+	wgs84 coordinateProjection = "4326"
+
+	// webMercator sends locations as easting and northing in meters on the Web Mercator
+	// projection, in place of longitude and latitude respectively
+	webMercator coordinateProjection = "3857"
+)
+
+// parseCoordinateProjection parses the EPSG code of a coordinate projection,
+// with or without the EPSG: prefix. An empty code selects wgs84.
+func parseCoordinateProjection(code string) (projection coordinateProjection, ok bool) {
+	switch coordinateProjection(strings.TrimPrefix(strings.ToUpper(code), "EPSG:")) {
+	case "", wgs84:
+		return wgs84, true
+	case webMercator:
+		return webMercator, true
+	}
+	return "", false
+}
+
+// webMercatorRadius is the radius in meters of the sphere Web Mercator projects
+const webMercatorRadius = 6378137
+
+// webMercatorMaxLatitude is the latitude beyond which Web Mercator is undefined,
+// the projection mapping the world to a square
+const webMercatorMaxLatitude = 85.05112878
+
+// toMercator projects location to Web Mercator (EPSG:3857), setting the easting and northing
+// in meters in place of Longitude and Latitude. Latitudes beyond webMercatorMaxLatitude are
+// clamped to it
+func toMercator(location models.Location) models.Location {
+	latitude := math.Max(-webMercatorMaxLatitude, math.Min(location.Latitude, webMercatorMaxLatitude))
+	location.Longitude = webMercatorRadius * location.Longitude * math.Pi / 180
+	location.Latitude = webMercatorRadius * math.Log(math.Tan(math.Pi/4+latitude*math.Pi/360))
+	return location
+}
+
+// project projects location to p
+func (p coordinateProjection) project(location models.Location) models.Location {
+	if p == webMercator {
+		return toMercator(location)
+	}
+	return location
+}
+
+// jobFields is the set of job fields a response is projected to,
+// along with the coordinate projection of their locations.
+// The zero jobFields projects to all fields in WGS84.
+type jobFields struct {
+	title    bool
+	location bool
+	distance bool
+	company  bool
+	labels   bool
+
+	projection coordinateProjection
+}
+
+// parseJobFields parses the comma-separated list of job field names
+// in the fields query parameter. An empty list selects all fields.
+func parseJobFields(fields string) (jobFields, error) {
+	var projection jobFields
+	if notValidString(fields) {
+		return projection, nil
+	}
+
+	for _, field := range strings.Split(fields, ",") {
+		switch strings.TrimSpace(field) {
+		case "title":
+			projection.title = true
+		case "location":
+			projection.location = true
+		case "distance":
+			projection.distance = true
+		case "company":
+			projection.company = true
+		case "labels":
+			projection.labels = true
+		default:
+			return projection, fmt.Errorf("unknown field %q, expected any of title, location, distance, company, labels", field)
+		}
+	}
+	return projection, nil
+}
+
+func (f jobFields) all() bool {
+	return !f.title && !f.location && !f.distance && !f.company && !f.labels
+}
+
+// projectLocations returns jobs with their locations projected to f.projection,
+// jobs themselves if f.projection is WGS84
+func (f jobFields) projectLocations(jobs []models.Job) []models.Job {
+	if f.projection != webMercator {
+		return jobs
+	}
+
+	projected := make([]models.Job, len(jobs))
+	for i, job := range jobs {
+		job.Location = toMercator(job.Location)
+		projected[i] = job
+	}
+	return projected
+}
+
+// projectLocationsWithDistance is projectLocations for jobs annotated with their distance
+func (f jobFields) projectLocationsWithDistance(jobs []models.JobWithDistance) []models.JobWithDistance {
+	if f.projection != webMercator {
+		return jobs
+	}
+
+	projected := make([]models.JobWithDistance, len(jobs))
+	for i, job := range jobs {
+		job.Location = toMercator(job.Location)
+		projected[i] = job
+	}
+	return projected
+}
+
+// jobDTO is a job projected to the fields requested by client.
+// Fields not requested are nil and omitted from the response,
+// except ID which is always sent so that jobs stay identifiable.
+type jobDTO struct {
+	ID       string           `json:"id"`
+	Title    *string          `json:"title,omitempty"`
+	Location *models.Location `json:"location,omitempty"`
+	Distance *float64         `json:"distance,omitempty"`
+	Company  *string          `json:"company,omitempty"`
+
+	// Labels are omitted if not requested or if the job has none
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// TravelMinutes is set on jobs found with a travel mode only
+	TravelMinutes *float64 `json:"travelMinutes,omitempty"`
+}
+
+func (f jobFields) newJobDTO(job models.Job) jobDTO {
+	dto := jobDTO{ID: job.ID}
+	if f.title {
+		dto.Title = &job.Title
+	}
+	if f.location {
+		location := f.projection.project(job.Location)
+		dto.Location = &location
+	}
+	if f.company && job.Company != "" {
+		dto.Company = &job.Company
+	}
+	if f.labels {
+		dto.Labels = job.Labels
+	}
+	return dto
+}
+
+// projectJobs projects each of jobs to f.
+// jobs are returned as is, but for their locations, if f selects all fields.
+func (f jobFields) projectJobs(jobs []models.Job) interface{} {
+	if f.all() {
+		return f.projectLocations(jobs)
+	}
+
+	dtos := make([]jobDTO, len(jobs))
+	for i, job := range jobs {
+		dtos[i] = f.newJobDTO(job)
+	}
+	return dtos
+}
+
+// projectJobsWithDistance projects each of jobs to f.
+// jobs are returned as is, but for their locations, if f selects all fields.
+func (f jobFields) projectJobsWithDistance(jobs []models.JobWithDistance) interface{} {
+	if f.all() {
+		return f.projectLocationsWithDistance(jobs)
+	}
+
+	dtos := make([]jobDTO, len(jobs))
+	for i := range jobs {
+		dtos[i] = f.newJobDTO(jobs[i].Job)
+		if f.distance {
+			dtos[i].Distance = &jobs[i].Distance
+		}
+	}
+	return dtos
+}
+
+// projectJobsWithTravelTime projects each of jobs to f, along with
+// the estimated minutes taken to travel its distance at speedKmh
+func (f jobFields) projectJobsWithTravelTime(jobs []models.JobWithDistance, speedKmh float64) []jobDTO {
+	if f.all() {
+		f = jobFields{title: true, location: true, distance: true, company: true, labels: true, projection: f.projection}
+	}
+
+	dtos := make([]jobDTO, len(jobs))
+	for i := range jobs {
+		dtos[i] = f.newJobDTO(jobs[i].Job)
+		if f.distance {
+			dtos[i].Distance = &jobs[i].Distance
+		}
+		minutes := models.TravelMinutes(jobs[i].Distance, speedKmh)
+		dtos[i].TravelMinutes = &minutes
+	}
+	return dtos
+}
+
+// projectTitleJobs projects the jobs of each title in titleJobs to f.
+// titleJobs is returned as is if f selects all fields in WGS84.
+func (f jobFields) projectTitleJobs(titleJobs map[string][]models.Job) interface{} {
+	if f.all() && f.projection != webMercator {
+		return titleJobs
+	}
+
+	projected := make(map[string]interface{}, len(titleJobs))
+	for title, jobs := range titleJobs {
+		projected[title] = f.projectJobs(jobs)
+	}
+	return projected
+}
+
+// titleJobsDTO is a title along with its jobs, listed as an element of an array
+type titleJobsDTO struct {
+	Title string      `json:"title"`
+	Jobs  interface{} `json:"jobs"`
+}
+
+// listTitleJobs projects the jobs of each title in titleJobs to f,
+// listing titles alphabetically
+func (f jobFields) listTitleJobs(titleJobs map[string][]models.Job) []titleJobsDTO {
+	titles := make([]string, 0, len(titleJobs))
+	for title := range titleJobs {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+
+	list := make([]titleJobsDTO, len(titles))
+	for i, title := range titles {
+		list[i] = titleJobsDTO{Title: title, Jobs: f.projectJobs(titleJobs[title])}
+	}
+	return list
+}
@@ -0,0 +1,130 @@
+package v1
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestJobFields(t *testing.T) {
+	routes := newTestRoutes(Config{},
+		models.Job{Title: "Nurse", Company: "Clinic", Location: models.Location{Longitude: 103.85, Latitude: 1.29}},
+		models.Job{Title: "Driver", Company: "Taxi", Location: models.Location{Longitude: 103.86, Latitude: 1.3}},
+	)
+
+	tests := []struct {
+		fields string
+		keys   []string
+	}{
+		{fields: "title", keys: []string{"id", "title"}},
+		{fields: "title,location", keys: []string{"id", "location", "title"}},
+	}
+	for _, test := range tests {
+		status, response := serve(t, routes, http.MethodGet,
+			"/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=5&fields="+test.fields, "")
+		if status != http.StatusOK {
+			t.Fatalf("fields=%s: status %d, want 200", test.fields, status)
+		}
+		var jobs []map[string]json.RawMessage
+		decodeData(t, response, &jobs)
+		if len(jobs) != 2 {
+			t.Fatalf("fields=%s: found %d jobs, want 2", test.fields, len(jobs))
+		}
+		for _, job := range jobs {
+			keys := make([]string, 0, len(job))
+			for key := range job {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if strings.Join(keys, ",") != strings.Join(test.keys, ",") {
+				t.Errorf("fields=%s: job has fields %v, want %v", test.fields, keys, test.keys)
+			}
+		}
+	}
+
+	// all fields by default
+	status, response := serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&radius=5", "")
+	var jobs []models.Job
+	decodeData(t, response, &jobs)
+	if status != http.StatusOK || len(jobs) != 2 || jobs[0].Title == "" || jobs[0].Company == "" || jobs[0].Location == (models.Location{}) {
+		t.Errorf("no fields: status %d and jobs %v, want 200 and 2 jobs with every field", status, jobs)
+	}
+
+	status, response = serve(t, routes, http.MethodGet, "/api/v1/jobs/nearby?latitude=1.29&longitude=103.85&fields=salary", "")
+	if status != http.StatusUnprocessableEntity || len(response.Errors) != 1 || response.Errors[0].Field != "fields" {
+		t.Errorf("unknown field: status %d and errors %v, want 422 with an invalid fields", status, response.Errors)
+	}
+}
+
+func TestToMercator(t *testing.T) {
+	tests := []struct {
+		location models.Location
+		easting  float64
+		northing float64
+	}{
+		{location: models.Location{Longitude: 0, Latitude: 0}, easting: 0, northing: 0},
+		{location: models.Location{Longitude: 180, Latitude: 45}, easting: 20037508.342789244, northing: 5621521.486192066},
+		{location: models.Location{Longitude: -180, Latitude: -45}, easting: -20037508.342789244, northing: -5621521.486192066},
+		{location: models.Location{Longitude: -0.1278, Latitude: 51.5074}, easting: -14226.63, northing: 6711542.48},
+
+		// the world projects to a square, clamped beyond its latitude
+		{location: models.Location{Longitude: 0, Latitude: 89}, easting: 0, northing: 20037508.34},
+		{location: models.Location{Longitude: 0, Latitude: -90}, easting: 0, northing: -20037508.34},
+	}
+	for _, test := range tests {
+		projected := toMercator(test.location)
+		if math.Abs(projected.Longitude-test.easting) > 0.01 || math.Abs(projected.Latitude-test.northing) > 0.01 {
+			t.Errorf("toMercator(%v) = (%v, %v), want (%v, %v)",
+				test.location, projected.Longitude, projected.Latitude, test.easting, test.northing)
+		}
+	}
+}
+
+func TestParseCoordinateProjection(t *testing.T) {
+	tests := []struct {
+		code       string
+		projection coordinateProjection
+		ok         bool
+	}{
+		{code: "", projection: wgs84, ok: true},
+		{code: "4326", projection: wgs84, ok: true},
+		{code: "3857", projection: webMercator, ok: true},
+		{code: "epsg:3857", projection: webMercator, ok: true},
+		{code: "EPSG:4326", projection: wgs84, ok: true},
+		{code: "900913", ok: false},
+	}
+	for _, test := range tests {
+		projection, ok := parseCoordinateProjection(test.code)
+		if projection != test.projection || ok != test.ok {
+			t.Errorf("parseCoordinateProjection(%q) = %q, %v, want %q, %v", test.code, projection, ok, test.projection, test.ok)
+		}
+	}
+}
+
+func TestNearbyInWebMercator(t *testing.T) {
+	routes := newTestRoutes(Config{}, models.Job{Title: "Nurse", Location: models.Location{Longitude: -0.1278, Latitude: 51.5074}})
+	target := "/api/v1/jobs/nearby?latitude=51.5074&longitude=-0.1278&radius=1"
+
+	status, response := serve(t, routes, http.MethodGet, target+"&projection=3857", "")
+	var jobs []models.Job
+	decodeData(t, response, &jobs)
+	if status != http.StatusOK || len(jobs) != 1 ||
+		math.Abs(jobs[0].Location.Longitude+14226.63) > 0.01 || math.Abs(jobs[0].Location.Latitude-6711542.48) > 0.01 {
+		t.Errorf("status %d and jobs %v, want 200 and a job at (-14226.63, 6711542.48)", status, jobs)
+	}
+
+	status, response = serve(t, routes, http.MethodGet, target, "")
+	decodeData(t, response, &jobs)
+	if status != http.StatusOK || len(jobs) != 1 || jobs[0].Location.Longitude != -0.1278 {
+		t.Errorf("status %d and jobs %v, want 200 and a job in WGS84", status, jobs)
+	}
+
+	if status, _ := serve(t, routes, http.MethodGet, target+"&projection=900913", ""); status != http.StatusUnprocessableEntity {
+		t.Errorf("unknown projection: status %d, want 422", status)
+	}
+}
@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ercross/grabjobs/internal/db"
+)
+
+// TestQueriesDuringRebuild queries continuously while the index is rebuilt, under either policy:
+// queries are served from the jobs before the rebuild, or rejected with 503 while it lasts.
+// Run with -race.
+func TestQueriesDuringRebuild(t *testing.T) {
+	random := rand.New(rand.NewSource(1439))
+	lines := make([]string, 5000)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("Nurse,%f,%f", 103.8+random.Float64()*0.1, 1.25+random.Float64()*0.1)
+	}
+	const target = "/api/v1/jobs/nearby?latitude=1.3&longitude=103.85&radius=3&fields=title"
+
+	for _, unavailable := range []bool{false, true} {
+		t.Run(fmt.Sprintf("UnavailableDuringRebuild=%v", unavailable), func(t *testing.T) {
+			dataset := newTestDataset(t, db.Options{}, lines...)
+			t.Cleanup(func() { dataset.Close() })
+			routes := Routes(dataset, Config{UnavailableDuringRebuild: unavailable})
+			_, response := serve(t, routes, http.MethodGet, target, "")
+			var before []jobDTO
+			decodeData(t, response, &before)
+
+			var rejected atomic.Int64
+			done := make(chan struct{})
+			var wg sync.WaitGroup
+			for reader := 0; reader < 4; reader++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-done:
+							return
+						default:
+						}
+						recorder := httptest.NewRecorder()
+						routes.ServeHTTP(recorder, newRequest(http.MethodGet, target, ""))
+						switch recorder.Code {
+						case http.StatusOK:
+							var found []jobDTO
+							decodeData(t, decodeResponse(t, recorder), &found)
+							if len(found) != len(before) {
+								t.Errorf("found %d jobs during a rebuild, want the %d before it", len(found), len(before))
+								return
+							}
+						case http.StatusServiceUnavailable:
+							rejected.Add(1)
+							if !unavailable || recorder.Header().Get("Retry-After") == "" {
+								t.Errorf("status 503 with Retry-After %q, want 200 unless unavailable during rebuilds",
+									recorder.Header().Get("Retry-After"))
+								return
+							}
+						default:
+							t.Errorf("status %d during a rebuild", recorder.Code)
+							return
+						}
+					}
+				}()
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for rebuilds := 0; rebuilds < 2 || unavailable && rejected.Load() == 0 && time.Now().Before(deadline); rebuilds++ {
+				dataset.Rebuild()
+			}
+			close(done)
+			wg.Wait()
+
+			if unavailable && rejected.Load() == 0 {
+				t.Error("no query rejected during rebuilds")
+			}
+			if status, _ := serve(t, routes, http.MethodGet, target, ""); status != http.StatusOK {
+				t.Errorf("status %d once rebuilt, want 200", status)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	"net/http"
+)
+
+// maxRouteSamples is the maximum number of locations a route is sampled with by getJobsAlongRoute
+// to find candidate jobs, beyond which samples are spread further apart along longer routes
+const maxRouteSamples = 1000
+
+// getJobsAlongRoute fetches jobs within radius of a route through several stops, in order,
+// i.e., of any stop or of the great-circle arc connecting consecutive stops, e.g., along a commute.
+// Each job is annotated with its distance to the nearest point of the route,
+// and jobs are ordered from the nearest.
+// Request Method: POST
+// Request Body: application/json
+//
+//	{
+//		"stops": [{"longitude": decimal/float, "latitude": decimal/float}], at least 2
+//		"radius": decimal/float, capped at Config.MaxRadiusKm
+//	}
+//
+// Query Parameters:
+//
+//	fields 		optional comma-separated list of title, location, distance
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//	label 		optional key:value, repeated to only find jobs having every label
+//
+// Response Type: application/json
+// meta.lengthKm is the length of the route.
+func (app *App) getJobsAlongRoute(w http.ResponseWriter, r *http.Request) {
+
+	var input struct {
+		Stops  []models.Location `json:"stops"`
+		Radius float64           `json:"radius"`
+	}
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.sendBadRequestResponse(w, err)
+		return
+	}
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	labels, ok := app.readLabels(w, r)
+	if !ok {
+		return
+	}
+
+	if len(input.Stops) < 2 {
+		app.sendFailedValidationResponse(w, validationError("stops", codeRequired, "at least two stops must be provided"))
+		return
+	}
+	for i, stop := range input.Stops {
+		if errors := validateLocation(fmt.Sprintf("stops[%d]", i), stop); len(errors) != 0 {
+			app.sendFailedValidationResponse(w, errors...)
+			return
+		}
+	}
+	if !app.checkGeometry(w, r, "stops", len(input.Stops), models.BoxAround(input.Stops)) {
+		return
+	}
+
+	if input.Radius < 0 {
+		app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius must not be negative"))
+		return
+	}
+	radius, withinLimit := app.capRadius(input.Radius)
+	if !withinLimit {
+		app.sendFailedValidationResponse(w, validationError("radius", codeOutOfRange,
+			fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+		return
+	}
+
+	// candidates are found around locations sampled along the route, every point of the route
+	// lying within step/2 of a sample, hence every job within radius of the route lies within
+	// radius+step/2 of a sample. Candidates are then filtered by their distance to the route.
+	sphere := app.repository(r).Sphere()
+	length := sphere.RouteLength(input.Stops)
+	step := radius
+	if minStep := length / maxRouteSamples; step < minStep {
+		step = minStep
+	}
+	samples := sphere.SampleRoute(input.Stops, step)
+	candidates, err := app.repository(r).FindJobsNearAny(r.Context(), samples, radius+step/2, rtree.Inclusive)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding jobs within a radius of %f along %v", radius, input.Stops))
+		return
+	}
+
+	jobs := make([]models.JobWithDistance, 0, len(candidates))
+	for _, candidate := range candidates {
+		distance := sphere.DistanceToRoute(candidate.Location, input.Stops)
+		if distance <= radius && candidate.HasLabels(labels) {
+			jobs = append(jobs, models.JobWithDistance{Job: candidate.Job, Distance: distance})
+		}
+	}
+	models.SortNearestFirst(jobs)
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Jobs along your route",
+	}
+	args.addMeta("lengthKm", length)
+	keep, withinBudget := app.checkResultBudget(args, len(jobs))
+	if !withinBudget {
+		return
+	}
+
+	app.sendJSONResponse(args, fields.projectJobsWithDistance(jobs[:keep]))
+}
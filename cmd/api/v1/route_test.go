@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestJobsAlongRoute(t *testing.T) {
+	stops := []models.Location{
+		{Longitude: 103.8, Latitude: 1.3},
+		{Longitude: 103.9, Latitude: 1.3},
+		{Longitude: 103.9, Latitude: 1.4},
+	}
+
+	// jobs on a grid around the route, every 0.01° about 1.1km
+	var jobs []models.Job
+	for x := 0; x <= 16; x++ {
+		for y := 0; y <= 16; y++ {
+			jobs = append(jobs, models.Job{
+				Title:    fmt.Sprintf("Job %d-%d", x, y),
+				Location: models.Location{Longitude: 103.77 + float64(x)*0.01, Latitude: 1.27 + float64(y)*0.01},
+			})
+		}
+	}
+	routes := newTestRoutes(Config{}, jobs...)
+
+	for _, radius := range []float64{0.5, 2, 3.5} {
+		want := make(map[string]float64)
+		for _, job := range jobs {
+			if distance := models.Earth.DistanceToRoute(job.Location, stops); distance <= radius {
+				want[job.Title] = distance
+			}
+		}
+
+		status, response := serve(t, routes, http.MethodPost, "/api/v1/jobs/route",
+			string(mustMarshal(t, map[string]interface{}{"stops": stops, "radius": radius})))
+		if status != http.StatusOK {
+			t.Fatalf("radius %vkm: status %d, want 200", radius, status)
+		}
+		var found []models.JobWithDistance
+		decodeData(t, response, &found)
+
+		if len(found) != len(want) || len(want) == 0 {
+			t.Errorf("radius %vkm: found %d jobs, want %d", radius, len(found), len(want))
+		}
+		seen := make(map[string]bool)
+		for _, job := range found {
+			distance, ok := want[job.Title]
+			if !ok || seen[job.Title] {
+				t.Errorf("radius %vkm: found %s %vkm from the route, want each job within radius once", radius, job.Title, job.Distance)
+			}
+			seen[job.Title] = true
+			if math.Abs(job.Distance-distance) > 1e-9 {
+				t.Errorf("radius %vkm: %s %vkm from the route, want %vkm", radius, job.Title, job.Distance, distance)
+			}
+		}
+		if !sort.SliceIsSorted(found, func(i, j int) bool { return found[i].Distance < found[j].Distance }) {
+			t.Errorf("radius %vkm: jobs not ordered from the nearest", radius)
+		}
+		if length := response.Meta["lengthKm"]; length != models.Earth.RouteLength(stops) {
+			t.Errorf("radius %vkm: meta.lengthKm %v, want %v", radius, length, models.Earth.RouteLength(stops))
+		}
+	}
+
+	for _, body := range []string{
+		`{"stops": [{"longitude": 103.8, "latitude": 1.3}], "radius": 1}`,
+		`{"stops": [{"longitude": 103.8, "latitude": 1.3}, {"longitude": 200, "latitude": 1.3}], "radius": 1}`,
+		`{"stops": [{"longitude": 103.8, "latitude": 1.3}, {"longitude": 103.9, "latitude": 1.3}], "radius": -1}`,
+	} {
+		if status, _ := serve(t, routes, http.MethodPost, "/api/v1/jobs/route", body); status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status %d, want 422", body, status)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/db/memory"
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// TestHandlersAgainstMemoryRepository serves a valid request to every handler but subscribeJobs,
+// which needs a websocket client, from a memory.MemoryRepository, without a data file nor an index
+func TestHandlersAgainstMemoryRepository(t *testing.T) {
+	repo := memory.NewMemoryRepository(
+		models.Job{Title: "Nurse", Company: "Clinic", Location: models.Location{Longitude: 103.85, Latitude: 1.29}},
+		models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.86, Latitude: 1.3}},
+		models.Job{Title: "Driver", Company: "Taxi", Location: models.Location{Longitude: 103.87, Latitude: 1.31}},
+		models.Job{Title: "Cook", Location: models.Location{Longitude: 103.9, Latitude: 1.35}},
+	)
+	titleJobs, _ := repo.TitleJobs()
+	id := titleJobs["nurse"][0].ID
+	routes := Routes(repo, Config{})
+
+	const (
+		center = "latitude=1.29&longitude=103.85"
+		box    = "minLat=1.2&minLon=103.8&maxLat=1.4&maxLon=104"
+	)
+	tests := []struct {
+		method, target, body string
+		status               int
+	}{
+		{http.MethodGet, "/healthz", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/available", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/stats", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/distribution", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/bootstrap", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/autocomplete?prefix=nu", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/id/" + id, "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/id/" + id + "/neighbors?k=2", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/nearby?" + center + "&radius=10", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/reachable?" + center + "&minutes=10", "", http.StatusOK},
+		{http.MethodPost, "/api/v1/jobs/nearby/more", `{"center": {"longitude": 103.85, "latitude": 1.29}, "radius": 10}`, http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/nearby/title-counts?" + center + "&radius=10", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/nearby/count?" + center + "&radius=10", "", http.StatusOK},
+		{http.MethodPost, "/api/v1/jobs/near-any", `{"centers": [{"longitude": 103.85, "latitude": 1.29}], "radius": 10}`, http.StatusOK},
+		{http.MethodPost, "/api/v1/jobs/route", `{"stops": [{"longitude": 103.85, "latitude": 1.29}, {"longitude": 103.9, "latitude": 1.35}], "radius": 1}`, http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/near-address?address=Raffles+Place", "", http.StatusNotImplemented},
+		{http.MethodGet, "/api/v1/jobs/search/advanced?" + center + "&radius=10&title=nurse", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/at?" + center, "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/hotspot?radius=5", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/nearest?" + center + "&k=2", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/nearest/per-title?" + center + "&title=nurse&title=driver", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/entries?" + box, "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/sample?" + box + "&n=2", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/density?" + box, "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/gaps?" + box, "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/centroid?title=nurse", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/jobs/top-jobs/around-me?" + center + "&title=nurse", "", http.StatusOK},
+		{http.MethodPost, "/api/v1/geofences", `{"name": "cbd", "center": {"longitude": 103.85, "latitude": 1.29}, "radius": 2}`, http.StatusCreated},
+		{http.MethodGet, "/api/v1/geofences/cbd/jobs", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/admin/spatial-join?titleA=nurse&titleB=driver&radius=5", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/admin/datasets/diff?to=candidate", "", http.StatusUnprocessableEntity},
+		{http.MethodPost, "/api/v1/jobs", `{"title": "Welder", "location": {"longitude": 103.88, "latitude": 1.32}}`, http.StatusCreated},
+		{http.MethodPost, "/api/v1/jobs/bulk", `[{"title": "Tailor", "location": {"longitude": 103.88, "latitude": 1.32}}]`, http.StatusCreated},
+		{http.MethodGet, "/api/v1/jobs/export", "", http.StatusOK},
+		{http.MethodDelete, "/api/v1/admin/jobs?title=cook&" + center + "&radius=20", "", http.StatusOK},
+		{http.MethodGet, "/metrics", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		routes.ServeHTTP(recorder, newRequest(test.method, test.target, test.body))
+		if recorder.Code != test.status {
+			t.Errorf("%s %s: status %d, want %d\n%s", test.method, test.target, recorder.Code, test.status, recorder.Body.String())
+		}
+	}
+}
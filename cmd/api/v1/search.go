@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	"net/http"
+	"strconv"
+)
+
+// defaultSearchLimit and maxSearchLimit are the default and maximum
+// number of jobs in a page fetched by getAdvancedSearch
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 200
+)
+
+// searchPosition is the position of the last job of a page of search results,
+// i.e., its sort key, encoded in the cursor of the next page
+type searchPosition struct {
+	Distance float64 `json:"d"`
+	Title    string  `json:"t"`
+	ID       string  `json:"id"`
+}
+
+// readPositionCursor reads the cursor query parameter of r, encoded with encodePositionCursor.
+// position is nil if no cursor is set. If the cursor is not valid,
+// a failed validation response is sent to client and ok is false.
+func (app *App) readPositionCursor(w http.ResponseWriter, r *http.Request) (position *models.NearestPosition, ok bool) {
+	cursor := r.URL.Query().Get("cursor")
+	if notValidString(cursor) {
+		return nil, true
+	}
+	var decoded searchPosition
+	value, err := decodeCursor(cursor)
+	if err == nil {
+		err = json.Unmarshal([]byte(value), &decoded)
+	}
+	if err != nil {
+		app.sendFailedValidationResponse(w, validationError("cursor", codeInvalid, "cursor is not valid"))
+		return nil, false
+	}
+	return (*models.NearestPosition)(&decoded), true
+}
+
+// encodePositionCursor encodes the position of job, the last of a page ordered
+// as with models.SortNearestFirst, into the cursor of the next page
+func encodePositionCursor(job models.JobWithDistance) (string, error) {
+	position, err := json.Marshal(searchPosition{Distance: job.Distance, Title: job.Title, ID: job.ID})
+	return encodeCursor(string(position)), err
+}
+
+// getAdvancedSearch fetches jobs matching several filters at once, applied in this order:
+// jobs within radius of a location, then having a title, then having every label.
+// Jobs are annotated with their distance, ordered from the nearest, and paginated:
+// meta.nextCursor, if set, fetches the following page, and meta.total counts every job matching.
+// Request Method: GET
+// Query Parameters:
+//
+//	latitude 	decimal/float
+//	longitude 	decimal/float
+//	radius 		optional decimal/float, default Config.DefaultRadius, capped at Config.MaxRadiusKm
+//	title 		optional string, matched as in top-jobs/around-me
+//	label 		optional key:value, repeated to only find jobs having every label
+//	limit 		optional integer, default 20, at most 200
+//	cursor 		optional string, meta.nextCursor of the previous page
+//	fields 		optional comma-separated list of title, location, distance, company, labels
+//	projection 	optional EPSG code of job locations, 4326 (WGS84 degrees, default) or
+//			3857 (Web Mercator meters, easting as longitude and northing as latitude)
+//
+// Response Type: application/json
+func (app *App) getAdvancedSearch(w http.ResponseWriter, r *http.Request) {
+
+	center, ok := app.readLocation(w, r)
+	if !ok {
+		return
+	}
+
+	var radius float64
+	if value := r.URL.Query().Get("radius"); !notValidString(value) {
+		var err error
+		radius, err = strconv.ParseFloat(value, 64)
+		if err != nil || radius < 0 {
+			app.sendFailedValidationResponse(w, validationError("radius", codeInvalid, "radius not a valid non-negative decimal/float"))
+			return
+		}
+	}
+	if radius == 0 {
+		radius = app.repository(r).DefaultRadius().Kilometers()
+	}
+	radius, withinLimit := app.capRadius(radius)
+	if !withinLimit {
+		app.sendFailedValidationResponse(w, validationError("radius", codeOutOfRange,
+			fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+		return
+	}
+
+	labels, ok := app.readLabels(w, r)
+	if !ok {
+		return
+	}
+
+	fields, ok := app.readJobFields(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultSearchLimit
+	if value := r.URL.Query().Get("limit"); !notValidString(value) {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			app.sendFailedValidationResponse(w, validationError("limit", codeInvalid,
+				fmt.Sprintf("limit must be an integer between 1 and %d", maxSearchLimit)))
+			return
+		}
+	}
+
+	position, ok := app.readPositionCursor(w, r)
+	if !ok {
+		return
+	}
+
+	// spatial prune
+	jobs, err := app.repository(r).FindJobsNearby(r.Context(), center, radius, rtree.Inclusive)
+	if err != nil {
+		app.sendServerErrorResponse(w, fmt.Errorf("error encountered finding jobs within a radius of %f", radius))
+		return
+	}
+
+	// title
+	title := r.URL.Query().Get("title")
+	if !notValidString(title) {
+		titled, err := app.repository(r).JobsWithTitle(title)
+		if err != nil {
+			app.sendServerErrorResponse(w, fmt.Errorf("error fetching jobs titled %v: %v", title, err))
+			return
+		}
+		ids := make(map[string]bool, len(titled))
+		for _, job := range titled {
+			ids[job.ID] = true
+		}
+		matching := make([]models.Job, 0, len(jobs))
+		for _, job := range jobs {
+			if ids[job.ID] {
+				matching = append(matching, job)
+			}
+		}
+		jobs = matching
+	}
+
+	// labels
+	jobs = models.JobsLabeled(jobs, labels)
+
+	results := models.NearestFirst(app.repository(r).Sphere(), center, jobs)
+	start := 0
+	if position != nil {
+		for start < len(results) && !position.Precedes(results[start]) {
+			start++
+		}
+	}
+	end := start + limit
+	if end > len(results) {
+		end = len(results)
+	}
+	page := results[start:end]
+
+	args := &responseWriterArgs{
+		writer:     w,
+		statusCode: 200,
+		status:     true,
+		message:    "Jobs matching your search",
+	}
+	args.addMeta("radiusKm", radius)
+	args.addMeta("total", len(results))
+	if end < len(results) {
+		next, err := encodePositionCursor(page[len(page)-1])
+		if err != nil {
+			app.sendServerErrorResponse(w, fmt.Errorf("error encoding cursor: %v", err))
+			return
+		}
+		args.addMeta("nextCursor", next)
+	}
+	app.sendJSONResponse(args, fields.projectJobsWithDistance(page))
+}
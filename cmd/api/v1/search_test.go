@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// advancedSearchJobs are jobs about 1.1 km apart eastward of advancedSearchCenter,
+// each of company its index, titled Driver at every third, else Nurse,
+// and labeled shift:night at every second, else shift:day
+func advancedSearchJobs() []models.Job {
+	jobs := make([]models.Job, 10)
+	for i := range jobs {
+		jobs[i] = models.Job{
+			Title:    "Nurse",
+			Company:  strconv.Itoa(i),
+			Location: models.Location{Longitude: 103.85 + float64(i)*0.01, Latitude: 1.29},
+			Labels:   map[string]string{"shift": "day"},
+		}
+		if i%3 == 0 {
+			jobs[i].Title = "Driver"
+		}
+		if i%2 == 0 {
+			jobs[i].Labels["shift"] = "night"
+		}
+	}
+	return jobs
+}
+
+const advancedSearchCenter = "/api/v1/jobs/search/advanced?latitude=1.29&longitude=103.85"
+
+// searchCompanies returns the companies of the jobs found by an advanced search with query,
+// following every page, and the total reported by the first page
+func searchCompanies(t *testing.T, routes http.Handler, query string) (companies []string, total float64) {
+	t.Helper()
+	cursor := ""
+	for pages := 0; ; pages++ {
+		target := advancedSearchCenter + query
+		if cursor != "" {
+			target += "&cursor=" + url.QueryEscape(cursor)
+		}
+		status, response := serve(t, routes, http.MethodGet, target, "")
+		if status != http.StatusOK {
+			t.Fatalf("%s: status %d, want 200", target, status)
+		}
+		if pages == 0 {
+			total, _ = response.Meta["total"].(float64)
+		}
+		var page []models.JobWithDistance
+		decodeData(t, response, &page)
+		for _, job := range page {
+			companies = append(companies, job.Company)
+		}
+		if cursor, _ = response.Meta["nextCursor"].(string); cursor == "" || pages > 20 {
+			return companies, total
+		}
+	}
+}
+
+func TestAdvancedSearch(t *testing.T) {
+	routes := newTestRoutes(Config{}, advancedSearchJobs()...)
+
+	tests := []struct {
+		query     string
+		companies []string
+	}{
+		{query: "&radius=5", companies: []string{"0", "1", "2", "3", "4"}},
+		{query: "&radius=5&title=Driver", companies: []string{"0", "3"}},
+		{query: "&radius=5&title=nurse", companies: []string{"1", "2", "4"}},
+		{query: "&radius=5&label=shift:night", companies: []string{"0", "2", "4"}},
+		{query: "&radius=8&title=Nurse&label=shift:day", companies: []string{"1", "5", "7"}},
+		{query: "&radius=20&title=Driver&label=shift:night", companies: []string{"0", "6"}},
+		{query: "&radius=20&title=Cook", companies: nil},
+		{query: "&radius=0.5&label=shift:day", companies: nil},
+	}
+	for _, test := range tests {
+		for _, limit := range []string{"", "&limit=1", "&limit=2"} {
+			companies, total := searchCompanies(t, routes, test.query+limit)
+			if !reflect.DeepEqual(companies, test.companies) || int(total) != len(test.companies) {
+				t.Errorf("%s: found %v of %v in total, want %v from the nearest", test.query+limit, companies, total, test.companies)
+			}
+		}
+	}
+
+	for _, query := range []string{"&radius=-1", "&limit=0", "&limit=201", "&cursor=nonsense", "&label=shift"} {
+		if status, _ := serve(t, routes, http.MethodGet, advancedSearchCenter+query, ""); status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: status %d, want 422", query, status)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// subscriptionBufferSize is the number of new jobs held for a subscriber
+// not keeping up. Further jobs are dropped until the subscriber catches up,
+// so that slow clients never hold up adding jobs.
+const subscriptionBufferSize = 64
+
+// subscriptionWriteTimeout is the time allowed to send a message to a subscriber
+const subscriptionWriteTimeout = 10 * time.Second
+
+// subscription is a client notified of new jobs added within an area
+type subscription struct {
+	center models.Location
+
+	// radius is in kilometers. A subscription is not notified until it has an area
+	radius  float64
+	hasArea bool
+
+	jobs chan models.Job
+}
+
+// subscriptions fans out newly added jobs to the subscriptions whose area they fall within
+type subscriptions struct {
+	lock sync.RWMutex
+	subs map[*subscription]struct{}
+
+	// sphere is the sphere distances from subscription centers are computed on
+	sphere models.Sphere
+}
+
+func newSubscriptions(sphere models.Sphere) *subscriptions {
+	return &subscriptions{subs: make(map[*subscription]struct{}), sphere: sphere}
+}
+
+// subscribe adds a subscription without an area, notified once setArea is called
+func (s *subscriptions) subscribe() *subscription {
+	sub := &subscription{jobs: make(chan models.Job, subscriptionBufferSize)}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.subs[sub] = struct{}{}
+	return sub
+}
+
+func (s *subscriptions) unsubscribe(sub *subscription) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.subs, sub)
+}
+
+// setArea replaces the area of sub with the circle of radius kilometers around center
+func (s *subscriptions) setArea(sub *subscription, center models.Location, radius float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sub.center, sub.radius, sub.hasArea = center, radius, true
+}
+
+// publish notifies every subscription of the jobs within its area
+func (s *subscriptions) publish(jobs ...models.Job) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for sub := range s.subs {
+		if !sub.hasArea {
+			continue
+		}
+		for _, job := range jobs {
+			if s.sphere.Distance(sub.center, job.Location) > sub.radius {
+				continue
+			}
+			select {
+			case sub.jobs <- job:
+			default:
+				log.Printf("dropped job %v for subscription around %v: subscriber not keeping up", job.ID, sub.center)
+			}
+		}
+	}
+}
+
+// subscriptionRequest is sent by a subscriber to set the area it is notified of new jobs in
+type subscriptionRequest struct {
+	Location *models.Location `json:"location"`
+
+	// Radius is in kilometers. If nil, the repository default radius is used
+	Radius *float64 `json:"radius"`
+}
+
+// subscriptionMessage is sent to a subscriber in the shape of the JSON responses
+// of the other endpoints, either acknowledging a subscriptionRequest or carrying a new job
+type subscriptionMessage struct {
+	Status  bool              `json:"status"`
+	Message string            `json:"message"`
+	Data    interface{}       `json:"data,omitempty"`
+	Errors  []ValidationError `json:"errors,omitempty"`
+}
+
+// upgrader upgrades subscribeJobs requests to websocket connections.
+// Requests from any origin are accepted, as with Access-Control-Allow-Origin: *
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// subscribeJobs pushes jobs added after subscribing within an area to client over a websocket.
+// Client sets or moves the area by sending a subscription request, acknowledged with
+// a message having status true, or false with errors if the request is invalid.
+// Request Method: GET (websocket upgrade)
+// Client Message: application/json
+//
+//	{
+//		"location": {"longitude": decimal/float, "latitude": decimal/float},
+//		"radius": decimal/float (in km, optional, capped at Config.MaxRadiusKm)
+//	}
+//
+// Server Message: application/json, with each new job in data
+func (app *App) subscribeJobs(w http.ResponseWriter, r *http.Request) {
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error
+		log.Printf("error upgrading subscription to websocket: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	sub := app.subscriptions.subscribe()
+	defer app.subscriptions.unsubscribe(sub)
+
+	// the connection is read here and written below, as websocket
+	// connections support one concurrent reader and one concurrent writer
+	replies := make(chan subscriptionMessage, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var request subscriptionRequest
+			if err := conn.ReadJSON(&request); err != nil {
+				return
+			}
+			replies <- app.handleSubscriptionRequest(sub, request, app.repository(r))
+		}
+	}()
+
+	for {
+		var message subscriptionMessage
+		select {
+		case <-done:
+			return
+		case message = <-replies:
+		case job := <-sub.jobs:
+			message = subscriptionMessage{Status: true, Message: "Job added", Data: job}
+		}
+
+		message.Data, _ = app.nameFields(message.Data, nil)
+		_ = conn.SetWriteDeadline(time.Now().Add(subscriptionWriteTimeout))
+		if err := conn.WriteJSON(message); err != nil {
+			log.Printf("error sending subscription message to client: %v", err)
+			return
+		}
+	}
+}
+
+// handleSubscriptionRequest validates request and moves sub to the requested area,
+// returning the message acknowledging request. The radius defaults to the DefaultRadius of repo.
+func (app *App) handleSubscriptionRequest(sub *subscription, request subscriptionRequest, repo repository) subscriptionMessage {
+	var errors []ValidationError
+	if request.Location == nil {
+		errors = append(errors, validationError("location", codeRequired, "location must be set"))
+	} else {
+		errors = append(errors, validateLocation("location", *request.Location)...)
+	}
+
+	radius := repo.DefaultRadius().Kilometers()
+	if request.Radius != nil {
+		radius = *request.Radius
+	}
+	if radius < 0 {
+		errors = append(errors, validationError("radius", codeInvalid, "radius must not be negative"))
+	}
+	radius, withinLimit := app.capRadius(radius)
+	if !withinLimit {
+		errors = append(errors, validationError("radius", codeOutOfRange,
+			fmt.Sprintf("radius must not exceed %v km", app.Config.MaxRadiusKm)))
+	}
+
+	if len(errors) != 0 {
+		return subscriptionMessage{Status: false, Message: "failed validation", Errors: errors}
+	}
+
+	app.subscriptions.setArea(sub, *request.Location, radius)
+	return subscriptionMessage{
+		Status:  true,
+		Message: fmt.Sprintf("Subscribed to jobs within %v km of %v", radius, *request.Location),
+	}
+}
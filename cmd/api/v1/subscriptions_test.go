@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+func TestSubscribeJobs(t *testing.T) {
+	server := httptest.NewServer(newTestRoutes(Config{}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/v1/jobs/subscribe", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	// readMessage reads the next message of the subscription, decoding its job into job
+	readMessage := func(job *models.Job) subscriptionMessage {
+		t.Helper()
+		var message struct {
+			subscriptionMessage
+			Data json.RawMessage `json:"data"`
+		}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+		if job != nil {
+			if err := json.Unmarshal(message.Data, job); err != nil {
+				t.Fatalf("job not decoded: %v\n%s", err, message.Data)
+			}
+		}
+		return message.subscriptionMessage
+	}
+
+	if err := conn.WriteJSON(map[string]interface{}{"location": map[string]float64{"latitude": 1.29}, "radius": -1}); err != nil {
+		t.Fatal(err)
+	}
+	if message := readMessage(nil); message.Status || len(message.Errors) != 1 {
+		t.Errorf("subscription with a negative radius acknowledged with status %v and errors %+v, want a radius error",
+			message.Status, message.Errors)
+	}
+
+	if err := conn.WriteJSON(map[string]interface{}{"location": map[string]float64{"longitude": 103.85, "latitude": 1.29}, "radius": 5}); err != nil {
+		t.Fatal(err)
+	}
+	if message := readMessage(nil); !message.Status {
+		t.Fatalf("subscription not acknowledged: %+v", message)
+	}
+
+	// jobs ~2.2km, ~100km and ~3.3km away of the center
+	for _, job := range []string{
+		`{"title": "Nurse", "location": {"longitude": 103.87, "latitude": 1.29}}`,
+		`{"title": "Driver", "location": {"longitude": 104.75, "latitude": 1.29}}`,
+		`{"title": "Cook", "location": {"longitude": 103.85, "latitude": 1.32}}`,
+	} {
+		response, err := http.Post(server.URL+"/api/v1/jobs", "application/json", strings.NewReader(job))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != http.StatusCreated {
+			t.Fatalf("status %d adding job %s, want %d", response.StatusCode, job, http.StatusCreated)
+		}
+	}
+
+	// pushes are in the order jobs are added, so the job out of area would come between the others
+	for _, want := range []string{"Nurse", "Cook"} {
+		var job models.Job
+		if message := readMessage(&job); !message.Status || job.Title != want {
+			t.Errorf("pushed %q with status %v, want %q", job.Title, message.Status, want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package v1
+
+// codes of ValidationError, for clients to tell validation failures apart
+// without parsing messages
+const (
+	// codeRequired is for a missing or empty value
+	codeRequired = "required"
+
+	// codeInvalid is for a value that cannot be parsed, e.g., a non-numeric latitude
+	codeInvalid = "invalid"
+
+	// codeOutOfRange is for a well-formed value outside the accepted range
+	codeOutOfRange = "out_of_range"
+
+	// codeUnknown is for a parameter or field the endpoint does not know of
+	codeUnknown = "unknown"
+
+	// codeConflict is for a value that cannot be combined with another value
+	codeConflict = "conflict"
+)
+
+// ValidationError describes why a field of a request failed validation.
+// A field may fail validation for several reasons, each being a ValidationError.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validationError returns a ValidationError of field with code and message
+func validationError(field, code, message string) ValidationError {
+	return ValidationError{Field: field, Code: code, Message: message}
+}
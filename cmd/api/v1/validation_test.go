@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestValidateJob(t *testing.T) {
+	job := models.Job{Location: models.Location{Longitude: 200, Latitude: -91}}
+	want := []ValidationError{
+		{Field: "jobs[3].title", Code: codeRequired, Message: "title must not be empty"},
+		{Field: "jobs[3].location.latitude", Code: codeOutOfRange, Message: "latitude must be between -90 and 90"},
+		{Field: "jobs[3].location.longitude", Code: codeOutOfRange, Message: "longitude must be between -180 and 180"},
+	}
+	if errors := validateJob("jobs[3]", job); !reflect.DeepEqual(errors, want) {
+		t.Errorf("validateJob(%+v) = %+v, want %+v", job, errors, want)
+	}
+
+	valid := models.Job{Title: "Nurse", Location: models.Location{Longitude: 103.85, Latitude: 1.29}}
+	if errors := validateJob("jobs[0]", valid); len(errors) != 0 {
+		t.Errorf("validateJob(%+v) = %+v, want no errors", valid, errors)
+	}
+}
+
+func TestValidationErrorsResponse(t *testing.T) {
+	const batch = `[{"title": "", "location": {"longitude": -181, "latitude": 91}}]`
+	status, response := serve(t, newTestRoutes(Config{}), http.MethodPost, "/api/v1/jobs/bulk", batch)
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, want %d", status, http.StatusUnprocessableEntity)
+	}
+	if response.Status {
+		t.Error("status true for a failed validation")
+	}
+
+	codes := make(map[string]string, len(response.Errors))
+	for _, err := range response.Errors {
+		if err.Message == "" {
+			t.Errorf("error of %s has no message", err.Field)
+		}
+		codes[err.Field] = err.Code
+	}
+	want := map[string]string{
+		"jobs[0].title":              codeRequired,
+		"jobs[0].location.latitude":  codeOutOfRange,
+		"jobs[0].location.longitude": codeOutOfRange,
+	}
+	if !reflect.DeepEqual(codes, want) {
+		t.Errorf("codes of errors %v, want %v", codes, want)
+	}
+}
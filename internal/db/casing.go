@@ -0,0 +1,88 @@
+package db
+
+import "github.com/ercross/grabjobs/internal/models"
+
+// TitleCasing specifies how titles differing only in case, e.g., "Nurse" and "nurse", are indexed
+type TitleCasing string
+
+const (
+
+	// MergeTitleCasings keys titles differing only in case alike, in lower case,
+	// jobs keeping their title as is. The zero TitleCasing merges title casings.
+	MergeTitleCasings TitleCasing = "merge"
+
+	// KeepFirstTitleCasing keys titles as MergeTitleCasings, and sets the title of every job
+	// to the casing its title first appeared with, e.g., in the data file
+	KeepFirstTitleCasing TitleCasing = "first"
+
+	// SeparateTitleCasings keys titles in their original case,
+	// so that titles differing only in case are searched apart
+	SeparateTitleCasings TitleCasing = "separate"
+)
+
+// ParseTitleCasing parses casing as any of merge, first or separate
+func ParseTitleCasing(casing string) (titleCasing TitleCasing, ok bool) {
+	switch titleCasing = TitleCasing(casing); titleCasing {
+	case MergeTitleCasings, KeepFirstTitleCasing, SeparateTitleCasings:
+		return titleCasing, true
+	}
+	return "", false
+}
+
+// indexTitleCasings maps the key of each title in jobs to the distinct casings of the titles
+// keyed with it under options, in order of appearance, following the casings already in previous.
+// Only the keys of titles in jobs are mapped
+func indexTitleCasings(jobs []models.Job, previous map[string][]string, options Options) map[string][]string {
+	casings := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, job := range jobs {
+		key := options.titleKey(job.Title)
+		if _, ok := casings[key]; !ok {
+			casings[key] = append([]string{}, previous[key]...)
+			for _, casing := range previous[key] {
+				seen[casing] = true
+			}
+		}
+		if !seen[job.Title] {
+			seen[job.Title] = true
+			casings[key] = append(casings[key], job.Title)
+		}
+	}
+	return casings
+}
+
+// mergedTitleCasings returns the casings of each title key in casings having more than one
+func mergedTitleCasings(casings map[string][]string) map[string][]string {
+	merged := make(map[string][]string)
+	for key, titles := range casings {
+		if len(titles) > 1 {
+			merged[key] = titles
+		}
+	}
+	return merged
+}
+
+// keepFirstTitleCasing returns jobs with the title of every job set to the first of the casings
+// of its title key, copying jobs if any title is changed, as with KeepFirstTitleCasing.
+// jobs are returned as is under any other TitleCasing
+func (o Options) keepFirstTitleCasing(jobs []models.Job, casings map[string][]string) []models.Job {
+	if o.TitleCasing != KeepFirstTitleCasing {
+		return jobs
+	}
+
+	var cased []models.Job
+	for i, job := range jobs {
+		first := casings[o.titleKey(job.Title)][0]
+		if job.Title == first {
+			continue
+		}
+		if cased == nil {
+			cased = append([]models.Job{}, jobs...)
+		}
+		cased[i].Title = first
+	}
+	if cased == nil {
+		return jobs
+	}
+	return cased
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestSeparateTitleCasings(t *testing.T) {
+	lines := []string{"IT,3.1,6.1", "it,3.1,6.1", "It,3.1,6.1", "Nurse,3.1,6.1"}
+	center := models.Location{Longitude: 3.1, Latitude: 6.1}
+
+	tests := []struct {
+		casing TitleCasing
+		titles int
+		found  map[string]int
+	}{
+		{casing: MergeTitleCasings, titles: 2, found: map[string]int{"IT": 3, "it": 3, "iT": 3, "nurse": 1}},
+		{casing: SeparateTitleCasings, titles: 4, found: map[string]int{"IT": 1, "it": 1, "iT": 0, "nurse": 0, "Nurse": 1}},
+	}
+	for _, test := range tests {
+		d := newTestDB(t, Options{TitleCasing: test.casing}, lines...)
+		titleJobs, err := d.TitleJobs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(titleJobs) != test.titles {
+			t.Errorf("TitleCasing %q: %d titles indexed, want %d", test.casing, len(titleJobs), test.titles)
+		}
+		for title, want := range test.found {
+			jobs, err := d.SearchJobsByTitleAndLocation(context.Background(), title, "", center)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(jobs) != want {
+				t.Errorf("TitleCasing %q: found %d %q jobs, want %d", test.casing, len(jobs), title, want)
+			}
+		}
+	}
+}
+
+func TestTitleCasing(t *testing.T) {
+	lines := []string{
+		"Nurse,3.1,6.1",
+		"nurse,3.2,6.2",
+		"NURSE,3.3,6.3",
+		"Driver,3.4,6.4",
+	}
+	tests := []struct {
+		casing TitleCasing
+		// titles maps each title key to the titles of its jobs, sorted
+		titles map[string][]string
+		merged map[string][]string
+	}{
+		{
+			casing: MergeTitleCasings,
+			titles: map[string][]string{"nurse": {"NURSE", "Nurse", "nurse"}, "driver": {"Driver"}},
+			merged: map[string][]string{"nurse": {"Nurse", "nurse", "NURSE"}},
+		},
+		{
+			casing: KeepFirstTitleCasing,
+			titles: map[string][]string{"nurse": {"Nurse", "Nurse", "Nurse"}, "driver": {"Driver"}},
+			merged: map[string][]string{"nurse": {"Nurse", "nurse", "NURSE"}},
+		},
+		{
+			casing: SeparateTitleCasings,
+			titles: map[string][]string{"Nurse": {"Nurse"}, "nurse": {"nurse"}, "NURSE": {"NURSE"}, "Driver": {"Driver"}},
+			merged: map[string][]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(string(test.casing), func(t *testing.T) {
+			d := newTestDB(t, Options{TitleCasing: test.casing}, lines...)
+
+			titleJobs, err := d.TitleJobs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			titles := make(map[string][]string, len(titleJobs))
+			for key, jobs := range titleJobs {
+				for _, job := range jobs {
+					titles[key] = append(titles[key], job.Title)
+				}
+				sort.Strings(titles[key])
+			}
+			if !reflect.DeepEqual(titles, test.titles) {
+				t.Errorf("titles %v, want %v", titles, test.titles)
+			}
+
+			stats, err := d.Stats()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if merged := stats.MergedTitleCasings; len(merged) != 0 || len(test.merged) != 0 {
+				if !reflect.DeepEqual(merged, test.merged) {
+					t.Errorf("merged casings %v, want %v", merged, test.merged)
+				}
+			}
+		})
+	}
+}
+
+func TestTitleCasingOnInsert(t *testing.T) {
+	d := newTestDB(t, Options{TitleCasing: KeepFirstTitleCasing}, "Nurse,3.1,6.1")
+
+	job := models.Job{Title: "nURSE", Location: models.Location{Longitude: 3.2, Latitude: 6.2}}
+	if err := d.AddJob(&job); err != nil {
+		t.Fatal(err)
+	}
+
+	titleJobs, _ := d.TitleJobs()
+	for _, job := range titleJobs["nurse"] {
+		if job.Title != "Nurse" {
+			t.Errorf("inserted job titled %q, want the first casing Nurse", job.Title)
+		}
+	}
+	if len(titleJobs["nurse"]) != 2 {
+		t.Errorf("%d nurse jobs, want 2", len(titleJobs["nurse"]))
+	}
+	stats, _ := d.Stats()
+	if want := []string{"Nurse", "nURSE"}; !reflect.DeepEqual(stats.MergedTitleCasings["nurse"], want) {
+		t.Errorf("merged casings %v, want %v", stats.MergedTitleCasings["nurse"], want)
+	}
+}
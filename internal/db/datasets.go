@@ -0,0 +1,66 @@
+package db
+
+import (
+	"sort"
+	"sync"
+)
+
+// LiveDataset names the dataset DB is initialized with, served by default
+const LiveDataset = "live"
+
+// ReloadDataset reads the location.csv file on filepath into the dataset named name,
+// e.g., a candidate dataset to compare against the live dataset.
+// Datasets other than LiveDataset are created on their first reload, and indexed with
+// the Options of the live dataset, except for Options.WatchFile and Options.RefreshInterval.
+func (d *DB) ReloadDataset(name, filepath string) error {
+	if name == LiveDataset {
+		return d.Reload(filepath)
+	}
+
+	d.datasetsLock.Lock()
+	defer d.datasetsLock.Unlock()
+	if dataset, ok := d.datasets[name]; ok {
+		return dataset.Reload(filepath)
+	}
+
+	options := d.options
+	options.WatchFile = false
+	options.RefreshInterval = 0
+	dataset := &DB{
+		options:       options,
+		titleSynonyms: d.titleSynonyms,
+		lock:          new(sync.Mutex),
+	}
+	if err := dataset.Reload(filepath); err != nil {
+		return err
+	}
+	if d.datasets == nil {
+		d.datasets = make(map[string]*DB)
+	}
+	d.datasets[name] = dataset
+	return nil
+}
+
+// Dataset returns the dataset named name, queried as any DB.
+// ok is false if no dataset is named name.
+func (d *DB) Dataset(name string) (dataset *DB, ok bool) {
+	if name == LiveDataset {
+		return d, true
+	}
+	d.datasetsLock.RLock()
+	defer d.datasetsLock.RUnlock()
+	dataset, ok = d.datasets[name]
+	return dataset, ok
+}
+
+// DatasetNames returns the names of every dataset of d in ascending order, LiveDataset included
+func (d *DB) DatasetNames() []string {
+	d.datasetsLock.RLock()
+	defer d.datasetsLock.RUnlock()
+	names := []string{LiveDataset}
+	for name := range d.datasets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
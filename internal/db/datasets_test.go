@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+)
+
+func TestReloadDataset(t *testing.T) {
+	live := newTestDB(t, Options{}, "Nurse,3.5,6.5")
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+
+	// titles returns the titles of the jobs of the dataset of live named name
+	titles := func(name string) []string {
+		t.Helper()
+		dataset, ok := live.Dataset(name)
+		if !ok {
+			t.Fatalf("no dataset named %s", name)
+		}
+		jobs, err := dataset.FindJobsNearby(context.Background(), center, 5, rtree.Inclusive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles := make([]string, len(jobs))
+		for i, job := range jobs {
+			titles[i] = job.Title
+		}
+		return titles
+	}
+
+	if err := live.ReloadDataset("candidate", writeCSV(t, "Driver,3.5,6.5")); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(LiveDataset); !reflect.DeepEqual(got, []string{"Nurse"}) {
+		t.Errorf("live dataset holds %v, want [Nurse]", got)
+	}
+	if got := titles("candidate"); !reflect.DeepEqual(got, []string{"Driver"}) {
+		t.Errorf("candidate dataset holds %v, want [Driver]", got)
+	}
+	if names := live.DatasetNames(); !reflect.DeepEqual(names, []string{"candidate", LiveDataset}) {
+		t.Errorf("DatasetNames() = %v, want [candidate live]", names)
+	}
+
+	// reloading a dataset replaces its jobs only
+	if err := live.ReloadDataset("candidate", writeCSV(t, "Cook,3.5,6.5")); err != nil {
+		t.Fatal(err)
+	}
+	if err := live.ReloadDataset(LiveDataset, writeCSV(t, "Welder,3.5,6.5")); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles("candidate"); !reflect.DeepEqual(got, []string{"Cook"}) {
+		t.Errorf("reloaded candidate dataset holds %v, want [Cook]", got)
+	}
+	if got := titles(LiveDataset); !reflect.DeepEqual(got, []string{"Welder"}) {
+		t.Errorf("reloaded live dataset holds %v, want [Welder]", got)
+	}
+
+	if _, ok := live.Dataset("unknown"); ok {
+		t.Error("found a dataset never loaded")
+	}
+}
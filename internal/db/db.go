@@ -1,19 +1,224 @@
 package db
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ercross/grabjobs/internal/models"
 	"github.com/ercross/grabjobs/internal/models/rtree"
+	"github.com/fsnotify/fsnotify"
+	"io"
 	"log"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// ErrNoValidRows is returned, wrapped, on loading a data file holding rows
+// none of which is a valid job, e.g., as its columns are not laid out as expected,
+// unlike a file holding no row, which is merely empty
+var ErrNoValidRows = errors.New("no row of the file is a valid job")
+
+// Options configures how DB indexes jobs
+type Options struct {
+
+	// TitleCasing specifies how titles differing only in case, e.g., "IT" and "it", are indexed.
+	// The zero TitleCasing merges them as MergeTitleCasings does, keying titles in lower case,
+	// while SeparateTitleCasings keys jobs by their title in its original case.
+	// The casings merged into each title are reported by DB.Stats
+	TitleCasing TitleCasing
+
+	// SearchWorkers is the number of goroutines computing distances in a large search.
+	// Zero defaults to runtime.GOMAXPROCS
+	SearchWorkers int
+
+	// DefaultRadius is the radius of nearby searches not specifying one.
+	// The zero Distance defaults to 5km
+	DefaultRadius models.Distance
+
+	// EarthRadiusKm is the radius in kilometers of the sphere distances between jobs
+	// are computed on, e.g., for a game world. Zero defaults to models.EarthRadiusKm
+	EarthRadiusKm float64
+
+	// RequireData fails Initialize, and keeps the current jobs on Reload,
+	// if no valid job is read from the file, e.g., on a misconfigured path to an empty file.
+	RequireData bool
+
+	// WatchFile reloads the DB whenever the file it was initialized from changes on disk.
+	// Use DB.Close to stop watching.
+	WatchFile bool
+
+	// RefreshInterval reloads the DB from the file it was initialized from on a schedule,
+	// e.g., where a file watcher is unreliable, as on some network file systems.
+	// Failed reloads keep the current jobs and back off. Use DB.Close to stop refreshing.
+	// Zero disables refreshing.
+	RefreshInterval time.Duration
+
+	// MaxJobs caps the number of jobs held, bounding the memory of the DB.
+	// Jobs are evicted following Evict whenever loading or adding jobs exceeds MaxJobs.
+	// Zero disables the cap.
+	MaxJobs int
+
+	// Evict picks the jobs evicted once MaxJobs is exceeded. Nil defaults to EvictOldest
+	Evict EvictionPolicy
+
+	// TitleSynonyms are groups of titles matching each other when searching jobs by title,
+	// e.g., {"software engineer", "SWE"}, as read with ReadTitleSynonyms.
+	// Jobs are still deleted by their exact title only.
+	TitleSynonyms [][]string
+
+	// WarmQueries are searched whenever jobs are indexed from scratch, e.g., on Initialize or Reload,
+	// and kept up to date as jobs are added or deleted, so that FindJobsNearby serves them without
+	// searching the index. Their results are a static precomputed set, not a cache, as with WarmQuery
+	WarmQueries []WarmQuery
+
+	// Verify checks the jobs found by FindJobsNearby against a scan of every job,
+	// logging mismatches, e.g., to catch a corrupted index. Verifying costs a scan per search.
+	// The zero VerifyMode does not verify searches.
+	Verify VerifyMode
+}
+
+// EvictionPolicy reports whether job a is evicted before job b
+// when the DB holds more than Options.MaxJobs jobs
+type EvictionPolicy func(a, b models.Job) bool
+
+// EvictOldest evicts jobs from the oldest by CreatedAt,
+// jobs read from the data file being older than any job added since
+func EvictOldest(a, b models.Job) bool {
+	if a.CreatedAt == nil || b.CreatedAt == nil {
+		return a.CreatedAt == nil && b.CreatedAt != nil
+	}
+	return a.CreatedAt.Before(*b.CreatedAt)
+}
+
+// EvictMatchingFirst evicts jobs for which match is true before other jobs,
+// and jobs alike as with EvictOldest
+func EvictMatchingFirst(match func(job models.Job) bool) EvictionPolicy {
+	return func(a, b models.Job) bool {
+		if matchA, matchB := match(a), match(b); matchA != matchB {
+			return matchA
+		}
+		return EvictOldest(a, b)
+	}
+}
+
+// evict returns the jobs left once the jobs exceeding MaxJobs under o are evicted
+// following o.Evict, in their original order. Jobs evicted alike are evicted
+// in their original order, e.g., the first of jobs read from the data file.
+// Every ID of jobs must be distinct, as assigned with models.AssignIDs.
+func (o Options) evict(jobs []models.Job) []models.Job {
+	evicted := o.evicted(jobs)
+	if len(evicted) == 0 {
+		return jobs
+	}
+	return withoutJobsMatching(jobs, func(job models.Job) bool { return evicted[job.ID] })
+}
+
+// evicted returns the set of the IDs of the jobs exceeding MaxJobs under o, as evicted with evict.
+// evicted is nil if jobs do not exceed MaxJobs
+func (o Options) evicted(jobs []models.Job) map[string]bool {
+	excess := len(jobs) - o.MaxJobs
+	if o.MaxJobs <= 0 || excess <= 0 {
+		return nil
+	}
+	policy := o.Evict
+	if policy == nil {
+		policy = EvictOldest
+	}
+
+	order := make([]int, len(jobs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return policy(jobs[order[i]], jobs[order[j]]) })
+	evicted := make(map[string]bool, excess)
+	for _, i := range order[:excess] {
+		evicted[jobs[i].ID] = true
+	}
+	log.Printf("evicted %d jobs above the cap of %d jobs", excess, o.MaxJobs)
+	return evicted
+}
+
+// defaultRadius returns the radius of nearby searches not specifying one under o
+func (o Options) defaultRadius() models.Distance {
+	if o.DefaultRadius.Value <= 0 {
+		return models.Distance{Unit: models.Kilometer, Value: 5}
+	}
+	return o.DefaultRadius
+}
+
+// companyKey returns the key company is indexed with.
+// Companies are matched case-insensitively regardless of o.TitleCasing,
+// and ignoring surrounding spaces
+func (o Options) companyKey(company string) string {
+	return strings.ToLower(strings.TrimSpace(company))
+}
+
+// titleKey returns the key title is indexed with under o
+func (o Options) titleKey(title string) string {
+	if o.TitleCasing == SeparateTitleCasings {
+		return title
+	}
+	return models.NormalizeTitle(title)
+}
+
 type DB struct {
-	lock *sync.RWMutex
+	options Options
+
+	// titleSynonyms maps the key of each title having synonyms
+	// to the keys of its synonyms under Options.TitleSynonyms
+	titleSynonyms map[string][]string
+
+	// lock serializes writers, i.e., Reload and Rebuild.
+	// Readers never take lock, they only load the current dataset.
+	lock *sync.Mutex
+
+	// current is the dataset served to readers.
+	// A new dataset is built aside and swapped in atomically,
+	// so readers always see either the complete old or complete new dataset.
+	current atomic.Pointer[dataset]
+
+	// rebuilding is set while a new dataset is indexed from scratch, e.g., on Reload or Rebuild
+	rebuilding atomic.Bool
+
+	// rebuilds, leafSplits, nodeSplits and leafMerges accumulate
+	// models.IndexStats across every index built
+	rebuilds   atomic.Int64
+	leafSplits atomic.Int64
+	nodeSplits atomic.Int64
+	leafMerges atomic.Int64
+
+	// verifyMismatches counts searches found to mismatch a scan under Options.Verify
+	verifyMismatches atomic.Int64
+
+	// load counts the rows of the data file last loaded with Reload, nil until then
+	load atomic.Pointer[models.LoadStats]
+
+	// watcher watches the file the DB was initialized from,
+	// if Options.WatchFile is set
+	watcher *fsnotify.Watcher
+
+	// stopRefresh is closed by Close to stop refreshing the DB,
+	// if Options.RefreshInterval is set
+	stopRefresh chan struct{}
+
+	// datasets are the datasets loaded aside the live dataset with ReloadDataset, by name
+	datasets     map[string]*DB
+	datasetsLock sync.RWMutex
+}
+
+// dataset is a snapshot of the jobs loaded into DB.
+// A dataset must not be modified once it is stored in DB.current.
+type dataset struct {
+	jobs []models.Job
 
 	// titleJobs index jobs based on job titles.
 	// This enables fast retrieval of jobs based on job titles.
@@ -21,109 +226,389 @@ type DB struct {
 	// standard geospatial based DBMS.
 	titleJobs map[string][]models.Job
 
+	// titleCasings maps the key of each title to the distinct casings of the titles
+	// keyed with it since the jobs were loaded, even if jobs were retitled under
+	// Options.TitleCasing or deleted since
+	titleCasings map[string][]string
+
+	// titleKeys are the keys of titleJobs in ascending order,
+	// snapshotted for deterministic pagination through titleJobs
+	titleKeys []string
+
+	// companyJobs and titleCompanyJobs index jobs having a company
+	// by company, and by title along with company respectively
+	companyJobs      map[string][]models.Job
+	titleCompanyJobs map[titleCompany][]models.Job
+
 	index *rtree.RTree
+
+	// stats is computed once when the dataset is built,
+	// sparing DB.Stats a scan of jobs on every call
+	stats models.Stats
+
+	// warmed holds the jobs within reach of each of Options.WarmQueries once the dataset is built, by warmKey.
+	// warmed is nil if there are no warm queries
+	warmed map[warmKey][]models.Job
 }
 
 // Initialize initializes the DB.
 // filepath is the path to the location.csv file.
-func Initialize(filepath string) (*DB, error) {
-	file, err := os.Open(filepath)
-	if err != nil {
-		return nil, fmt.Errorf("error: failed to open file on path %s: %v", filepath, err)
+func Initialize(filepath string, options Options) (*DB, error) {
+	db := &DB{
+		options:       options,
+		titleSynonyms: indexTitleSynonyms(options.TitleSynonyms, options),
+		lock:          new(sync.Mutex),
 	}
-	defer file.Close()
+	if err := db.Reload(filepath); err != nil {
+		return nil, err
+	}
+	if options.WatchFile {
+		if err := db.watch(filepath); err != nil {
+			return nil, err
+		}
+	}
+	if options.RefreshInterval > 0 {
+		db.refresh(filepath)
+	}
+	return db, nil
+}
 
-	reader := csv.NewReader(file)
-	lines, err := reader.ReadAll()
+// Reload reads the location.csv file on filepath into a new dataset
+// and swaps it in place of the current dataset.
+// Queries running while Reload is in progress are served from the current dataset.
+// A file holding rows none of which is a valid job is an error wrapping ErrNoValidRows,
+// and with Options.RequireData, so is a file without any row.
+// With Options.MaxJobs, jobs above the cap are evicted.
+func (d *DB) Reload(filepath string) error {
+	jobs, load, err := readJobs(filepath)
 	if err != nil {
-		return nil, fmt.Errorf("error encountered reading file on path %s : %v", filepath, err)
+		return err
+	}
+	if d.options.RequireData && len(jobs) == 0 {
+		return fmt.Errorf("error: no valid job found in file on path %s", filepath)
+	}
+	if load.SkippedRows > 0 {
+		log.Printf("skipped %d malformed rows of %d in file on path %s", load.SkippedRows, load.Rows, filepath)
 	}
+	models.AssignIDs(jobs, make(map[string]bool, len(jobs)))
+	jobs = d.options.evict(jobs)
 
-	db, _ := loadTitleJobs(removeTitleLine(lines))
-	db.lock = new(sync.RWMutex)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.current.Store(d.newDataset(jobs, nil))
+	d.load.Store(&load)
+	return nil
+}
 
-	return db, nil
+// Rebuilding reports whether a new dataset is being indexed from scratch, e.g., on Reload or Rebuild.
+// Queries are served from the current dataset meanwhile, without blocking.
+func (d *DB) Rebuilding() bool {
+	return d.rebuilding.Load()
 }
 
-// removeTitleLine removes title line if present in
-// Some csv file may contain table titles on the first line.
-// Remove first line in lines if it contains the table titles
-func removeTitleLine(lines [][]string) [][]string {
-	removeFirstLine := false
-	firstLine := lines[0]
+// Rebuild rebuilds the index of the current dataset and swaps it in place
+// of the current dataset.
+func (d *DB) Rebuild() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	current := d.current.Load()
+	d.current.Store(d.newDataset(current.jobs, current.titleCasings))
+	log.Printf("index rebuilt, %d rebuilds so far", d.rebuilds.Load())
+}
 
-	// if second and/or third column isn't a valid float value,
-	// then first line is title line
-	_, err := strconv.ParseFloat(firstLine[1], 32)
+// readJobs reads jobs from the location.csv file on filepath, along with counts of its rows.
+// The file may be gzip-compressed. If the file holds rows but none is a valid job,
+// the error returned wraps ErrNoValidRows and describes the first row.
+func readJobs(filepath string) (jobs []models.Job, load models.LoadStats, err error) {
+	file, err := os.Open(filepath)
 	if err != nil {
-		removeFirstLine = true
+		return nil, load, fmt.Errorf("error: failed to open file on path %s: %v", filepath, err)
 	}
+	defer file.Close()
 
-	_, err = strconv.ParseFloat(firstLine[2], 32)
+	source, err := decompress(bufio.NewReader(file))
 	if err != nil {
-		removeFirstLine = true
+		return nil, load, fmt.Errorf("error encountered decompressing file on path %s : %v", filepath, err)
 	}
 
-	if removeFirstLine {
-		return lines[1:]
+	// lines are read one at a time rather than with ReadAll,
+	// so that only the jobs parsed are held in memory, not every line of the file
+	reader := csv.NewReader(source)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	jobs = make([]models.Job, 0)
+	columns := defaultColumns
+	firstRow, firstRowColumns := -1, 0
+	for i := 0; ; i++ {
+		line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, load, fmt.Errorf("error encountered reading file on path %s : %v", filepath, err)
+		}
+
+		// Some csv file may contain table titles on the first line,
+		// naming any column following title, longitude and latitude
+		if i == 0 && isTitleLine(line) {
+			columns = parseColumns(line)
+			continue
+		}
+		load.Rows++
+		if firstRow < 0 {
+			firstRow, firstRowColumns = i, len(line)
+		}
+		if job, ok := parseJob(line, i, columns); ok {
+			jobs = append(jobs, job)
+		} else {
+			load.SkippedRows++
+		}
+	}
+	if load.Rows > 0 && len(jobs) == 0 {
+		return nil, load, fmt.Errorf("error reading file on path %s: %w: all %d rows are malformed, "+
+			"e.g., line %d has %d columns, expected title, longitude and latitude followed by optional columns",
+			filepath, ErrNoValidRows, load.Rows, firstRow+1, firstRowColumns)
 	}
+	return jobs, load, nil
+}
+
+// gzipMagicNumber are the first bytes of every gzip file
+var gzipMagicNumber = []byte{0x1f, 0x8b}
 
-	return lines
+// decompress wraps r in a gzip reader if r is gzip-compressed,
+// e.g., a .csv.gz file, otherwise r is returned as is.
+func decompress(r *bufio.Reader) (io.Reader, error) {
+	header, err := r.Peek(len(gzipMagicNumber))
+	if err != nil || !bytes.Equal(header, gzipMagicNumber) {
+		return r, nil
+	}
+	return gzip.NewReader(r)
 }
 
-// loadTitleJobs reads job on each line of lines into DB.
-// Each line in lines must contain job title, longitude, latitude
-// in that order of indexing
-func loadTitleJobs(lines [][]string) (*DB, []models.Job) {
-	titleJobs := make(map[string][]models.Job)
-	jobs := make([]models.Job, 0)
-	var db DB
-	if len(lines) == 0 {
-		return &db, nil
+// newDataset indexes jobs by title and location from scratch, e.g., on Reload or Rebuild,
+// following the title casings of the dataset jobs are derived from, if any.
+// The returned dataset index is nil if jobs is empty,
+// hence query methods must check for a nil index before using it.
+// The index is compacted once built, as with rtree.RTree.Compact, then warmed with Options.WarmQueries.
+// Neither is done again as jobs are added or deleted, being indexed incrementally with withJobs
+// and withoutJobs, so that only full reloads and rebuilds pay for compacting the whole index.
+func (d *DB) newDataset(jobs []models.Job, titleCasings map[string][]string) *dataset {
+	d.rebuilding.Store(true)
+	defer d.rebuilding.Store(false)
+
+	titleCasings = indexTitleCasings(jobs, titleCasings, d.options)
+	jobs = d.options.keepFirstTitleCasing(jobs, titleCasings)
+	ds := &dataset{
+		jobs:         jobs,
+		titleJobs:    indexTitleJobs(jobs, d.options),
+		titleCasings: titleCasings,
+	}
+	ds.companyJobs, ds.titleCompanyJobs = indexCompanyJobs(jobs, d.options)
+	ds.titleKeys = make([]string, 0, len(ds.titleJobs))
+	for key := range ds.titleJobs {
+		ds.titleKeys = append(ds.titleKeys, key)
+	}
+	sort.Strings(ds.titleKeys)
+	if len(jobs) != 0 {
+		ds.index = d.newIndex(jobs)
+		d.leafMerges.Add(int64(ds.index.Compact()))
+		d.warm(ds)
 	}
+	d.rebuilds.Add(1)
+	ds.stats = models.NewStats(jobs, len(ds.titleJobs))
+	ds.stats.MergedTitleCasings = mergedTitleCasings(titleCasings)
+	return ds
+}
 
-	for i, line := range lines {
-		var job models.Job
+// newIndex indexes jobs by location on a new tree, counting the splits done building it.
+// jobs must not be empty
+func (d *DB) newIndex(jobs []models.Job) *rtree.RTree {
+	index, _ := rtree.NewWithEntries(jobs...)
+	index.SetSearchWorkers(d.options.SearchWorkers)
+	index.SetSphere(d.Sphere())
+	d.countSplits(index, 0, 0)
+	return index
+}
 
-		// check that line contains exactly 3 items,
-		// else line is incomplete and skipped
-		if len(line) != 3 {
-			continue
-		}
+// countSplits adds the splits done on index since it had done leafSplits and nodeSplits
+// to the splits counted across every index built
+func (d *DB) countSplits(index *rtree.RTree, leafSplits, nodeSplits int) {
+	totalLeafSplits, totalNodeSplits := index.Splits()
+	d.leafSplits.Add(int64(totalLeafSplits - leafSplits))
+	d.nodeSplits.Add(int64(totalNodeSplits - nodeSplits))
+}
 
-		longitude, err := strconv.ParseFloat(line[1], 32)
-		if err != nil {
-			log.Printf("error parsing longitude on line %d", i)
-			continue
+// isTitleLine checks if line contains the table titles,
+// i.e., its second and/or third column isn't a valid float value
+func isTitleLine(line []string) bool {
+	if len(line) < 3 {
+		return false
+	}
+
+	if _, err := strconv.ParseFloat(line[1], 32); err != nil {
+		return true
+	}
+	_, err := strconv.ParseFloat(line[2], 32)
+	return err != nil
+}
+
+// csvColumns locates the optional columns of a csv file,
+// following the title, longitude and latitude columns
+type csvColumns struct {
+
+	// count is the largest number of items on a line,
+	// not counting items following every column holding a key=value label
+	count int
+
+	// company and altitude are the index of their column, -1 if absent.
+	// If both are the same column, it holds the altitude if numeric, else the company
+	// unless a key=value label
+	company  int
+	altitude int
+
+	// labels is the index of the column holding the labels of a job
+	// as a JSON object of strings, -1 if absent
+	labels int
+}
+
+// defaultColumns are the columns of a csv file without a title line,
+// whose 4th column, if any, is either of altitude or company
+var defaultColumns = csvColumns{count: 4, company: 3, altitude: 3, labels: -1}
+
+// parseColumns locates the optional columns named in titleLine.
+// Columns named neither company, altitude nor labels hold key=value labels, if any
+func parseColumns(titleLine []string) csvColumns {
+	columns := csvColumns{count: len(titleLine), company: -1, altitude: -1, labels: -1}
+	for i := 3; i < len(titleLine); i++ {
+		switch strings.ToLower(strings.TrimSpace(titleLine[i])) {
+		case "company":
+			columns.company = i
+		case "altitude":
+			columns.altitude = i
+		case "labels":
+			columns.labels = i
 		}
-		latitude, err := strconv.ParseFloat(line[2], 32)
-		if err != nil {
-			log.Printf("error parsing latitude on line %d", i)
-			continue
+	}
+	return columns
+}
+
+// parseLabel parses value as a key=value label.
+// ok is false if value is not a key=value pair with a non-empty key
+func parseLabel(value string) (key, labelValue string, ok bool) {
+	key, labelValue, ok = strings.Cut(value, "=")
+	key = strings.TrimSpace(key)
+	return key, strings.TrimSpace(labelValue), ok && key != ""
+}
+
+// parseJob reads the job on line number i.
+// line must contain job title, longitude, latitude in that order of indexing,
+// and may be followed by the optional columns and any key=value label, else ok is false
+func parseJob(line []string, i int, columns csvColumns) (job models.Job, ok bool) {
+
+	// check that line contains 3 items and at most every optional column,
+	// followed by labels only, else line is incomplete or malformed and skipped
+	if len(line) < 3 {
+		return job, false
+	}
+	for index := columns.count; index < len(line); index++ {
+		if _, _, ok := parseLabel(line[index]); !ok && strings.TrimSpace(line[index]) != "" {
+			return job, false
 		}
-		job.Title = line[0]
-		job.Location = models.Location{
-			Longitude: longitude,
-			Latitude:  latitude,
+	}
+
+	longitude, err := strconv.ParseFloat(line[1], 32)
+	if err != nil {
+		log.Printf("error parsing longitude on line %d", i)
+		return job, false
+	}
+	latitude, err := strconv.ParseFloat(line[2], 32)
+	if err != nil {
+		log.Printf("error parsing latitude on line %d", i)
+		return job, false
+	}
+	job.Title = line[0]
+	job.Location = models.Location{
+		Longitude: longitude,
+		Latitude:  latitude,
+	}
+	for index := 3; index < len(line); index++ {
+		value := strings.TrimSpace(line[index])
+		switch {
+		case value == "":
+		case index == columns.labels:
+			var labels map[string]string
+			if err := json.Unmarshal([]byte(value), &labels); err != nil {
+				log.Printf("error parsing labels on line %d: %v", i, err)
+			}
+			for key, labelValue := range labels {
+				job.SetLabel(key, labelValue)
+			}
+		case index == columns.altitude:
+			if altitude, err := strconv.ParseFloat(value, 64); err == nil {
+				job.Location.Altitude = &altitude
+			} else if key, labelValue, ok := parseLabel(value); ok && columns == defaultColumns {
+				job.SetLabel(key, labelValue)
+			} else if index == columns.company {
+				job.Company = value
+			} else {
+				log.Printf("error parsing altitude on line %d", i)
+			}
+		case index == columns.company:
+			job.Company = value
+		default:
+			if key, labelValue, ok := parseLabel(value); ok {
+				job.SetLabel(key, labelValue)
+			}
 		}
-		jobs = append(jobs, job)
+	}
+	return job, true
+}
+
+// indexTitleJobs maps the key of each title in jobs to the jobs having that title
+func indexTitleJobs(jobs []models.Job, options Options) map[string][]models.Job {
+	titleJobs := make(map[string][]models.Job)
+	for _, job := range jobs {
+
 		// check that map contains jobs with same title,
 		// else initialize new slice for jobs with job.Title
 		//
-		// Map keys are converted to lower case to eliminate case sensitivity
-		// when searching for jobs based on title.
-		// Ensure also that job title search queries are converted
-		// to lower case before using on titleJobs
-		lowercasedTitle := strings.ToLower(job.Title)
-		if sameJobs, ok := titleJobs[lowercasedTitle]; ok {
+		// Map keys are normalized with models.NormalizeTitle to eliminate case sensitivity
+		// when searching for jobs based on title, unless titles are keyed case-sensitively.
+		// Ensure also that job title search queries are keyed
+		// with Options.titleKey before using on titleJobs
+		key := options.titleKey(job.Title)
+		if sameJobs, ok := titleJobs[key]; ok {
 			sameJobs = append(sameJobs, job)
-			titleJobs[lowercasedTitle] = sameJobs
+			titleJobs[key] = sameJobs
 		} else {
-			titleJobs[lowercasedTitle] = []models.Job{job}
+			titleJobs[key] = []models.Job{job}
 		}
-
 	}
+	return titleJobs
+}
+
+// titleCompany is the key of jobs having a title at a company,
+// both keyed with Options
+type titleCompany struct {
+	title   string
+	company string
+}
 
-	db.titleJobs = titleJobs
-	return &db, jobs
+// indexCompanyJobs maps the key of each company in jobs to the jobs at that company,
+// and the keys of each title along with company to the jobs having that title at that company.
+// Jobs without a company are not indexed
+func indexCompanyJobs(jobs []models.Job, options Options) (map[string][]models.Job, map[titleCompany][]models.Job) {
+	companyJobs := make(map[string][]models.Job)
+	titleCompanyJobs := make(map[titleCompany][]models.Job)
+	for _, job := range jobs {
+		company := options.companyKey(job.Company)
+		if company == "" {
+			continue
+		}
+		companyJobs[company] = append(companyJobs[company], job)
+		key := titleCompany{title: options.titleKey(job.Title), company: company}
+		titleCompanyJobs[key] = append(titleCompanyJobs[key], job)
+	}
+	return companyJobs, titleCompanyJobs
 }
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+)
+
+// writeCSV writes a data file holding the title line followed by lines, and returns its path
+func writeCSV(t testing.TB, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "location.csv")
+	data := "title,longitude,latitude\n" + strings.Join(lines, "\n")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// randomLines returns n lines of a data file, each titled one of titles
+// and located at random around Lagos
+func randomLines(random *rand.Rand, n int, titles ...string) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("%s,%f,%f", titles[i%len(titles)], 3+random.Float64(), 6+random.Float64())
+	}
+	return lines
+}
+
+// newTestDB initializes a DB from a data file holding lines
+func newTestDB(t testing.TB, options Options, lines ...string) *DB {
+	t.Helper()
+	d, err := Initialize(writeCSV(t, lines...), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+// scanNearby returns the IDs of the jobs of d within radius of center, in ascending order,
+// scanning every job rather than searching the index
+func scanNearby(d *DB, center models.Location, radius float64) []string {
+	ids := make([]string, 0)
+	for _, job := range d.current.Load().jobs {
+		if d.Sphere().Distance(center, job.Location) <= radius {
+			ids = append(ids, job.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// idsOf returns the IDs of jobs in ascending order
+func idsOf(jobs []models.Job) []string {
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// TestReadsDuringRebuilds reads continuously while the dataset is rebuilt and reloaded,
+// each read seeing either the whole dataset before a swap or the whole dataset after it.
+// Run with -race.
+func TestReadsDuringRebuilds(t *testing.T) {
+	lines := randomLines(rand.New(rand.NewSource(1)), 3000, "Nurse", "Driver")
+	path := writeCSV(t, lines...)
+	d, err := Initialize(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	want, _ := d.FindJobsNearby(context.Background(), center, 30, rtree.Inclusive)
+
+	done := make(chan struct{})
+	errs := make(chan error, 4)
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				jobs, err := d.FindJobsNearby(context.Background(), center, 30, rtree.Inclusive)
+				if err == nil && len(jobs) != len(want) {
+					err = fmt.Errorf("found %d jobs during a rebuild, want %d", len(jobs), len(want))
+				}
+				if stats, _ := d.Stats(); err == nil && stats.JobCount != len(lines) {
+					err = fmt.Errorf("counted %d jobs during a rebuild, want %d", stats.JobCount, len(lines))
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		d.Rebuild()
+		if err := d.Reload(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	readers.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestRequireData(t *testing.T) {
+	empty := writeCSV(t)
+	if _, err := Initialize(empty, Options{RequireData: true}); err == nil {
+		t.Error("initialized from an empty file with RequireData")
+	}
+
+	d, err := Initialize(empty, Options{})
+	if err != nil {
+		t.Fatalf("error initializing from an empty file without RequireData: %v", err)
+	}
+	defer d.Close()
+	jobs, err := d.FindJobsNearby(context.Background(), models.Location{Longitude: 3.5, Latitude: 6.5}, 5, rtree.Inclusive)
+	if err != nil || len(jobs) != 0 {
+		t.Errorf("found %d jobs in an empty dataset, error %v", len(jobs), err)
+	}
+
+	// an empty file does not replace the current jobs with RequireData
+	d = newTestDB(t, Options{RequireData: true}, "Nurse,3.5,6.5")
+	if err := d.Reload(empty); err == nil {
+		t.Error("reloaded an empty file with RequireData")
+	}
+	if stats, _ := d.Stats(); stats.JobCount != 1 {
+		t.Errorf("%d jobs after failing to reload, want the 1 job before", stats.JobCount)
+	}
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"github.com/ercross/grabjobs/internal/models"
+	"sort"
+)
+
+// DiffDatasets lists the jobs added, removed and moved from dataset a to dataset b.
+// Jobs are identified by their ID, which is derived from their title and location, hence jobs
+// with the same ID in both datasets are unchanged. Among the other jobs, a job of a is moved to a
+// job of b having the same title and company keyed under the Options of a, pairing jobs from the
+// nearest pair, and the jobs left unpaired are removed from a or added to b respectively.
+// Jobs are listed in the order of their dataset, moves in the order of a.
+func DiffDatasets(a, b *DB) models.DatasetDiff {
+	jobsA, jobsB := a.current.Load().jobs, b.current.Load().jobs
+	idsA := make(map[string]bool, len(jobsA))
+	for _, job := range jobsA {
+		idsA[job.ID] = true
+	}
+	idsB := make(map[string]bool, len(jobsB))
+	for _, job := range jobsB {
+		idsB[job.ID] = true
+	}
+
+	// group the jobs only in either dataset by title and company
+	onlyA := make(map[titleCompany][]int)
+	for i, job := range jobsA {
+		if !idsB[job.ID] {
+			key := titleCompany{title: a.options.titleKey(job.Title), company: a.options.companyKey(job.Company)}
+			onlyA[key] = append(onlyA[key], i)
+		}
+	}
+	onlyB := make(map[titleCompany][]int)
+	for i, job := range jobsB {
+		if !idsA[job.ID] {
+			key := titleCompany{title: a.options.titleKey(job.Title), company: a.options.companyKey(job.Company)}
+			onlyB[key] = append(onlyB[key], i)
+		}
+	}
+
+	movedFrom := make(map[int]models.JobMove)
+	movedTo := make(map[int]bool)
+	for key, fromA := range onlyA {
+		toB := onlyB[key]
+		if len(toB) == 0 {
+			continue
+		}
+
+		type candidate struct {
+			from, to int
+			distance float64
+		}
+		candidates := make([]candidate, 0, len(fromA)*len(toB))
+		for _, i := range fromA {
+			for _, j := range toB {
+				candidates = append(candidates, candidate{from: i, to: j, distance: a.Sphere().Distance(jobsA[i].Location, jobsB[j].Location)})
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+		for _, c := range candidates {
+			if _, ok := movedFrom[c.from]; ok || movedTo[c.to] {
+				continue
+			}
+			movedFrom[c.from] = models.JobMove{From: jobsA[c.from], To: jobsB[c.to], Distance: c.distance}
+			movedTo[c.to] = true
+		}
+	}
+
+	diff := models.DatasetDiff{Added: []models.Job{}, Removed: []models.Job{}, Moved: []models.JobMove{}}
+	for i, job := range jobsA {
+		if move, ok := movedFrom[i]; ok {
+			diff.Moved = append(diff.Moved, move)
+		} else if !idsB[job.ID] {
+			diff.Removed = append(diff.Removed, job)
+		}
+	}
+	for j, job := range jobsB {
+		if !idsA[job.ID] && !movedTo[j] {
+			diff.Added = append(diff.Added, job)
+		}
+	}
+	return diff
+}
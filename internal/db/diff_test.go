@@ -0,0 +1,63 @@
+package db
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestDiffDatasets(t *testing.T) {
+	live := newTestDB(t, Options{},
+		"Nurse,3.1,6.1",
+		"Driver,3.2,6.2",
+		"Cook,3.3,6.3",
+		"Cook,3.5,6.5",
+		"Tailor,3.4,6.4",
+	)
+	candidate := newTestDB(t, Options{},
+		"Nurse,3.1,6.1",
+		"Driver,3.2,6.2",
+		"cook,3.51,6.5",
+		"Cook,3.31,6.3",
+		"Welder,3.6,6.6",
+	)
+
+	diff := DiffDatasets(live, candidate)
+	titles := func(jobs []models.Job) []string {
+		titles := make([]string, len(jobs))
+		for i, job := range jobs {
+			titles[i] = job.Title
+		}
+		return titles
+	}
+	if added := titles(diff.Added); !reflect.DeepEqual(added, []string{"Welder"}) {
+		t.Errorf("added %v, want [Welder]", added)
+	}
+	if removed := titles(diff.Removed); !reflect.DeepEqual(removed, []string{"Tailor"}) {
+		t.Errorf("removed %v, want [Tailor]", removed)
+	}
+
+	// each cook moves to the nearest cook of the candidate
+	if len(diff.Moved) != 2 {
+		t.Fatalf("moved %+v, want both cooks", diff.Moved)
+	}
+	for _, move := range diff.Moved {
+		if math.Abs(move.To.Location.Longitude-move.From.Location.Longitude-0.01) > 1e-4 || move.From.Location.Latitude != move.To.Location.Latitude {
+			t.Errorf("%v moved to %v, want to the cook 0.01° east", move.From.Location, move.To.Location)
+		}
+		if want := live.Sphere().Distance(move.From.Location, move.To.Location); move.Distance != want || move.Distance > 1.2 {
+			t.Errorf("moved %vkm, want %vkm", move.Distance, want)
+		}
+	}
+
+	// the diff of a dataset to itself is empty, and diffs are symmetric
+	if same := DiffDatasets(live, live); len(same.Added)+len(same.Removed)+len(same.Moved) != 0 {
+		t.Errorf("diff to itself %+v, want none", same)
+	}
+	reverse := DiffDatasets(candidate, live)
+	if !reflect.DeepEqual(titles(reverse.Added), []string{"Tailor"}) || !reflect.DeepEqual(titles(reverse.Removed), []string{"Welder"}) || len(reverse.Moved) != 2 {
+		t.Errorf("reverse diff %+v, want Tailor added, Welder removed and both cooks moved", reverse)
+	}
+}
@@ -0,0 +1,271 @@
+package db
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+)
+
+// writeGzippedCSV writes a gzip-compressed data file holding the title line followed by lines,
+// named name, and returns its path
+func writeGzippedCSV(t *testing.T, name string, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write([]byte("title,longitude,latitude\n" + strings.Join(lines, "\n"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGzippedCSV(t *testing.T) {
+	lines := randomLines(rand.New(rand.NewSource(1)), 2000, "Nurse", "Driver", "Cook")
+	plain := newTestDB(t, Options{}, lines...)
+
+	// gzip is sniffed from the content of the file, whatever its name
+	for _, name := range []string{"location.csv.gz", "location.csv"} {
+		gzipped, err := Initialize(writeGzippedCSV(t, name, lines...), Options{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		defer gzipped.Close()
+
+		plainStats, _ := plain.Stats()
+		gzippedStats, _ := gzipped.Stats()
+		if plainStats.JobCount != gzippedStats.JobCount || plainStats.TitleCount != gzippedStats.TitleCount ||
+			plainStats.BoundingBox != gzippedStats.BoundingBox {
+			t.Errorf("%s: Stats() = %+v, want %+v as loaded from csv", name, gzippedStats, plainStats)
+		}
+
+		center := models.Location{Longitude: 3.5, Latitude: 6.5}
+		for _, radius := range []float64{5, 20, 100} {
+			want, _ := plain.FindJobsNearby(context.Background(), center, radius, rtree.Inclusive)
+			got, _ := gzipped.FindJobsNearby(context.Background(), center, radius, rtree.Inclusive)
+			if !reflect.DeepEqual(locationsOf(got), locationsOf(want)) {
+				t.Errorf("%s: found %d jobs within %gkm, want %d as found from csv", name, len(got), radius, len(want))
+			}
+		}
+	}
+}
+
+// locationsOf returns the titles and locations of jobs, in the order of jobs
+func locationsOf(jobs []models.Job) []string {
+	locations := make([]string, len(jobs))
+	for i, job := range jobs {
+		locations[i] = job.Title + " " + job.Location.String()
+	}
+	return locations
+}
+
+// readAllJobs reads the jobs of the data file on path as readJobs once did,
+// reading every line of the file at once with ReadAll before parsing them
+func readAllJobs(t *testing.T, path string) []models.Job {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	lines, err := reader.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := make([]models.Job, 0, len(lines))
+	columns := defaultColumns
+	for i, line := range lines {
+		if i == 0 && isTitleLine(line) {
+			columns = parseColumns(line)
+			continue
+		}
+		if job, ok := parseJob(line, i, columns); ok {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs
+}
+
+func TestStreamingImportMatchesReadAll(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	lines := make([]string, 100_000)
+	for i := range lines {
+		switch {
+		case i%1000 == 0:
+			lines[i] = "malformed row"
+		case i%3 == 0:
+			lines[i] = fmt.Sprintf("\"Title %d, Senior\",%f,%f", i%97, 3+random.Float64(), 6+random.Float64())
+		default:
+			lines[i] = fmt.Sprintf("Title %d,%f,%f", i%97, 3+random.Float64(), 6+random.Float64())
+		}
+	}
+	path := writeCSV(t, lines...)
+
+	streamed, load, err := readJobs(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := readAllJobs(t, path)
+	if load.Rows != len(lines) || load.SkippedRows != len(lines)/1000 {
+		t.Errorf("read %d rows skipping %d, want %d rows skipping %d", load.Rows, load.SkippedRows, len(lines), len(lines)/1000)
+	}
+
+	// fields of records reused across rows must not leak into the jobs read before
+	if !reflect.DeepEqual(streamed, want) {
+		t.Fatalf("streamed %d jobs, ReadAll reads %d different jobs", len(streamed), len(want))
+	}
+
+	d, err := Initialize(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if stats, _ := d.Stats(); stats.JobCount != len(want) || stats.TitleCount != 2*97 {
+		t.Errorf("Stats() counts %d jobs and %d titles, want %d and 194", stats.JobCount, stats.TitleCount, len(want))
+	}
+}
+
+func TestAltitudeColumn(t *testing.T) {
+	altitude := func(meters float64) *float64 { return &meters }
+
+	tests := []struct {
+		name      string
+		data      string
+		altitudes []*float64
+		companies []string
+	}{
+		{name: "3 columns", data: "title,longitude,latitude\nNurse,3.1,6.1\nDriver,3.2,6.2",
+			altitudes: []*float64{nil, nil}, companies: []string{"", ""}},
+		{name: "4 columns", data: "title,longitude,latitude,altitude\nNurse,3.1,6.1,120.5\nDriver,3.2,6.2,",
+			altitudes: []*float64{altitude(120.5), nil}, companies: []string{"", ""}},
+		{name: "4 columns without title line", data: "Nurse,3.1,6.1,42\nDriver,3.2,6.2,Acme\nCook,3.3,6.3",
+			altitudes: []*float64{altitude(42), nil, nil}, companies: []string{"", "Acme", ""}},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "location.csv")
+		if err := os.WriteFile(path, []byte(test.data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		jobs, _, err := readJobs(path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(jobs) != len(test.altitudes) {
+			t.Fatalf("%s: read %d jobs, want %d", test.name, len(jobs), len(test.altitudes))
+		}
+		for i, job := range jobs {
+			if !reflect.DeepEqual(job.Location.Altitude, test.altitudes[i]) || job.Company != test.companies[i] {
+				t.Errorf("%s: job %d at altitude %v of company %q, want %v and %q",
+					test.name, i, job.Location.Altitude, job.Company, test.altitudes[i], test.companies[i])
+			}
+		}
+	}
+
+	// altitude does not take part in searches
+	d := newTestDB(t, Options{}, "Nurse,3.1,6.1")
+	if err := d.AddJob(&models.Job{Title: "Driver", Location: models.Location{Longitude: 3.1, Latitude: 6.1, Altitude: altitude(8000)}}); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := d.FindJobsNearby(context.Background(), models.Location{Longitude: 3.1, Latitude: 6.1}, 0.1, rtree.Inclusive)
+	if err != nil || len(jobs) != 2 {
+		t.Errorf("found %d jobs at the same longitude and latitude, want 2, error %v", len(jobs), err)
+	}
+}
+
+func TestLabelColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		labels []map[string]string
+	}{
+		{name: "key=value columns", data: "title,longitude,latitude\nNurse,3.1,6.1,shift=night,remote=false\nDriver,3.2,6.2\nCook,3.3,6.3, shift = day ",
+			labels: []map[string]string{{"shift": "night", "remote": "false"}, nil, {"shift": "day"}}},
+		{name: "key=value column without title line", data: "Nurse,3.1,6.1,shift=night\nDriver,3.2,6.2,Acme",
+			labels: []map[string]string{{"shift": "night"}, nil}},
+		{name: "labels column", data: "title,longitude,latitude,labels\n" + `Nurse,3.1,6.1,"{""shift"": ""night"", ""remote"": ""true""}"` + "\nDriver,3.2,6.2,",
+			labels: []map[string]string{{"shift": "night", "remote": "true"}, nil}},
+		{name: "labels and key=value columns", data: "title,longitude,latitude,labels\n" + `Nurse,3.1,6.1,"{""shift"": ""night""}",remote=true`,
+			labels: []map[string]string{{"shift": "night", "remote": "true"}}},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "location.csv")
+		if err := os.WriteFile(path, []byte(test.data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		jobs, _, err := readJobs(path)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(jobs) != len(test.labels) {
+			t.Fatalf("%s: read %d jobs, want %d", test.name, len(jobs), len(test.labels))
+		}
+		for i, job := range jobs {
+			if !reflect.DeepEqual(job.Labels, test.labels[i]) {
+				t.Errorf("%s: job %d labeled %v, want %v", test.name, i, job.Labels, test.labels[i])
+			}
+		}
+	}
+
+	// a line followed by anything but labels is malformed
+	jobs, _, err := readJobs(writeCSV(t, "Nurse,3.1,6.1,night", "Driver,3.2,6.2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Title != "Driver" {
+		t.Errorf("read %v, want the Driver job only", jobs)
+	}
+}
+
+func TestAllRowsMalformed(t *testing.T) {
+	malformed := writeCSV(t, "Nurse", "Driver,3.5", "Cook;3.5;6.5")
+
+	_, err := Initialize(malformed, Options{})
+	if !errors.Is(err, ErrNoValidRows) {
+		t.Fatalf("initializing from a file of malformed rows: error %v, want ErrNoValidRows", err)
+	}
+	if want := "all 3 rows are malformed, e.g., line 2 has 1 columns"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q, want it to tell %q", err, want)
+	}
+
+	// a file holding no row is merely empty
+	d, err := Initialize(writeCSV(t), Options{})
+	if err != nil {
+		t.Fatalf("error initializing from an empty file: %v", err)
+	}
+	defer d.Close()
+	if stats, _ := d.Stats(); stats.Load != (models.LoadStats{}) {
+		t.Errorf("load stats %+v of an empty file, want no row", stats.Load)
+	}
+
+	// a file of malformed rows does not replace the current jobs, even without RequireData
+	d = newTestDB(t, Options{}, "Nurse,3.5,6.5", "Driver", "Cook,3.6,6.6")
+	if stats, _ := d.Stats(); stats.Load != (models.LoadStats{Rows: 3, SkippedRows: 1}) {
+		t.Errorf("load stats %+v, want 3 rows of which 1 skipped", stats.Load)
+	}
+	if err := d.Reload(malformed); !errors.Is(err, ErrNoValidRows) {
+		t.Errorf("reloading a file of malformed rows: error %v, want ErrNoValidRows", err)
+	}
+	if stats, _ := d.Stats(); stats.JobCount != 2 || stats.Load != (models.LoadStats{Rows: 3, SkippedRows: 1}) {
+		t.Errorf("%d jobs loaded from %+v after failing to reload, want the 2 jobs of the 3 rows before", stats.JobCount, stats.Load)
+	}
+}
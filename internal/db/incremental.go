@@ -0,0 +1,217 @@
+package db
+
+import (
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	"sort"
+)
+
+// withJobs returns a copy of ds along with jobs, indexed incrementally rather than from scratch
+// as with newDataset: jobs are inserted into a copy of the index of ds, and added to copies of
+// its title and company maps, and to the warmed results of Options.WarmQueries they match.
+// ds is left untouched, as it may still be read.
+//
+// Jobs are appended to the slices of ds in place, past their length, which no reader of ds
+// looks at. This holds as long as only the current dataset is derived from, under DB.lock,
+// since every dataset sharing the backing array of a slice then ends before the current one.
+// The IDs of jobs must be set and distinct from those of ds, and their titles cased
+// as with Options.keepFirstTitleCasing.
+func (d *DB) withJobs(ds *dataset, jobs []models.Job, titleCasings map[string][]string) *dataset {
+	updated := &dataset{
+		jobs:             append(ds.jobs, jobs...),
+		titleJobs:        copyJobsIndex(ds.titleJobs),
+		titleCasings:     titleCasings,
+		titleKeys:        ds.titleKeys,
+		companyJobs:      copyJobsIndex(ds.companyJobs),
+		titleCompanyJobs: copyJobsIndex(ds.titleCompanyJobs),
+	}
+
+	newTitleKeys := make([]string, 0)
+	for _, job := range jobs {
+		key := d.options.titleKey(job.Title)
+		if _, ok := updated.titleJobs[key]; !ok {
+			newTitleKeys = append(newTitleKeys, key)
+		}
+		updated.titleJobs[key] = append(updated.titleJobs[key], job)
+
+		company := d.options.companyKey(job.Company)
+		if company == "" {
+			continue
+		}
+		updated.companyJobs[company] = append(updated.companyJobs[company], job)
+		titleCompanyKey := titleCompany{title: key, company: company}
+		updated.titleCompanyJobs[titleCompanyKey] = append(updated.titleCompanyJobs[titleCompanyKey], job)
+	}
+	if len(newTitleKeys) != 0 {
+		updated.titleKeys = make([]string, 0, len(ds.titleKeys)+len(newTitleKeys))
+		updated.titleKeys = append(append(updated.titleKeys, ds.titleKeys...), newTitleKeys...)
+		sort.Strings(updated.titleKeys)
+	}
+
+	if ds.index == nil {
+		updated.index = d.newIndex(jobs)
+	} else {
+		updated.index = ds.index.Clone()
+		leafSplits, nodeSplits := updated.index.Splits()
+		for _, job := range jobs {
+			updated.index.Insert(*rtree.NewEntry(job))
+		}
+		d.countSplits(updated.index, leafSplits, nodeSplits)
+	}
+
+	if ds.warmed == nil {
+		d.warm(updated)
+	} else {
+		updated.warmed = d.warmedWithJobs(ds.warmed, jobs)
+	}
+
+	locations := make([]models.Location, 0, len(jobs)+2)
+	if len(ds.jobs) != 0 {
+		locations = append(locations, ds.stats.BoundingBox.Min, ds.stats.BoundingBox.Max)
+	}
+	for _, job := range jobs {
+		locations = append(locations, job.Location)
+	}
+	updated.stats = ds.stats
+	updated.stats.JobCount = len(updated.jobs)
+	updated.stats.TitleCount = len(updated.titleJobs)
+	updated.stats.BoundingBox = models.BoxAround(locations)
+	updated.stats.MergedTitleCasings = mergedTitleCasings(titleCasings)
+	return updated
+}
+
+// warmedWithJobs returns a copy of warmed along with the jobs within reach of each of Options.WarmQueries,
+// as with warmReach, jobs at the reach included as when searching the index with rtree.Inclusive.
+// Jobs are appended to the slices of warmed in place, as with withJobs.
+func (d *DB) warmedWithJobs(warmed map[warmKey][]models.Job, jobs []models.Job) map[warmKey][]models.Job {
+	updated := make(map[warmKey][]models.Job, len(warmed))
+	for key, found := range warmed {
+		updated[key] = found
+	}
+	sphere := d.Sphere()
+	added := make(map[warmKey]bool, len(warmed))
+	for _, query := range d.options.WarmQueries {
+		radius := query.RadiusKm
+		if radius == 0 {
+			radius = d.DefaultRadius().Kilometers()
+		}
+		// jobs are added once to the results of queries matched by the same searches, as with warm
+		key, reach := newWarmKey(query.Center, radius), d.warmReach(query.Center, radius)
+		if added[key] {
+			continue
+		}
+		added[key] = true
+		for _, job := range jobs {
+			if sphere.Distance(query.Center, job.Location) <= reach {
+				updated[key] = append(updated[key], job)
+			}
+		}
+	}
+	return updated
+}
+
+// addTitleCasings returns a copy of casings along with the casings of the titles of jobs,
+// as indexed with indexTitleCasings
+func addTitleCasings(casings map[string][]string, jobs []models.Job, options Options) map[string][]string {
+	updated := make(map[string][]string, len(casings))
+	for key, titles := range casings {
+		updated[key] = titles
+	}
+	for key, titles := range indexTitleCasings(jobs, casings, options) {
+		updated[key] = titles
+	}
+	return updated
+}
+
+// copyJobsIndex returns a copy of index, sharing the slices of jobs of index
+func copyJobsIndex[K comparable](index map[K][]models.Job) map[K][]models.Job {
+	copied := make(map[K][]models.Job, len(index))
+	for key, jobs := range index {
+		copied[key] = jobs
+	}
+	return copied
+}
+
+// withoutJobs returns a copy of ds without the jobs for which match is true, deleted
+// incrementally rather than indexing the jobs left from scratch as with newDataset:
+// jobs are deleted from a copy of the index of ds with rtree.RTree.Delete, and from copies
+// of the title and company maps of ds, and of its warmed results. ds is left untouched.
+// Title casings are kept, as they are recorded even for titles of jobs deleted since.
+func (d *DB) withoutJobs(ds *dataset, match func(job models.Job) bool) *dataset {
+	updated := &dataset{
+		jobs:             withoutJobsMatching(ds.jobs, match),
+		titleJobs:        copyJobsIndex(ds.titleJobs),
+		titleCasings:     ds.titleCasings,
+		titleKeys:        ds.titleKeys,
+		companyJobs:      copyJobsIndex(ds.companyJobs),
+		titleCompanyJobs: copyJobsIndex(ds.titleCompanyJobs),
+	}
+	if len(updated.jobs) == len(ds.jobs) {
+		return ds
+	}
+
+	// only the slices holding a job deleted are copied
+	deleted := make([]models.Job, 0, len(ds.jobs)-len(updated.jobs))
+	titleKeys := make(map[string]bool)
+	companies := make(map[string]bool)
+	titleCompanies := make(map[titleCompany]bool)
+	for _, job := range ds.jobs {
+		if !match(job) {
+			continue
+		}
+		deleted = append(deleted, job)
+		key := d.options.titleKey(job.Title)
+		titleKeys[key] = true
+		if company := d.options.companyKey(job.Company); company != "" {
+			companies[company] = true
+			titleCompanies[titleCompany{title: key, company: company}] = true
+		}
+	}
+	titleKeysDeleted := withoutJobsIn(updated.titleJobs, titleKeys, match)
+	withoutJobsIn(updated.companyJobs, companies, match)
+	withoutJobsIn(updated.titleCompanyJobs, titleCompanies, match)
+	if titleKeysDeleted {
+		updated.titleKeys = make([]string, 0, len(updated.titleJobs))
+		for _, key := range ds.titleKeys {
+			if _, ok := updated.titleJobs[key]; ok {
+				updated.titleKeys = append(updated.titleKeys, key)
+			}
+		}
+	}
+
+	if len(updated.jobs) != 0 {
+		updated.index = ds.index.Clone()
+		for _, job := range deleted {
+			updated.index.Delete(job.ID)
+		}
+		if ds.warmed != nil {
+			updated.warmed = make(map[warmKey][]models.Job, len(ds.warmed))
+			for key, found := range ds.warmed {
+				updated.warmed[key] = withoutJobsMatching(found, match)
+			}
+		}
+	}
+
+	updated.stats = ds.stats
+	stats := models.NewStats(updated.jobs, len(updated.titleJobs))
+	updated.stats.JobCount = stats.JobCount
+	updated.stats.TitleCount = stats.TitleCount
+	updated.stats.BoundingBox = stats.BoundingBox
+	return updated
+}
+
+// withoutJobsIn replaces the jobs of index at each of keys with a copy of them
+// without the jobs for which match is true, deleting keys left without jobs.
+// withoutJobsIn returns true if a key was deleted
+func withoutJobsIn[K comparable](index map[K][]models.Job, keys map[K]bool, match func(job models.Job) bool) (deleted bool) {
+	for key := range keys {
+		remaining := withoutJobsMatching(index[key], match)
+		if len(remaining) == 0 {
+			delete(index, key)
+			deleted = true
+			continue
+		}
+		index[key] = remaining
+	}
+	return deleted
+}
@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+)
+
+func TestDeleteJobsMatchesScan(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	d := newTestDB(t, Options{}, randomLines(random, 2000, "Nurse", "Driver", "Cook")...)
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	before, _ := d.FindJobsNearby(context.Background(), center, 20, rtree.Inclusive)
+
+	deleted, err := d.DeleteJobs("nurse", center, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted == 0 {
+		t.Fatal("DeleteJobs deleted no job")
+	}
+
+	after, err := d.FindJobsNearby(context.Background(), center, 20, rtree.Inclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before)-deleted {
+		t.Errorf("found %d jobs after deleting %d of %d", len(after), deleted, len(before))
+	}
+	for _, job := range after {
+		if job.Title == "Nurse" {
+			t.Errorf("found job %q titled Nurse once deleted", job.ID)
+		}
+	}
+	for _, radius := range []float64{5, 30, 100} {
+		found, _ := d.FindJobsNearby(context.Background(), center, radius, rtree.Inclusive)
+		if got, want := idsOf(found), scanNearby(d, center, radius); !reflect.DeepEqual(got, want) {
+			t.Errorf("found %d jobs within %gkm after deleting, scan finds %d", len(got), radius, len(want))
+		}
+	}
+	if stats, _ := d.Stats(); stats.JobCount != 2000-deleted || stats.Index.Rebuilds != 1 {
+		t.Errorf("Stats() counts %d jobs and %d rebuilds, want %d and 1", stats.JobCount, stats.Index.Rebuilds, 2000-deleted)
+	}
+}
+
+func TestDeleteEveryJobOfTitle(t *testing.T) {
+	d := newTestDB(t, Options{}, "Nurse,3.5,6.5", "Driver,3.6,6.6", "Nurse,3.7,6.7")
+	if deleted, _ := d.DeleteJobs("Nurse", models.Location{Longitude: 3.6, Latitude: 6.6}, 50); deleted != 2 {
+		t.Fatalf("DeleteJobs deleted %d jobs, want 2", deleted)
+	}
+
+	titles, _ := d.TitleJobs()
+	if _, ok := titles["nurse"]; ok || len(titles) != 1 {
+		t.Errorf("TitleJobs() = %v, want Driver only", titles)
+	}
+	if deleted, _ := d.DeleteJobs("Driver", models.Location{Longitude: 3.6, Latitude: 6.6}, 0); deleted != 1 {
+		t.Fatalf("DeleteJobs deleted %d jobs, want 1", deleted)
+	}
+	if found, _ := d.FindJobsNearby(context.Background(), models.Location{Longitude: 3.6, Latitude: 6.6}, 50, rtree.Inclusive); len(found) != 0 {
+		t.Errorf("found %d jobs once every job deleted", len(found))
+	}
+
+	job := models.Job{Title: "Cook", Location: models.Location{Longitude: 3.6, Latitude: 6.6}}
+	if err := d.AddJob(&job); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := d.FindJobsNearby(context.Background(), job.Location, 1, rtree.Inclusive); len(found) != 1 {
+		t.Errorf("found %d jobs once added to an emptied DB, want 1", len(found))
+	}
+}
+
+func TestAddJobsEvictsAboveMaxJobs(t *testing.T) {
+	d := newTestDB(t, Options{MaxJobs: 3}, "Nurse,3.5,6.5", "Driver,3.6,6.6", "Cook,3.7,6.7")
+	added := []models.Job{
+		{Title: "Welder", Location: models.Location{Longitude: 3.8, Latitude: 6.8}},
+		{Title: "Tailor", Location: models.Location{Longitude: 3.9, Latitude: 6.9}},
+	}
+	if err := d.AddJobs(added); err != nil {
+		t.Fatal(err)
+	}
+
+	found, _ := d.FindJobsNearby(context.Background(), models.Location{Longitude: 3.7, Latitude: 6.7}, 100, rtree.Inclusive)
+	titles := make(map[string]bool)
+	for _, job := range found {
+		titles[job.Title] = true
+	}
+	if want := map[string]bool{"Cook": true, "Welder": true, "Tailor": true}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("found jobs titled %v, want the oldest evicted leaving %v", titles, want)
+	}
+	if stats, _ := d.Stats(); stats.JobCount != 3 || stats.TitleCount != 3 {
+		t.Errorf("Stats() counts %d jobs and %d titles, want 3 and 3", stats.JobCount, stats.TitleCount)
+	}
+}
+
+func TestEvictionPolicies(t *testing.T) {
+	lines := []string{"Nurse,3.5,6.5", "Driver,3.6,6.6", "Cook,3.7,6.7", "Driver,3.8,6.8"}
+	center := models.Location{Longitude: 3.7, Latitude: 6.7}
+
+	// titles returns the titles of the jobs of d, in ascending order
+	titles := func(d *DB) []string {
+		found, _ := d.FindJobsNearby(context.Background(), center, 100, rtree.Inclusive)
+		titles := make([]string, len(found))
+		for i, job := range found {
+			titles[i] = job.Title
+		}
+		sort.Strings(titles)
+		return titles
+	}
+
+	tests := []struct {
+		name   string
+		evict  EvictionPolicy
+		loaded []string
+		added  []string
+	}{
+		{name: "oldest", loaded: []string{"Cook", "Driver", "Driver"}, added: []string{"Cook", "Tailor", "Welder"}},
+		{name: "cooks first", evict: EvictMatchingFirst(func(job models.Job) bool { return job.Title == "Cook" }),
+			loaded: []string{"Driver", "Driver", "Nurse"}, added: []string{"Driver", "Tailor", "Welder"}},
+	}
+	for _, test := range tests {
+		d := newTestDB(t, Options{MaxJobs: 3, Evict: test.evict}, lines...)
+		if got := titles(d); !reflect.DeepEqual(got, test.loaded) {
+			t.Errorf("%s: loaded %v, want %v", test.name, got, test.loaded)
+		}
+
+		// jobs added one by one never exceed the cap
+		for _, title := range []string{"Welder", "Cook", "Tailor"} {
+			if err := d.AddJob(&models.Job{Title: title, Location: center}); err != nil {
+				t.Fatal(err)
+			}
+			if stats, _ := d.Stats(); stats.JobCount != 3 {
+				t.Errorf("%s: %d jobs after adding %s, want 3", test.name, stats.JobCount, title)
+			}
+		}
+		if got := titles(d); !reflect.DeepEqual(got, test.added) {
+			t.Errorf("%s: kept %v, want %v", test.name, got, test.added)
+		}
+	}
+}
+
+func TestIncrementalUpdatesDoNotCompact(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	d := newTestDB(t, Options{}, randomLines(random, 500, "Nurse", "Driver")...)
+	before, _ := d.Stats()
+
+	for i := 0; i < 50; i++ {
+		job := models.Job{Title: "Cook", Location: models.Location{Longitude: 3 + random.Float64(), Latitude: 6 + random.Float64()}}
+		if err := d.AddJob(&job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.DeleteJobs("Nurse", models.Location{Longitude: 3.5, Latitude: 6.5}, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	after, _ := d.Stats()
+	if after.Index.Rebuilds != before.Index.Rebuilds || after.Index.LeafMerges != before.Index.LeafMerges {
+		t.Errorf("adding and deleting jobs took the index from %d rebuilds and %d leaf merges to %d and %d",
+			before.Index.Rebuilds, before.Index.LeafMerges, after.Index.Rebuilds, after.Index.LeafMerges)
+	}
+
+	d.Rebuild()
+	if rebuilt, _ := d.Stats(); rebuilt.Index.Rebuilds != before.Index.Rebuilds+1 {
+		t.Errorf("Rebuild took the index from %d rebuilds to %d", before.Index.Rebuilds, rebuilt.Index.Rebuilds)
+	}
+}
+
+func TestAddJobsBatch(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	d := newTestDB(t, Options{}, randomLines(random, 100, "Nurse")...)
+
+	jobs := make([]models.Job, 10000)
+	for i := range jobs {
+		jobs[i] = models.Job{
+			Title:    []string{"Driver", "Cook", "Welder"}[i%3],
+			Location: models.Location{Longitude: 3 + random.Float64(), Latitude: 6 + random.Float64()},
+		}
+	}
+	if err := d.AddJobs(jobs); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, job := range jobs {
+		if job.ID == "" {
+			t.Fatalf("job %v added without an ID", job)
+		}
+	}
+	if stats, _ := d.Stats(); stats.JobCount != 10100 || stats.TitleCount != 4 {
+		t.Errorf("Stats() counts %d jobs and %d titles, want 10100 and 4", stats.JobCount, stats.TitleCount)
+	}
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	found, err := d.FindJobsNearby(context.Background(), center, 1000, rtree.Inclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 10100 {
+		t.Errorf("found %d jobs within 1000km, want 10100", len(found))
+	}
+	for _, radius := range []float64{2, 20, 50} {
+		found, _ := d.FindJobsNearby(context.Background(), center, radius, rtree.Inclusive)
+		if got, want := idsOf(found), scanNearby(d, center, radius); !reflect.DeepEqual(got, want) {
+			t.Errorf("found %d jobs within %gkm once added, scan finds %d", len(got), radius, len(want))
+		}
+	}
+	for _, job := range jobs[:100] {
+		if got, ok := d.JobByID(job.ID); !ok || got.ID != job.ID {
+			t.Errorf("JobByID(%q) = %v, %v, want the job added", job.ID, got, ok)
+		}
+	}
+}
+
+func TestDeleteJobsAtRoundedLocation(t *testing.T) {
+	// coordinates are stored at float32 precision, a few centimeters off those of the file
+	d := newTestDB(t, Options{}, "Nurse,3.123456789,6.987654321", "Nurse,3.2,6.9")
+
+	tests := []struct {
+		center  models.Location
+		deleted int
+	}{
+		{center: models.Location{Longitude: 3.12350, Latitude: 6.98765}, deleted: 0},     // ~5m off
+		{center: models.Location{Longitude: 3.1234597, Latitude: 6.9876573}, deleted: 1}, // ~0.5m off
+	}
+	for _, test := range tests {
+		deleted, err := d.DeleteJobs("Nurse", test.center, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted != test.deleted {
+			t.Errorf("DeleteJobs at %v deleted %d jobs, want %d", test.center, deleted, test.deleted)
+		}
+	}
+
+	// the exact coordinates of the file match too
+	d = newTestDB(t, Options{}, "Nurse,3.123456789,6.987654321", "Nurse,3.2,6.9")
+	if deleted, _ := d.DeleteJobs("Nurse", models.Location{Longitude: 3.123456789, Latitude: 6.987654321}, 0); deleted != 1 {
+		t.Errorf("DeleteJobs at the coordinates of the file deleted %d jobs, want 1", deleted)
+	}
+}
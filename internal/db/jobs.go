@@ -1,39 +1,543 @@
 package db
 
 import (
+	"context"
 	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	"go.opentelemetry.io/otel/attribute"
+	"sort"
 	"strings"
+	"time"
 )
 
 func (d *DB) TitleJobs() (map[string][]models.Job, error) {
-	d.lock.RLock()
-	titleJobs := d.titleJobs
-	d.lock.RUnlock()
-	return titleJobs, nil
+	return d.current.Load().titleJobs, nil
 }
 
-func (d *DB) FindJobsNearby(center models.Location, radius float64) ([]models.Job, error) {
-	d.lock.RLock()
-	jobs := d.index.FindJobs(models.Distance{
+// TitleJobsPage fetches up to limit titles, with their jobs, following the title
+// keyed after in ascending order. An empty after starts from the first title.
+// next is the key of the last title in page if more titles follow, else empty.
+func (d *DB) TitleJobsPage(after string, limit int) (page map[string][]models.Job, next string, err error) {
+	ds := d.current.Load()
+	start := sort.SearchStrings(ds.titleKeys, after)
+	if start < len(ds.titleKeys) && ds.titleKeys[start] == after {
+		start++
+	}
+
+	end := start + limit
+	if end > len(ds.titleKeys) {
+		end = len(ds.titleKeys)
+	}
+
+	page = make(map[string][]models.Job, end-start)
+	for _, key := range ds.titleKeys[start:end] {
+		page[key] = ds.titleJobs[key]
+	}
+	if end < len(ds.titleKeys) {
+		next = ds.titleKeys[end-1]
+	}
+	return page, next, nil
+}
+
+// JobsWithTitle finds the jobs keyed with the key of title under Options or of any of its synonyms
+func (d *DB) JobsWithTitle(title string) ([]models.Job, error) {
+	ds := d.current.Load()
+	jobs := make([]models.Job, 0)
+	for _, key := range d.titleKeys(title) {
+		jobs = append(jobs, ds.titleJobs[key]...)
+	}
+	return jobs, nil
+}
+
+// TitleCountsWithPrefix counts the jobs of each title keyed with a key starting with
+// the key of prefix, walking the sorted title keys from the first key not before prefix
+func (d *DB) TitleCountsWithPrefix(prefix string) (map[string]int, error) {
+	ds := d.current.Load()
+	prefixKey := d.options.titleKey(prefix)
+	counts := make(map[string]int)
+	for _, key := range ds.titleKeys[sort.SearchStrings(ds.titleKeys, prefixKey):] {
+		if !strings.HasPrefix(key, prefixKey) {
+			break
+		}
+		counts[key] = len(ds.titleJobs[key])
+	}
+	return counts, nil
+}
+
+func (d *DB) Stats() (models.Stats, error) {
+	ds := d.current.Load()
+	stats := ds.stats
+	stats.Index = models.IndexStats{
+		Rebuilds:   d.rebuilds.Load(),
+		LeafSplits: d.leafSplits.Load(),
+		NodeSplits: d.nodeSplits.Load(),
+		LeafMerges: d.leafMerges.Load(),
+
+		VerifyMismatches: d.verifyMismatches.Load(),
+	}
+	if load := d.load.Load(); load != nil {
+		stats.Load = *load
+	}
+	if ds.index != nil {
+		stats.Index.Height = ds.index.Height()
+		_, stats.Index.Nodes = ds.index.Size()
+	}
+	return stats, nil
+}
+
+// DefaultRadius returns the radius of nearby searches not specifying one,
+// configured with Options.DefaultRadius
+func (d *DB) DefaultRadius() models.Distance {
+	return d.options.defaultRadius()
+}
+
+// Sphere returns the sphere distances between jobs are computed on,
+// configured with Options.EarthRadiusKm
+func (d *DB) Sphere() models.Sphere {
+	return models.Sphere{RadiusKm: d.options.EarthRadiusKm}
+}
+
+// FindJobsNearby substitutes DefaultRadius for a zero radius.
+// Searches matching any of Options.WarmQueries are served without searching the index.
+// The jobs found are checked against a scan of every job under Options.Verify.
+// Jobs exactly at radius are found unless boundary is rtree.Exclusive.
+// FindJobsNearby returns ctx.Err() if ctx is done before the search completes
+func (d *DB) FindJobsNearby(ctx context.Context, center models.Location, radius float64, boundary rtree.Boundary) (jobs []models.Job, err error) {
+	if radius == 0 {
+		radius = d.DefaultRadius().Kilometers()
+	}
+	ctx, span := startSpan(ctx, "DB.FindJobsNearby")
+	span.SetAttributes(attribute.Float64("radius_km", radius))
+	defer func() { endSpan(span, err) }()
+
+	ds := d.current.Load()
+	if ds.index == nil {
+		return []models.Job{}, nil
+	}
+	if warmed, ok := d.warmedJobs(ds, center, radius, boundary); ok {
+		span.SetAttributes(attribute.Bool("warmed", true))
+		return d.verifyNearby(ds, center, radius, boundary, warmed), nil
+	}
+	jobs = ds.index.FindJobs(ctx, models.Distance{
+		Unit:  models.Kilometer,
+		Value: radius,
+	}, center, ds.titleJobs, boundary)
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	return d.verifyNearby(ds, center, radius, boundary, jobs), nil
+}
+
+// FindJobsNearbyApproximately finds every job within radius of center along with
+// other jobs indexed next to them, which may lie outside radius, as with rtree.RTree.FindJobsApproximately.
+// FindJobsNearbyApproximately substitutes DefaultRadius for a zero radius.
+func (d *DB) FindJobsNearbyApproximately(ctx context.Context, center models.Location, radius float64) (jobs []models.Job, err error) {
+	if radius == 0 {
+		radius = d.DefaultRadius().Kilometers()
+	}
+	ctx, span := startSpan(ctx, "DB.FindJobsNearbyApproximately")
+	span.SetAttributes(attribute.Float64("radius_km", radius))
+	defer func() { endSpan(span, err) }()
+
+	ds := d.current.Load()
+	if ds.index == nil {
+		return []models.Job{}, nil
+	}
+	jobs = ds.index.FindJobsApproximately(models.Distance{
 		Unit:  models.Kilometer,
 		Value: radius,
-	}, center, d.titleJobs)
-	d.lock.RUnlock()
+	}, center)
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
 	return jobs, nil
 }
 
-func (d *DB) SearchJobsByTitleAndLocation(title string, location models.Location) ([]models.Job, error) {
-	d.lock.RLock()
-	jobs := d.index.FindJobs(models.Distance{
+// CountJobsNearbyApprox counts the jobs within radius of center, as with rtree.RTree.CountJobsApproximately.
+// CountJobsNearbyApprox substitutes DefaultRadius for a zero radius.
+// Jobs exactly at radius are counted unless boundary is rtree.Exclusive.
+func (d *DB) CountJobsNearbyApprox(ctx context.Context, center models.Location, radius float64, boundary rtree.Boundary) (count int, err error) {
+	if radius == 0 {
+		radius = d.DefaultRadius().Kilometers()
+	}
+	ctx, span := startSpan(ctx, "DB.CountJobsNearbyApprox")
+	span.SetAttributes(attribute.Float64("radius_km", radius))
+	defer func() { endSpan(span, err) }()
+
+	ds := d.current.Load()
+	if ds.index == nil {
+		return 0, nil
+	}
+	count = ds.index.CountJobsApproximately(models.Distance{
+		Unit:  models.Kilometer,
+		Value: radius,
+	}, center, boundary)
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// TitleCountsNearby counts the jobs of each title within radius of center,
+// titles being keyed as in TitleJobs.
+// TitleCountsNearby returns ctx.Err() if ctx is done before the search completes
+func (d *DB) TitleCountsNearby(ctx context.Context, center models.Location, radius float64) (map[string]int, error) {
+	jobs, err := d.FindJobsNearby(ctx, center, radius, rtree.Inclusive)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, job := range jobs {
+		counts[d.options.titleKey(job.Title)]++
+	}
+	return counts, nil
+}
+
+// Jobs exactly at radius of their nearest center are found unless boundary is rtree.Exclusive.
+// FindJobsNearAny returns ctx.Err() if ctx is done before the search completes
+func (d *DB) FindJobsNearAny(ctx context.Context, centers []models.Location, radius float64, boundary rtree.Boundary) (jobs []models.JobWithDistance, err error) {
+	ctx, span := startSpan(ctx, "DB.FindJobsNearAny")
+	span.SetAttributes(attribute.Float64("radius_km", radius), attribute.Int("centers", len(centers)))
+	defer func() { endSpan(span, err) }()
+
+	ds := d.current.Load()
+	if ds.index == nil {
+		return []models.JobWithDistance{}, nil
+	}
+	jobs = ds.index.FindJobsNearAny(ctx, centers, models.Distance{
 		Unit:  models.Kilometer,
-		Value: 5,
-	}, location, d.titleJobs)
-	d.lock.RUnlock()
-	titleJobs := make([]models.Job, 0)
+		Value: radius,
+	}, boundary)
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// FindEntriesInBox returns ctx.Err() if ctx is done before the search completes
+func (d *DB) FindEntriesInBox(ctx context.Context, box models.Box) (entries []rtree.EntryView, err error) {
+	ctx, span := startSpan(ctx, "DB.FindEntriesInBox")
+	defer func() { endSpan(span, err) }()
+
+	ds := d.current.Load()
+	if ds.index == nil {
+		return []rtree.EntryView{}, nil
+	}
+	entries = ds.index.FindEntriesInBox(box.Min, box.Max)
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// cancellationCheckInterval is the number of jobs CountJobsInBox counts
+// between checks that its context is done
+const cancellationCheckInterval = 1024
+
+// CountJobsInBox counts the jobs located within box.
+// CountJobsInBox returns ctx.Err() if ctx is done before the count completes
+func (d *DB) CountJobsInBox(ctx context.Context, box models.Box) (count int, err error) {
+	ctx, span := startSpan(ctx, "DB.CountJobsInBox")
+	defer func() { endSpan(span, err) }()
+
+	ds := d.current.Load()
+	if ds.index == nil {
+		return 0, nil
+	}
+	ds.index.ForEachInBox(box.Min, box.Max, func(job models.Job) bool {
+		count++
+		return count%cancellationCheckInterval != 0 || ctx.Err() == nil
+	})
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountJobsInGrid counts the jobs located within each cell of grid, over its box.
+// CountJobsInGrid returns ctx.Err() if ctx is done before the count completes
+func (d *DB) CountJobsInGrid(ctx context.Context, grid *models.Grid) (err error) {
+	ctx, span := startSpan(ctx, "DB.CountJobsInGrid")
+	defer func() { endSpan(span, err) }()
+
+	ds := d.current.Load()
+	if ds.index == nil {
+		return nil
+	}
+	box, count := grid.Box(), 0
+	ds.index.ForEachInBox(box.Min, box.Max, func(job models.Job) bool {
+		grid.Count(job.Location)
+		count++
+		return count%cancellationCheckInterval != 0 || ctx.Err() == nil
+	})
+	return ctx.Err()
+}
+
+// FindNearestJobs returns ctx.Err() if ctx is done before the search completes
+func (d *DB) FindNearestJobs(ctx context.Context, center models.Location, k int, box *models.Box, after *models.NearestPosition) (jobs []models.JobWithDistance, err error) {
+	ctx, span := startSpan(ctx, "DB.FindNearestJobs")
+	span.SetAttributes(attribute.Int("k", k), attribute.Bool("in_box", box != nil), attribute.Bool("after", after != nil))
+	defer func() { endSpan(span, err) }()
+
+	ds := d.current.Load()
+	if ds.index == nil {
+		return []models.JobWithDistance{}, nil
+	}
+	switch {
+	case box == nil && after == nil:
+		jobs = ds.index.Nearest(center, k)
+	case box == nil:
+		jobs = ds.index.NearestAfter(center, k, *after)
+	case after == nil:
+		jobs = ds.index.NearestInBox(center, k, box.Min, box.Max)
+	default:
+		jobs = ds.index.NearestInBoxAfter(center, k, box.Min, box.Max, *after)
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// NeighborsOf finds up to k jobs nearest to the job with id, but that job itself,
+// each annotated with its distance to that job, from the nearest.
+// No job is found if no job has id.
+func (d *DB) NeighborsOf(id string, k int) []models.JobWithDistance {
+	ds := d.current.Load()
+	if ds.index == nil {
+		return []models.JobWithDistance{}
+	}
+	job, ok := ds.index.JobByID(id)
+	if !ok {
+		return []models.JobWithDistance{}
+	}
+	return ds.index.NearestExcept(job.Location, k, id)
+}
+
+// NearestPerTitle finds up to k jobs nearest to center for each of titles, each annotated
+// with its distance to center, from the nearest. Titles are keyed by title as given and
+// match their synonyms too, as in SearchJobsByTitleAndLocation.
+// A title having no job is mapped to an empty slice
+func (d *DB) NearestPerTitle(center models.Location, titles []string, k int) map[string][]models.JobWithDistance {
+	ds := d.current.Load()
+	nearest := make(map[string][]models.JobWithDistance, len(titles))
+	for _, title := range titles {
+		var sameJobs []models.Job
+		for _, key := range d.titleKeys(title) {
+			sameJobs = append(sameJobs, ds.titleJobs[key]...)
+		}
+		jobs := models.NearestFirst(d.Sphere(), center, sameJobs)
+		if len(jobs) > k {
+			jobs = jobs[:k]
+		}
+		nearest[title] = jobs
+	}
+	return nearest
+}
+
+// Hotspot returns ctx.Err() if ctx is done before the search completes.
+// ok is false if there are no jobs.
+func (d *DB) Hotspot(ctx context.Context, radius float64) (hotspot models.Hotspot, ok bool, err error) {
+	ctx, span := startSpan(ctx, "DB.Hotspot")
+	span.SetAttributes(attribute.Float64("radius_km", radius))
+	defer func() { endSpan(span, err) }()
+
+	ds := d.current.Load()
+	if ds.index == nil {
+		return hotspot, false, nil
+	}
+	hotspot = ds.index.Hotspot(ctx, models.Distance{
+		Unit:  models.Kilometer,
+		Value: radius,
+	})
+	if err = ctx.Err(); err != nil {
+		return hotspot, false, err
+	}
+	return hotspot, true, nil
+}
+
+// rareTitleThreshold is the maximum number of jobs a title, company or both can have
+// for SearchJobsByTitleAndLocation to compute distances on their jobs only,
+// rather than filtering every job found around location by title and company.
+const rareTitleThreshold = 50
+
+// SearchJobsByTitleAndLocation matches title and company on their keys under Options,
+// title matching its synonyms too. An empty title or company matches any title or company respectively.
+// SearchJobsByTitleAndLocation returns ctx.Err() if ctx is done before the search completes
+func (d *DB) SearchJobsByTitleAndLocation(ctx context.Context, title, company string, location models.Location) (jobs []models.Job, err error) {
+	ctx, span := startSpan(ctx, "DB.SearchJobsByTitleAndLocation")
+	span.SetAttributes(attribute.String("title", title), attribute.String("company", company))
+	defer func() { endSpan(span, err) }()
+
+	within := d.DefaultRadius()
+	titleKeys, companyKey := d.titleKeys(title), d.options.companyKey(company)
+
+	ds := d.current.Load()
+	if ds.index == nil {
+		return []models.Job{}, nil
+	}
+
+	var sameJobs []models.Job
+	switch {
+	case title == "":
+		sameJobs = ds.companyJobs[companyKey]
+	case companyKey == "":
+		for _, titleKey := range titleKeys {
+			sameJobs = append(sameJobs, ds.titleJobs[titleKey]...)
+		}
+	default:
+		for _, titleKey := range titleKeys {
+			sameJobs = append(sameJobs, ds.titleCompanyJobs[titleCompany{title: titleKey, company: companyKey}]...)
+		}
+	}
+	if len(sameJobs) <= rareTitleThreshold {
+		return ds.index.FindJobsAmong(within, location, sameJobs, rtree.Inclusive), nil
+	}
+
+	jobs = ds.index.FindJobs(ctx, within, location, ds.titleJobs, rtree.Inclusive)
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	titleKeySet := d.titleKeySet(title)
+	matching := make([]models.Job, 0)
+	for _, job := range jobs {
+		if (title == "" || titleKeySet[d.options.titleKey(job.Title)]) &&
+			(companyKey == "" || d.options.companyKey(job.Company) == companyKey) {
+			matching = append(matching, job)
+		}
+	}
+	return matching, nil
+}
+
+// JobByID finds the job with id.
+// ok is false if no job has id.
+func (d *DB) JobByID(id string) (job models.Job, ok bool) {
+	ds := d.current.Load()
+	if ds.index == nil {
+		return job, false
+	}
+	return ds.index.JobByID(id)
+}
+
+// AddJob adds job to the DB, setting job.ID as with AddJobs.
+// Prefer AddJobs when adding several jobs at once.
+func (d *DB) AddJob(job *models.Job) error {
+	jobs := []models.Job{*job}
+	err := d.AddJobs(jobs)
+	*job = jobs[0]
+	return err
+}
+
+// AddJobs adds jobs to the DB in a single batch.
+// The ID of each of jobs lacking one, or having one already taken, is set in place,
+// and the CreatedAt of each of jobs is set to the time of the call, as is the Title
+// of each of jobs retitled under Options.TitleCasing.
+// With Options.MaxJobs, jobs above the cap are evicted, possibly among jobs.
+// The write lock is taken once for the whole batch, and jobs are inserted into a copy of
+// the current index rather than indexing every job again, as with withJobs,
+// jobs evicted being deleted from that copy as with withoutJobs.
+// Queries running while AddJobs is in progress are served from the current dataset.
+func (d *DB) AddJobs(jobs []models.Job) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// the current dataset may still be read, hence jobs is added to a copy
+	current := d.current.Load()
+	models.AssignNewIDs(jobs, func(id string) bool {
+		_, taken := d.JobByID(id)
+		return taken
+	})
+	now := time.Now()
+	for i := range jobs {
+		jobs[i].CreatedAt = &now
+	}
+
+	titleCasings := addTitleCasings(current.titleCasings, jobs, d.options)
+	cased := d.options.keepFirstTitleCasing(jobs, titleCasings)
+	updated := d.withJobs(current, cased, titleCasings)
+	if evicted := d.options.evicted(updated.jobs); len(evicted) != 0 {
+		updated = d.withoutJobs(updated, func(job models.Job) bool { return evicted[job.ID] })
+	}
+	d.current.Store(updated)
+	if d.options.TitleCasing == KeepFirstTitleCasing {
+		copy(jobs, cased)
+	}
+	return nil
+}
+
+// SpatialJoin pairs each job titled titleA with every job titled titleB within distance of it,
+// querying the index around each titleA job. Either title matches its synonyms too.
+// A job is never paired with itself.
+// Pairs are ordered as the titleA jobs, then from the nearest titleB job.
+func (d *DB) SpatialJoin(titleA, titleB string, within models.Distance) []models.JobPair {
+	ds := d.current.Load()
+	pairs := make([]models.JobPair, 0)
+	if ds.index == nil {
+		return pairs
+	}
+
+	var jobsA []models.Job
+	for _, keyA := range d.titleKeys(titleA) {
+		jobsA = append(jobsA, ds.titleJobs[keyA]...)
+	}
+	keysB := d.titleKeySet(titleB)
+	for _, a := range jobsA {
+		nearby := make([]models.Job, 0)
+		for _, b := range ds.index.FindJobs(context.Background(), within, a.Location, ds.titleJobs, rtree.Inclusive) {
+			if b.ID != a.ID && keysB[d.options.titleKey(b.Title)] {
+				nearby = append(nearby, b)
+			}
+		}
+		for _, b := range models.NearestFirst(d.Sphere(), a.Location, nearby) {
+			pairs = append(pairs, models.JobPair{A: a, B: b.Job, Distance: b.Distance})
+		}
+	}
+	return pairs
+}
+
+// DeleteJobs deletes jobs matching title within radius of center,
+// and returns the number of jobs deleted.
+// A zero radius deletes jobs located at center, as with models.Sphere.Matches.
+// Queries running while DeleteJobs is in progress are served from the current dataset.
+func (d *DB) DeleteJobs(title string, center models.Location, radius float64) (int, error) {
+	titleKey, sphere := d.options.titleKey(title), d.Sphere()
+	deleted := d.deleteJobsMatching(func(job models.Job) bool {
+		return d.options.titleKey(job.Title) == titleKey && sphere.Matches(center, job.Location, radius)
+	})
+	return deleted, nil
+}
+
+// deleteJobsMatching deletes every job for which match is true from the DB,
+// and returns the number of jobs deleted.
+// Jobs are deleted from a copy of the current index rather than indexing the jobs left
+// again, as with withoutJobs, and the copy replaces the current dataset only if a job was deleted.
+func (d *DB) deleteJobsMatching(match func(job models.Job) bool) int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// the current dataset may still be read, hence jobs are deleted from a copy
+	current := d.current.Load()
+	updated := d.withoutJobs(current, match)
+
+	deleted := len(current.jobs) - len(updated.jobs)
+	if deleted != 0 {
+		d.current.Store(updated)
+	}
+	return deleted
+}
+
+// withoutJobsMatching returns a copy of jobs without the jobs for which match is true
+func withoutJobsMatching(jobs []models.Job, match func(job models.Job) bool) []models.Job {
+	remaining := make([]models.Job, 0, len(jobs))
 	for _, job := range jobs {
-		if strings.ToLower(job.Title) == strings.ToLower(title) {
-			titleJobs = append(titleJobs, job)
+		if !match(job) {
+			remaining = append(remaining, job)
 		}
 	}
-	return titleJobs, nil
+	return remaining
 }
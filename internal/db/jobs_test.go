@@ -0,0 +1,604 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// titledLines returns the lines of a data file holding common jobs of two titles,
+// along with a few jobs titled Astronaut, all located at random around Lagos
+func titledLines(random *rand.Rand, common int) []string {
+	lines := randomLines(random, common, "Nurse", "Driver")
+	return append(lines, randomLines(random, rareTitleThreshold/5, "Astronaut")...)
+}
+
+func TestSearchJobsByTitleAndLocation(t *testing.T) {
+	d := newTestDB(t, Options{}, titledLines(rand.New(rand.NewSource(1)), 5000)...)
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+
+	for _, title := range []string{"Astronaut", "Nurse"} {
+		jobs, err := d.SearchJobsByTitleAndLocation(context.Background(), title, "", center)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make([]models.Job, 0)
+		for _, job := range d.current.Load().jobs {
+			if job.Title == title && d.Sphere().Distance(center, job.Location) <= d.DefaultRadius().Kilometers() {
+				want = append(want, job)
+			}
+		}
+		if got := idsOf(jobs); !reflect.DeepEqual(got, idsOf(want)) {
+			t.Errorf("found %d jobs titled %s, a scan finds %d", len(got), title, len(want))
+		}
+	}
+}
+
+func TestSearchJobsByTitleAndCompany(t *testing.T) {
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+
+	// few jobs are searched among the jobs of their title, many are searched for in the index
+	for _, perPair := range []int{2, rareTitleThreshold} {
+		d := newTestDB(t, Options{}, "Cook,3.5,6.5")
+		var jobs []models.Job
+		for i := 0; i < perPair; i++ {
+			for _, title := range []string{"Nurse", "Driver"} {
+				for _, company := range []string{"St. Mary's", "Acme"} {
+					jobs = append(jobs, models.Job{Title: title, Company: company,
+						Location: models.Location{Longitude: 3.5 + float64(i)*1e-4, Latitude: 6.5}})
+				}
+			}
+		}
+		if err := d.AddJobs(jobs); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			title, company string
+			found          int
+		}{
+			{title: "nurse", found: 2 * perPair},
+			{company: "st. mary's", found: 2 * perPair},
+			{title: "Nurse", company: " ST. MARY'S ", found: perPair},
+			{title: "Cook", company: "Acme", found: 0},
+		}
+		for _, test := range tests {
+			found, err := d.SearchJobsByTitleAndLocation(context.Background(), test.title, test.company, center)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found) != test.found {
+				t.Errorf("%d jobs per title and company: found %d %q jobs at %q, want %d",
+					perPair, len(found), test.title, test.company, test.found)
+			}
+			for _, job := range found {
+				if test.title != "" && !strings.EqualFold(job.Title, test.title) ||
+					test.company != "" && !strings.EqualFold(job.Company, strings.TrimSpace(test.company)) {
+					t.Errorf("found %q job at %q searching %q jobs at %q", job.Title, job.Company, test.title, test.company)
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkSearchJobsByTitleAndLocation compares computing distances on the jobs of a title only
+// with filtering the jobs found by searching the index, for a rare and a common title
+func BenchmarkSearchJobsByTitleAndLocation(b *testing.B) {
+	d := newTestDB(b, Options{}, titledLines(rand.New(rand.NewSource(2)), 200000)...)
+	ds := d.current.Load()
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	within := models.Distance{Unit: models.Kilometer, Value: 20}
+
+	for _, title := range []string{"astronaut", "nurse"} {
+		b.Run("among/"+title, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ds.index.FindJobsAmong(within, center, ds.titleJobs[title], rtree.Inclusive)
+			}
+		})
+		b.Run("index/"+title, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				matching := 0
+				for _, job := range ds.index.FindJobs(context.Background(), within, center, ds.titleJobs, rtree.Inclusive) {
+					if d.options.titleKey(job.Title) == title {
+						matching++
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTitleJobsPage(t *testing.T) {
+	titles := make([]string, 23)
+	for i := range titles {
+		titles[i] = fmt.Sprintf("Title %02d", len(titles)-i)
+	}
+	d := newTestDB(t, Options{}, randomLines(rand.New(rand.NewSource(1)), 100, titles...)...)
+
+	for _, limit := range []int{1, 5, 23, 50} {
+		seen := make([]string, 0, len(titles))
+		jobs := 0
+		after := ""
+		for pages := 0; ; pages++ {
+			if pages > len(titles) {
+				t.Fatalf("limit %d: more pages than titles", limit)
+			}
+			page, next, err := d.TitleJobsPage(after, limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page) > limit || len(page) < limit && next != "" {
+				t.Fatalf("limit %d: page of %d titles followed by %q", limit, len(page), next)
+			}
+			keys := make([]string, 0, len(page))
+			for key, titleJobs := range page {
+				keys = append(keys, key)
+				jobs += len(titleJobs)
+			}
+			sort.Strings(keys)
+			seen = append(seen, keys...)
+			if next == "" {
+				break
+			}
+			after = next
+		}
+
+		want := make([]string, len(titles))
+		for i, title := range titles {
+			want[i] = strings.ToLower(title)
+		}
+		sort.Strings(want)
+		if !reflect.DeepEqual(seen, want) || jobs != 100 {
+			t.Errorf("limit %d: paginated titles %v holding %d jobs, want %v holding 100 jobs", limit, seen, jobs, want)
+		}
+	}
+}
+
+func TestJobByID(t *testing.T) {
+	d := newTestDB(t, Options{}, "Nurse,3.5,6.5", "Driver,3.6,6.6")
+	job := models.Job{Title: "Cook", Location: models.Location{Longitude: 3.7, Latitude: 6.7}}
+	if err := d.AddJob(&job); err != nil {
+		t.Fatal(err)
+	}
+
+	found, ok := d.JobByID(job.ID)
+	if !ok || found.Title != "Cook" || found.Location != job.Location {
+		t.Errorf("JobByID(%q) = %v, %v, want the job added", job.ID, found, ok)
+	}
+	if found, ok := d.JobByID("missing"); ok {
+		t.Errorf("JobByID(%q) = %v, want none", "missing", found)
+	}
+
+	// every job found by a query is found by its ID
+	jobs, _ := d.FindJobsNearby(context.Background(), job.Location, 100, rtree.Inclusive)
+	for _, job := range jobs {
+		if job.ID == "" {
+			t.Errorf("job %v found without an ID", job)
+		} else if _, ok := d.JobByID(job.ID); !ok {
+			t.Errorf("JobByID(%q) not found", job.ID)
+		}
+	}
+}
+
+func TestTitleCountsWithPrefix(t *testing.T) {
+	d := newTestDB(t, Options{},
+		"Nurse,3.1,6.1", "nurse,3.2,6.1", "Nursery Teacher,3.3,6.1", "Nutritionist,3.4,6.1", "Driver,3.5,6.1")
+
+	tests := []struct {
+		prefix string
+		counts map[string]int
+	}{
+		{prefix: "NU", counts: map[string]int{"nurse": 2, "nursery teacher": 1, "nutritionist": 1}},
+		{prefix: "nurs", counts: map[string]int{"nurse": 2, "nursery teacher": 1}},
+		{prefix: "nursery", counts: map[string]int{"nursery teacher": 1}},
+		{prefix: "pilot", counts: map[string]int{}},
+		{prefix: "zz", counts: map[string]int{}},
+	}
+	for _, test := range tests {
+		counts, err := d.TitleCountsWithPrefix(test.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(counts, test.counts) {
+			t.Errorf("TitleCountsWithPrefix(%q) = %v, want %v", test.prefix, counts, test.counts)
+		}
+	}
+}
+
+func TestTitleCountsNearby(t *testing.T) {
+	titles := []string{"Nurse", "Driver", "Cook", "Welder", "Tailor"}
+	radius := 15.0
+	d := newTestDB(t, Options{DefaultRadius: models.Distance{Unit: models.Kilometer, Value: radius}},
+		randomLines(rand.New(rand.NewSource(3)), 3000, titles...)...)
+	ctx := context.Background()
+
+	for _, center := range []models.Location{{Longitude: 3.5, Latitude: 6.5}, {Longitude: 3.05, Latitude: 6.95}, {Longitude: 5, Latitude: 8}} {
+		counts, err := d.TitleCountsNearby(ctx, center, radius)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, title := range titles {
+			jobs, err := d.SearchJobsByTitleAndLocation(ctx, title, "", center)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if counts[strings.ToLower(title)] != len(jobs) {
+				t.Errorf("counted %d %s jobs within %vkm of %v, a search of the title finds %d",
+					counts[strings.ToLower(title)], title, radius, center, len(jobs))
+			}
+		}
+
+		// titles without jobs in range are not counted
+		for title, count := range counts {
+			if count == 0 {
+				t.Errorf("title %q counted without jobs within %vkm of %v", title, radius, center)
+			}
+		}
+	}
+}
+
+func TestSpatialJoin(t *testing.T) {
+	d := newTestDB(t, Options{},
+		"Office,3,6",
+		"Office,3.5,6.5",
+		"Cafe,3.003,6",    // ~330m east of the first office
+		"Cafe,3,6.004",    // ~440m north of the first office
+		"Cafe,3.5,6.51",   // ~1.1km north of the second office
+		"Cafe,3.25,6.25",  // far from either office
+		"Office,3.0005,6", // ~55m east of the first office
+	)
+	within := models.Distance{Unit: models.Kilometer, Value: 0.5}
+
+	describe := func(pairs []models.JobPair) []string {
+		described := make([]string, len(pairs))
+		for i, pair := range pairs {
+			described[i] = fmt.Sprintf("%s %v - %s %v", pair.A.Title, pair.A.Location, pair.B.Title, pair.B.Location)
+		}
+		return described
+	}
+	at := func(longitude, latitude float64) models.Location {
+		return models.Location{Longitude: float64(float32(longitude)), Latitude: float64(float32(latitude))}
+	}
+	want := describe([]models.JobPair{
+		{A: models.Job{Title: "Office", Location: at(3, 6)}, B: models.Job{Title: "Cafe", Location: at(3.003, 6)}},
+		{A: models.Job{Title: "Office", Location: at(3, 6)}, B: models.Job{Title: "Cafe", Location: at(3, 6.004)}},
+		{A: models.Job{Title: "Office", Location: at(3.0005, 6)}, B: models.Job{Title: "Cafe", Location: at(3.003, 6)}},
+		{A: models.Job{Title: "Office", Location: at(3.0005, 6)}, B: models.Job{Title: "Cafe", Location: at(3, 6.004)}},
+	})
+	pairs := d.SpatialJoin("office", "cafe", within)
+	if got := describe(pairs); !reflect.DeepEqual(got, want) {
+		t.Errorf("SpatialJoin(office, cafe) = %q, want %q", got, want)
+	}
+	for _, pair := range pairs {
+		if distance := d.Sphere().Distance(pair.A.Location, pair.B.Location); pair.Distance != distance || distance > 0.5 {
+			t.Errorf("pair %s - %s %vkm apart, want %vkm within 0.5km", pair.A.ID, pair.B.ID, pair.Distance, distance)
+		}
+	}
+
+	// jobs are not paired with themselves
+	want = describe([]models.JobPair{
+		{A: models.Job{Title: "Office", Location: at(3, 6)}, B: models.Job{Title: "Office", Location: at(3.0005, 6)}},
+		{A: models.Job{Title: "Office", Location: at(3.0005, 6)}, B: models.Job{Title: "Office", Location: at(3, 6)}},
+	})
+	if got := describe(d.SpatialJoin("office", "office", within)); !reflect.DeepEqual(got, want) {
+		t.Errorf("SpatialJoin(office, office) = %q, want %q", got, want)
+	}
+}
+
+func TestDefaultRadius(t *testing.T) {
+	lines := randomLines(rand.New(rand.NewSource(4)), 2000, "Nurse")
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	ctx := context.Background()
+
+	tests := []struct {
+		radius models.Distance
+		km     float64
+	}{
+		{km: 5},
+		{radius: models.Distance{Unit: models.Kilometer, Value: 12}, km: 12},
+		{radius: models.Distance{Unit: models.Mile, Value: 3}, km: 4.828032},
+	}
+	for _, test := range tests {
+		d := newTestDB(t, Options{DefaultRadius: test.radius}, lines...)
+		if got := d.DefaultRadius().Kilometers(); math.Abs(got-test.km) > 1e-9 {
+			t.Errorf("DefaultRadius() = %vkm, want %vkm", got, test.km)
+		}
+
+		found, err := d.FindJobsNearby(ctx, center, 0, rtree.Inclusive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := idsOf(found), scanNearby(d, center, test.km); len(want) == 0 || !reflect.DeepEqual(got, want) {
+			t.Errorf("found %d jobs within a zero radius, want the %d within %vkm", len(got), len(want), test.km)
+		}
+	}
+}
+
+func TestDefaultRadiusTraced(t *testing.T) {
+	d := newTestDB(t, Options{DefaultRadius: models.Distance{Unit: models.Kilometer, Value: 12}}, "Nurse,3.5,6.5")
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	exporter := tracetest.NewInMemoryExporter()
+	ctx, parent := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test").Start(context.Background(), "test")
+
+	// spans record the radius searched, i.e., DefaultRadius for a zero radius
+	if _, err := d.FindJobsNearby(ctx, center, 0, rtree.Inclusive); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.FindJobsNearbyApproximately(ctx, center, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.CountJobsNearbyApprox(ctx, center, 0, rtree.Inclusive); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	traced := 0
+	for _, span := range exporter.GetSpans() {
+		for _, kv := range span.Attributes {
+			if kv.Key == "radius_km" {
+				traced++
+				if kv.Value.AsFloat64() != 12 {
+					t.Errorf("%s span radius_km %v, want 12", span.Name, kv.Value.AsFloat64())
+				}
+			}
+		}
+	}
+	if traced != 3 {
+		t.Errorf("%d spans recorded radius_km, want 3", traced)
+	}
+}
+
+func TestStats(t *testing.T) {
+	d := newTestDB(t, Options{}, "Nurse,103.5,1.5", "nurse,104.25,1.25", "Driver,103.75,2.25", "bad row")
+	stats, err := d.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := models.Box{
+		Min: models.Location{Longitude: 103.5, Latitude: 1.25},
+		Max: models.Location{Longitude: 104.25, Latitude: 2.25},
+	}
+	if stats.JobCount != 3 || stats.TitleCount != 2 || !reflect.DeepEqual(stats.BoundingBox, want) {
+		t.Errorf("Stats() = %d jobs, %d titles within %+v, want 3 jobs, 2 titles within %+v",
+			stats.JobCount, stats.TitleCount, stats.BoundingBox, want)
+	}
+	if stats.Load.Rows != 4 || stats.Load.SkippedRows != 1 {
+		t.Errorf("Stats() loaded %d rows skipping %d, want 4 rows skipping 1", stats.Load.Rows, stats.Load.SkippedRows)
+	}
+	if jobs, nodes := d.current.Load().index.Size(); jobs != 3 || stats.Index.Nodes != nodes || stats.Index.Rebuilds != 1 {
+		t.Errorf("Stats() reports %d nodes and %d rebuilds, want %d and 1", stats.Index.Nodes, stats.Index.Rebuilds, nodes)
+	}
+}
+
+func TestIndexStatsCountSplitsAndRebuilds(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	d := newTestDB(t, Options{}, randomLines(random, 20, "Nurse")...)
+	before, _ := d.Stats()
+	if before.Index.LeafSplits != 0 || before.Index.Rebuilds != 1 {
+		t.Fatalf("Stats() reports %d leaf splits and %d rebuilds of 20 jobs, want 0 and 1", before.Index.LeafSplits, before.Index.Rebuilds)
+	}
+
+	jobs := make([]models.Job, 1000)
+	for i := range jobs {
+		jobs[i] = models.Job{Title: "Driver", Location: models.Location{Longitude: 3 + random.Float64(), Latitude: 6 + random.Float64()}}
+	}
+	if err := d.AddJobs(jobs); err != nil {
+		t.Fatal(err)
+	}
+	added, _ := d.Stats()
+	if added.Index.LeafSplits < 10 || added.Index.NodeSplits == 0 || added.Index.Rebuilds != 1 {
+		t.Errorf("Stats() reports %d leaf splits, %d node splits and %d rebuilds once 1000 jobs added, want several splits and 1 rebuild",
+			added.Index.LeafSplits, added.Index.NodeSplits, added.Index.Rebuilds)
+	}
+
+	d.Rebuild()
+	rebuilt, _ := d.Stats()
+	if rebuilt.Index.Rebuilds != 2 || rebuilt.Index.LeafSplits <= added.Index.LeafSplits {
+		t.Errorf("Stats() reports %d rebuilds and %d leaf splits once rebuilt, want 2 and more than %d",
+			rebuilt.Index.Rebuilds, rebuilt.Index.LeafSplits, added.Index.LeafSplits)
+	}
+}
+
+func TestEmptyDB(t *testing.T) {
+	d := newTestDB(t, Options{})
+	ctx := context.Background()
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	box := models.Box{Min: models.Location{Longitude: 3, Latitude: 6}, Max: models.Location{Longitude: 4, Latitude: 7}}
+
+	results := map[string]int{}
+	count := func(name string, n int, err error) {
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		results[name] = n
+	}
+	titleJobs, err := d.TitleJobs()
+	count("TitleJobs", len(titleJobs), err)
+	page, next, err := d.TitleJobsPage("", 10)
+	count("TitleJobsPage", len(page)+len(next), err)
+	jobs, err := d.JobsWithTitle("Nurse")
+	count("JobsWithTitle", len(jobs), err)
+	prefixed, err := d.TitleCountsWithPrefix("nu")
+	count("TitleCountsWithPrefix", len(prefixed), err)
+	stats, err := d.Stats()
+	count("Stats", stats.JobCount+stats.TitleCount+stats.Index.Nodes, err)
+	jobs, err = d.FindJobsNearby(ctx, center, 10, rtree.Inclusive)
+	count("FindJobsNearby", len(jobs), err)
+	jobs, err = d.FindJobsNearbyApproximately(ctx, center, 10)
+	count("FindJobsNearbyApproximately", len(jobs), err)
+	n, err := d.CountJobsNearbyApprox(ctx, center, 10, rtree.Inclusive)
+	count("CountJobsNearbyApprox", n, err)
+	titleCounts, err := d.TitleCountsNearby(ctx, center, 10)
+	count("TitleCountsNearby", len(titleCounts), err)
+	near, err := d.FindJobsNearAny(ctx, []models.Location{center}, 10, rtree.Inclusive)
+	count("FindJobsNearAny", len(near), err)
+	entries, err := d.FindEntriesInBox(ctx, box)
+	count("FindEntriesInBox", len(entries), err)
+	n, err = d.CountJobsInBox(ctx, box)
+	count("CountJobsInBox", n, err)
+	grid := models.NewGrid(box, 2, 2)
+	err = d.CountJobsInGrid(ctx, grid)
+	count("CountJobsInGrid", 0, err)
+	near, err = d.FindNearestJobs(ctx, center, 5, nil, nil)
+	count("FindNearestJobs", len(near), err)
+	count("NeighborsOf", len(d.NeighborsOf("1", 5)), nil)
+	count("NearestPerTitle", len(d.NearestPerTitle(center, []string{"Nurse"}, 5)["Nurse"]), nil)
+	_, ok, err := d.Hotspot(ctx, 10)
+	if ok {
+		t.Error("Hotspot found a hotspot among no job")
+	}
+	count("Hotspot", 0, err)
+	jobs, err = d.SearchJobsByTitleAndLocation(ctx, "Nurse", "", center)
+	count("SearchJobsByTitleAndLocation", len(jobs), err)
+	if _, ok := d.JobByID("1"); ok {
+		t.Error("JobByID found a job among no job")
+	}
+	count("SpatialJoin", len(d.SpatialJoin("Nurse", "Driver", models.Distance{Unit: models.Kilometer, Value: 10})), nil)
+	suggestions, err := d.SuggestTitles("Nurse", 5)
+	count("SuggestTitles", len(suggestions), err)
+	n, err = d.DeleteJobs("Nurse", center, 10)
+	count("DeleteJobs", n, err)
+
+	for name, n := range results {
+		if n != 0 {
+			t.Errorf("%s found %d results in an empty DB", name, n)
+		}
+	}
+}
+
+func TestNearestPerTitle(t *testing.T) {
+	random := rand.New(rand.NewSource(1438))
+	d := newTestDB(t, Options{}, randomLines(random, 300, "Nurse", "Driver", "Cook")...)
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+
+	for _, k := range []int{1, 3, 50, 500} {
+		nearest := d.NearestPerTitle(center, []string{"Nurse", "driver", "Astronaut"}, k)
+		if len(nearest) != 3 {
+			t.Fatalf("k=%d: %d titles mapped, want 3", k, len(nearest))
+		}
+		if jobs, ok := nearest["Astronaut"]; !ok || len(jobs) != 0 {
+			t.Errorf("k=%d: Astronaut mapped to %v, want no job", k, jobs)
+		}
+
+		for _, title := range []string{"Nurse", "driver"} {
+			var all []models.Job
+			for _, job := range d.current.Load().jobs {
+				if strings.EqualFold(job.Title, title) {
+					all = append(all, job)
+				}
+			}
+			want := models.NearestFirst(d.Sphere(), center, all)
+			if len(want) > k {
+				want = want[:k]
+			}
+
+			jobs := nearest[title]
+			if len(jobs) != len(want) {
+				t.Fatalf("k=%d: %d %s jobs, want %d", k, len(jobs), title, len(want))
+			}
+			for i, job := range jobs {
+				if !strings.EqualFold(job.Title, title) {
+					t.Errorf("k=%d: %s job titled %s", k, title, job.Title)
+				}
+				if i > 0 && job.Distance < jobs[i-1].Distance {
+					t.Errorf("k=%d: %s job %d at %vkm follows one at %vkm", k, title, i, job.Distance, jobs[i-1].Distance)
+				}
+				if job.ID != want[i].ID {
+					t.Errorf("k=%d: %s job %d is %s at %vkm, want %s at %vkm", k, title, i, job.ID, job.Distance, want[i].ID, want[i].Distance)
+				}
+			}
+		}
+	}
+}
+
+// TestTitleNormalization checks that jobs are indexed by title and searched by title
+// through the same normalization, whatever casing either is in
+func TestTitleNormalization(t *testing.T) {
+	d := newTestDB(t, Options{}, "Nurse,3.301,6.3", "NURSE,3.302,6.3", "Ébéniste,3.303,6.3", "ébéniste,3.304,6.3", "Driver,3.305,6.3")
+	center := models.Location{Longitude: 3.3, Latitude: 6.3}
+
+	titleJobs, err := d.TitleJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, jobs := range titleJobs {
+		for _, job := range jobs {
+			if key != models.NormalizeTitle(job.Title) {
+				t.Errorf("%s job indexed under %q, want %q", job.Title, key, models.NormalizeTitle(job.Title))
+			}
+		}
+	}
+
+	for _, title := range []string{"nurse", "Nurse", "nURSE", "ÉBÉNISTE", "ébéniste", "DRIVER"} {
+		jobs, err := d.SearchJobsByTitleAndLocation(context.Background(), title, "", center)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := titleJobs[models.NormalizeTitle(title)]
+		if len(want) == 0 || !reflect.DeepEqual(idsOf(jobs), idsOf(want)) {
+			t.Errorf("%q: found %v, want the jobs indexed under %q %v", title, idsOf(jobs), models.NormalizeTitle(title), idsOf(want))
+		}
+	}
+}
+
+func TestNeighborsOf(t *testing.T) {
+	random := rand.New(rand.NewSource(1451))
+	lines := randomLines(random, 500, "Nurse", "Driver")
+	// jobs sharing a location are neighbors at zero distance, but not of themselves
+	lines = append(lines, "Cook,3.5,6.5", "Tailor,3.5,6.5", "Welder,3.5,6.5")
+	d := newTestDB(t, Options{}, lines...)
+	jobs := d.current.Load().jobs
+
+	for _, i := range append(random.Perm(len(jobs))[:20], len(jobs)-1) {
+		job := jobs[i]
+		others := make([]models.Job, 0, len(jobs)-1)
+		for _, other := range jobs {
+			if other.ID != job.ID {
+				others = append(others, other)
+			}
+		}
+		all := models.NearestFirst(d.Sphere(), job.Location, others)
+
+		for _, k := range []int{1, 5, 50, len(jobs)} {
+			neighbors := d.NeighborsOf(job.ID, k)
+			want := all
+			if len(want) > k {
+				want = want[:k]
+			}
+			if len(neighbors) != len(want) {
+				t.Fatalf("%s, k=%d: %d neighbors, want %d", job.ID, k, len(neighbors), len(want))
+			}
+			for j, neighbor := range neighbors {
+				if neighbor.ID == job.ID {
+					t.Errorf("%s, k=%d: job among its own neighbors", job.ID, k)
+				}
+				if j > 0 && neighbor.Distance < neighbors[j-1].Distance {
+					t.Errorf("%s, k=%d: neighbor %d at %vkm follows one at %vkm", job.ID, k, j, neighbor.Distance, neighbors[j-1].Distance)
+				}
+				if neighbor.ID != want[j].ID || neighbor.Distance != want[j].Distance {
+					t.Errorf("%s, k=%d: neighbor %d is %s at %vkm, want %s at %vkm", job.ID, k, j, neighbor.ID, neighbor.Distance, want[j].ID, want[j].Distance)
+				}
+			}
+		}
+	}
+
+	// the last job shares its location with two others, nearest at zero distance
+	last := jobs[len(jobs)-1]
+	if neighbors := d.NeighborsOf(last.ID, 2); len(neighbors) != 2 || neighbors[0].Distance != 0 || neighbors[1].Distance != 0 {
+		t.Errorf("neighbors of %s sharing its location %+v, want 2 at zero distance", last.Title, neighbors)
+	}
+	if neighbors := d.NeighborsOf("unknown", 5); len(neighbors) != 0 {
+		t.Errorf("%d neighbors of an unknown job, want none", len(neighbors))
+	}
+}
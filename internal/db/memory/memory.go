@@ -0,0 +1,418 @@
+// Package memory provides an in-memory repository of jobs for tests.
+// It serves the same queries as db.DB without reading a csv file
+// or building an R-tree, by scanning a plain slice of jobs.
+package memory
+
+import (
+	"context"
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is a lightweight repository of jobs backed by a slice.
+// Titles are matched once normalized with models.NormalizeTitle, as with db.DB.
+// MemoryRepository is safe for concurrent use.
+type MemoryRepository struct {
+	lock *sync.RWMutex
+	jobs []models.Job
+}
+
+// NewMemoryRepository returns a MemoryRepository holding jobs.
+// IDs are assigned to jobs as with db.DB.
+func NewMemoryRepository(jobs ...models.Job) *MemoryRepository {
+	m := &MemoryRepository{
+		lock: new(sync.RWMutex),
+		jobs: append([]models.Job{}, jobs...),
+	}
+	models.AssignIDs(m.jobs, make(map[string]bool, len(m.jobs)))
+	return m
+}
+
+func (m *MemoryRepository) TitleJobs() (map[string][]models.Job, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	titleJobs := make(map[string][]models.Job)
+	for _, job := range m.jobs {
+		key := models.NormalizeTitle(job.Title)
+		titleJobs[key] = append(titleJobs[key], job)
+	}
+	return titleJobs, nil
+}
+
+func (m *MemoryRepository) JobsWithTitle(title string) ([]models.Job, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	title = models.NormalizeTitle(title)
+	jobs := make([]models.Job, 0)
+	for _, job := range m.jobs {
+		if models.NormalizeTitle(job.Title) == title {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *MemoryRepository) TitleJobsPage(after string, limit int) (map[string][]models.Job, string, error) {
+	titleJobs, _ := m.TitleJobs()
+	keys := make([]string, 0, len(titleJobs))
+	for key := range titleJobs {
+		if key > after {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var next string
+	if len(keys) > limit {
+		keys = keys[:limit]
+		next = keys[limit-1]
+	}
+	page := make(map[string][]models.Job, len(keys))
+	for _, key := range keys {
+		page[key] = titleJobs[key]
+	}
+	return page, next, nil
+}
+
+// Rebuilding is always false, as MemoryRepository has no index
+func (m *MemoryRepository) Rebuilding() bool {
+	return false
+}
+
+func (m *MemoryRepository) Stats() (models.Stats, error) {
+	titleJobs, _ := m.TitleJobs()
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return models.NewStats(m.jobs, len(titleJobs)), nil
+}
+
+// DefaultRadius is always 5km
+func (m *MemoryRepository) DefaultRadius() models.Distance {
+	return models.Distance{Unit: models.Kilometer, Value: 5}
+}
+
+// Sphere is always the earth
+func (m *MemoryRepository) Sphere() models.Sphere {
+	return models.Earth
+}
+
+// FindJobsNearby excludes jobs exactly at radius if boundary is rtree.Exclusive, as with db.DB
+func (m *MemoryRepository) FindJobsNearby(ctx context.Context, location models.Location, radius float64, boundary rtree.Boundary) ([]models.Job, error) {
+	if radius == 0 {
+		radius = m.DefaultRadius().Kilometers()
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	jobs := make([]models.Job, 0)
+	for _, job := range m.jobs {
+		if boundary.Contains(location.DistanceTo(job.Location), radius) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// FindJobsNearbyApproximately finds the jobs within the bounding box of the circle
+// of radius around location, as there is no index to approximate with
+func (m *MemoryRepository) FindJobsNearbyApproximately(ctx context.Context, location models.Location, radius float64) ([]models.Job, error) {
+	if radius == 0 {
+		radius = m.DefaultRadius().Kilometers()
+	}
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	box := models.Earth.SearchBox(location, models.Distance{Unit: models.Kilometer, Value: radius})
+	jobs := make([]models.Job, 0)
+	for _, job := range m.jobs {
+		if box.Contains(job.Location) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// CountJobsNearbyApprox counts the jobs within radius of location exactly,
+// as there is no index to approximate with
+func (m *MemoryRepository) CountJobsNearbyApprox(ctx context.Context, location models.Location, radius float64, boundary rtree.Boundary) (int, error) {
+	jobs, _ := m.FindJobsNearby(ctx, location, radius, boundary)
+	return len(jobs), nil
+}
+
+func (m *MemoryRepository) TitleCountsNearby(ctx context.Context, location models.Location, radius float64) (map[string]int, error) {
+	jobs, _ := m.FindJobsNearby(ctx, location, radius, rtree.Inclusive)
+	counts := make(map[string]int)
+	for _, job := range jobs {
+		counts[models.NormalizeTitle(job.Title)]++
+	}
+	return counts, nil
+}
+
+func (m *MemoryRepository) TitleCountsWithPrefix(prefix string) (map[string]int, error) {
+	titleJobs, _ := m.TitleJobs()
+	prefix = models.NormalizeTitle(prefix)
+	counts := make(map[string]int)
+	for key, jobs := range titleJobs {
+		if strings.HasPrefix(key, prefix) {
+			counts[key] = len(jobs)
+		}
+	}
+	return counts, nil
+}
+
+func (m *MemoryRepository) FindJobsNearAny(ctx context.Context, centers []models.Location, radius float64, boundary rtree.Boundary) ([]models.JobWithDistance, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	jobs := make([]models.JobWithDistance, 0)
+	for _, job := range m.jobs {
+		nearest := math.Inf(1)
+		for _, center := range centers {
+			nearest = math.Min(nearest, center.DistanceTo(job.Location))
+		}
+		if boundary.Contains(nearest, radius) {
+			jobs = append(jobs, models.JobWithDistance{Job: job, Distance: nearest})
+		}
+	}
+
+	models.SortNearestFirst(jobs)
+	return jobs, nil
+}
+
+func (m *MemoryRepository) FindNearestJobs(ctx context.Context, center models.Location, k int, box *models.Box, after *models.NearestPosition) ([]models.JobWithDistance, error) {
+	m.lock.RLock()
+	candidates := make([]models.Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if box == nil || box.Contains(job.Location) {
+			candidates = append(candidates, job)
+		}
+	}
+	m.lock.RUnlock()
+
+	jobs := models.NearestFirst(models.Earth, center, candidates)
+	if after != nil {
+		start := 0
+		for start < len(jobs) && !after.Precedes(jobs[start]) {
+			start++
+		}
+		jobs = jobs[start:]
+	}
+	if len(jobs) > k {
+		jobs = jobs[:k]
+	}
+	return jobs, nil
+}
+
+func (m *MemoryRepository) NeighborsOf(id string, k int) []models.JobWithDistance {
+	job, ok := m.JobByID(id)
+	if !ok {
+		return []models.JobWithDistance{}
+	}
+
+	m.lock.RLock()
+	candidates := make([]models.Job, 0, len(m.jobs))
+	for _, candidate := range m.jobs {
+		if candidate.ID != id {
+			candidates = append(candidates, candidate)
+		}
+	}
+	m.lock.RUnlock()
+
+	jobs := models.NearestFirst(models.Earth, job.Location, candidates)
+	if len(jobs) > k {
+		jobs = jobs[:k]
+	}
+	return jobs
+}
+
+func (m *MemoryRepository) NearestPerTitle(center models.Location, titles []string, k int) map[string][]models.JobWithDistance {
+	nearest := make(map[string][]models.JobWithDistance, len(titles))
+	for _, title := range titles {
+		titled, _ := m.JobsWithTitle(title)
+		jobs := models.NearestFirst(models.Earth, center, titled)
+		if len(jobs) > k {
+			jobs = jobs[:k]
+		}
+		nearest[title] = jobs
+	}
+	return nearest
+}
+
+func (m *MemoryRepository) FindEntriesInBox(ctx context.Context, box models.Box) ([]rtree.EntryView, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	entries := make([]rtree.EntryView, 0)
+	for _, job := range m.jobs {
+		if box.Contains(job.Location) {
+			entries = append(entries, rtree.EntryView{
+				Job: job,
+				MBR: models.Box{Min: job.Location, Max: job.Location},
+			})
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryRepository) CountJobsInBox(ctx context.Context, box models.Box) (int, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	count := 0
+	for _, job := range m.jobs {
+		if box.Contains(job.Location) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryRepository) CountJobsInGrid(ctx context.Context, grid *models.Grid) error {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	for _, job := range m.jobs {
+		grid.Count(job.Location)
+	}
+	return nil
+}
+
+// Hotspot counts neighbours around every job, without sampling
+func (m *MemoryRepository) Hotspot(ctx context.Context, radius float64) (models.Hotspot, bool, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if len(m.jobs) == 0 {
+		return models.Hotspot{}, false, nil
+	}
+	hotspot := models.Hotspot{Count: -1}
+	for i, candidate := range m.jobs {
+		count := 0
+		for j, job := range m.jobs {
+			if i != j && candidate.Location.DistanceTo(job.Location) <= radius {
+				count++
+			}
+		}
+		if count > hotspot.Count {
+			hotspot = models.Hotspot{Location: candidate.Location, Count: count}
+		}
+	}
+	return hotspot, true, nil
+}
+
+func (m *MemoryRepository) SearchJobsByTitleAndLocation(ctx context.Context, title, company string, location models.Location) ([]models.Job, error) {
+	nearby, _ := m.FindJobsNearby(ctx, location, 0, rtree.Inclusive)
+	title, company = models.NormalizeTitle(title), strings.TrimSpace(company)
+	jobs := make([]models.Job, 0)
+	for _, job := range nearby {
+		if (title == "" || models.NormalizeTitle(job.Title) == title) &&
+			(company == "" || strings.EqualFold(strings.TrimSpace(job.Company), company)) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *MemoryRepository) JobByID(id string) (models.Job, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	for _, job := range m.jobs {
+		if job.ID == id {
+			return job, true
+		}
+	}
+	return models.Job{}, false
+}
+
+func (m *MemoryRepository) AddJob(job *models.Job) error {
+	jobs := []models.Job{*job}
+	err := m.AddJobs(jobs)
+	*job = jobs[0]
+	return err
+}
+
+// AddJobs sets the ID and CreatedAt of each of jobs in place, as with db.DB
+func (m *MemoryRepository) AddJobs(jobs []models.Job) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	taken := make(map[string]bool, len(m.jobs)+len(jobs))
+	for _, job := range m.jobs {
+		taken[job.ID] = true
+	}
+	models.AssignIDs(jobs, taken)
+	now := time.Now()
+	for i := range jobs {
+		jobs[i].CreatedAt = &now
+	}
+	m.jobs = append(m.jobs, jobs...)
+	return nil
+}
+
+func (m *MemoryRepository) SpatialJoin(titleA, titleB string, within models.Distance) []models.JobPair {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	titleA, titleB = models.NormalizeTitle(titleA), models.NormalizeTitle(titleB)
+	pairs := make([]models.JobPair, 0)
+	for _, a := range m.jobs {
+		if models.NormalizeTitle(a.Title) != titleA {
+			continue
+		}
+		nearby := make([]models.Job, 0)
+		for _, b := range m.jobs {
+			if b.ID != a.ID && models.NormalizeTitle(b.Title) == titleB && a.Location.DistanceTo(b.Location) <= within.Kilometers() {
+				nearby = append(nearby, b)
+			}
+		}
+		for _, b := range models.NearestFirst(models.Earth, a.Location, nearby) {
+			pairs = append(pairs, models.JobPair{A: a, B: b.Job, Distance: b.Distance})
+		}
+	}
+	return pairs
+}
+
+func (m *MemoryRepository) DeleteJobs(title string, center models.Location, radius float64) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	title = models.NormalizeTitle(title)
+	remaining := make([]models.Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		if models.NormalizeTitle(job.Title) != title || !m.Sphere().Matches(center, job.Location, radius) {
+			remaining = append(remaining, job)
+		}
+	}
+	deleted := len(m.jobs) - len(remaining)
+	m.jobs = remaining
+	return deleted, nil
+}
+
+// SuggestTitles suggests up to limit titles containing title, or contained in title,
+// ignoring case. Unlike db.DB, spelling mistakes are not accounted for.
+func (m *MemoryRepository) SuggestTitles(title string, limit int) ([]string, error) {
+	titleJobs, _ := m.TitleJobs()
+	title = models.NormalizeTitle(title)
+
+	suggestions := make([]string, 0)
+	for key, jobs := range titleJobs {
+		if strings.Contains(key, title) || strings.Contains(title, key) {
+			suggestions = append(suggestions, jobs[0].Title)
+		}
+	}
+
+	sort.Strings(suggestions)
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestAddJobsSetsCreatedAt(t *testing.T) {
+	m := NewMemoryRepository(models.Job{Title: "Nurse"})
+	job := models.Job{Title: "Driver"}
+	if err := m.AddJob(&job); err != nil {
+		t.Fatal(err)
+	}
+	if job.ID == "" || job.CreatedAt == nil {
+		t.Fatalf("AddJob set ID %q and CreatedAt %v, want both set", job.ID, job.CreatedAt)
+	}
+
+	stored, ok := m.JobByID(job.ID)
+	if !ok || stored.CreatedAt == nil || !stored.CreatedAt.Equal(*job.CreatedAt) {
+		t.Errorf("JobByID(%q) = %+v, want the job added with its CreatedAt", job.ID, stored)
+	}
+}
+
+func TestTitleNormalization(t *testing.T) {
+	m := NewMemoryRepository(models.Job{Title: "Nurse"}, models.Job{Title: "NURSE"}, models.Job{Title: "Ébéniste"}, models.Job{Title: "ébéniste"})
+	titleJobs, _ := m.TitleJobs()
+	if len(titleJobs) != 2 {
+		t.Fatalf("%d titles indexed, want 2", len(titleJobs))
+	}
+
+	for _, title := range []string{"nurse", "nUrSe", "ÉBÉNISTE"} {
+		jobs, _ := m.JobsWithTitle(title)
+		if want := titleJobs[models.NormalizeTitle(title)]; len(jobs) != 2 || len(want) != 2 {
+			t.Errorf("%q: found %d jobs, want the 2 indexed under %q", title, len(jobs), models.NormalizeTitle(title))
+		}
+	}
+}
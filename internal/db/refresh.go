@@ -0,0 +1,55 @@
+package db
+
+import (
+	"log"
+	"math/rand"
+	"time"
+)
+
+const (
+	// refreshJitter is the fraction of Options.RefreshInterval each refresh is moved by at random,
+	// so that servers started together do not all reread the file at once
+	refreshJitter = 0.1
+
+	// maxRefreshBackoff caps the multiple of Options.RefreshInterval waited after failed refreshes
+	maxRefreshBackoff = 8
+)
+
+// refresh reloads the DB from the file on path every Options.RefreshInterval, give or take
+// refreshJitter, until DB.Close is called. The interval doubles after each failed reload,
+// up to maxRefreshBackoff times, and is restored by the next reload succeeding.
+func (d *DB) refresh(path string) {
+	// the channel is kept aside, as Close clears d.stopRefresh once closed
+	stop := make(chan struct{})
+	d.stopRefresh = stop
+	go func() {
+		backoff := 1
+		timer := time.NewTimer(refreshDelay(d.options.RefreshInterval, backoff))
+		defer timer.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-timer.C:
+			}
+
+			if err := d.Reload(path); err != nil {
+				if backoff < maxRefreshBackoff {
+					backoff *= 2
+				}
+				log.Printf("error refreshing %s, retrying in %v: %v", path, time.Duration(backoff)*d.options.RefreshInterval, err)
+			} else {
+				backoff = 1
+				log.Printf("refreshed %s, %d jobs available", path, len(d.current.Load().jobs))
+			}
+			timer.Reset(refreshDelay(d.options.RefreshInterval, backoff))
+		}
+	}()
+}
+
+// refreshDelay returns interval times backoff, moved by up to refreshJitter of it either way
+func refreshDelay(interval time.Duration, backoff int) time.Duration {
+	delay := interval * time.Duration(backoff)
+	jitter := time.Duration((rand.Float64()*2 - 1) * refreshJitter * float64(delay))
+	return delay + jitter
+}
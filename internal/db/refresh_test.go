@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+)
+
+func TestRefreshInterval(t *testing.T) {
+	path := writeCSV(t, "Nurse,3.5,6.5")
+	d, err := Initialize(path, Options{RefreshInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	titles := func() string {
+		found, _ := d.FindJobsNearby(context.Background(), center, 1, rtree.Inclusive)
+		titles := make([]string, len(found))
+		for i, job := range found {
+			titles[i] = job.Title
+		}
+		sort.Strings(titles)
+		return strings.Join(titles, ",")
+	}
+	write := func(lines string) {
+		if err := os.WriteFile(path, []byte("title,longitude,latitude\n"+lines), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// await waits for the jobs found to be titled want, at most a second
+	await := func(want string) {
+		t.Helper()
+		got := titles()
+		for deadline := time.Now().Add(time.Second); got != want && time.Now().Before(deadline); got = titles() {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got != want {
+			t.Fatalf("found jobs titled %q, want %q once refreshed", got, want)
+		}
+	}
+
+	// changes to the file are picked up without watching it
+	write("Driver,3.5,6.5\nCook,3.5,6.5\n")
+	await("Cook,Driver")
+
+	// a file failing to load keeps the jobs available, until fixed
+	write("Welder\n")
+	time.Sleep(100 * time.Millisecond)
+	if got := titles(); got != "Cook,Driver" {
+		t.Errorf("found jobs titled %q after a failed refresh, want those before", got)
+	}
+	write("Welder,3.5,6.5\n")
+	await("Welder")
+
+	// refreshing stops once closed
+	d.Close()
+	write("Tailor,3.5,6.5\n")
+	time.Sleep(100 * time.Millisecond)
+	if got := titles(); got != "Welder" {
+		t.Errorf("found jobs titled %q once closed and the file changed, want those before", got)
+	}
+}
+
+func TestRefreshDelay(t *testing.T) {
+	const interval = time.Second
+	for _, backoff := range []int{1, 2, maxRefreshBackoff} {
+		delay := interval * time.Duration(backoff)
+		spread := time.Duration(refreshJitter * float64(delay))
+		low, high := delay, delay
+		for i := 0; i < 1000; i++ {
+			got := refreshDelay(interval, backoff)
+			if got < delay-spread || got > delay+spread {
+				t.Fatalf("backoff %d: delay %v beyond %v give or take %v", backoff, got, delay, spread)
+			}
+			if got < low {
+				low = got
+			}
+			if got > high {
+				high = got
+			}
+		}
+		// delays are spread either side of the interval
+		if low > delay-spread/2 || high < delay+spread/2 {
+			t.Errorf("backoff %d: delays within [%v, %v], want them jittered across %v give or take %v", backoff, low, high, delay, spread)
+		}
+	}
+}
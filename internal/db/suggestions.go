@@ -0,0 +1,77 @@
+package db
+
+import "sort"
+
+// maxSuggestionDistance is the maximum edit distance between a searched title
+// and an indexed title for the indexed title to be suggested
+const maxSuggestionDistance = 3
+
+// SuggestTitles finds up to limit indexed titles closest to title by Levenshtein distance,
+// ordered from the closest. Only titles within maxSuggestionDistance edits of title are suggested.
+func (d *DB) SuggestTitles(title string, limit int) ([]string, error) {
+	type suggestion struct {
+		title    string
+		distance int
+	}
+
+	titleKey := d.options.titleKey(title)
+	suggestions := make([]suggestion, 0)
+	for key, jobs := range d.current.Load().titleJobs {
+		if distance := levenshtein(titleKey, key); distance <= maxSuggestionDistance {
+			suggestions = append(suggestions, suggestion{title: jobs[0].Title, distance: distance})
+		}
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].title < suggestions[j].title
+	})
+
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	titles := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		titles[i] = s.title
+	}
+	return titles, nil
+}
+
+// levenshtein computes the minimum number of single-character insertions,
+// deletions and substitutions required to change a into b.
+// ref: https://en.wikipedia.org/wiki/Levenshtein_distance
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+
+	// previous holds distances between s[:i-1] and each prefix of t
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			substitutionCost := 1
+			if s[i-1] == t[j-1] {
+				substitutionCost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+substitutionCost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(t)]
+}
+
+func minInt(first int, rest ...int) int {
+	min := first
+	for _, v := range rest {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
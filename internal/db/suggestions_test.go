@@ -0,0 +1,63 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggestTitles(t *testing.T) {
+	d := newTestDB(t, Options{},
+		"Software Engineer,3.1,6.1",
+		"Software Engineer,3.2,6.2",
+		"Hardware Engineer,3.3,6.3",
+		"Nurse,3.4,6.4",
+		"Nurses Aide,3.5,6.5",
+	)
+
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		{title: "Sofware Engineer", want: []string{"Software Engineer"}},
+		{title: "Nurce", want: []string{"Nurse"}},
+		{title: "Softwear Enginer", want: []string{"Software Engineer"}},
+		{title: "Pilot", want: []string{}},
+	}
+	for _, test := range tests {
+		got, err := d.SuggestTitles(test.title, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SuggestTitles(%q) = %q, want %q", test.title, got, test.want)
+		}
+	}
+
+	// suggestions are capped to limit, from the closest
+	d = newTestDB(t, Options{}, "Cook,3.1,6.1", "Cooks,3.2,6.2", "Book,3.3,6.3", "Cookie,3.4,6.4", "Hook,3.5,6.5")
+	got, err := d.SuggestTitles("Cook", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Cook", "Book", "Cooks"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SuggestTitles(%q, 3) = %q, want %q", "Cook", got, want)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"nurse", "", 5},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"café", "cafe", 1},
+	}
+	for _, test := range tests {
+		if got := levenshtein(test.a, test.b); got != test.distance {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, got, test.distance)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ReadTitleSynonyms reads the title synonyms in the csv file on filepath,
+// each line listing titles matching each other, e.g., software engineer,SWE,software developer
+func ReadTitleSynonyms(filepath string) ([][]string, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening title synonyms file on path %s: %v", filepath, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	groups, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading title synonyms file on path %s: %v", filepath, err)
+	}
+	return groups, nil
+}
+
+// indexTitleSynonyms maps the key of each title in groups to the keys of its synonyms
+// under options, i.e., of every title in any of its groups, itself included, in ascending order.
+// Surrounding spaces and empty titles are ignored.
+func indexTitleSynonyms(groups [][]string, options Options) map[string][]string {
+	synonyms := make(map[string]map[string]bool)
+	for _, group := range groups {
+		keys := make([]string, 0, len(group))
+		for _, title := range group {
+			if title = strings.TrimSpace(title); title != "" {
+				keys = append(keys, options.titleKey(title))
+			}
+		}
+		for _, key := range keys {
+			if synonyms[key] == nil {
+				synonyms[key] = make(map[string]bool)
+			}
+			for _, synonym := range keys {
+				synonyms[key][synonym] = true
+			}
+		}
+	}
+
+	index := make(map[string][]string, len(synonyms))
+	for key, set := range synonyms {
+		for synonym := range set {
+			index[key] = append(index[key], synonym)
+		}
+		sort.Strings(index[key])
+	}
+	return index
+}
+
+// titleKeys returns the keys title and its synonyms are indexed with,
+// or just the key of title if it has no synonym
+func (d *DB) titleKeys(title string) []string {
+	key := d.options.titleKey(title)
+	if synonyms, ok := d.titleSynonyms[key]; ok {
+		return synonyms
+	}
+	return []string{key}
+}
+
+// titleKeySet returns the titleKeys of title as a set
+func (d *DB) titleKeySet(title string) map[string]bool {
+	keys := d.titleKeys(title)
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set
+}
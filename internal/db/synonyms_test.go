@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestReadTitleSynonyms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "synonyms.csv")
+	if err := os.WriteFile(path, []byte("Software Engineer,SWE,Software Developer\nNurse,RN\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	groups, err := ReadTitleSynonyms(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"Software Engineer", "SWE", "Software Developer"}, {"Nurse", "RN"}}; !reflect.DeepEqual(groups, want) {
+		t.Errorf("ReadTitleSynonyms() = %v, want %v", groups, want)
+	}
+
+	if _, err := ReadTitleSynonyms(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("read title synonyms of a missing file")
+	}
+}
+
+func TestIndexTitleSynonyms(t *testing.T) {
+	groups := [][]string{{"Software Engineer", " SWE ", ""}, {"SWE", "Developer"}}
+	want := map[string][]string{
+		"software engineer": {"software engineer", "swe"},
+		"swe":               {"developer", "software engineer", "swe"},
+		"developer":         {"developer", "swe"},
+	}
+	if index := indexTitleSynonyms(groups, Options{}); !reflect.DeepEqual(index, want) {
+		t.Errorf("indexTitleSynonyms() = %v, want %v", index, want)
+	}
+}
+
+func TestSearchTitleSynonyms(t *testing.T) {
+	lines := []string{"Software Engineer,3.5,6.5", "Software Engineer,3.51,6.5", "SWE,3.52,6.5", "Nurse,3.5,6.5"}
+	d := newTestDB(t, Options{TitleSynonyms: [][]string{{"Software Engineer", "SWE"}}}, lines...)
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+
+	for _, title := range []string{"swe", "Software Engineer"} {
+		jobs, err := d.JobsWithTitle(title)
+		if err != nil || len(jobs) != 3 {
+			t.Errorf("JobsWithTitle(%q) found %d jobs, want 3, error %v", title, len(jobs), err)
+		}
+		jobs, err = d.SearchJobsByTitleAndLocation(context.Background(), title, "", center)
+		if err != nil || len(jobs) != 3 {
+			t.Errorf("SearchJobsByTitleAndLocation(%q) found %d jobs, want 3, error %v", title, len(jobs), err)
+		}
+	}
+
+	// titles without synonyms match themselves only
+	if jobs, _ := d.JobsWithTitle("nurse"); len(jobs) != 1 {
+		t.Errorf("JobsWithTitle(nurse) found %d jobs, want 1", len(jobs))
+	}
+}
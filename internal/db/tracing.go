@@ -0,0 +1,26 @@
+package db
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans started by DB
+const tracerName = "github.com/ercross/grabjobs/internal/db"
+
+// startSpan starts a span named name as a child of the span in ctx.
+// The span is started with the tracer provider of the span in ctx,
+// hence spans are no-ops unless the caller traces the request.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, name)
+}
+
+// endSpan records err, if any, on span and ends span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	"log"
+	"strings"
+)
+
+// VerifyMode specifies whether the jobs found by searching the index are checked against
+// a scan of every job, e.g., to catch a corrupted index in production
+type VerifyMode string
+
+const (
+	// VerifyOff trusts the index. The zero VerifyMode does not verify searches.
+	VerifyOff VerifyMode = ""
+
+	// VerifyAndLog scans every job after each search and logs any job the search
+	// missed or found wrongly, still returning the jobs found by the search
+	VerifyAndLog VerifyMode = "log"
+
+	// VerifyAndFallback verifies as VerifyAndLog, but returns the jobs found by the scan
+	// if they differ from those found by the search
+	VerifyAndFallback VerifyMode = "fallback"
+)
+
+// ParseVerifyMode parses mode as any of off, log or fallback
+func ParseVerifyMode(mode string) (verifyMode VerifyMode, ok bool) {
+	switch verifyMode = VerifyMode(mode); verifyMode {
+	case "off":
+		return VerifyOff, true
+	case VerifyAndLog, VerifyAndFallback:
+		return verifyMode, true
+	}
+	return "", false
+}
+
+// maxLoggedMismatches is the number of IDs of jobs logged by verifyNearby
+// among those missed or found wrongly by a search
+const maxLoggedMismatches = 5
+
+// verifyNearby checks jobs, found within radius of center under boundary by searching the index of ds,
+// against the jobs of ds found so by a scan, under Options.Verify.
+// Mismatches are logged and counted, and the jobs of the scan are returned in place of jobs
+// under VerifyAndFallback. verifyNearby scans every job, costing as much as a search without index.
+func (d *DB) verifyNearby(ds *dataset, center models.Location, radius float64, boundary rtree.Boundary, jobs []models.Job) []models.Job {
+	if d.options.Verify == VerifyOff {
+		return jobs
+	}
+
+	sphere := d.Sphere()
+	scanned := make([]models.Job, 0, len(jobs))
+	for _, job := range ds.jobs {
+		if boundary.Contains(sphere.Distance(center, job.Location), radius) {
+			scanned = append(scanned, job)
+		}
+	}
+
+	found := make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		found[job.ID] = true
+	}
+	var missed, wrong []string
+	for _, job := range scanned {
+		if !found[job.ID] {
+			missed = append(missed, job.ID)
+		}
+		delete(found, job.ID)
+	}
+	for id := range found {
+		wrong = append(wrong, id)
+	}
+	if len(missed) == 0 && len(wrong) == 0 {
+		return jobs
+	}
+
+	d.verifyMismatches.Add(1)
+	log.Printf("index search within %v km of %v found %d jobs where a scan found %d, missing %d [%s] and wrongly finding %d [%s]",
+		radius, center, len(jobs), len(scanned), len(missed), loggedIDs(missed), len(wrong), loggedIDs(wrong))
+	if d.options.Verify == VerifyAndFallback {
+		return scanned
+	}
+	return jobs
+}
+
+// loggedIDs joins the first maxLoggedMismatches of ids, eliding the others
+func loggedIDs(ids []string) string {
+	if len(ids) > maxLoggedMismatches {
+		return strings.Join(ids[:maxLoggedMismatches], " ") + " ..."
+	}
+	return strings.Join(ids, " ")
+}
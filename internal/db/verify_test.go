@@ -0,0 +1,100 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+)
+
+// captureLog returns the buffer the standard logger writes to for the duration of t
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buffer bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buffer)
+	t.Cleanup(func() { log.SetOutput(previous) })
+	return &buffer
+}
+
+func TestVerifyDetectsCorruptedIndex(t *testing.T) {
+	random := rand.New(rand.NewSource(1453))
+	lines := randomLines(random, 3000, "Nurse", "Driver")
+
+	for _, mode := range []VerifyMode{VerifyOff, VerifyAndLog, VerifyAndFallback} {
+		d := newTestDB(t, Options{Verify: mode}, lines...)
+		ds := d.current.Load()
+		center := ds.jobs[0].Location
+		want := scanNearby(d, center, 5)
+
+		// corrupt the index: a job within radius is dropped from it,
+		// and a job absent from the dataset is indexed within radius
+		missing := want[len(want)-1]
+		if !ds.index.Delete(missing) {
+			t.Fatalf("%q: job %s not deleted from the index", mode, missing)
+		}
+		ghost := models.Job{ID: "ghost", Title: "Nurse", Location: models.Location{Longitude: center.Longitude + 0.001, Latitude: center.Latitude}}
+		ds.index.Insert(*rtree.NewEntry(ghost))
+
+		logged := captureLog(t)
+		jobs, err := d.FindJobsNearby(context.Background(), center, 5, rtree.Inclusive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, _ := d.Stats()
+
+		if mode == VerifyOff {
+			if logged.Len() != 0 || stats.Index.VerifyMismatches != 0 {
+				t.Errorf("%q: logged %q and counted %d mismatches, want neither", mode, logged, stats.Index.VerifyMismatches)
+			}
+		} else {
+			line := logged.String()
+			if stats.Index.VerifyMismatches != 1 || !strings.Contains(line, "missing 1 ["+missing+"]") || !strings.Contains(line, "wrongly finding 1 [ghost]") {
+				t.Errorf("%q: counted %d mismatches and logged %q, want 1 naming %s missed and ghost found", mode, stats.Index.VerifyMismatches, line, missing)
+			}
+		}
+
+		got := idsOf(jobs)
+		if mode == VerifyAndFallback {
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%q: found %d jobs, want the %d scanned", mode, len(got), len(want))
+			}
+		} else if containsID(jobs, missing) || !containsID(jobs, "ghost") {
+			t.Errorf("%q: found the jobs of a scan, want those of the corrupted index", mode)
+		}
+	}
+}
+
+func TestVerifyIntactIndex(t *testing.T) {
+	random := rand.New(rand.NewSource(14530))
+	d := newTestDB(t, Options{Verify: VerifyAndLog}, randomLines(random, 3000, "Nurse", "Driver")...)
+	logged := captureLog(t)
+
+	for i := 0; i < 20; i++ {
+		center := models.Location{Longitude: 3 + random.Float64(), Latitude: 6 + random.Float64()}
+		for _, radius := range []float64{1, 5, 50, 500} {
+			if _, err := d.FindJobsNearby(context.Background(), center, radius, rtree.Inclusive); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if stats, _ := d.Stats(); stats.Index.VerifyMismatches != 0 || logged.Len() != 0 {
+		t.Errorf("verifying an intact index counted %d mismatches and logged %q, want none", stats.Index.VerifyMismatches, logged)
+	}
+}
+
+func TestParseVerifyMode(t *testing.T) {
+	for mode, want := range map[string]VerifyMode{"off": VerifyOff, "log": VerifyAndLog, "fallback": VerifyAndFallback} {
+		if parsed, ok := ParseVerifyMode(mode); !ok || parsed != want {
+			t.Errorf("ParseVerifyMode(%q) = %q, %v, want %q", mode, parsed, ok, want)
+		}
+	}
+	if _, ok := ParseVerifyMode("always"); ok {
+		t.Error("ParseVerifyMode(always) succeeded, want it rejected")
+	}
+}
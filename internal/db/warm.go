@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WarmQuery is a nearby search whose results are computed whenever jobs are indexed,
+// so that searching a popular area is served without searching the index.
+// The results of warm queries are a static precomputed set rather than a cache:
+// they are computed only for the configured warm queries, kept up to date as jobs
+// are added or deleted, and never filled or evicted by the searches they serve
+type WarmQuery struct {
+	Center models.Location
+
+	// RadiusKm is the radius searched in kilometers. Zero is DefaultRadius.
+	// Searches match a WarmQuery only if their radius rounds to the same meter as RadiusKm,
+	// hence RadiusKm should be a radius clients search, e.g., once rounded to a step,
+	// and their center lies in the geohash cell of Center, as with warmKey
+	RadiusKm float64
+}
+
+// ParseWarmQueries parses queries listing warm queries separated by semicolons,
+// each of a coordinate pair in order and optional radius in km, separated by commas,
+// e.g., 1.29,103.85,5;1.35,103.82 with models.LatLng
+func ParseWarmQueries(queries string, order models.CoordinateOrder) ([]WarmQuery, error) {
+	warmQueries := make([]WarmQuery, 0)
+	for _, query := range strings.Split(queries, ";") {
+		if query = strings.TrimSpace(query); query == "" {
+			continue
+		}
+		values := strings.Split(query, ",")
+		if len(values) < 2 || len(values) > 3 {
+			return nil, fmt.Errorf("warm query %q is not a coordinate pair and optional radius", query)
+		}
+		center, err := models.ParseCoordinates(values[0]+","+values[1], order)
+		if err != nil {
+			return nil, fmt.Errorf("invalid warm query %q: %v", query, err)
+		}
+		warmQuery := WarmQuery{Center: center}
+		if len(values) == 3 {
+			if warmQuery.RadiusKm, err = strconv.ParseFloat(strings.TrimSpace(values[2]), 64); err != nil {
+				return nil, fmt.Errorf("warm query %q holds an invalid radius %q", query, values[2])
+			}
+		}
+		if warmQuery.RadiusKm < 0 {
+			return nil, fmt.Errorf("warm query %q has a negative radius", query)
+		}
+		warmQueries = append(warmQueries, warmQuery)
+	}
+	return warmQueries, nil
+}
+
+// warmGeohashPrecision is the length of the geohashes of centers compared to match a search
+// to a warm query, i.e., cells of about 150m by 150m at the equator
+const warmGeohashPrecision = 7
+
+// warmRadiusBucketKm is the width of the buckets of radii compared to match a search
+// to a warm query, i.e., radii are compared to the nearest meter
+const warmRadiusBucketKm = 0.001
+
+// warmKey identifies the results of a warm query, matched by searches whose radius falls
+// in the bucket of its radius and whose center lies in the geohash cell of its center,
+// so that searches from about the same place and distance, however precisely their
+// coordinates and radius are given, are served alike
+type warmKey struct {
+	geohash      string
+	radiusBucket int64
+}
+
+func newWarmKey(center models.Location, radiusKm float64) warmKey {
+	return warmKey{
+		geohash:      center.Geohash(warmGeohashPrecision),
+		radiusBucket: int64(math.Round(radiusKm / warmRadiusBucketKm)),
+	}
+}
+
+// warmReach returns the radius a warm query of radiusKm around center is searched with:
+// radiusKm widened by the diagonal of the geohash cell of center and by half a radius bucket,
+// so that the jobs found hold every job within the radius of any search matching it
+func (d *DB) warmReach(center models.Location, radiusKm float64) float64 {
+	cell := models.GeohashBox(center.Geohash(warmGeohashPrecision))
+	return radiusKm + warmRadiusBucketKm/2 + d.Sphere().Distance(cell.Min, cell.Max)
+}
+
+// warm searches ds for each of Options.WarmQueries, keeping the jobs found in ds.warmed.
+// ds.index must be built
+func (d *DB) warm(ds *dataset) {
+	if len(d.options.WarmQueries) == 0 || ds.index == nil {
+		return
+	}
+	ds.warmed = make(map[warmKey][]models.Job, len(d.options.WarmQueries))
+	for _, query := range d.options.WarmQueries {
+		radius := query.RadiusKm
+		if radius == 0 {
+			radius = d.DefaultRadius().Kilometers()
+		}
+		// queries matched by the same searches are searched once
+		key := newWarmKey(query.Center, radius)
+		if _, ok := ds.warmed[key]; ok {
+			continue
+		}
+		ds.warmed[key] = ds.index.FindJobs(context.Background(), models.Distance{
+			Unit:  models.Kilometer,
+			Value: d.warmReach(query.Center, radius),
+		}, query.Center, ds.titleJobs, rtree.Inclusive)
+	}
+	log.Printf("warmed %d queries", len(ds.warmed))
+}
+
+// warmedJobs returns the jobs within radius of center, at the radius included unless boundary
+// is Exclusive, among those found when ds was warmed, ok being false unless a warm query matches.
+// A new slice is returned, as callers may reorder jobs.
+func (d *DB) warmedJobs(ds *dataset, center models.Location, radius float64, boundary rtree.Boundary) (jobs []models.Job, ok bool) {
+	if ds.warmed == nil {
+		return nil, false
+	}
+	warmed, ok := ds.warmed[newWarmKey(center, radius)]
+	if !ok {
+		return nil, false
+	}
+	sphere := d.Sphere()
+	jobs = make([]models.Job, 0, len(warmed))
+	for _, job := range warmed {
+		if boundary.Contains(sphere.Distance(center, job.Location), radius) {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, true
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+)
+
+func TestWarmedSearchesHonourBoundary(t *testing.T) {
+	lines := []string{"Nurse,3.5,6.5", "Driver,3.6,6.5"}
+	loaded := newTestDB(t, Options{}, lines...)
+	center, at := loaded.current.Load().jobs[0].Location, loaded.current.Load().jobs[1].Location
+
+	// the radius is exactly the distance to the Driver job, as parsed from the data file
+	radius := loaded.Sphere().Distance(center, at)
+	d := newTestDB(t, Options{
+		WarmQueries: []WarmQuery{{Center: center, RadiusKm: radius}},
+		Verify:      VerifyAndLog,
+	}, lines...)
+
+	for _, test := range []struct {
+		boundary rtree.Boundary
+		jobs     int
+	}{
+		{boundary: rtree.Inclusive, jobs: 2},
+		{boundary: rtree.Exclusive, jobs: 1},
+	} {
+		jobs, err := d.FindJobsNearby(context.Background(), center, radius, test.boundary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) != test.jobs {
+			t.Errorf("boundary %d: found %d jobs, want %d", test.boundary, len(jobs), test.jobs)
+		}
+		count, _ := d.CountJobsNearbyApprox(context.Background(), center, radius, test.boundary)
+		if count != test.jobs {
+			t.Errorf("boundary %d: counted %d jobs, want %d", test.boundary, count, test.jobs)
+		}
+		near, _ := d.FindJobsNearAny(context.Background(), []models.Location{center}, radius, test.boundary)
+		if len(near) != test.jobs {
+			t.Errorf("boundary %d: found %d jobs near any, want %d", test.boundary, len(near), test.jobs)
+		}
+	}
+	if stats, _ := d.Stats(); stats.Index.VerifyMismatches != 0 {
+		t.Errorf("verifying searches found %d mismatches", stats.Index.VerifyMismatches)
+	}
+}
+
+func TestWarmQueriesServedWithoutTraversal(t *testing.T) {
+	random := rand.New(rand.NewSource(1448))
+	lines := randomLines(random, 3000, "Nurse", "Driver")
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	d := newTestDB(t, Options{WarmQueries: []WarmQuery{{Center: center, RadiusKm: 8}, {Center: center}}}, lines...)
+
+	search := func(center models.Location, radius float64) ([]models.Job, rtree.SearchStats) {
+		t.Helper()
+		var stats rtree.SearchStats
+		jobs, err := d.FindJobsNearby(rtree.WithSearchStats(context.Background(), &stats), center, radius, rtree.Inclusive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return jobs, stats
+	}
+	served := func(stats rtree.SearchStats) bool {
+		return stats.NodesVisited == 0 && stats.EntriesVisited == 0 && !stats.FullScan
+	}
+
+	// the first search of a warm query, or of the default radius of one without, traverses nothing
+	for _, radius := range []float64{8, 0, d.DefaultRadius().Kilometers()} {
+		jobs, stats := search(center, radius)
+		if !served(stats) {
+			t.Errorf("radius %vkm: warmed search visited %+v, want nothing", radius, stats)
+		}
+		if want := scanNearby(d, center, d.DefaultRadius().Kilometers()); radius == 8 {
+			want = scanNearby(d, center, 8)
+			if got := idsOf(jobs); len(got) == 0 || !reflect.DeepEqual(got, want) {
+				t.Errorf("radius 8km: warmed search found %d jobs, want %d", len(got), len(want))
+			}
+		} else if got := idsOf(jobs); len(got) == 0 || !reflect.DeepEqual(got, want) {
+			t.Errorf("radius %vkm: warmed search found %d jobs, want %d", radius, len(got), len(want))
+		}
+	}
+
+	// a search centered elsewhere in the geohash cell of a warm query is served, and still exact
+	cell := models.GeohashBox(center.Geohash(warmGeohashPrecision))
+	nearby := models.Location{Longitude: cell.Min.Longitude + 0.0001, Latitude: cell.Max.Latitude - 0.0001}
+	if nearby == center || nearby.Geohash(warmGeohashPrecision) != center.Geohash(warmGeohashPrecision) {
+		t.Fatalf("%v is not elsewhere in the cell of %v", nearby, center)
+	}
+	for _, radius := range []float64{8, d.DefaultRadius().Kilometers()} {
+		jobs, stats := search(nearby, radius)
+		if !served(stats) {
+			t.Errorf("radius %vkm: search in the cell of a warm query visited %+v, want nothing", radius, stats)
+		}
+		if got, want := idsOf(jobs), scanNearby(d, nearby, radius); !reflect.DeepEqual(got, want) {
+			t.Errorf("radius %vkm: search in the cell of a warm query found %d jobs, want %d", radius, len(got), len(want))
+		}
+	}
+
+	// a search of a radius rounding to the same meter is served, and still exact
+	for _, bucketed := range []struct {
+		center models.Location
+		radius float64
+	}{{center, 8.0004}, {center, 7.9996}, {nearby, 8.0004}} {
+		jobs, stats := search(bucketed.center, bucketed.radius)
+		if !served(stats) {
+			t.Errorf("%v radius %vkm: search in the radius bucket of a warm query visited %+v, want nothing", bucketed.center, bucketed.radius, stats)
+		}
+		if got, want := idsOf(jobs), scanNearby(d, bucketed.center, bucketed.radius); !reflect.DeepEqual(got, want) {
+			t.Errorf("%v radius %vkm: search in the radius bucket of a warm query found %d jobs, want %d", bucketed.center, bucketed.radius, len(got), len(want))
+		}
+	}
+
+	// any other search traverses the index
+	if _, stats := search(center, 8.5); served(stats) {
+		t.Errorf("search of a radius not warmed visited nothing")
+	}
+	if _, stats := search(models.Location{Longitude: 3.6, Latitude: 6.5}, 8); served(stats) {
+		t.Errorf("search of a center not warmed visited nothing")
+	}
+
+	// warmed results follow jobs added and deleted, still without traversal
+	added := models.Job{Title: "Welder", Location: models.Location{Longitude: 3.5001, Latitude: 6.5}}
+	if err := d.AddJob(&added); err != nil {
+		t.Fatal(err)
+	}
+	jobs, stats := search(center, 8)
+	if !served(stats) || !containsID(jobs, added.ID) {
+		t.Errorf("once added, warmed search visited %+v and found the job added: %v", stats, containsID(jobs, added.ID))
+	}
+	if _, err := d.DeleteJobs("Welder", added.Location, 0.01); err != nil {
+		t.Fatal(err)
+	}
+	jobs, stats = search(center, 8)
+	if !served(stats) || containsID(jobs, added.ID) || !reflect.DeepEqual(idsOf(jobs), scanNearby(d, center, 8)) {
+		t.Errorf("once deleted, warmed search visited %+v and found the job deleted: %v", stats, containsID(jobs, added.ID))
+	}
+}
+
+// containsID checks that any of jobs has id
+func containsID(jobs []models.Job, id string) bool {
+	for _, job := range jobs {
+		if job.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestParseWarmQueries(t *testing.T) {
+	queries, err := ParseWarmQueries(" 1.29,103.85,5 ; 1.35,103.82;", models.LatLng)
+	want := []WarmQuery{
+		{Center: models.Location{Latitude: 1.29, Longitude: 103.85}, RadiusKm: 5},
+		{Center: models.Location{Latitude: 1.35, Longitude: 103.82}},
+	}
+	if err != nil || !reflect.DeepEqual(queries, want) {
+		t.Errorf("parsed %+v, %v, want %+v", queries, err, want)
+	}
+	if queries, err := ParseWarmQueries("103.85,1.29,5", models.LngLat); err != nil || !reflect.DeepEqual(queries, want[:1]) {
+		t.Errorf("parsed %+v, %v longitude first, want %+v", queries, err, want[:1])
+	}
+	for _, invalid := range []string{"1.29", "1.29,103.85,5,6", "a,103.85", "1.29,103.85,far", "1.29,103.85,-1"} {
+		if _, err := ParseWarmQueries(invalid, models.LatLng); err == nil {
+			t.Errorf("ParseWarmQueries(%q) succeeded, want an error", invalid)
+		}
+	}
+}
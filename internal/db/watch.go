@@ -0,0 +1,74 @@
+package db
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// reloadDebounce is how long the watched file must go unchanged before it is reloaded,
+// so that a burst of writes, e.g., while a large file is copied, triggers a single reload
+const reloadDebounce = 500 * time.Millisecond
+
+// watch reloads the DB from the file on path whenever it changes on disk.
+// The directory of path is watched rather than the file itself,
+// so that the file is still watched after being replaced, e.g., by an editor or mv.
+func (d *DB) watch(path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating file watcher: %v", err)
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("error watching %s: %v", path, err)
+	}
+	d.watcher = watcher
+
+	go func() {
+		var debounce *time.Timer
+		reload := func() {
+			if err := d.Reload(path); err != nil {
+				log.Printf("error reloading %s: %v", path, err)
+				return
+			}
+			log.Printf("reloaded %s, %d jobs available", path, len(d.current.Load().jobs))
+		}
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(path) || !event.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+				if debounce == nil {
+					debounce = time.AfterFunc(reloadDebounce, reload)
+				} else {
+					debounce.Reset(reloadDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("error watching %s: %v", path, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops watching and refreshing the file the DB was initialized from, if any.
+func (d *DB) Close() error {
+	if d.stopRefresh != nil {
+		close(d.stopRefresh)
+		d.stopRefresh = nil
+	}
+	if d.watcher == nil {
+		return nil
+	}
+	return d.watcher.Close()
+}
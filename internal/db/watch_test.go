@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ercross/grabjobs/internal/models"
+	"github.com/ercross/grabjobs/internal/models/rtree"
+)
+
+func TestWatchFile(t *testing.T) {
+	path := writeCSV(t, "Nurse,3.5,6.5")
+	d, err := Initialize(path, Options{WatchFile: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// a burst of writes is reloaded once, from the last version written
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	versions := []string{"Driver,3.5,6.5\n", "Driver,3.5,6.5\nCook,3.5,6.5\n", "Driver,3.5,6.5\nCook,3.5,6.5\nWelder,3.5,6.5\n"}
+	for _, version := range versions {
+		if err := os.WriteFile(path, []byte("title,longitude,latitude\n"+version), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var found []models.Job
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		found, _ = d.FindJobsNearby(context.Background(), center, 1, rtree.Inclusive)
+		if len(found) == 3 {
+			break
+		}
+	}
+	titles := make(map[string]bool, len(found))
+	for _, job := range found {
+		titles[job.Title] = true
+	}
+	if len(found) != 3 || !titles["Driver"] || !titles["Cook"] || !titles["Welder"] || titles["Nurse"] {
+		t.Fatalf("found %v once the file changed, want the Driver, Cook and Welder written last", found)
+	}
+	if stats, _ := d.Stats(); stats.Index.Rebuilds != 2 {
+		t.Errorf("Stats() reports %d rebuilds, want 2 as the burst of writes is debounced", stats.Index.Rebuilds)
+	}
+
+	// the watcher stops once closed
+	d.Close()
+	if err := os.WriteFile(path, []byte("title,longitude,latitude\nTailor,3.5,6.5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * reloadDebounce)
+	if found, _ := d.FindJobsNearby(context.Background(), center, 1, rtree.Inclusive); len(found) != 3 {
+		t.Errorf("found %d jobs once closed and the file changed, want the 3 jobs before", len(found))
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import "math"
+
+// Centroid is the mean location of the jobs having a title
+type Centroid struct {
+	Title    string   `json:"title"`
+	Count    int      `json:"count"`
+	Location Location `json:"location"`
+}
+
+// SphericalCentroid computes the mean of locations on the sphere, i.e., the location under
+// the mean of their unit vectors from the center of the sphere, unlike the mean of their
+// latitudes and longitudes which is off for locations far apart or across the antimeridian.
+// SphericalCentroid returns the zero Location if locations is empty or spread so evenly
+// around the sphere that their mean is its center
+func SphericalCentroid(locations []Location) Location {
+	var x, y, z float64
+	for _, location := range locations {
+		lat, lon := toRadians(location.Latitude), toRadians(location.Longitude)
+		x += math.Cos(lat) * math.Cos(lon)
+		y += math.Cos(lat) * math.Sin(lon)
+		z += math.Sin(lat)
+	}
+	if math.Abs(x)+math.Abs(y)+math.Abs(z) < 1e-9*float64(len(locations)) {
+		return Location{}
+	}
+
+	return Location{
+		Longitude: math.Atan2(y, x) * 180 / math.Pi,
+		Latitude:  math.Atan2(z, math.Hypot(x, y)) * 180 / math.Pi,
+	}
+}
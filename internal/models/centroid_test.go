@@ -0,0 +1,39 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSphericalCentroid(t *testing.T) {
+	tests := []struct {
+		name      string
+		locations []Location
+		centroid  Location
+	}{
+		{name: "single location", locations: []Location{{Longitude: 103.85, Latitude: 1.29}},
+			centroid: Location{Longitude: 103.85, Latitude: 1.29}},
+		{name: "square around the equator", locations: []Location{
+			{Longitude: 9, Latitude: -1}, {Longitude: 11, Latitude: -1}, {Longitude: 9, Latitude: 1}, {Longitude: 11, Latitude: 1},
+		}, centroid: Location{Longitude: 10, Latitude: 0}},
+		{name: "across the antimeridian", locations: []Location{{Longitude: 179, Latitude: 0}, {Longitude: -179, Latitude: 0}},
+			centroid: Location{Longitude: 180, Latitude: 0}},
+		{name: "pair on a meridian", locations: []Location{{Longitude: 30, Latitude: 10}, {Longitude: 30, Latitude: 50}},
+			centroid: Location{Longitude: 30, Latitude: 30}},
+		{name: "antipodes", locations: []Location{{Longitude: 0, Latitude: 0}, {Longitude: 180, Latitude: 0}}},
+		{name: "none"},
+	}
+	for _, test := range tests {
+		centroid := SphericalCentroid(test.locations)
+		if distance := Earth.Distance(centroid, test.centroid); distance > 1e-6 {
+			t.Errorf("%s: centroid %v, want %v", test.name, centroid, test.centroid)
+		}
+	}
+
+	// the great circle joining locations on a northern parallel runs north of it,
+	// hence so does their centroid, unlike the mean of their latitudes
+	northern := []Location{{Longitude: 0, Latitude: 60}, {Longitude: 90, Latitude: 60}}
+	if centroid := SphericalCentroid(northern); math.Abs(centroid.Longitude-45) > 1e-9 || centroid.Latitude <= 60 {
+		t.Errorf("centroid of %v is %v, want on the meridian 45 north of 60", northern, centroid)
+	}
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CoordinateOrder is the order of latitude and longitude in a coordinate pair,
+// i.e., in pairs parsed with ParseCoordinates and formatted with FormatCoordinates.
+// Fields named latitude and longitude, e.g., of JSON or query parameters, are not affected,
+// nor is Location.String, which is always Longitude,Latitude as in the data file.
+type CoordinateOrder string
+
+const (
+	// LatLng orders latitude first, e.g., 1.29,103.85
+	LatLng CoordinateOrder = ""
+
+	// LngLat orders longitude first, e.g., 103.85,1.29, as in GeoJSON and the data file
+	LngLat CoordinateOrder = "lnglat"
+)
+
+// ParseCoordinateOrder parses order, latlng or lnglat.
+// ok is false if order is neither, and the order returned LatLng.
+func ParseCoordinateOrder(order string) (parsed CoordinateOrder, ok bool) {
+	switch strings.ToLower(order) {
+	case "latlng":
+		return LatLng, true
+	case string(LngLat):
+		return LngLat, true
+	}
+	return LatLng, false
+}
+
+// ParseCoordinates parses pair, a latitude and a longitude separated by a comma,
+// in order, e.g., 1.29,103.85 with LatLng
+func ParseCoordinates(pair string, order CoordinateOrder) (Location, error) {
+	values := strings.Split(pair, ",")
+	if len(values) != 2 {
+		return Location{}, fmt.Errorf("coordinates %q are not a %s pair", pair, order.pattern())
+	}
+	numbers := make([]float64, len(values))
+	for i, value := range values {
+		var err error
+		if numbers[i], err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+			return Location{}, fmt.Errorf("coordinates %q hold an invalid number %q", pair, value)
+		}
+	}
+	if order == LngLat {
+		return Location{Longitude: numbers[0], Latitude: numbers[1]}, nil
+	}
+	return Location{Latitude: numbers[0], Longitude: numbers[1]}, nil
+}
+
+// FormatCoordinates formats l as a coordinate pair in order,
+// so that it parses back with ParseCoordinates, e.g., 1.290000,103.850000 with LatLng
+func FormatCoordinates(l Location, order CoordinateOrder) string {
+	if order == LngLat {
+		return fmt.Sprintf("%f,%f", l.Longitude, l.Latitude)
+	}
+	return fmt.Sprintf("%f,%f", l.Latitude, l.Longitude)
+}
+
+// pattern describes pairs in order o, e.g., latitude,longitude
+func (o CoordinateOrder) pattern() string {
+	if o == LngLat {
+		return "longitude,latitude"
+	}
+	return "latitude,longitude"
+}
@@ -0,0 +1,47 @@
+package models
+
+import "testing"
+
+func TestCoordinateOrder(t *testing.T) {
+	location := Location{Longitude: 103.85, Latitude: 1.29}
+	tests := []struct {
+		order  CoordinateOrder
+		pair   string
+		format string
+	}{
+		{order: LatLng, pair: "1.29, 103.85", format: "1.290000,103.850000"},
+		{order: LngLat, pair: "103.85,1.29", format: "103.850000,1.290000"},
+	}
+	for _, test := range tests {
+		if got := FormatCoordinates(location, test.order); got != test.format {
+			t.Errorf("%q: formatted %q, want %q", test.order, got, test.format)
+		}
+		if parsed, err := ParseCoordinates(test.pair, test.order); err != nil || parsed != location {
+			t.Errorf("%q: parsed %q as %v, %v, want %v", test.order, test.pair, parsed, err, location)
+		}
+		if parsed, err := ParseCoordinates(FormatCoordinates(location, test.order), test.order); err != nil || parsed != location {
+			t.Errorf("%q: formatted location parsed back as %v, %v, want %v", test.order, parsed, err, location)
+		}
+		for _, invalid := range []string{"1.29", "1.29,103.85,0", "north,103.85", ""} {
+			if _, err := ParseCoordinates(invalid, test.order); err == nil {
+				t.Errorf("%q: parsed %q, want an error", test.order, invalid)
+			}
+		}
+	}
+
+	// String is Longitude,Latitude whatever the order pairs are parsed in
+	if got, want := location.String(), "103.850000,1.290000"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	for order, want := range map[string]CoordinateOrder{"latlng": LatLng, "LngLat": LngLat} {
+		if parsed, ok := ParseCoordinateOrder(order); !ok || parsed != want {
+			t.Errorf("ParseCoordinateOrder(%q) = %q, %v, want %q", order, parsed, ok, want)
+		}
+	}
+	for _, order := range []string{"xy", "", "lnglat "} {
+		if parsed, ok := ParseCoordinateOrder(order); ok || parsed != LatLng {
+			t.Errorf("ParseCoordinateOrder(%q) = %q, %v, want it rejected as the zero order", order, parsed, ok)
+		}
+	}
+}
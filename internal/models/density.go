@@ -0,0 +1,19 @@
+package models
+
+// Density is the number of jobs per square kilometer within a box
+type Density struct {
+	Box Box `json:"box"`
+
+	// Count is the number of jobs within Box, covering AreaKm2 square kilometers
+	Count   int     `json:"count"`
+	AreaKm2 float64 `json:"areaKm2"`
+
+	JobsPerKm2 float64 `json:"jobsPerKm2"`
+}
+
+// NewDensity computes the density of count jobs within box on sphere.
+// box must cover a positive area.
+func NewDensity(sphere Sphere, box Box, count int) Density {
+	area := sphere.Area(box)
+	return Density{Box: box, Count: count, AreaKm2: area, JobsPerKm2: float64(count) / area}
+}
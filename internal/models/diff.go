@@ -0,0 +1,20 @@
+package models
+
+// DatasetDiff lists the jobs changed from one dataset to another
+type DatasetDiff struct {
+
+	// Added and Removed are the jobs only in the second and first dataset respectively
+	Added   []Job `json:"added"`
+	Removed []Job `json:"removed"`
+
+	// Moved are the jobs found at another location in the second dataset
+	Moved []JobMove `json:"moved"`
+}
+
+// JobMove is a job moved from one location to another,
+// along with the distance in kilometers between both
+type JobMove struct {
+	From     Job     `json:"from"`
+	To       Job     `json:"to"`
+	Distance float64 `json:"distance"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"math"
+	"strings"
+)
+
+// compassBearings maps compass directions to their bearing
+// in degrees clockwise from the north
+var compassBearings = map[string]float64{
+	"N":  0,
+	"NE": 45,
+	"E":  90,
+	"SE": 135,
+	"S":  180,
+	"SW": 225,
+	"W":  270,
+	"NW": 315,
+}
+
+// CompassBearing returns the bearing in degrees of a compass direction
+// such as N, SE or w. ok is false if direction is not a known compass direction.
+func CompassBearing(direction string) (bearing float64, ok bool) {
+	bearing, ok = compassBearings[strings.ToUpper(direction)]
+	return bearing, ok
+}
+
+// BearingTo computes the initial great-circle bearing from l to other,
+// in degrees clockwise from the north within [0, 360).
+// ref: https://www.movable-type.co.uk/scripts/latlong.html
+func (l Location) BearingTo(other Location) float64 {
+	lat1, lat2 := toRadians(l.Latitude), toRadians(other.Latitude)
+	deltaLon := toRadians(other.Longitude - l.Longitude)
+
+	y := math.Sin(deltaLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(deltaLon)
+	bearing := math.Atan2(y, x) * 180 / math.Pi
+	return math.Mod(bearing+360, 360)
+}
+
+// BearingDifference returns the smallest angle in degrees between bearings a and b
+func BearingDifference(a, b float64) float64 {
+	difference := math.Mod(math.Abs(a-b), 360)
+	if difference > 180 {
+		difference = 360 - difference
+	}
+	return difference
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
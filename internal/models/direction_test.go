@@ -0,0 +1,48 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBearingTo(t *testing.T) {
+	center := Location{Longitude: 103.85, Latitude: 1.29}
+	tests := []struct {
+		direction string
+		other     Location
+	}{
+		{direction: "N", other: Location{Longitude: 103.85, Latitude: 1.39}},
+		{direction: "E", other: Location{Longitude: 103.95, Latitude: 1.29}},
+		{direction: "S", other: Location{Longitude: 103.85, Latitude: 1.19}},
+		{direction: "W", other: Location{Longitude: 103.75, Latitude: 1.29}},
+		{direction: "ne", other: Location{Longitude: 103.95, Latitude: 1.39}},
+		{direction: "SW", other: Location{Longitude: 103.75, Latitude: 1.19}},
+	}
+	for _, test := range tests {
+		want, ok := CompassBearing(test.direction)
+		if !ok {
+			t.Fatalf("CompassBearing(%q) not ok", test.direction)
+		}
+		if got := center.BearingTo(test.other); BearingDifference(got, want) > 0.1 {
+			t.Errorf("bearing to %v = %v, want %v (%s)", test.other, got, want, test.direction)
+		}
+	}
+	if _, ok := CompassBearing("up"); ok {
+		t.Error("CompassBearing(\"up\") ok, want not")
+	}
+}
+
+func TestBearingDifference(t *testing.T) {
+	tests := []struct{ a, b, difference float64 }{
+		{0, 0, 0},
+		{10, 350, 20},
+		{350, 10, 20},
+		{90, 270, 180},
+		{45, 90, 45},
+	}
+	for _, test := range tests {
+		if got := BearingDifference(test.a, test.b); math.Abs(got-test.difference) > 1e-9 {
+			t.Errorf("BearingDifference(%v, %v) = %v, want %v", test.a, test.b, got, test.difference)
+		}
+	}
+}
@@ -6,9 +6,34 @@ const (
 	UnknownUnit DistanceUnit = iota
 
 	Kilometer
+	Mile
 )
 
+// ParseDistanceUnit parses the abbreviation of a unit, i.e., km or mi.
+// ok is false if unit is not a known abbreviation
+func ParseDistanceUnit(unit string) (u DistanceUnit, ok bool) {
+	switch unit {
+	case "km":
+		return Kilometer, true
+	case "mi":
+		return Mile, true
+	}
+	return UnknownUnit, false
+}
+
+// kmPerMile is the number of kilometers in a mile
+const kmPerMile = 1.609344
+
 type Distance struct {
 	Unit  DistanceUnit
 	Value float64
 }
+
+// Kilometers converts d to kilometers, the unit distances are compared in.
+// A Distance of UnknownUnit is taken to be in kilometers.
+func (d Distance) Kilometers() float64 {
+	if d.Unit == Mile {
+		return d.Value * kmPerMile
+	}
+	return d.Value
+}
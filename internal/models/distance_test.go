@@ -0,0 +1,19 @@
+package models
+
+import "testing"
+
+func TestKilometers(t *testing.T) {
+	tests := []struct {
+		distance Distance
+		km       float64
+	}{
+		{distance: Distance{Unit: Kilometer, Value: 5}, km: 5},
+		{distance: Distance{Unit: Mile, Value: 5}, km: 8.04672},
+		{distance: Distance{Unit: Mile, Value: 0}, km: 0},
+	}
+	for _, test := range tests {
+		if got := test.distance.Kilometers(); got != test.km {
+			t.Errorf("%+v.Kilometers() = %v, want %v", test.distance, got, test.km)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+// CountBucket counts the titles having between Min and Max jobs, both included
+type CountBucket struct {
+	Min int `json:"min"`
+
+	// Max is nil on the last bucket, which has no upper bound
+	Max *int `json:"max,omitempty"`
+
+	// Titles is the number of titles in the bucket, and Jobs their total number of jobs
+	Titles int `json:"titles"`
+	Jobs   int `json:"jobs"`
+}
+
+// Distribution buckets titles by their number of jobs, counts listing the number of jobs of each
+// title. bounds are the upper bounds of the buckets, in ascending order, e.g., 1, 5 buckets titles
+// having 1 job, 2 to 5 jobs, and more than 5 jobs. Every bucket is listed, even if empty
+func Distribution(counts []int, bounds []int) []CountBucket {
+	buckets := make([]CountBucket, len(bounds)+1)
+	min := 1
+	for i := range bounds {
+		buckets[i] = CountBucket{Min: min, Max: &bounds[i]}
+		min = bounds[i] + 1
+	}
+	buckets[len(bounds)] = CountBucket{Min: min}
+
+	for _, count := range counts {
+		i := 0
+		for i < len(bounds) && count > bounds[i] {
+			i++
+		}
+		buckets[i].Titles++
+		buckets[i].Jobs += count
+	}
+	return buckets
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDistribution(t *testing.T) {
+	bound := func(max int) *int { return &max }
+	counts := []int{1, 1, 2, 5, 6, 12, 100}
+
+	tests := []struct {
+		bounds  []int
+		buckets []CountBucket
+	}{
+		{bounds: nil, buckets: []CountBucket{{Min: 1, Titles: 7, Jobs: 127}}},
+		{bounds: []int{1, 5, 10}, buckets: []CountBucket{
+			{Min: 1, Max: bound(1), Titles: 2, Jobs: 2},
+			{Min: 2, Max: bound(5), Titles: 2, Jobs: 7},
+			{Min: 6, Max: bound(10), Titles: 1, Jobs: 6},
+			{Min: 11, Titles: 2, Jobs: 112},
+		}},
+		{bounds: []int{2, 3, 4, 200}, buckets: []CountBucket{
+			{Min: 1, Max: bound(2), Titles: 3, Jobs: 4},
+			{Min: 3, Max: bound(3), Titles: 0, Jobs: 0},
+			{Min: 4, Max: bound(4), Titles: 0, Jobs: 0},
+			{Min: 5, Max: bound(200), Titles: 4, Jobs: 123},
+			{Min: 201, Titles: 0, Jobs: 0},
+		}},
+	}
+	for _, test := range tests {
+		if buckets := Distribution(counts, test.bounds); !reflect.DeepEqual(buckets, test.buckets) {
+			t.Errorf("bounds %v: buckets %+v, want %+v", test.bounds, buckets, test.buckets)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package models
+
+import "sort"
+
+// GridCell is a cell of a Grid, counting the jobs located within Box
+type GridCell struct {
+
+	// Row and Col locate the cell on the grid, from the south-west corner
+	Row int `json:"row"`
+	Col int `json:"col"`
+
+	Box   Box `json:"box"`
+	Count int `json:"count"`
+}
+
+// Grid divides a box into cells of equal span in degrees, counting the jobs within each,
+// e.g., to find the areas of the box having the fewest jobs.
+// If the box crosses the antimeridian, its columns run eastwards across it.
+type Grid struct {
+	box        Box
+	rows, cols int
+	counts     []int
+}
+
+// NewGrid returns a grid of rows by cols cells over box, counting no job yet.
+// rows and cols must be positive.
+func NewGrid(box Box, rows, cols int) *Grid {
+	return &Grid{box: box, rows: rows, cols: cols, counts: make([]int, rows*cols)}
+}
+
+// Box returns the box g divides
+func (g *Grid) Box() Box {
+	return g.box
+}
+
+// Count counts a job located at location within the cell holding it.
+// Locations outside the box of g are ignored.
+func (g *Grid) Count(location Location) {
+	if !g.box.Contains(location) {
+		return
+	}
+	// longitudes west of box.Min lie east of the antimeridian in a box crossing it
+	longitude := location.Longitude
+	if longitude < g.box.Min.Longitude {
+		longitude += 360
+	}
+	cellWidth, cellHeight := g.cellSpans()
+	row := gridCell(location.Latitude, g.box.Min.Latitude, cellHeight, g.rows)
+	col := gridCell(longitude, g.box.Min.Longitude, cellWidth, g.cols)
+	g.counts[row*g.cols+col]++
+}
+
+// Sparsest returns the n cells of g counting the fewest jobs, from the fewest.
+// Cells counting as many jobs are ordered row by row from the south-west corner.
+func (g *Grid) Sparsest(n int) []GridCell {
+	cells := g.Cells()
+	sort.SliceStable(cells, func(i, j int) bool {
+		return cells[i].Count < cells[j].Count
+	})
+	if n < len(cells) {
+		cells = cells[:n]
+	}
+	return cells
+}
+
+// Empty counts the cells of g holding no job
+func (g *Grid) Empty() int {
+	empty := 0
+	for _, count := range g.counts {
+		if count == 0 {
+			empty++
+		}
+	}
+	return empty
+}
+
+// Cells returns every cell of g, row by row from the south-west corner
+func (g *Grid) Cells() []GridCell {
+	cellWidth, cellHeight := g.cellSpans()
+	cells := make([]GridCell, 0, len(g.counts))
+	for row := 0; row < g.rows; row++ {
+		for col := 0; col < g.cols; col++ {
+			minLongitude := g.box.Min.Longitude + float64(col)*cellWidth
+			minLatitude := g.box.Min.Latitude + float64(row)*cellHeight
+			cells = append(cells, GridCell{
+				Row: row,
+				Col: col,
+				Box: Box{
+					Min: Location{Longitude: wrapLongitude(minLongitude), Latitude: minLatitude},
+					Max: Location{Longitude: wrapLongitude(minLongitude + cellWidth), Latitude: minLatitude + cellHeight},
+				},
+				Count: g.counts[row*g.cols+col],
+			})
+		}
+	}
+	return cells
+}
+
+// cellSpans returns the degrees of longitude and latitude each cell of g spans
+func (g *Grid) cellSpans() (width, height float64) {
+	return g.box.lonSpan() / float64(g.cols), (g.box.Max.Latitude - g.box.Min.Latitude) / float64(g.rows)
+}
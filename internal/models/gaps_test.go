@@ -0,0 +1,77 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+// cellsAt returns the row and column of each of cells, in order
+func cellsAt(cells []GridCell) [][2]int {
+	at := make([][2]int, len(cells))
+	for i, cell := range cells {
+		at[i] = [2]int{cell.Row, cell.Col}
+	}
+	return at
+}
+
+func TestGridSparsest(t *testing.T) {
+	box := Box{Min: Location{Longitude: 0, Latitude: 0}, Max: Location{Longitude: 10, Latitude: 10}}
+	grid := NewGrid(box, 10, 10)
+
+	// every cell holds two jobs but a 2x2 hole in rows 4 and 5, columns 6 and 7,
+	// and the cell of row 0, column 9 holding one
+	for row := 0; row < 10; row++ {
+		for col := 0; col < 10; col++ {
+			if row >= 4 && row <= 5 && col >= 6 && col <= 7 {
+				continue
+			}
+			location := Location{Longitude: float64(col) + 0.5, Latitude: float64(row) + 0.5}
+			grid.Count(location)
+			if row != 0 || col != 9 {
+				grid.Count(location)
+			}
+		}
+	}
+	// locations outside the box are ignored, those on its north-east edge counted in its last cells
+	grid.Count(Location{Longitude: 6.5, Latitude: 10.5})
+	grid.Count(Location{Longitude: 10, Latitude: 10})
+
+	if empty := grid.Empty(); empty != 4 {
+		t.Errorf("%d empty cells, want the 4 of the hole", empty)
+	}
+	want := [][2]int{{4, 6}, {4, 7}, {5, 6}, {5, 7}, {0, 9}}
+	sparsest := grid.Sparsest(5)
+	if got := cellsAt(sparsest); !reflect.DeepEqual(got, want) {
+		t.Errorf("sparsest cells %v, want the hole, then the cell of one job %v", got, want)
+	}
+	if hole := (Box{Min: Location{Longitude: 6, Latitude: 4}, Max: Location{Longitude: 7, Latitude: 5}}); sparsest[0].Box != hole || sparsest[0].Count != 0 {
+		t.Errorf("sparsest cell %+v, want %+v holding no job", sparsest[0], hole)
+	}
+	if cells := grid.Cells(); cells[99].Count != 3 {
+		t.Errorf("north-east cell counts %d jobs, want 3 with the job on the edge of the box", cells[99].Count)
+	}
+	if all := grid.Sparsest(1000); len(all) != 100 {
+		t.Errorf("%d sparsest cells of 1000 asked for, want all 100", len(all))
+	}
+}
+
+func TestGridAcrossAntimeridian(t *testing.T) {
+	box := Box{Min: Location{Longitude: 178, Latitude: -1}, Max: Location{Longitude: -178, Latitude: 1}}
+	grid := NewGrid(box, 1, 4)
+	for _, longitude := range []float64{178.5, 178.6, 179.5, -179.5, -179.4, -179.3} {
+		grid.Count(Location{Longitude: longitude, Latitude: 0})
+	}
+	grid.Count(Location{Longitude: 0, Latitude: 0})
+
+	counts := make([]int, 0, 4)
+	for _, cell := range grid.Cells() {
+		counts = append(counts, cell.Count)
+	}
+	if want := []int{2, 1, 3, 0}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("cells eastward across the antimeridian count %v, want %v", counts, want)
+	}
+	want := Box{Min: Location{Longitude: -179, Latitude: -1}, Max: Location{Longitude: -178, Latitude: 1}}
+	if sparsest := grid.Sparsest(1)[0]; sparsest.Box != want {
+		t.Errorf("sparsest cell %+v, want the empty cell %+v west of the antimeridian", sparsest.Box, want)
+	}
+}
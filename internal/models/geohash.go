@@ -0,0 +1,107 @@
+package models
+
+import "strings"
+
+// geohashBase32 is the alphabet of the geohash base32 encoding.
+// It excludes the letters a, i, l and o.
+const geohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Geohash encodes l into a geohash of precision characters.
+// ref: https://en.wikipedia.org/wiki/Geohash
+// Precision is limited to between 1 and 12 characters inclusive.
+func (l Location) Geohash(precision int) string {
+	if precision < 1 {
+		precision = 1
+	}
+	if precision > 12 {
+		precision = 12
+	}
+
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+	var hash strings.Builder
+	hash.Grow(precision)
+
+	// bits are interleaved starting with longitude, 5 bits per character
+	evenBit := true
+	bit, char := 0, 0
+	for hash.Len() < precision {
+		if evenBit {
+			mid := (minLon + maxLon) / 2
+			if l.Longitude >= mid {
+				char = char<<1 | 1
+				minLon = mid
+			} else {
+				char = char << 1
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if l.Latitude >= mid {
+				char = char<<1 | 1
+				minLat = mid
+			} else {
+				char = char << 1
+				maxLat = mid
+			}
+		}
+		evenBit = !evenBit
+
+		bit++
+		if bit == 5 {
+			hash.WriteByte(geohashBase32[char])
+			bit, char = 0, 0
+		}
+	}
+	return hash.String()
+}
+
+// FromGeohash decodes hash into the Location at the center of the cell it represents.
+// Characters not in the geohash alphabet are ignored.
+func FromGeohash(hash string) Location {
+	cell := GeohashBox(hash)
+	return Location{
+		Longitude: (cell.Min.Longitude + cell.Max.Longitude) / 2,
+		Latitude:  (cell.Min.Latitude + cell.Max.Latitude) / 2,
+	}
+}
+
+// GeohashBox decodes hash into the cell it represents, i.e., the box holding
+// every location whose geohash starts with hash.
+// Characters not in the geohash alphabet are ignored.
+func GeohashBox(hash string) Box {
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+
+	evenBit := true
+	for _, c := range strings.ToLower(hash) {
+		char := strings.IndexRune(geohashBase32, c)
+		if char < 0 {
+			continue
+		}
+
+		for mask := 16; mask > 0; mask >>= 1 {
+			if evenBit {
+				mid := (minLon + maxLon) / 2
+				if char&mask != 0 {
+					minLon = mid
+				} else {
+					maxLon = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if char&mask != 0 {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			evenBit = !evenBit
+		}
+	}
+
+	return Box{
+		Min: Location{Longitude: minLon, Latitude: minLat},
+		Max: Location{Longitude: maxLon, Latitude: maxLat},
+	}
+}
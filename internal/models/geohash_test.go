@@ -0,0 +1,56 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeohash(t *testing.T) {
+	tests := []struct {
+		location Location
+		hash     string
+	}{
+		{location: Location{Longitude: -5.6, Latitude: 42.6}, hash: "ezs42"},
+		{location: Location{Longitude: 10.40744, Latitude: 57.64911}, hash: "u4pruydqqvj"},
+		{location: Location{Longitude: 103.85, Latitude: 1.29}, hash: "w21z7"},
+		{location: Location{Longitude: -0.1257, Latitude: 51.5085}, hash: "gcpvj0"},
+	}
+	for _, test := range tests {
+		if got := test.location.Geohash(len(test.hash)); got != test.hash {
+			t.Errorf("%v.Geohash(%d) = %q, want %q", test.location, len(test.hash), got, test.hash)
+		}
+
+		// the center of the cell lies within half a cell of the location encoded
+		decoded := FromGeohash(test.hash)
+		lonBits := (5*len(test.hash) + 1) / 2
+		latBits := 5*len(test.hash) - lonBits
+		if math.Abs(decoded.Longitude-test.location.Longitude) > 180/math.Pow(2, float64(lonBits)) ||
+			math.Abs(decoded.Latitude-test.location.Latitude) > 90/math.Pow(2, float64(latBits)) {
+			t.Errorf("FromGeohash(%q) = %v, want within the cell of %v", test.hash, decoded, test.location)
+		}
+	}
+}
+
+func TestGeohashBox(t *testing.T) {
+	location := Location{Longitude: 103.85, Latitude: 1.29}
+	for precision := 1; precision <= 12; precision++ {
+		hash := location.Geohash(precision)
+		cell := GeohashBox(hash)
+		if !cell.Contains(location) {
+			t.Errorf("cell %+v of %q does not contain %v", cell, hash, location)
+		}
+		// every corner of the cell, nudged inwards, encodes to the same hash
+		nudge := (cell.Max.Latitude - cell.Min.Latitude) / 1e3
+		for _, corner := range []Location{
+			{Longitude: cell.Min.Longitude + nudge, Latitude: cell.Min.Latitude + nudge},
+			{Longitude: cell.Max.Longitude - nudge, Latitude: cell.Max.Latitude - nudge},
+		} {
+			if got := corner.Geohash(precision); got != hash {
+				t.Errorf("corner %v of the cell of %q encodes to %q", corner, hash, got)
+			}
+		}
+		if center := FromGeohash(hash); !cell.Contains(center) {
+			t.Errorf("FromGeohash(%q) = %v, outside its cell %+v", hash, center, cell)
+		}
+	}
+}
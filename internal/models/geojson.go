@@ -0,0 +1,14 @@
+package models
+
+// Polygon is a GeoJSON Polygon geometry.
+// ref: https://www.rfc-editor.org/rfc/rfc7946#section-3.1.6
+type Polygon struct {
+
+	// Type is always Polygon
+	Type string `json:"type"`
+
+	// Coordinates are linear rings of [longitude, latitude] positions,
+	// the first being the exterior ring, followed by any hole.
+	// Each ring is closed, i.e., its first and last positions are equal
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
@@ -0,0 +1,9 @@
+package models
+
+// Hotspot is the job location having the most other jobs within some radius
+type Hotspot struct {
+	Location Location `json:"location"`
+
+	// Count is the number of other jobs within radius of Location
+	Count int `json:"count"`
+}
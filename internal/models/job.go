@@ -1,6 +1,179 @@
 package models
 
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+)
+
 type Job struct {
+
+	// ID identifies the job across queries, e.g., to detect
+	// the same job on different pages or radii.
+	// ID is assigned with AssignIDs when the job is stored
+	ID string `json:"id"`
+
 	Title    string   `json:"title"`
 	Location Location `json:"location"`
+
+	// Company is the employer offering the job, empty where unknown
+	Company string `json:"company,omitempty"`
+
+	// Labels are arbitrary metadata of the job, e.g., shift=night or remote=true
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// CreatedAt is when the job was added to the available jobs,
+	// nil for jobs read from the data file
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// NormalizeTitle returns the form titles differing only in case are compared in,
+// both when indexing jobs by title and when searching them by title,
+// so that both always normalize titles alike
+func NormalizeTitle(title string) string {
+	return strings.ToLower(title)
+}
+
+// IsComplete checks that every optional field of j is set, i.e., Company and Location.Altitude
+func (j Job) IsComplete() bool {
+	return j.Company != "" && j.Location.Altitude != nil
+}
+
+// SetLabel sets the label key of j to value
+func (j *Job) SetLabel(key, value string) {
+	if j.Labels == nil {
+		j.Labels = make(map[string]string)
+	}
+	j.Labels[key] = value
+}
+
+// HasLabels checks that j has every label of labels with the same value
+func (j Job) HasLabels(labels map[string]string) bool {
+	for key, value := range labels {
+		if labelValue, ok := j.Labels[key]; !ok || labelValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// JobsLabeled returns the jobs among jobs having every label of labels, in the same order
+func JobsLabeled(jobs []Job, labels map[string]string) []Job {
+	labeled := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		if job.HasLabels(labels) {
+			labeled = append(labeled, job)
+		}
+	}
+	return labeled
+}
+
+// CompleteJobs returns the jobs among jobs that are complete, in the same order
+func CompleteJobs(jobs []Job) []Job {
+	complete := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		if job.IsComplete() {
+			complete = append(complete, job)
+		}
+	}
+	return complete
+}
+
+// JobWithDistance is a Job annotated with its distance
+// in kilometers from some location
+type JobWithDistance struct {
+	Job
+	Distance float64 `json:"distance"`
+}
+
+// JobPair is a pair of jobs along with
+// the distance in kilometers between them
+type JobPair struct {
+	A        Job     `json:"a"`
+	B        Job     `json:"b"`
+	Distance float64 `json:"distance"`
+}
+
+// NearestFirst annotates each of jobs with its distance from center on sphere,
+// and orders them from the nearest job
+func NearestFirst(sphere Sphere, center Location, jobs []Job) []JobWithDistance {
+	withDistances := make([]JobWithDistance, len(jobs))
+	for i, job := range jobs {
+		withDistances[i] = JobWithDistance{Job: job, Distance: sphere.Distance(center, job.Location)}
+	}
+	SortNearestFirst(withDistances)
+	return withDistances
+}
+
+// NearestPosition is the position of a job in the order of SortNearestFirst, i.e., its sort key,
+// e.g., of the last job of a page of jobs nearest to a location
+type NearestPosition struct {
+	Distance float64
+	Title    string
+	ID       string
+}
+
+// Precedes checks that job follows p in the order of SortNearestFirst
+func (p NearestPosition) Precedes(job JobWithDistance) bool {
+	if job.Distance != p.Distance {
+		return job.Distance > p.Distance
+	}
+	if job.Title != p.Title {
+		return job.Title > p.Title
+	}
+	return job.ID > p.ID
+}
+
+// SortNearestFirst orders jobs from the nearest job.
+// Equidistant jobs are ordered by title, then by ID,
+// so that the order is the same across calls, e.g., when paginating.
+func SortNearestFirst(jobs []JobWithDistance) {
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].Distance != jobs[j].Distance {
+			return jobs[i].Distance < jobs[j].Distance
+		}
+		if jobs[i].Title != jobs[j].Title {
+			return jobs[i].Title < jobs[j].Title
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+}
+
+// AssignIDs sets the ID of each of jobs lacking one, or having an ID in taken.
+// IDs are derived from the job title and location, so that the same jobs get the same IDs
+// across reloads, and suffixed with a counter to tell identical jobs apart.
+// Every ID of jobs is added to taken.
+func AssignIDs(jobs []Job, taken map[string]bool) {
+	assignIDs(jobs, func(id string) bool { return taken[id] }, func(id string) { taken[id] = true })
+}
+
+// AssignNewIDs sets the ID of each of jobs as AssignIDs does, IDs being taken if taken is true
+// for them or if they are the ID of another of jobs, e.g., to look IDs up in an index
+// rather than gathering every ID already taken into a map
+func AssignNewIDs(jobs []Job, taken func(id string) bool) {
+	assigned := make(map[string]bool, len(jobs))
+	assignIDs(jobs, func(id string) bool { return assigned[id] || taken(id) }, func(id string) { assigned[id] = true })
+}
+
+// assignIDs sets the ID of each of jobs lacking one, or having an ID for which taken is true,
+// calling take on every ID of jobs
+func assignIDs(jobs []Job, taken func(id string) bool, take func(id string)) {
+	for i := range jobs {
+		if jobs[i].ID != "" && !taken(jobs[i].ID) {
+			take(jobs[i].ID)
+			continue
+		}
+
+		hash := fnv.New64a()
+		fmt.Fprintf(hash, "%s|%f|%f", jobs[i].Title, jobs[i].Location.Longitude, jobs[i].Location.Latitude)
+		base := fmt.Sprintf("%016x", hash.Sum64())
+		id := base
+		for n := 2; taken(id); n++ {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		jobs[i].ID = id
+		take(id)
+	}
 }
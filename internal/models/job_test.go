@@ -0,0 +1,76 @@
+package models
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestNearestFirstBreaksTies(t *testing.T) {
+	center := Location{Longitude: 103.85, Latitude: 1.29}
+	north, south := Location{Longitude: 103.85, Latitude: 1.3}, Location{Longitude: 103.85, Latitude: 1.28}
+	jobs := []Job{
+		{ID: "5", Title: "Nurse", Location: north},
+		{ID: "3", Title: "Nurse", Location: south},
+		{ID: "4", Title: "Cook", Location: north},
+		{ID: "1", Title: "Nurse", Location: north},
+		{ID: "2", Title: "Driver", Location: center},
+		{ID: "6", Title: "Cook", Location: south},
+	}
+
+	// north and south lie equidistant from center, so jobs at either tie on distance
+	want := []string{"2", "4", "6", "1", "3", "5"}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		random.Shuffle(len(jobs), func(i, j int) { jobs[i], jobs[j] = jobs[j], jobs[i] })
+		sorted := NearestFirst(Earth, center, jobs)
+		ids := make([]string, len(sorted))
+		for i, job := range sorted {
+			ids[i] = job.ID
+			if i > 0 && !(NearestPosition{Distance: sorted[i-1].Distance, Title: sorted[i-1].Title, ID: sorted[i-1].ID}).Precedes(job) {
+				t.Errorf("job %q does not follow job %q", job.ID, sorted[i-1].ID)
+			}
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Fatalf("NearestFirst ordered jobs %v, want %v", ids, want)
+		}
+	}
+}
+
+func TestJobIsComplete(t *testing.T) {
+	altitude := 12.0
+	tests := []struct {
+		job      Job
+		complete bool
+	}{
+		{job: Job{Title: "Nurse"}, complete: false},
+		{job: Job{Title: "Nurse", Company: "Clinic"}, complete: false},
+		{job: Job{Title: "Nurse", Location: Location{Altitude: &altitude}}, complete: false},
+		{job: Job{Title: "Nurse", Company: "Clinic", Location: Location{Altitude: &altitude}}, complete: true},
+	}
+	for _, test := range tests {
+		if complete := test.job.IsComplete(); complete != test.complete {
+			t.Errorf("IsComplete() of %+v = %v, want %v", test.job, complete, test.complete)
+		}
+	}
+}
+
+func TestNormalizeTitle(t *testing.T) {
+	for _, titles := range [][]string{
+		{"Nurse", "nurse", "NURSE", "nUrSe"},
+		{"Ébéniste", "ÉBÉNISTE", "ébéniste"},
+		{"Straße", "STRAßE"},
+	} {
+		for _, title := range titles {
+			if NormalizeTitle(title) != NormalizeTitle(titles[0]) {
+				t.Errorf("%q normalized to %q, want %q as %q", title, NormalizeTitle(title), NormalizeTitle(titles[0]), titles[0])
+			}
+			if NormalizeTitle(NormalizeTitle(title)) != NormalizeTitle(title) {
+				t.Errorf("%q normalized twice to %q, want %q", title, NormalizeTitle(NormalizeTitle(title)), NormalizeTitle(title))
+			}
+		}
+	}
+	if NormalizeTitle("Nurse") == NormalizeTitle("Nurses") {
+		t.Error("distinct titles normalized alike")
+	}
+}
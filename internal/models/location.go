@@ -1,12 +1,19 @@
 package models
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+)
 
 // Location is a 2D representation of a place
 // on a map
 type Location struct {
 	Longitude float64 `json:"longitude"`
 	Latitude  float64 `json:"latitude"`
+
+	// Altitude is the elevation in meters, e.g., of an office in a high-rise,
+	// nil where unknown. Altitude is not indexed, nor accounted for in distances
+	Altitude *float64 `json:"altitude,omitempty"`
 }
 
 // String represents this Location as a string of Longitude,Latitude
@@ -14,6 +21,132 @@ func (l Location) String() string {
 	return fmt.Sprintf("%f,%f", l.Longitude, l.Latitude)
 }
 
+// DistanceTo computes the great-circle distance in kilometers between l and other on Earth
+func (l Location) DistanceTo(other Location) float64 {
+	return Earth.Distance(l, other)
+}
+
+// Equal checks that l and other are at most tolMeters apart,
+// so that coordinates differing only by rounding, e.g., in a csv file, are equal
+func (l Location) Equal(other Location, tolMeters float64) bool {
+	return l.DistanceTo(other)*1000 <= tolMeters
+}
+
+// MatchToleranceMeters is the distance in meters within which a location matches another,
+// e.g., that of a job to delete, as with Equal
+const MatchToleranceMeters = 1.0
+
+// Matches checks that location lies within radiusKm of center on s,
+// or within MatchToleranceMeters, so that a zero radius matches center itself
+func (s Sphere) Matches(center, location Location, radiusKm float64) bool {
+	return s.Distance(center, location) <= math.Max(radiusKm, MatchToleranceMeters/1000)
+}
+
+// BoundingBox returns the south-west (min) and north-east (max) corners
+// of the smallest latitude/longitude box containing the circle of radius around l on Earth
+func (l Location) BoundingBox(radius Distance) (min, max Location) {
+	return Earth.BoundingBox(l, radius)
+}
+
+// Within checks that l lies inside the box having min and max as
+// south-west and north-east corners respectively
+func (l Location) Within(min, max Location) bool {
+	return l.Latitude >= min.Latitude && l.Latitude <= max.Latitude &&
+		l.Longitude >= min.Longitude && l.Longitude <= max.Longitude
+}
+
+// InPolygon checks that l lies inside the polygon having vertices in order,
+// by casting a ray from l and counting the edges of the polygon it crosses.
+// Longitudes and latitudes are taken as planar coordinates, which holds for
+// polygons spanning a few degrees away from the poles and the antimeridian.
+// ref: https://en.wikipedia.org/wiki/Point_in_polygon#Ray_casting_algorithm
+func (l Location) InPolygon(vertices []Location) bool {
+	inside := false
+	for i, j := 0, len(vertices)-1; i < len(vertices); j, i = i, i+1 {
+		a, b := vertices[i], vertices[j]
+		if (a.Latitude > l.Latitude) != (b.Latitude > l.Latitude) &&
+			l.Longitude < a.Longitude+(l.Latitude-a.Latitude)*(b.Longitude-a.Longitude)/(b.Latitude-a.Latitude) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Box is a rectangle on a map bounded by
+// lines of latitude and longitude
+type Box struct {
+
+	// Min is the south-west corner of the box
+	Min Location `json:"min"`
+
+	// Max is the north-east corner of the box.
+	// A box whose Max.Longitude is below its Min.Longitude crosses the antimeridian,
+	// spanning east of Min.Longitude to 180 and from -180 to Max.Longitude.
+	Max Location `json:"max"`
+}
+
+// CrossesAntimeridian checks that b spans the ±180° meridian,
+// i.e., that its Max.Longitude is below its Min.Longitude
+func (b Box) CrossesAntimeridian() bool {
+	return b.Max.Longitude < b.Min.Longitude
+}
+
+// Split returns b if it does not cross the antimeridian, else the boxes either side of it,
+// so that each box returned has its Min below its Max and may be searched as a plane
+func (b Box) Split() []Box {
+	if !b.CrossesAntimeridian() {
+		return []Box{b}
+	}
+	return []Box{
+		{Min: b.Min, Max: Location{Longitude: 180, Latitude: b.Max.Latitude}},
+		{Min: Location{Longitude: -180, Latitude: b.Min.Latitude}, Max: b.Max},
+	}
+}
+
+// Contains checks that l lies inside b, which may cross the antimeridian
+func (b Box) Contains(l Location) bool {
+	if l.Latitude < b.Min.Latitude || l.Latitude > b.Max.Latitude {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return l.Longitude >= b.Min.Longitude || l.Longitude <= b.Max.Longitude
+	}
+	return l.Longitude >= b.Min.Longitude && l.Longitude <= b.Max.Longitude
+}
+
+// lonSpan returns the degrees of longitude b spans, eastwards from Min.Longitude
+func (b Box) lonSpan() float64 {
+	if b.CrossesAntimeridian() {
+		return b.Max.Longitude - b.Min.Longitude + 360
+	}
+	return b.Max.Longitude - b.Min.Longitude
+}
+
+// BoxAround returns the smallest box containing every location in locations.
+// BoxAround returns the zero Box if locations is empty
+func BoxAround(locations []Location) Box {
+	if len(locations) == 0 {
+		return Box{}
+	}
+
+	box := Box{Min: locations[0], Max: locations[0]}
+	for _, location := range locations[1:] {
+		if location.Latitude < box.Min.Latitude {
+			box.Min.Latitude = location.Latitude
+		}
+		if location.Longitude < box.Min.Longitude {
+			box.Min.Longitude = location.Longitude
+		}
+		if location.Latitude > box.Max.Latitude {
+			box.Max.Latitude = location.Latitude
+		}
+		if location.Longitude > box.Max.Longitude {
+			box.Max.Longitude = location.Longitude
+		}
+	}
+	return box
+}
+
 // GeoJSON represents this Location in GeoJSON specification RCF7946.
 // https://geojson.org/
 func (l Location) GeoJSON() {
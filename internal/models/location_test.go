@@ -0,0 +1,129 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLocationEqual(t *testing.T) {
+	l := Location{Longitude: 103.85, Latitude: 1.29}
+
+	// at the equator, a millionth of a degree spans about 11cm
+	tests := []struct {
+		other     Location
+		tolMeters float64
+		equal     bool
+	}{
+		{other: l, tolMeters: 0, equal: true},
+		{other: Location{Longitude: 103.850001, Latitude: 1.29}, tolMeters: 1, equal: true},
+		{other: Location{Longitude: 103.85, Latitude: 1.290004}, tolMeters: 1, equal: true},
+		{other: Location{Longitude: 103.850005, Latitude: 1.290005}, tolMeters: 1, equal: true},
+		{other: Location{Longitude: 103.850005, Latitude: 1.290005}, tolMeters: 0.5, equal: false},
+		{other: Location{Longitude: 103.85001, Latitude: 1.29}, tolMeters: 1, equal: false},
+		{other: Location{Longitude: 103.850001, Latitude: 1.29}, tolMeters: 0, equal: false},
+	}
+	for _, test := range tests {
+		if got := l.Equal(test.other, test.tolMeters); got != test.equal {
+			t.Errorf("%v.Equal(%v, %v) = %v, want %v (%vm apart)",
+				l, test.other, test.tolMeters, got, test.equal, l.DistanceTo(test.other)*1000)
+		}
+		if got := test.other.Equal(l, test.tolMeters); got != test.equal {
+			t.Errorf("%v.Equal(%v, %v) = %v, want %v", test.other, l, test.tolMeters, got, test.equal)
+		}
+	}
+}
+
+func TestSphereMatches(t *testing.T) {
+	center := Location{Longitude: 103.85, Latitude: 1.29}
+	halved := Sphere{RadiusKm: EarthRadiusKm / 2}
+
+	// about 0.78m apart on the earth, and half that on a sphere of half its radius
+	near := Location{Longitude: 103.850005, Latitude: 1.290005}
+	// about 1.1km apart on the earth
+	far := Location{Longitude: 103.86, Latitude: 1.29}
+	tests := []struct {
+		sphere   Sphere
+		location Location
+		radiusKm float64
+		match    bool
+	}{
+		{sphere: Earth, location: center, radiusKm: 0, match: true},
+		{sphere: Earth, location: near, radiusKm: 0, match: true},
+		{sphere: Earth, location: far, radiusKm: 0, match: false},
+		{sphere: Earth, location: far, radiusKm: 1, match: false},
+		{sphere: Earth, location: far, radiusKm: 2, match: true},
+		{sphere: halved, location: far, radiusKm: 1, match: true},
+		{sphere: halved, location: near, radiusKm: 0, match: true},
+	}
+	for _, test := range tests {
+		if got := test.sphere.Matches(center, test.location, test.radiusKm); got != test.match {
+			t.Errorf("on a sphere of %vkm, %v matches %v within %vkm: %v, want %v (%vkm apart)",
+				test.sphere.RadiusKm, test.location, center, test.radiusKm, got, test.match, test.sphere.Distance(center, test.location))
+		}
+	}
+}
+
+func TestLocationJSON(t *testing.T) {
+	altitude := 120.5
+	for _, l := range []Location{
+		{Longitude: 103.85, Latitude: 1.29},
+		{Longitude: 103.85, Latitude: 1.29, Altitude: &altitude},
+	} {
+		data, err := json.Marshal(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Location
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, l) {
+			t.Errorf("%s decoded to %+v, want %+v", data, decoded, l)
+		}
+	}
+
+	if data, _ := json.Marshal(Location{Longitude: 103.85, Latitude: 1.29}); string(data) != `{"longitude":103.85,"latitude":1.29}` {
+		t.Errorf("location without altitude encoded to %s, want altitude omitted", data)
+	}
+}
+
+func TestBoxAcrossAntimeridian(t *testing.T) {
+	crossing := Box{Min: Location{Longitude: 170, Latitude: -20}, Max: Location{Longitude: -170, Latitude: -10}}
+	plain := Box{Min: Location{Longitude: -170, Latitude: -20}, Max: Location{Longitude: 170, Latitude: -10}}
+
+	tests := []struct {
+		location Location
+		crossing bool
+		plain    bool
+	}{
+		{location: Location{Longitude: 175, Latitude: -15}, crossing: true, plain: false},
+		{location: Location{Longitude: -175, Latitude: -15}, crossing: true, plain: false},
+		{location: Location{Longitude: 180, Latitude: -15}, crossing: true, plain: false},
+		{location: Location{Longitude: -180, Latitude: -15}, crossing: true, plain: false},
+		{location: Location{Longitude: 0, Latitude: -15}, crossing: false, plain: true},
+		{location: Location{Longitude: 175, Latitude: -5}, crossing: false, plain: false},
+	}
+	for _, test := range tests {
+		if got := crossing.Contains(test.location); got != test.crossing {
+			t.Errorf("box across the antimeridian contains %v: %v, want %v", test.location, got, test.crossing)
+		}
+		if got := plain.Contains(test.location); got != test.plain {
+			t.Errorf("box %+v contains %v: %v, want %v", plain, test.location, got, test.plain)
+		}
+	}
+
+	if !crossing.CrossesAntimeridian() || plain.CrossesAntimeridian() {
+		t.Error("only the box whose maximum longitude is below its minimum crosses the antimeridian")
+	}
+	want := []Box{
+		{Min: Location{Longitude: 170, Latitude: -20}, Max: Location{Longitude: 180, Latitude: -10}},
+		{Min: Location{Longitude: -180, Latitude: -20}, Max: Location{Longitude: -170, Latitude: -10}},
+	}
+	if parts := crossing.Split(); !reflect.DeepEqual(parts, want) {
+		t.Errorf("split into %+v, want %+v", parts, want)
+	}
+	if parts := plain.Split(); !reflect.DeepEqual(parts, []Box{plain}) {
+		t.Errorf("split into %+v, want the box itself", parts)
+	}
+}
@@ -0,0 +1,75 @@
+package models
+
+import "math"
+
+// DistanceToSegment computes the great-circle distance in kilometers on s from location
+// to the nearest point of the great-circle arc from a to b, i.e., to the arc itself if
+// location lies alongside it, else to the nearer of a and b.
+// ref: https://www.movable-type.co.uk/scripts/latlong.html, cross-track distance
+func (s Sphere) DistanceToSegment(location, a, b Location) float64 {
+	toLocation, length := s.Distance(a, location), s.Distance(a, b)
+	if length == 0 || toLocation == 0 {
+		return toLocation
+	}
+
+	// location lies behind a, seen along the arc
+	bearingDifference := toRadians(a.BearingTo(location) - a.BearingTo(b))
+	if math.Cos(bearingDifference) <= 0 {
+		return toLocation
+	}
+
+	radius := s.radiusKm()
+	angular := toLocation / radius
+	crossTrack := math.Asin(math.Sin(angular) * math.Sin(bearingDifference))
+	alongTrack := math.Acos(math.Max(-1, math.Min(1, math.Cos(angular)/math.Cos(crossTrack)))) * radius
+	if alongTrack >= length {
+		return s.Distance(b, location)
+	}
+	return math.Abs(crossTrack) * radius
+}
+
+// DistanceToRoute computes the great-circle distance in kilometers on s from location
+// to the nearest stop of the route through stops, in order, or to the arcs connecting them.
+// DistanceToRoute returns +Inf if there are no stops.
+func (s Sphere) DistanceToRoute(location Location, stops []Location) float64 {
+	if len(stops) == 1 {
+		return s.Distance(location, stops[0])
+	}
+	nearest := math.Inf(1)
+	for i := 1; i < len(stops); i++ {
+		nearest = math.Min(nearest, s.DistanceToSegment(location, stops[i-1], stops[i]))
+	}
+	return nearest
+}
+
+// RouteLength computes the length in kilometers on s of the route through stops, in order
+func (s Sphere) RouteLength(stops []Location) float64 {
+	length := 0.0
+	for i := 1; i < len(stops); i++ {
+		length += s.Distance(stops[i-1], stops[i])
+	}
+	return length
+}
+
+// SampleRoute returns locations along the route through stops on s, in order,
+// every stop included, such that no point of the route lies further than stepKm/2
+// from the nearest location returned. A zero stepKm returns stops only.
+func (s Sphere) SampleRoute(stops []Location, stepKm float64) []Location {
+	if len(stops) == 0 {
+		return []Location{}
+	}
+	samples := []Location{stops[0]}
+	for i := 1; i < len(stops); i++ {
+		a, b := stops[i-1], stops[i]
+		length := s.Distance(a, b)
+		if stepKm > 0 && length > stepKm {
+			pieces := math.Ceil(length / stepKm)
+			bearing := a.BearingTo(b)
+			for j := 1.0; j < pieces; j++ {
+				samples = append(samples, s.Destination(a, bearing, length*j/pieces))
+			}
+		}
+		samples = append(samples, b)
+	}
+	return samples
+}
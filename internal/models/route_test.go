@@ -0,0 +1,85 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+// threeStops is a route east then north through 3 stops about 11km apart, near the equator
+var threeStops = []Location{
+	{Longitude: 103.8, Latitude: 1.3},
+	{Longitude: 103.9, Latitude: 1.3},
+	{Longitude: 103.9, Latitude: 1.4},
+}
+
+func TestDistanceToRoute(t *testing.T) {
+	// kmPerDegree is the length of a degree of latitude on Earth
+	kmPerDegree := EarthRadiusKm * math.Pi / 180
+
+	tests := []struct {
+		name     string
+		location Location
+		distance float64
+	}{
+		{name: "at the first stop", location: threeStops[0], distance: 0},
+		{name: "at the middle stop", location: threeStops[1], distance: 0},
+		{name: "on the first segment", location: Location{Longitude: 103.85, Latitude: 1.3}, distance: 0},
+		{name: "1km north of the first segment", location: Location{Longitude: 103.85, Latitude: 1.3 + 1/kmPerDegree}, distance: 1},
+		{name: "2km south of the first segment", location: Location{Longitude: 103.85, Latitude: 1.3 - 2/kmPerDegree}, distance: 2},
+		{name: "1km east of the second segment", location: Location{Longitude: 103.9 + 1/kmPerDegree, Latitude: 1.35}, distance: 1},
+		{name: "before the first stop", location: Location{Longitude: 103.8 - 3/kmPerDegree, Latitude: 1.3}, distance: 3},
+		{name: "past the last stop", location: Location{Longitude: 103.9, Latitude: 1.4 + 4/kmPerDegree}, distance: 4},
+		{name: "inside the corner", location: Location{Longitude: 103.89, Latitude: 1.31}, distance: 0.01 * kmPerDegree},
+	}
+	for _, test := range tests {
+		if distance := Earth.DistanceToRoute(test.location, threeStops); math.Abs(distance-test.distance) > 0.01 {
+			t.Errorf("%s: %vkm from the route, want %vkm", test.name, distance, test.distance)
+		}
+		// the distance to a route is that to its nearest segment
+		nearest := math.Min(Earth.DistanceToSegment(test.location, threeStops[0], threeStops[1]),
+			Earth.DistanceToSegment(test.location, threeStops[1], threeStops[2]))
+		if distance := Earth.DistanceToRoute(test.location, threeStops); distance != nearest {
+			t.Errorf("%s: %vkm from the route, want %vkm from its nearest segment", test.name, distance, nearest)
+		}
+	}
+
+	if distance := Earth.DistanceToRoute(threeStops[0], threeStops[1:2]); distance != Earth.Distance(threeStops[0], threeStops[1]) {
+		t.Errorf("%vkm from a single stop route, want the distance to the stop", distance)
+	}
+	if distance := Earth.DistanceToRoute(threeStops[0], nil); !math.IsInf(distance, 1) {
+		t.Errorf("%vkm from a route without stops, want +Inf", distance)
+	}
+}
+
+func TestSampleRoute(t *testing.T) {
+	length := Earth.RouteLength(threeStops)
+	if want := Earth.Distance(threeStops[0], threeStops[1]) + Earth.Distance(threeStops[1], threeStops[2]); length != want {
+		t.Errorf("route %vkm long, want %vkm", length, want)
+	}
+
+	for _, step := range []float64{0, 0.5, 3, 50} {
+		samples := Earth.SampleRoute(threeStops, step)
+		stops := 0
+		for i, sample := range samples {
+			if Earth.DistanceToRoute(sample, threeStops) > 1e-6 {
+				t.Errorf("step %vkm: sample %v off the route", step, sample)
+			}
+			for _, stop := range threeStops {
+				if sample == stop {
+					stops++
+				}
+			}
+			if i > 0 && step > 0 {
+				if gap := Earth.Distance(samples[i-1], sample); gap > step+1e-6 {
+					t.Errorf("step %vkm: samples %vkm apart", step, gap)
+				}
+			}
+		}
+		if stops != len(threeStops) || samples[0] != threeStops[0] || samples[len(samples)-1] != threeStops[2] {
+			t.Errorf("step %vkm: %d stops sampled from %v to %v, want every stop in order", step, stops, samples[0], samples[len(samples)-1])
+		}
+		if step == 0 && len(samples) != len(threeStops) {
+			t.Errorf("step 0: %d samples, want the stops only", len(samples))
+		}
+	}
+}
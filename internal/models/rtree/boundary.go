@@ -0,0 +1,21 @@
+package rtree
+
+// Boundary specifies whether a radius search finds jobs exactly at its radius
+type Boundary int
+
+const (
+	// Inclusive finds jobs within the radius, jobs exactly at the radius included,
+	// as searches usually do
+	Inclusive Boundary = iota
+
+	// Exclusive finds jobs strictly within the radius, excluding jobs exactly at the radius
+	Exclusive
+)
+
+// Contains checks that distance is within radius, at the radius included unless b is Exclusive
+func (b Boundary) Contains(distance, radius float64) bool {
+	if b == Exclusive {
+		return distance < radius
+	}
+	return distance <= radius
+}
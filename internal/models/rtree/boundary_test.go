@@ -0,0 +1,58 @@
+package rtree
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestBoundary(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(3)), 500)
+	tree, _ := NewWithEntries(jobs...)
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+
+	// the radius is exactly the distance to the last job
+	at := jobs[len(jobs)-1]
+	radius := models.Distance{Unit: models.Kilometer, Value: models.Earth.Distance(center, at.Location)}
+	byTitle := map[string][]models.Job{"": jobs}
+
+	for _, test := range []struct {
+		boundary Boundary
+		found    bool
+	}{
+		{boundary: Inclusive, found: true},
+		{boundary: Exclusive, found: false},
+	} {
+		found := map[string]bool{
+			"FindJobs":      containsJob(tree.FindJobs(context.Background(), radius, center, byTitle, test.boundary), at.ID),
+			"FindJobsAmong": containsJob(tree.FindJobsAmong(radius, center, jobs, test.boundary), at.ID),
+		}
+		found["FindJobsNearAny"] = false
+		for _, job := range tree.FindJobsNearAny(context.Background(), []models.Location{center}, radius, test.boundary) {
+			found["FindJobsNearAny"] = found["FindJobsNearAny"] || job.ID == at.ID
+		}
+		for search, ok := range found {
+			if ok != test.found {
+				t.Errorf("%s with boundary %d found the job at the radius: %t, want %t", search, test.boundary, ok, test.found)
+			}
+		}
+	}
+
+	inclusive := tree.CountJobsApproximately(radius, center, Inclusive)
+	exclusive := tree.CountJobsApproximately(radius, center, Exclusive)
+	if inclusive != exclusive+1 {
+		t.Errorf("CountJobsApproximately counts %d jobs inclusive and %d exclusive, want one more inclusive", inclusive, exclusive)
+	}
+}
+
+// containsJob checks that jobs holds the job having id
+func containsJob(jobs []models.Job, id string) bool {
+	for _, job := range jobs {
+		if job.ID == id {
+			return true
+		}
+	}
+	return false
+}
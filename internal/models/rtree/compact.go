@@ -0,0 +1,89 @@
+package rtree
+
+// underfullEntriesPerLeaf is the number of entries below which a leaf is underfull,
+// hence merged by Compact into a sibling leaf having room for its entries
+const underfullEntriesPerLeaf = maxEntriesPerLeaf / 2
+
+// Compact merges underfull sibling leaves of tree whose entries fit together in one leaf,
+// e.g., leaves left half empty by splits, so that queries visit fewer leaves.
+// An underfull leaf is merged into the sibling whose mbr needs the least expansion
+// to accommodate it, i.e., the nearest one, as long as both are underfull.
+// If the root is left with a single leaf, that leaf becomes the root.
+// Compact returns the number of leaves merged, and must not be called while tree is searched.
+func (tree *RTree) Compact() (merged int) {
+	parents := make([]*node, 0)
+	tree.root.forEachParentToLeaves(func(parent *node) {
+		parents = append(parents, parent)
+	})
+	for _, parent := range parents {
+		merged += parent.mergeUnderfullLeaves()
+	}
+	tree.totalNodes -= merged
+
+	// merges do not change the mbr of a parent, as its leaves still hold the same entries,
+	// hence no ancestor needs adjusting, but a root left with a single leaf is shrunk
+	if root := tree.root; len(root.children) == 1 && root.children[0].isLeaf() {
+		tree.root = root.children[0]
+		tree.root.parent = nil
+		tree.height--
+		tree.totalNodes--
+	}
+	return merged
+}
+
+// forEachParentToLeaves calls fn on every node of the subtree rooted at n whose children are leaves
+func (n *node) forEachParentToLeaves(fn func(parent *node)) {
+	if n.isLeaf() || len(n.children) == 0 {
+		return
+	}
+	if n.isParentToLeafNode() {
+		fn(n)
+		return
+	}
+	for _, child := range n.children {
+		child.forEachParentToLeaves(fn)
+	}
+}
+
+// mergeUnderfullLeaves merges every underfull leaf child of n into its nearest
+// underfull sibling having room for its entries, and returns the number of leaves merged
+func (n *node) mergeUnderfullLeaves() (merged int) {
+	leaves := append([]*node{}, n.children...)
+	for _, leaf := range leaves {
+		if leaf.parent == nil {
+			// already merged into a sibling
+			continue
+		}
+		for len(leaf.entries) < underfullEntriesPerLeaf {
+			sibling := leaf.nearestUnderfullSibling()
+			if sibling == nil {
+				break
+			}
+			leaf.entries = append(leaf.entries, sibling.entries...)
+			leaf.fitMBR()
+			leaf.fitCount()
+			sibling.entries = nil
+			n.removeChild(sibling)
+			merged++
+		}
+	}
+	return merged
+}
+
+// nearestUnderfullSibling finds the underfull sibling of leaf n whose entries fit
+// in n along its own, and whose mbr expands the mbr of n the least, nil if none
+func (n *node) nearestUnderfullSibling() *node {
+	var nearest *node
+	var nearestArea float64
+	for _, sibling := range n.parent.children {
+		if sibling == n || len(sibling.entries) >= underfullEntriesPerLeaf ||
+			len(n.entries)+len(sibling.entries) > maxEntriesPerLeaf {
+			continue
+		}
+		area := n.mbr.expandToAccommodate(sibling.mbr).area()
+		if nearest == nil || area < nearestArea {
+			nearest, nearestArea = sibling, area
+		}
+	}
+	return nearest
+}
@@ -0,0 +1,126 @@
+package rtree
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// leavesOf returns the number of leaves of tree, and of those underfull sharing their parent
+// with another underfull leaf their entries fit along, i.e., leaves Compact may merge
+func leavesOf(tree *RTree) (leaves, mergeable int) {
+	tree.root.forEachParentToLeaves(func(parent *node) {
+		for _, leaf := range parent.children {
+			if len(leaf.entries) < underfullEntriesPerLeaf && leaf.nearestUnderfullSibling() != nil {
+				mergeable++
+			}
+		}
+	})
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n.isLeaf() {
+			leaves++
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(tree.root)
+	return leaves, mergeable
+}
+
+// queryResults returns the results of a few searches of tree, to compare trees holding the same jobs
+func queryResults(tree *RTree) []interface{} {
+	var results []interface{}
+	for _, center := range []models.Location{{Longitude: 3.5, Latitude: 6.5}, {Longitude: 3.1, Latitude: 6.9}, {Longitude: 3.9, Latitude: 6.2}} {
+		for _, km := range []float64{1, 10, 40} {
+			found := tree.FindJobs(context.Background(), models.Distance{Unit: models.Kilometer, Value: km}, center, nil, Inclusive)
+			results = append(results, idsOfJobs(found), tree.CountJobsApproximately(models.Distance{Unit: models.Kilometer, Value: km}, center, Inclusive))
+		}
+		results = append(results, tree.Nearest(center, 25))
+	}
+	box := idsOfJobs(viewedJobs(tree.FindEntriesInBox(models.Location{Longitude: 3.2, Latitude: 6.2}, models.Location{Longitude: 3.6, Latitude: 6.7})))
+	return append(results, box)
+}
+
+// viewedJobs returns the jobs of entries
+func viewedJobs(entries []EntryView) []models.Job {
+	jobs := make([]models.Job, len(entries))
+	for i, e := range entries {
+		jobs[i] = e.Job
+	}
+	return jobs
+}
+
+func TestCompact(t *testing.T) {
+	random := rand.New(rand.NewSource(1445))
+	jobs := randomJobs(random, 3000)
+	tree, _ := NewWithEntries(jobs...)
+
+	// deleting most jobs leaves underfull sibling leaves, still above the minimum fill of a leaf
+	for _, p := range random.Perm(len(jobs))[:2000] {
+		tree.Delete(jobs[p].ID)
+	}
+	leaves, mergeable := leavesOf(tree)
+	if mergeable < 2 {
+		t.Fatalf("%d underfull sibling leaves once deleted, want some to compact", mergeable)
+	}
+	before := queryResults(tree)
+	jobsBefore, nodesBefore := tree.Size()
+
+	merged := tree.Compact()
+	compacted, _ := leavesOf(tree)
+	if merged == 0 || compacted != leaves-merged {
+		t.Errorf("compacted %d leaves into %d, merging %d, want fewer leaves by the number merged", leaves, compacted, merged)
+	}
+	if jobs, nodes := tree.Size(); jobs != jobsBefore || nodes != nodesBefore-merged {
+		t.Errorf("compacted tree holds %d jobs and %d nodes, want %d and %d", jobs, nodes, jobsBefore, nodesBefore-merged)
+	}
+	checkShape(t, tree)
+	if after := queryResults(tree); !reflect.DeepEqual(after, before) {
+		t.Error("compacted tree finds other jobs than before compaction")
+	}
+	for _, job := range jobs {
+		if found, ok := tree.JobByID(job.ID); ok && found.ID != job.ID {
+			t.Fatalf("JobByID(%q) = %q once compacted", job.ID, found.ID)
+		}
+	}
+
+	if again := tree.Compact(); again != 0 {
+		t.Errorf("compacting twice merged %d more leaves, want none", again)
+	}
+}
+
+func TestCompactShrinksRoot(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(14450)), maxEntriesPerLeaf+1)
+	tree, _ := NewWithEntries(jobs...)
+	if tree.Height() != 1 {
+		t.Fatalf("tree of %d jobs has height %d, want 1", len(jobs), tree.Height())
+	}
+
+	// leave both leaves underfull, but above the minimum fill of a leaf
+	deleted := 0
+	tree.root.forEachParentToLeaves(func(parent *node) {
+		for _, leaf := range parent.children {
+			for _, e := range append([]*entry{}, leaf.entries[minEntriesPerLeaf+1:]...) {
+				tree.Delete(e.job.ID)
+				deleted++
+			}
+		}
+	})
+	before := queryResults(tree)
+
+	if merged := tree.Compact(); merged != 1 || tree.Height() != 0 || !tree.root.isLeaf() {
+		t.Errorf("compaction merged %d leaves, leaving a tree of height %d, want a single leaf root", merged, tree.Height())
+	}
+	if size, nodes := tree.Size(); size != len(jobs)-deleted || nodes != 1 {
+		t.Errorf("compacted tree holds %d jobs and %d nodes, want %d and 1", size, nodes, len(jobs)-deleted)
+	}
+	checkShape(t, tree)
+	if after := queryResults(tree); !reflect.DeepEqual(after, before) {
+		t.Error("compacted tree finds other jobs than before compaction")
+	}
+}
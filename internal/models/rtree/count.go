@@ -0,0 +1,42 @@
+package rtree
+
+import (
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// CountJobsApproximately counts the jobs within radial distance of center location without
+// visiting every job found, e.g., for radii spanning most of tree: a subtree is counted whole
+// from its node count once its mbr lies within distance, i.e., the farthest point of the mbr
+// from center, and only the jobs of leaves partially within distance are checked one by one.
+// Subtrees about distance away are checked job by job rather than counted whole, hence the count
+// departs from an exact count by at most the jobs of leaves partially within distance.
+// Jobs exactly at the distance are counted unless boundary is Exclusive.
+func (tree *RTree) CountJobsApproximately(within models.Distance, center models.Location, boundary Boundary) int {
+	return tree.root.countJobs(tree.sphere, within.Kilometers(), center, boundary, newSearchRegion(tree.sphere, center, within))
+}
+
+// farthestSlackKm widens the distance to the farthest point of an mbr against the rounding
+// of computing it from the antipode of center, e.g., for an mbr exactly at the radius
+const farthestSlackKm = 1e-6
+
+// countJobs counts the jobs of the subtree rooted at n within radius of center on sphere,
+// jobs exactly at radius counted unless boundary is Exclusive, area being the region bounding the circle searched
+func (n *node) countJobs(sphere models.Sphere, radius float64, center models.Location, boundary Boundary, area region) int {
+	if !area.overlapsWith(n.mbr) {
+		return 0
+	}
+	if boundary.Contains(sphere.FarthestDistanceToBox(center, n.mbr.box())+farthestSlackKm, radius) {
+		return n.count
+	}
+
+	count := 0
+	for _, e := range n.entries {
+		if boundary.Contains(sphere.Distance(center, e.job.Location), radius) {
+			count++
+		}
+	}
+	for _, child := range n.children {
+		count += child.countJobs(sphere, radius, center, boundary, area)
+	}
+	return count
+}
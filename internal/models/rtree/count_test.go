@@ -0,0 +1,106 @@
+package rtree
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// worldJobs returns n jobs having distinct IDs, located at random anywhere between the polar circles
+func worldJobs(random *rand.Rand, n int) []models.Job {
+	jobs := make([]models.Job, n)
+	for i := range jobs {
+		jobs[i] = models.Job{
+			ID:    fmt.Sprintf("job-%d", i),
+			Title: fmt.Sprintf("title %d", i%7),
+			Location: models.Location{
+				Longitude: random.Float64()*360 - 180,
+				Latitude:  random.Float64()*132 - 66,
+			},
+		}
+	}
+	return jobs
+}
+
+// partialLeafJobs counts the jobs of the leaves of the subtree rooted at n holding
+// jobs both within and beyond radius of center on the earth
+func partialLeafJobs(n *node, radius float64, center models.Location) int {
+	if len(n.children) != 0 {
+		jobs := 0
+		for _, child := range n.children {
+			jobs += partialLeafJobs(child, radius, center)
+		}
+		return jobs
+	}
+	within := 0
+	for _, e := range n.entries {
+		if models.Earth.Distance(center, e.job.Location) <= radius {
+			within++
+		}
+	}
+	if within == 0 || within == len(n.entries) {
+		return 0
+	}
+	return len(n.entries)
+}
+
+func TestCountJobsApproximately(t *testing.T) {
+	random := rand.New(rand.NewSource(1446))
+	tests := []struct {
+		name  string
+		jobs  []models.Job
+		radii []float64
+	}{
+		{name: "around Lagos", jobs: randomJobs(random, 5000), radii: []float64{1, 5, 20, 50, 100, 500}},
+		{name: "worldwide", jobs: worldJobs(random, 5000), radii: []float64{100, 1000, 3000, 8000, 15000}},
+	}
+	for _, test := range tests {
+		tree, _ := NewWithEntries(test.jobs...)
+		for _, radius := range test.radii {
+			for i := 0; i < 5; i++ {
+				center := test.jobs[random.Intn(len(test.jobs))].Location
+				within := models.Distance{Unit: models.Kilometer, Value: radius}
+
+				exact := 0
+				for _, job := range test.jobs {
+					if models.Earth.Distance(center, job.Location) <= radius {
+						exact++
+					}
+				}
+
+				// the approximation departs from the exact count by at most the jobs of leaves partially within radius
+				approximate := tree.CountJobsApproximately(within, center, Inclusive)
+				if margin := partialLeafJobs(tree.root, radius, center); approximate < exact-margin || approximate > exact+margin {
+					t.Errorf("%s: counted %d jobs within %vkm of %v, want %d within %d jobs of partial leaves",
+						test.name, approximate, radius, center, exact, margin)
+				}
+				if found := len(tree.FindJobs(context.Background(), within, center, titleJobs(test.jobs), Inclusive)); found != exact {
+					t.Errorf("%s: found %d jobs within %vkm of %v, want %d", test.name, found, radius, center, exact)
+				}
+			}
+		}
+	}
+}
+
+func TestCountJobsApproximatelyAfterDeletes(t *testing.T) {
+	random := rand.New(rand.NewSource(14460))
+	jobs := randomJobs(random, 2000)
+	tree, _ := NewWithEntries(jobs...)
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	within := models.Distance{Unit: models.Kilometer, Value: 500}
+
+	// a radius covering every job counts every job left from node counts, once deleted and compacted
+	for _, p := range random.Perm(len(jobs))[:1500] {
+		tree.Delete(jobs[p].ID)
+	}
+	if count := tree.CountJobsApproximately(within, center, Inclusive); count != 500 {
+		t.Errorf("counted %d jobs once deleted, want 500", count)
+	}
+	tree.Compact()
+	if count := tree.CountJobsApproximately(within, center, Inclusive); count != 500 {
+		t.Errorf("counted %d jobs once compacted, want 500", count)
+	}
+}
@@ -0,0 +1,109 @@
+package rtree
+
+// Delete deletes the job having id from tree, following Delete and CondenseTree of
+// http://www-db.deis.unibo.it/courses/SI-LS/papers/Gut84.pdf section 3.3:
+// the leaf holding the job is found by descending the nodes whose mbr contains its location,
+// then every node left with fewer than minEntriesPerLeaf entries or children on the way back
+// to the root is removed, and the jobs of the nodes removed are inserted again.
+// A root left with a single child is replaced by that child.
+// ok is false if no job on tree has id, e.g., jobs indexed without an ID.
+func (tree *RTree) Delete(id string) (ok bool) {
+	e, ok := tree.ids[id]
+	if !ok {
+		return false
+	}
+	leaf := tree.root.findLeaf(e)
+	if leaf == nil {
+		return false
+	}
+
+	leaf.removeEntry(id)
+	delete(tree.ids, id)
+	tree.indexCount--
+	tree.condense(leaf)
+	return true
+}
+
+// findLeaf finds the leaf of the subtree rooted at n holding the entry of the job of e,
+// nil if none. Entries are matched by the ID of their job, as e may be a copy of the entry on tree.
+func (n *node) findLeaf(e *entry) *node {
+	if !e.mbr.canFitWithin(n.mbr) {
+		return nil
+	}
+	for _, stored := range n.entries {
+		if stored.job.ID == e.job.ID {
+			return n
+		}
+	}
+	for _, child := range n.children {
+		if leaf := child.findLeaf(e); leaf != nil {
+			return leaf
+		}
+	}
+	return nil
+}
+
+// removeEntry removes the entry of the job having id from n.entries, if any,
+// without fitting n.mbr and n.count to the entries left
+func (n *node) removeEntry(id string) {
+	for i, e := range n.entries {
+		if e.job.ID == id {
+			n.entries = append(n.entries[:i], n.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// condense condenses tree once an entry is removed from leaf, as CondenseTree does.
+// Underfull nodes from leaf up to the root are removed from their parent, and the other nodes
+// are fitted to what they hold, then the entries of the nodes removed are inserted again,
+// hence at the level of leaves whatever the level of the node removed.
+// Last, the root is shrunk while it has a single child, dropping a level each time.
+func (tree *RTree) condense(leaf *node) {
+	orphans := make([]*entry, 0)
+	for n := leaf; n != tree.root; {
+		parent := n.parent
+		if len(n.entries)+len(n.children) < minEntriesPerLeaf {
+			parent.removeChild(n)
+			tree.totalNodes -= n.size()
+			n.forEachEntry(func(e *entry) bool {
+				orphans = append(orphans, e)
+				return true
+			})
+		} else {
+			n.fitMBR()
+			n.fitCount()
+		}
+		n = parent
+	}
+	tree.root.fitMBR()
+	tree.root.fitCount()
+
+	// a root left with neither entries nor children becomes an empty leaf
+	if tree.root.isEmpty() {
+		tree.root = new(node)
+		tree.height = 0
+		tree.totalNodes = 1
+	}
+
+	tree.indexCount -= len(orphans)
+	for _, e := range orphans {
+		tree.Insert(*e)
+	}
+
+	for len(tree.root.children) == 1 {
+		tree.root = tree.root.children[0]
+		tree.root.parent = nil
+		tree.height--
+		tree.totalNodes--
+	}
+}
+
+// size returns the number of nodes of the subtree rooted at n, n included
+func (n *node) size() int {
+	size := 1
+	for _, child := range n.children {
+		size += child.size()
+	}
+	return size
+}
@@ -0,0 +1,124 @@
+package rtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// randomJobs returns n jobs having distinct IDs, located at random around Lagos
+func randomJobs(random *rand.Rand, n int) []models.Job {
+	jobs := make([]models.Job, n)
+	for i := range jobs {
+		jobs[i] = models.Job{
+			ID:    fmt.Sprintf("job-%d", i),
+			Title: fmt.Sprintf("title %d", i%7),
+			Location: models.Location{
+				Longitude: 3 + random.Float64(),
+				Latitude:  6 + random.Float64(),
+			},
+		}
+	}
+	return jobs
+}
+
+// checkShape fails t unless every leaf of tree lies at tree.Height(), every node is bounded
+// and counted by its parent, and the nodes and jobs of tree are those counted by Size
+func checkShape(t *testing.T, tree *RTree) {
+	t.Helper()
+	nodes, jobs := 0, 0
+	var walk func(n *node, depth int)
+	walk = func(n *node, depth int) {
+		nodes++
+		jobs += len(n.entries)
+		if len(n.children) == 0 && depth != tree.Height() && len(n.entries) != 0 {
+			t.Errorf("leaf at depth %d, want %d", depth, tree.Height())
+		}
+		count := len(n.entries)
+		for _, child := range n.children {
+			if child.parent != n {
+				t.Errorf("child at depth %d not attached to its parent", depth+1)
+			}
+			if !child.mbr.canFitWithin(n.mbr) {
+				t.Errorf("child mbr %v not within parent mbr %v", child.mbr, n.mbr)
+			}
+			count += child.count
+			walk(child, depth+1)
+		}
+		if count != n.count {
+			t.Errorf("node at depth %d counts %d jobs, holds %d", depth, n.count, count)
+		}
+	}
+	walk(tree.root, 0)
+
+	wantJobs, wantNodes := tree.Size()
+	if jobs != wantJobs || nodes != wantNodes {
+		t.Errorf("tree holds %d jobs and %d nodes, Size reports %d and %d", jobs, nodes, wantJobs, wantNodes)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	jobs := randomJobs(random, 1000)
+	tree, _ := NewWithEntries(jobs...)
+
+	deleted := make(map[string]bool)
+	for i, p := range random.Perm(len(jobs)) {
+		id := jobs[p].ID
+		if !tree.Delete(id) {
+			t.Fatalf("Delete(%q) = false, want true", id)
+		}
+		deleted[id] = true
+		if tree.Delete(id) {
+			t.Fatalf("Delete(%q) of a job deleted = true, want false", id)
+		}
+		if i%100 != 0 {
+			continue
+		}
+
+		checkShape(t, tree)
+		found := tree.FindEntriesInBox(models.Location{Longitude: 2, Latitude: 5}, models.Location{Longitude: 5, Latitude: 8})
+		if len(found) != len(jobs)-len(deleted) {
+			t.Fatalf("found %d jobs after deleting %d of %d", len(found), len(deleted), len(jobs))
+		}
+		for _, e := range found {
+			if deleted[e.Job.ID] {
+				t.Fatalf("found job %q once deleted", e.Job.ID)
+			}
+		}
+		for _, job := range jobs {
+			if _, ok := tree.JobByID(job.ID); ok == deleted[job.ID] {
+				t.Fatalf("JobByID(%q) = %t once deleted", job.ID, ok)
+			}
+		}
+	}
+
+	if size, nodes := tree.Size(); size != 0 || nodes != 1 || tree.Height() != 0 {
+		t.Errorf("emptied tree has %d jobs, %d nodes and height %d, want 0, 1 and 0", size, nodes, tree.Height())
+	}
+	tree.Insert(*NewEntry(jobs[0]))
+	if _, ok := tree.JobByID(jobs[0].ID); !ok {
+		t.Errorf("job inserted into an emptied tree not found")
+	}
+	checkShape(t, tree)
+}
+
+func TestDeleteFromClone(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(2)), 200)
+	tree, _ := NewWithEntries(jobs...)
+	clone := tree.Clone()
+	for _, job := range jobs[:150] {
+		clone.Delete(job.ID)
+	}
+
+	if size, _ := tree.Size(); size != len(jobs) {
+		t.Errorf("tree holds %d jobs once deleted from its clone, want %d", size, len(jobs))
+	}
+	checkShape(t, tree)
+	checkShape(t, clone)
+	if _, ok := tree.JobByID(jobs[0].ID); !ok {
+		t.Errorf("job deleted from a clone not found on tree")
+	}
+}
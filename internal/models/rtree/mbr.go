@@ -22,19 +22,31 @@ type mbr struct {
 	minX float64
 }
 
-// arbitrary MBR dimension factor is a random factor
-// to add to a location in order to draw an mbr over the location
-const arbitraryMBRDimFactor = 0.2
-
-// newMBR returns a new minimum bounding rectangle around this location.
+// newMBRAround returns a new minimum bounding rectangle around this location.
 // RTree property:: For each entry in a leaf node, an MBR should exist to spatially contain
-// the 2D location object
+// the 2D location object.
+// Since a location is a point, its mbr is degenerate, i.e., the point itself,
+// so that leaf mbrs exactly bound their locations without inflating overlaps.
 func newMBRAround(location models.Location) mbr {
+	return newMBR(location, location)
+}
+
+// newMBR returns the minimum bounding rectangle of the box
+// having min and max as south-west and north-east corners respectively
+func newMBR(min, max models.Location) mbr {
 	return mbr{
-		maxY: location.Longitude + arbitraryMBRDimFactor,
-		minY: location.Longitude - arbitraryMBRDimFactor,
-		maxX: location.Latitude + arbitraryMBRDimFactor,
-		minX: location.Latitude - arbitraryMBRDimFactor,
+		maxY: max.Longitude,
+		minY: min.Longitude,
+		maxX: max.Latitude,
+		minX: min.Latitude,
+	}
+}
+
+// box returns m as a models.Box
+func (m mbr) box() models.Box {
+	return models.Box{
+		Min: models.Location{Longitude: m.minY, Latitude: m.minX},
+		Max: models.Location{Longitude: m.maxY, Latitude: m.maxX},
 	}
 }
 
@@ -42,6 +54,19 @@ func (m mbr) area() float64 {
 	return math.Abs(m.height() * m.width())
 }
 
+// margin returns half the perimeter of m, which unlike its area
+// tells apart degenerate mbrs, e.g., around collinear locations
+func (m mbr) margin() float64 {
+	return m.height() + m.width()
+}
+
+// enlargement computes the growth of the area and margin of m needed to accommodate child.
+// Unlike a percentage of the area of m, the enlargement of a point or line m is finite.
+func (m mbr) enlargement(child mbr) (area, margin float64) {
+	expanded := m.expandToAccommodate(child)
+	return expanded.area() - m.area(), expanded.margin() - m.margin()
+}
+
 func (m mbr) height() float64 {
 	return m.maxY - m.minY
 }
@@ -51,19 +76,16 @@ func (m mbr) width() float64 {
 }
 
 // overlapsWith checks that m and other overlaps.
+// Rectangles sharing only an edge or a corner overlap,
+// and either rectangle may be a point or a line.
 func (m mbr) overlapsWith(other mbr) bool {
 	// two rectangles do not overlap if
-	// 1. either of the rectangles area is zero
-	if m.area() == 0 || other.area() == 0 {
-		return false
-	}
-
-	// 2. one rectangle is on the left side of the other
+	// 1. one rectangle is on the left side of the other
 	if m.minX > other.maxX || other.minX > m.maxX {
 		return false
 	}
 
-	// 3. one rectangle is above the other
+	// 2. one rectangle is above the other
 	if m.minY > other.maxY || other.minY > m.maxY {
 		return false
 	}
@@ -71,31 +93,18 @@ func (m mbr) overlapsWith(other mbr) bool {
 	return true
 }
 
-// canFitWithin checks if m can fit inside other without expanding other
-func (m mbr) canFitWithin(other mbr) bool {
-	if m.area() < other.area() {
-		return false
-	}
-
-	fitsWithinWidth := other.minX < m.minX && other.maxX > m.maxX
-	fitsWithinHeight := other.minY < m.minY && other.maxY > m.maxY
-
-	if fitsWithinWidth && fitsWithinHeight {
-		return true
-	}
-
-	return false
+// contains checks that location lies within m
+func (m mbr) contains(location models.Location) bool {
+	return location.Latitude >= m.minX && location.Latitude <= m.maxX &&
+		location.Longitude >= m.minY && location.Longitude <= m.maxY
 }
 
-// calculatePercentageExpansion calculates the percentage area expansion
-// needed for m to accommodate child.
-func (m mbr) calculatePercentageExpansion(child mbr) float64 {
-	if child.area() > m.area() {
-		return math.Inf(1)
-	}
-
-	expanded := m.expandToAccommodate(child)
-	return (expanded.area() * 100) / m.area()
+// canFitWithin checks if m can fit inside other without expanding other.
+// m may share edges with other.
+func (m mbr) canFitWithin(other mbr) bool {
+	fitsWithinWidth := other.minX <= m.minX && other.maxX >= m.maxX
+	fitsWithinHeight := other.minY <= m.minY && other.maxY >= m.maxY
+	return fitsWithinWidth && fitsWithinHeight
 }
 
 func (m mbr) expandToAccommodate(child mbr) mbr {
@@ -124,30 +133,3 @@ func (m mbr) expandToAccommodate(child mbr) mbr {
 	}
 	return expanded
 }
-
-func (m mbr) shrinkOnRemoval(child mbr) mbr {
-	// find potential decrease in all directions of this minimum bounding rectangle.
-	// Initialize new expanded mbr to current mbr
-	shrunk := m
-
-	// potential shrink leftwards
-	if child.minX == m.minX {
-		shrunk.minX = shrunk.minX - child.minX
-	}
-
-	// potential expansion rightwards
-	if child.maxX == m.maxX {
-		shrunk.maxX = shrunk.maxX - child.maxX
-	}
-
-	// potential expansion downwards
-	if child.minY == m.minY {
-		shrunk.minY = shrunk.minY - child.minY
-	}
-
-	// potential expansion upwards
-	if child.maxY > m.maxY {
-		shrunk.maxY = shrunk.maxY - child.maxY
-	}
-	return shrunk
-}
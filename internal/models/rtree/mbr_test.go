@@ -0,0 +1,82 @@
+package rtree
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestLeafMBRBoundsPoints(t *testing.T) {
+	tree, _ := NewWithEntries(randomJobs(rand.New(rand.NewSource(9)), 3000)...)
+	leaves := 0
+	var walk func(n *node)
+	walk = func(n *node) {
+		if !n.isLeaf() {
+			for _, child := range n.children {
+				walk(child)
+			}
+			return
+		}
+
+		leaves++
+		locations := make([]models.Location, len(n.entries))
+		for i, e := range n.entries {
+			locations[i] = e.job.Location
+			if e.mbr != newMBR(e.job.Location, e.job.Location) {
+				t.Fatalf("entry of job %q at %v has mbr %+v, want the point itself", e.job.ID, e.job.Location, e.mbr)
+			}
+		}
+		if want := models.BoxAround(locations); n.mbr.box() != want {
+			t.Fatalf("leaf mbr %+v, want exactly %+v bounding its %d points", n.mbr.box(), want, len(locations))
+		}
+	}
+	walk(tree.root)
+	if leaves < 2 {
+		t.Fatalf("tree has %d leaves, want several", leaves)
+	}
+}
+
+// inflatedMBRDimFactor is the size in degrees of the boxes job points used to be wrapped in
+const inflatedMBRDimFactor = 0.2
+
+// BenchmarkPruning compares the nodes visited by searches of a tree of point entries
+// with those of a tree whose entries are wrapped in boxes of inflatedMBRDimFactor degrees
+func BenchmarkPruning(b *testing.B) {
+	jobs := randomJobs(rand.New(rand.NewSource(10)), 100_000)
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	within := models.Distance{Unit: models.Kilometer, Value: 5}
+
+	points, _ := NewWithEntries(jobs...)
+	inflate := func(job models.Job) entry {
+		e := *NewEntry(job)
+		half := inflatedMBRDimFactor / 2
+		e.mbr = newMBR(
+			models.Location{Longitude: job.Location.Longitude - half, Latitude: job.Location.Latitude - half},
+			models.Location{Longitude: job.Location.Longitude + half, Latitude: job.Location.Latitude + half},
+		)
+		return e
+	}
+	inflated := NewWithEntry(inflate(jobs[0]))
+	for _, job := range jobs[1:] {
+		inflated.Insert(inflate(job))
+	}
+
+	for _, bench := range []struct {
+		name string
+		tree *RTree
+	}{
+		{name: "points", tree: points},
+		{name: "inflated", tree: inflated},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			var stats SearchStats
+			ctx := WithSearchStats(context.Background(), &stats)
+			for i := 0; i < b.N; i++ {
+				bench.tree.FindJobs(ctx, within, center, nil, Inclusive)
+			}
+			b.ReportMetric(float64(stats.NodesVisited)/float64(b.N), "nodes/op")
+		})
+	}
+}
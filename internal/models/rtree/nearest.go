@@ -0,0 +1,118 @@
+package rtree
+
+import (
+	"container/heap"
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// NearestInBox finds up to k jobs within the box having min and max as south-west
+// and north-east corners respectively, nearest to center first.
+// Jobs outside the box are never returned, even if nearer to center than jobs in the box.
+// The box crosses the antimeridian if max lies west of min, as with models.Box.
+// Nodes are visited from the nearest to center, skipping nodes lying fully outside the box,
+// until no unvisited node can hold a job nearer than the k-th job found.
+// Equidistant jobs are ordered as with models.SortNearestFirst.
+func (tree *RTree) NearestInBox(center models.Location, k int, min, max models.Location) []models.JobWithDistance {
+	area := newRegion(models.Box{Min: min, Max: max})
+	return tree.nearest(center, k, area.overlapsWith, nil)
+}
+
+// NearestInBoxAfter finds up to k jobs as NearestInBox does, but only jobs following after
+// in the order of models.SortNearestFirst, e.g., the next page of jobs nearest to center.
+// Jobs preceding after are skipped as they are visited, rather than found and sorted.
+func (tree *RTree) NearestInBoxAfter(center models.Location, k int, min, max models.Location, after models.NearestPosition) []models.JobWithDistance {
+	area := newRegion(models.Box{Min: min, Max: max})
+	return tree.nearest(center, k, area.overlapsWith, after.Precedes)
+}
+
+// Nearest finds up to k jobs nearest to center, nearest first.
+// Equidistant jobs are ordered as with models.SortNearestFirst.
+func (tree *RTree) Nearest(center models.Location, k int) []models.JobWithDistance {
+	return tree.nearest(center, k, func(m mbr) bool { return true }, nil)
+}
+
+// NearestAfter finds up to k jobs nearest to center following after in the order
+// of models.SortNearestFirst, e.g., the next page of jobs nearest to center.
+// Jobs preceding after are skipped as they are visited, rather than found and sorted.
+func (tree *RTree) NearestAfter(center models.Location, k int, after models.NearestPosition) []models.JobWithDistance {
+	return tree.nearest(center, k, func(m mbr) bool { return true }, after.Precedes)
+}
+
+// NearestExcept finds up to k jobs nearest to center but the job having id, nearest first,
+// e.g., the neighbors of that job when searched from its location.
+// Equidistant jobs are ordered as with models.SortNearestFirst.
+func (tree *RTree) NearestExcept(center models.Location, k int, id string) []models.JobWithDistance {
+	return tree.nearest(center, k, func(m mbr) bool { return true }, func(job models.JobWithDistance) bool {
+		return job.ID != id
+	})
+}
+
+// nearest finds up to k jobs nearest to center among the entries whose mbr is accepted,
+// in nodes whose mbr is accepted, by a best-first traversal of tree.
+// Jobs are skipped unless kept by keep, if not nil.
+func (tree *RTree) nearest(center models.Location, k int, accept func(m mbr) bool, keep func(job models.JobWithDistance) bool) []models.JobWithDistance {
+	jobs := make([]models.JobWithDistance, 0)
+	if k < 1 || !accept(tree.root.mbr) {
+		return jobs
+	}
+
+	queue := &nearestQueue{{node: tree.root, distance: tree.sphere.DistanceToBox(center, tree.root.mbr.box())}}
+	for queue.Len() != 0 {
+		item := heap.Pop(queue).(nearestItem)
+
+		// every job left is farther than the k jobs found, except for jobs as far as
+		// the k-th job, which are kept so that ties are broken the same way across calls
+		if len(jobs) >= k && item.distance > jobs[k-1].Distance {
+			break
+		}
+
+		if item.entry != nil {
+			jobs = append(jobs, models.JobWithDistance{Job: item.entry.job, Distance: item.distance})
+			continue
+		}
+		for _, e := range item.node.entries {
+			if !accept(e.mbr) {
+				continue
+			}
+			distance := tree.sphere.Distance(center, e.job.Location)
+			if keep == nil || keep(models.JobWithDistance{Job: e.job, Distance: distance}) {
+				heap.Push(queue, nearestItem{entry: e, distance: distance})
+			}
+		}
+		for _, child := range item.node.children {
+			if accept(child.mbr) {
+				heap.Push(queue, nearestItem{node: child, distance: tree.sphere.DistanceToBox(center, child.mbr.box())})
+			}
+		}
+	}
+
+	models.SortNearestFirst(jobs)
+	if len(jobs) > k {
+		jobs = jobs[:k]
+	}
+	return jobs
+}
+
+// nearestItem is a node or an entry queued by nearest,
+// along with its distance, or the least distance of any of its jobs, to the center searched
+type nearestItem struct {
+	node     *node
+	entry    *entry
+	distance float64
+}
+
+// nearestQueue is a min-heap of nearestItem by distance, for use with container/heap
+type nearestQueue []nearestItem
+
+func (q nearestQueue) Len() int           { return len(q) }
+func (q nearestQueue) Less(i, j int) bool { return q[i].distance < q[j].distance }
+func (q nearestQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *nearestQueue) Push(x interface{}) { *q = append(*q, x.(nearestItem)) }
+
+func (q *nearestQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,102 @@
+package rtree
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// nearestIDs returns the IDs of jobs in order
+func nearestIDs(jobs []models.JobWithDistance) []string {
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	return ids
+}
+
+func TestNearestInBox(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(18)), 3000)
+	tree, _ := NewWithEntries(jobs...)
+
+	// center lies outside the box, its nearest jobs all outside it
+	center := models.Location{Longitude: 3.2, Latitude: 6.2}
+	box := models.Box{Min: models.Location{Longitude: 3.5, Latitude: 6.5}, Max: models.Location{Longitude: 3.8, Latitude: 6.9}}
+
+	var inBox []models.Job
+	for _, job := range jobs {
+		if box.Contains(job.Location) {
+			inBox = append(inBox, job)
+		}
+	}
+	for _, k := range []int{1, 10, 100} {
+		want := models.NearestFirst(models.Earth, center, inBox)[:k]
+		got := tree.NearestInBox(center, k, box.Min, box.Max)
+		if !reflect.DeepEqual(nearestIDs(got), nearestIDs(want)) {
+			t.Errorf("found %v nearest in box, want %v", nearestIDs(got), nearestIDs(want))
+		}
+		if nearest := tree.Nearest(center, 1)[0]; box.Contains(nearest.Location) || nearest.Distance >= got[0].Distance {
+			t.Errorf("nearest job %v in box, or no nearer than the %vkm of the nearest in box", nearest.Location, got[0].Distance)
+		}
+	}
+
+	// a box holding fewer than k jobs yields them all
+	small := models.Box{Min: models.Location{Longitude: 3.5, Latitude: 6.5}, Max: models.Location{Longitude: 3.51, Latitude: 6.51}}
+	var inSmall int
+	for _, job := range jobs {
+		if small.Contains(job.Location) {
+			inSmall++
+		}
+	}
+	if got := tree.NearestInBox(center, 50, small.Min, small.Max); len(got) != inSmall {
+		t.Errorf("found %d nearest in a box of %d jobs", len(got), inSmall)
+	}
+}
+
+func TestNearestAfterPages(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(1454)), 500)
+	// co-located jobs are ordered by title, then by ID, across pages
+	for i := 0; i < 41; i++ {
+		jobs = append(jobs, models.Job{
+			ID:       fmt.Sprintf("co-located-%02d", i),
+			Title:    fmt.Sprintf("title %d", i%3),
+			Location: models.Location{Longitude: 3.4, Latitude: 6.4},
+		})
+	}
+	tree, _ := NewWithEntries(jobs...)
+	center := models.Location{Longitude: 3.41, Latitude: 6.4}
+	box := models.Box{Min: models.Location{Longitude: 3.2, Latitude: 6.2}, Max: models.Location{Longitude: 3.7, Latitude: 6.6}}
+
+	for _, size := range []int{1, 3, 7, 100} {
+		for _, inBox := range []bool{false, true} {
+			var want, got []models.JobWithDistance
+			if inBox {
+				want = tree.NearestInBox(center, len(jobs), box.Min, box.Max)
+			} else {
+				want = tree.Nearest(center, len(jobs))
+			}
+
+			page := tree.Nearest(center, size)
+			if inBox {
+				page = tree.NearestInBox(center, size, box.Min, box.Max)
+			}
+			for len(page) > 0 {
+				got = append(got, page...)
+				last := page[len(page)-1]
+				after := models.NearestPosition{Distance: last.Distance, Title: last.Title, ID: last.ID}
+				if inBox {
+					page = tree.NearestInBoxAfter(center, size, box.Min, box.Max, after)
+				} else {
+					page = tree.NearestAfter(center, size, after)
+				}
+			}
+
+			if !reflect.DeepEqual(nearestIDs(got), nearestIDs(want)) {
+				t.Errorf("pages of %d (in box %v): found %d jobs, want the %d of a single search in order", size, inBox, len(got), len(want))
+			}
+		}
+	}
+}
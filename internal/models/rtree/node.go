@@ -3,7 +3,6 @@ package rtree
 import (
 	"errors"
 	"github.com/ercross/grabjobs/internal/models"
-	"github.com/umahmood/haversine"
 )
 
 // the data to be stored inside leaf of a node.
@@ -22,31 +21,40 @@ func NewEntry(from models.Job) *entry {
 	return &entry
 }
 
-// insertIntoAny inserts e into any of first or second node
+// insertIntoAny inserts e into any of first or second node, as chosen by chooseGroup,
 // and expands the node.mbr to which e was inserted.
 // The above implies that one of first or second node will be modified.
 func (e *entry) insertIntoAny(n1, n2 *node) {
+	chooseGroup(n1, n2, e.mbr).insertEntry(*e)
+}
 
-	// insert into node that needs the percentage least expansion
-	n1PercentExp := n1.mbr.calculatePercentageExpansion(e.mbr)
-	n2PercentExp := n2.mbr.calculatePercentageExpansion(e.mbr)
-
-	if n2PercentExp > n1PercentExp {
-		n1.insertEntry(*e)
-	}
-
-	if n1PercentExp > n2PercentExp {
-		n2.insertEntry(*e)
-	}
+// chooseGroup chooses which of the halves n1 and n2 of a split node accommodates toFit:
+// the one needing the least enlargement of its area, then of its margin, then the one with
+// the smallest area, then with the fewest entries or children, following
+// http://www-db.deis.unibo.it/courses/SI-LS/papers/Gut84.pdf section 3.5.2, PickNext.
+// Area enlargements, rather than percentage expansions, let the halves of a split grow
+// from point seeds evenly, instead of any half but a point taking every entry,
+// e.g., when locations are inserted sorted, which left most leaves barely filled.
+func chooseGroup(n1, n2 *node, toFit mbr) *node {
+	area1, margin1 := n1.mbr.enlargement(toFit)
+	area2, margin2 := n2.mbr.enlargement(toFit)
+	switch {
+	case area1 != area2:
+		return pick(area1 < area2, n1, n2)
+	case margin1 != margin2:
+		return pick(margin1 < margin2, n1, n2)
+	case n1.mbr.area() != n2.mbr.area():
+		return pick(n1.mbr.area() < n2.mbr.area(), n1, n2)
+	}
+	return pick(len(n1.entries)+len(n1.children) <= len(n2.entries)+len(n2.children), n1, n2)
+}
 
-	// if percentage expansion is same, insert into that with the smallest area
-	if n1PercentExp == n2PercentExp {
-		if n2.mbr.area() < n1.mbr.area() {
-			n2.insertEntry(*e)
-		} else {
-			n1.insertEntry(*e)
-		}
+// pick returns n1 if first, else n2
+func pick(first bool, n1, n2 *node) *node {
+	if first {
+		return n1
 	}
+	return n2
 }
 
 type node struct {
@@ -61,57 +69,126 @@ type node struct {
 
 	// entries is the spatial data to be stored in this node
 	entries []*entry
+
+	// count is the number of entries stored in the leaves of the subtree rooted at this node,
+	// kept up to date on insertion, split and compaction, so that subtrees are counted
+	// without being visited. The count of a node being split is left stale, as the node is dropped.
+	count int
 }
 
-// fetchJobs fetches jobs found @within radial distance of center location,
-// provided n is a leaf
-func (n *node) fetchJobs(within models.Distance, center models.Location) []models.Job {
+// fetchJobs fetches jobs found @within radial distance of center location on sphere,
+// provided n is a leaf. Jobs exactly @within distance are fetched unless boundary is Exclusive.
+func (n *node) fetchJobs(sphere models.Sphere, within models.Distance, center models.Location, boundary Boundary) []models.Job {
 	jobs := make([]models.Job, 0)
 	if !n.isLeaf() {
 		return []models.Job{}
 	}
 
-	c1 := haversine.Coord{
-		Lat: center.Latitude,
-		Lon: center.Longitude,
-	}
 	for _, entry := range n.entries {
-		c2 := haversine.Coord{
-			Lat: entry.job.Location.Latitude,
-			Lon: entry.job.Location.Longitude,
-		}
-		if d, _ := haversine.Distance(c1, c2); d <= within.Value {
+		if boundary.Contains(sphere.Distance(center, entry.job.Location), within.Kilometers()) {
 			jobs = append(jobs, entry.job)
 		}
 	}
 	return jobs
 }
 
-// snInsertIntoAny inserts n into any of first or second node
-// and expands the node.mbr to which n was inserted.
-// The above implies that one of first or second node will be modified.
-func (n *node) snInsertIntoAny(n1, n2 *node) {
+// fetchJobsRecursive fetches jobs found @within radial distance of center location on sphere
+// from every leaf of the subtree rooted at n, descending only into children whose
+// mbr overlaps the bounding box of the search circle, hence could hold jobs in range.
+// stats counts every node and entry visited. Jobs exactly @within distance are fetched unless boundary is Exclusive.
+func (n *node) fetchJobsRecursive(sphere models.Sphere, within models.Distance, center models.Location, boundary Boundary, stats *SearchStats) []models.Job {
+	stats.NodesVisited++
+	if n.isLeaf() {
+		stats.EntriesVisited += len(n.entries)
+		return n.fetchJobs(sphere, within, center, boundary)
+	}
+
+	area := newSearchRegion(sphere, center, within)
+	jobs := make([]models.Job, 0)
+	for _, child := range n.children {
+		if area.overlapsWith(child.mbr) {
+			jobs = append(jobs, child.fetchJobsRecursive(sphere, within, center, boundary, stats)...)
+		}
+	}
+	return jobs
+}
 
-	// insert into node that needs the percentage least expansion
-	n1PercentExp := n1.mbr.calculatePercentageExpansion(n.mbr)
-	n2PercentExp := n2.mbr.calculatePercentageExpansion(n.mbr)
+// fetchJobsInOverlappingLeaves fetches every job stored in the leaves of the subtree rooted at n
+// whose mbr overlaps area, without checking that the jobs themselves lie within area
+func (n *node) fetchJobsInOverlappingLeaves(area region) []models.Job {
+	if !area.overlapsWith(n.mbr) {
+		return []models.Job{}
+	}
 
-	if n2PercentExp > n1PercentExp {
-		n1.insertChild(n)
+	jobs := make([]models.Job, 0, len(n.entries))
+	for _, e := range n.entries {
+		jobs = append(jobs, e.job)
+	}
+	for _, child := range n.children {
+		jobs = append(jobs, child.fetchJobsInOverlappingLeaves(area)...)
 	}
+	return jobs
+}
 
-	if n1PercentExp > n2PercentExp {
-		n2.insertChild(n)
+// forEachEntry calls fn on every entry stored in the leaves of the subtree rooted at n,
+// until fn returns false. forEachEntry returns false if it was stopped by fn.
+func (n *node) forEachEntry(fn func(e *entry) bool) bool {
+	for _, e := range n.entries {
+		if !fn(e) {
+			return false
+		}
+	}
+	for _, child := range n.children {
+		if !child.forEachEntry(fn) {
+			return false
+		}
 	}
+	return true
+}
+
+// forEachEntryIn calls fn on every entry whose job is located within area,
+// skipping subtrees whose mbr does not overlap area, until fn returns false.
+// forEachEntryIn returns false if it was stopped by fn.
+func (n *node) forEachEntryIn(area region, fn func(e *entry) bool) bool {
+	if !area.overlapsWith(n.mbr) {
+		return true
+	}
+	for _, e := range n.entries {
+		if area.contains(e.job.Location) && !fn(e) {
+			return false
+		}
+	}
+	for _, child := range n.children {
+		if !child.forEachEntryIn(area, fn) {
+			return false
+		}
+	}
+	return true
+}
 
-	// if percentage expansion is same, insert into that with the smallest area
-	if n1PercentExp == n2PercentExp {
-		if n2.mbr.area() < n1.mbr.area() {
-			n2.insertChild(n)
-		} else {
-			n1.insertChild(n)
+// clone returns a copy of the subtree rooted at n, attached to parent.
+// The entries of n are shared with the copy, as entries are never modified once stored.
+func (n *node) clone(parent *node) *node {
+	c := &node{
+		mbr:     n.mbr,
+		parent:  parent,
+		entries: append([]*entry(nil), n.entries...),
+		count:   n.count,
+	}
+	if len(n.children) != 0 {
+		c.children = make([]*node, len(n.children))
+		for i, child := range n.children {
+			c.children[i] = child.clone(c)
 		}
 	}
+	return c
+}
+
+// snInsertIntoAny inserts n into any of first or second node, as chosen by chooseGroup,
+// and expands the node.mbr to which n was inserted.
+// The above implies that one of first or second node will be modified.
+func (n *node) snInsertIntoAny(n1, n2 *node) {
+	chooseGroup(n1, n2, n.mbr).insertChild(n)
 }
 
 // hasEntrySpace checks that this node has enough space to store one more entry,
@@ -128,8 +205,13 @@ func (n *node) hasNodeSpace() bool {
 
 // insertEntry inserts e into n, expanding n.mbr to accommodate e.mbr as well
 func (n *node) insertEntry(e entry) {
+	if n.isEmpty() {
+		n.mbr = e.mbr
+	} else {
+		n.mbr = n.mbr.expandToAccommodate(e.mbr)
+	}
 	n.entries = append(n.entries, &e)
-	n.mbr = n.mbr.expandToAccommodate(e.mbr)
+	n.count++
 }
 
 func (n *node) insertMultipleChildren(children ...*node) {
@@ -153,12 +235,23 @@ func (n *node) insertMultipleEntry(entries ...*entry) {
 }
 
 func (n *node) insertChild(child *node) {
+	if n.isEmpty() {
+		n.mbr = child.mbr
+	} else {
+		n.mbr = n.mbr.expandToAccommodate(child.mbr)
+	}
 	child.parent = n
 	n.children = append(n.children, child)
-	n.mbr = n.mbr.expandToAccommodate(child.mbr)
+	n.count += child.count
 }
 
-// removeChild node from n.children if found and shrinks n.mbr.
+// isEmpty checks that n has neither entries nor children, in which case
+// n.mbr bounds nothing and must not be expanded, lest it bounds the zero mbr too
+func (n *node) isEmpty() bool {
+	return len(n.entries) == 0 && len(n.children) == 0
+}
+
+// removeChild node from n.children if found and shrinks n.mbr to fit the remaining children.
 // On return from removeChild, the invoking code may check that
 // len(n.children) >= minEntriesPerNode. If this check fails,
 // shrink tree if necessary.
@@ -175,7 +268,38 @@ func (n *node) removeChild(child *node) {
 
 	// detach child from n
 	child.parent = nil
-	n.mbr = n.mbr.shrinkOnRemoval(child.mbr)
+	n.fitMBR()
+	n.fitCount()
+}
+
+// fitMBR shrinks or expands n.mbr to the minimum bounding rectangle
+// of n.children, or n.entries if n is a leaf
+func (n *node) fitMBR() {
+	var fitted mbr
+	for i, child := range n.children {
+		if i == 0 {
+			fitted = child.mbr
+			continue
+		}
+		fitted = fitted.expandToAccommodate(child.mbr)
+	}
+	for i, e := range n.entries {
+		if i == 0 {
+			fitted = e.mbr
+			continue
+		}
+		fitted = fitted.expandToAccommodate(e.mbr)
+	}
+	n.mbr = fitted
+}
+
+// fitCount sets n.count to the number of entries of n if n is a leaf,
+// else to the sum of the counts of n.children
+func (n *node) fitCount() {
+	n.count = len(n.entries)
+	for _, child := range n.children {
+		n.count += child.count
+	}
 }
 
 // splitLeaf node that fails node.hasEntrySpace test on addition of new entry e
@@ -186,18 +310,20 @@ func (n *node) splitLeaf(e entry) (n1, n2 *node) {
 	n.insertEntry(e)
 	s1, s2 := n.linearPickSeed()
 	n1, n2 = new(node), new(node)
-	n1.entries = append(n1.entries, s1)
-	n2.entries = append(n2.entries, s2)
+	n1.insertEntry(*s1)
+	n2.insertEntry(*s2)
 	for len(n.entries) != 0 {
 
-		// if any of first or second leaf node have less entries than minEntriesPerLeaf
-		// and the n.entries is almost exhausted
-		if len(n.entries) < minEntriesPerLeaf && len(n1.entries) < minEntriesPerLeaf && len(n2.entries) > minEntriesPerLeaf {
+		// if any of first or second leaf node needs every entry left
+		// to have minEntriesPerLeaf entries, it takes them all
+		if len(n1.entries)+len(n.entries) <= minEntriesPerLeaf {
 			n1.insertMultipleEntry(n.entries...)
+			n.entries = nil
 			break
 		}
-		if len(n.entries) < minEntriesPerLeaf && len(n2.entries) < minEntriesPerLeaf && len(n1.entries) > minEntriesPerLeaf {
+		if len(n2.entries)+len(n.entries) <= minEntriesPerLeaf {
 			n2.insertMultipleEntry(n.entries...)
+			n.entries = nil
 			break
 		}
 
@@ -217,18 +343,20 @@ func (n *node) splitNode(child *node) (n1, n2 *node) {
 	n.insertChild(child)
 	n1, n2 = new(node), new(node)
 	s1, s2 := n.snLinearPickSeed()
-	n1.children = append(n1.children, s1)
-	n2.children = append(n2.children, s2)
+	n1.insertChild(s1)
+	n2.insertChild(s2)
 	for len(n.children) != 0 {
 
-		// if any of first or second node have fewer children than minEntriesPerLeaf
-		// and the n.children is almost exhausted
-		if len(n.children) < minEntriesPerLeaf && len(n1.children) < minEntriesPerLeaf && len(n2.children) > minEntriesPerLeaf {
+		// if any of first or second node needs every child left
+		// to have minEntriesPerLeaf children, it takes them all
+		if len(n1.children)+len(n.children) <= minEntriesPerLeaf {
 			n1.insertMultipleChildren(n.children...)
+			n.children = nil
 			break
 		}
-		if len(n.children) < minEntriesPerLeaf && len(n2.children) < minEntriesPerLeaf && len(n1.children) > minEntriesPerLeaf {
+		if len(n2.children)+len(n.children) <= minEntriesPerLeaf {
 			n2.insertMultipleChildren(n.children...)
+			n.children = nil
 			break
 		}
 
@@ -243,10 +371,6 @@ func (n *node) splitNode(child *node) (n1, n2 *node) {
 
 // pickNext removes and returns next entry from n.entries
 func (n *node) pickNext() *entry {
-	if len(n.entries) == 1 {
-		return n.entries[0]
-	}
-
 	e := n.entries[0]
 	n.entries = n.entries[1:]
 	return e
@@ -254,10 +378,6 @@ func (n *node) pickNext() *entry {
 
 // snPickNext removes and returns next child from n.children
 func (n *node) snPickNext() *node {
-	if len(n.children) == 1 {
-		return n.children[0]
-	}
-
 	e := n.children[0]
 	n.children = n.children[1:]
 	return e
@@ -267,7 +387,9 @@ func (n *node) snPickNext() *node {
 // but unlike linearPickSeed, it works for splitting non-leaf node
 func (n *node) snLinearPickSeed() (s1, s2 *node) {
 	if len(n.children) == 2 {
-		return n.children[0], n.children[1]
+		s1, s2 = n.children[0], n.children[1]
+		n.children = nil
+		return s1, s2
 	}
 
 	// find one node for each side that has its
@@ -317,11 +439,15 @@ func (n *node) snLinearPickSeed() (s1, s2 *node) {
 	normalizedSepAlongX := sepAlongX / n.mbr.width()
 
 	if normalizedSepAlongY > normalizedSepAlongX {
+		downmostIndex = distinctSeedIndex(upmostIndex, downmostIndex)
+		s1, s2 = upmost, n.children[downmostIndex]
 		n.removeChildren(upmostIndex, downmostIndex)
-		return upmost, downmost
+		return s1, s2
 	} else {
+		leftmostIndex = distinctSeedIndex(rightmostIndex, leftmostIndex)
+		s1, s2 = rightmost, n.children[leftmostIndex]
 		n.removeChildren(leftmostIndex, rightmostIndex)
-		return rightmost, leftmost
+		return s1, s2
 	}
 }
 
@@ -331,7 +457,9 @@ func (n *node) snLinearPickSeed() (s1, s2 *node) {
 func (n *node) linearPickSeed() (s1, s2 *entry) {
 
 	if len(n.entries) == 2 {
-		return n.entries[0], n.entries[1]
+		s1, s2 = n.entries[0], n.entries[1]
+		n.entries = nil
+		return s1, s2
 	}
 
 	// find one entry for each side that has its
@@ -381,11 +509,15 @@ func (n *node) linearPickSeed() (s1, s2 *entry) {
 	normalizedSepAlongX := sepAlongX / n.mbr.width()
 
 	if normalizedSepAlongY > normalizedSepAlongX {
+		downmostIndex = distinctSeedIndex(upmostIndex, downmostIndex)
+		s1, s2 = upmost, n.entries[downmostIndex]
 		n.removeEntries(upmostIndex, downmostIndex)
-		return upmost, downmost
+		return s1, s2
 	} else {
+		leftmostIndex = distinctSeedIndex(rightmostIndex, leftmostIndex)
+		s1, s2 = rightmost, n.entries[leftmostIndex]
 		n.removeEntries(leftmostIndex, rightmostIndex)
-		return rightmost, leftmost
+		return s1, s2
 	}
 }
 
@@ -393,10 +525,8 @@ func (n *node) linearPickSeed() (s1, s2 *entry) {
 func (n *node) removeEntries(i1, i2 int) {
 	n.entries = append(n.entries[:i1], n.entries[i1+1:]...)
 
-	// shift index of i2
-	if i1 > i2 {
-		i2 += 1
-	} else {
+	// shift index of i2 if it comes after the removed i1
+	if i2 > i1 {
 		i2 -= 1
 	}
 
@@ -407,16 +537,28 @@ func (n *node) removeEntries(i1, i2 int) {
 func (n *node) removeChildren(i1, i2 int) {
 	n.children = append(n.children[:i1], n.children[i1+1:]...)
 
-	// shift index of i2
-	if i1 > i2 {
-		i2 += 1
-	} else {
+	// shift index of i2 if it comes after the removed i1
+	if i2 > i1 {
 		i2 -= 1
 	}
 
 	n.children = append(n.children[:i2], n.children[i2+1:]...)
 }
 
+// distinctSeedIndex returns i2 if it differs from i1,
+// otherwise the first index other than i1.
+// It guards the pick-seed algorithms against picking the same
+// entry/child as both seeds when it is the extreme on both sides.
+func distinctSeedIndex(i1, i2 int) int {
+	if i1 != i2 {
+		return i2
+	}
+	if i1 == 0 {
+		return 1
+	}
+	return 0
+}
+
 // isLeaf checks that node is a leaf.
 // RTree property:: All leaves appear on the same level
 func (n *node) isLeaf() bool {
@@ -437,29 +579,23 @@ func (n *node) isParentToLeafNode() bool {
 }
 
 // findMBRNeedingLeastExpansion finds the child node that its mbr needs the least
-// expansion toAccommodate new entry.
+// expansion toAccommodate new entry, i.e., the least enlargement of its area as in ChooseLeaf of
+// http://www-db.deis.unibo.it/courses/SI-LS/papers/Gut84.pdf section 3.2.
+// As with chooseGroup, ties are broken by the least enlargement of the margin, which tells apart
+// the point and line mbrs having no area, then by the smallest area, then by the first of children,
+// so that the same child is chosen for the same children.
 // If len(children) == 0, program panics as this is a programmer error
 func findMBRNeedingLeastExpansion(toAccommodate entry, children []*node) *node {
 	if len(children) == 0 {
 		panic(errors.New("zero length children passed to findMBRNeedingLeastExpansion"))
 	}
-	leastExpansionPercentage := 0.00
 	leastExpansion := children[0]
-	for _, child := range children {
-		expansionPercentage := child.mbr.calculatePercentageExpansion(toAccommodate.mbr)
-
-		if expansionPercentage < leastExpansionPercentage {
-			leastExpansionPercentage = expansionPercentage
-			leastExpansion = child
-			continue
-		}
-
-		// if any two nodes can have same leastExpansionPercentage,
-		// pick the one with the smallest area
-		if expansionPercentage == leastExpansionPercentage {
-			if child.mbr.area() < leastExpansion.mbr.area() {
-				leastExpansion = child
-			}
+	leastArea, leastMargin := leastExpansion.mbr.enlargement(toAccommodate.mbr)
+	for _, child := range children[1:] {
+		area, margin := child.mbr.enlargement(toAccommodate.mbr)
+		if area < leastArea || area == leastArea &&
+			(margin < leastMargin || margin == leastMargin && child.mbr.area() < leastExpansion.mbr.area()) {
+			leastExpansion, leastArea, leastMargin = child, area, margin
 		}
 	}
 
@@ -0,0 +1,102 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// nodeAround returns a node bounded by the box having min and max as south-west and north-east corners
+func nodeAround(minLon, minLat, maxLon, maxLat float64) *node {
+	return &node{mbr: newMBR(
+		models.Location{Longitude: minLon, Latitude: minLat},
+		models.Location{Longitude: maxLon, Latitude: maxLat},
+	)}
+}
+
+func TestFindMBRNeedingLeastExpansion(t *testing.T) {
+	at := func(lon, lat float64) entry {
+		return *NewEntry(models.Job{Location: models.Location{Longitude: lon, Latitude: lat}})
+	}
+	tests := []struct {
+		name     string
+		toFit    entry
+		children []*node
+		want     int
+	}{
+		{
+			// the large node is enlarged by 5 units, 5% of its area, and the small node by 0.5 units,
+			// 50% of its area, hence the small node is chosen as needing the least enlargement
+			name:     "least absolute enlargement",
+			toFit:    at(1.5, 0.5),
+			children: []*node{nodeAround(2, -5, 12, 5), nodeAround(0, 0, 1, 1)},
+			want:     1,
+		},
+		{
+			name:     "fitting without enlargement",
+			toFit:    at(5, 5),
+			children: []*node{nodeAround(6, 6, 7, 7), nodeAround(0, 0, 10, 10)},
+			want:     1,
+		},
+		{
+			name:     "tie broken by the smallest area",
+			toFit:    at(1, 1),
+			children: []*node{nodeAround(0, 0, 10, 10), nodeAround(0, 0, 2, 2)},
+			want:     1,
+		},
+		{
+			name:     "tie of points broken by the least margin enlargement",
+			toFit:    at(1, 1),
+			children: []*node{nodeAround(5, 5, 5, 5), nodeAround(2, 2, 2, 2)},
+			want:     1,
+		},
+		{
+			name:     "full tie broken by the first child",
+			toFit:    at(1, 1),
+			children: []*node{nodeAround(0, 0, 2, 2), nodeAround(0, 0, 2, 2)},
+			want:     0,
+		},
+	}
+	for _, test := range tests {
+		if got := findMBRNeedingLeastExpansion(test.toFit, test.children); got != test.children[test.want] {
+			t.Errorf("%s: chose another child than child %d", test.name, test.want)
+		}
+	}
+}
+
+func TestFetchJobsRecursive(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(15)), 5000)
+	tree, _ := NewWithEntries(jobs...)
+	if tree.Height() < 2 {
+		t.Fatalf("tree of height %d, want several levels", tree.Height())
+	}
+
+	for _, test := range []struct {
+		center models.Location
+		km     float64
+	}{
+		{center: models.Location{Longitude: 3.5, Latitude: 6.5}, km: 10},
+		{center: models.Location{Longitude: 3.01, Latitude: 6.99}, km: 25},
+		{center: models.Location{Longitude: 3.3, Latitude: 6.6}, km: 0.5},
+		{center: models.Location{Longitude: 5, Latitude: 8}, km: 10},
+	} {
+		within := models.Distance{Unit: models.Kilometer, Value: test.km}
+		var stats SearchStats
+		found := tree.root.fetchJobsRecursive(models.Earth, within, test.center, Inclusive, &stats)
+
+		want := make([]models.Job, 0)
+		for _, job := range jobs {
+			if models.Earth.Distance(test.center, job.Location) <= test.km {
+				want = append(want, job)
+			}
+		}
+		if got, want := idsOfJobs(found), idsOfJobs(want); !reflect.DeepEqual(got, want) {
+			t.Errorf("found %d jobs within %vkm of %v across leaves, want %d", len(got), test.km, test.center, len(want))
+		}
+		if _, nodes := tree.Size(); stats.NodesVisited >= nodes {
+			t.Errorf("visited %d of %d nodes within %vkm of %v, want subtrees out of range skipped", stats.NodesVisited, nodes, test.km, test.center)
+		}
+	}
+}
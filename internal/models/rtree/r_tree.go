@@ -1,10 +1,15 @@
 package rtree
 
 import (
+	"context"
 	"errors"
 	"github.com/ercross/grabjobs/internal/models"
-	"github.com/umahmood/haversine"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"math"
+	"runtime"
+	"sort"
+	"sync"
 )
 
 // maxEntriesPerLeaf is the maximum branching factor
@@ -33,6 +38,24 @@ type RTree struct {
 	indexCount int
 	totalNodes int
 
+	// leafSplits and nodeSplits count how many times a leaf
+	// and a non-leaf node were split respectively.
+	// Frequent splits hint at a degrading tree.
+	leafSplits int
+	nodeSplits int
+
+	// searchWorkers is the number of goroutines FindJobs filters jobs with.
+	// Zero means runtime.GOMAXPROCS
+	searchWorkers int
+
+	// sphere is the sphere distances between jobs are computed on.
+	// The zero sphere is the earth
+	sphere models.Sphere
+
+	// ids maps the ID of each job indexed to its entry,
+	// for constant time lookup of a job by ID
+	ids map[string]*entry
+
 	// root node may be a leaf if it's the only node on the tree.
 	// RTree property:: If root is not a leaf, then it must have at least 2 children.
 	// RTree property:: If root is a leaf, it can contain any number of entries less than maxEntriesPerLeaf
@@ -44,6 +67,7 @@ func NewWithEntry(e entry) *RTree {
 	leaf := &node{
 		mbr:     newMBRAround(e.job.Location),
 		entries: []*entry{&e},
+		count:   1,
 	}
 
 	tree := RTree{
@@ -51,7 +75,9 @@ func NewWithEntry(e entry) *RTree {
 		indexCount: 1,
 		totalNodes: 1,
 		root:       leaf,
+		ids:        make(map[string]*entry),
 	}
+	tree.addID(leaf.entries[0])
 	return &tree
 }
 
@@ -68,8 +94,19 @@ func NewWithEntries(entries ...models.Job) (*RTree, error) {
 	return tree, nil
 }
 
-// FindJobs finds job within radial distance of center location.
-func (tree *RTree) FindJobs(within models.Distance, center models.Location, d map[string][]models.Job) []models.Job {
+// tracerName identifies the spans started by RTree
+const tracerName = "github.com/ercross/grabjobs/internal/models/rtree"
+
+// cancellationCheckInterval is the number of jobs a search goes through
+// between checks that its context is done
+const cancellationCheckInterval = 1024
+
+// FindJobs finds job within radial distance of center location,
+// including jobs exactly at the distance unless boundary is Exclusive.
+// FindJobs stops early, returning the jobs found so far, once ctx is done.
+// If ctx is traced, FindJobs records a span with the number of nodes visited,
+// and if ctx carries SearchStats, FindJobs adds its counts to them.
+func (tree *RTree) FindJobs(ctx context.Context, within models.Distance, center models.Location, d map[string][]models.Job, boundary Boundary) []models.Job {
 	// *********** Current implementation *************
 	// FindJobs fetches all entries that fall in ancestral/sibling relationship with center on the tree,
 	// iterate through each entry to get the haversine distance.
@@ -82,39 +119,257 @@ func (tree *RTree) FindJobs(within models.Distance, center models.Location, d ma
 	// (ref: https://en.wikipedia.org/wiki/Vincenty%27s_formulae).
 	// The resulting circle is tightly fitted inside a mbr,
 	// and the mbr is used to query tree
+	//
+	// The tree is descended only through nodes overlapping the bounding box of the search circle.
+	// If the box covers the whole tree, no node can be skipped, hence all jobs are scanned
+	// across workers instead.
+
+	_, span := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName).Start(ctx, "RTree.FindJobs")
+	defer span.End()
+
+	if !newSearchRegion(tree.sphere, center, within).covers(tree.root.mbr) {
+		var stats SearchStats
+		jobs := tree.root.fetchJobsRecursive(tree.sphere, within, center, boundary, &stats)
+		span.SetAttributes(attribute.Int("rtree.nodes_visited", stats.NodesVisited), attribute.Int("rtree.jobs_found", len(jobs)))
+		searchStatsFrom(ctx).add(stats)
+		return jobs
+	}
+	jobs := search(ctx, tree.sphere, within, center, boundary, d, tree.workers())
+	span.SetAttributes(attribute.Bool("rtree.full_scan", true), attribute.Int("rtree.jobs_found", len(jobs)))
+	searchStatsFrom(ctx).add(SearchStats{EntriesVisited: tree.indexCount, FullScan: true})
+	return jobs
+}
 
-	job := models.Job{
-		Title:    "",
-		Location: center,
+// FindJobsApproximately finds jobs possibly within radial distance of center location,
+// trading precision for speed. Only nodes are pruned, by their mbr overlapping the
+// bounding box of the search circle, while jobs are not checked for their distance to center,
+// hence every job within distance is found along with any other job sharing its leaf,
+// which may lie well outside the circle.
+func (tree *RTree) FindJobsApproximately(within models.Distance, center models.Location) []models.Job {
+	return tree.root.fetchJobsInOverlappingLeaves(newSearchRegion(tree.sphere, center, within))
+}
+
+// SetSearchWorkers sets the number of goroutines FindJobs filters jobs with
+// when searching many jobs. Zero or less means runtime.GOMAXPROCS.
+// SetSearchWorkers must be called before tree is searched.
+func (tree *RTree) SetSearchWorkers(workers int) {
+	tree.searchWorkers = workers
+}
+
+// SetSphere sets the sphere tree computes distances between jobs on,
+// e.g., to search a game world rather than the earth.
+// SetSphere must be called before tree is searched.
+func (tree *RTree) SetSphere(sphere models.Sphere) {
+	tree.sphere = sphere
+}
+
+func (tree *RTree) workers() int {
+	if tree.searchWorkers <= 0 {
+		return runtime.GOMAXPROCS(0)
 	}
-	entry := NewEntry(job)
-	return search(within, center, d)
+	return tree.searchWorkers
+}
+
+// FindJobsAmong finds jobs in candidates within radial distance of center location.
+// Candidates falling outside the bounding box of the search circle are skipped
+// without computing their haversine distance, which makes FindJobsAmong
+// suitable for small candidate sets such as jobs of a rare title.
+// Jobs exactly at the distance are found unless boundary is Exclusive.
+func (tree *RTree) FindJobsAmong(within models.Distance, center models.Location, candidates []models.Job, boundary Boundary) []models.Job {
 	jobs := make([]models.Job, 0)
-	if !entry.mbr.canFitWithin(tree.root.mbr) {
-		return jobs
+	box := tree.sphere.SearchBox(center, within)
+	for _, j := range candidates {
+		if !box.Contains(j.Location) {
+			continue
+		}
+		if boundary.Contains(tree.sphere.Distance(center, j.Location), within.Kilometers()) {
+			jobs = append(jobs, j)
+		}
 	}
+	return jobs
+}
 
-	if tree.root.isLeaf() {
-		return tree.root.fetchJobs(within, center)
+// FindJobsNearAny finds jobs within radial distance of any of centers.
+// A job within radius of more than one center is returned once, annotated with
+// its distance to the nearest center. Jobs are ordered from the nearest.
+// The tree is descended only through nodes overlapping the bounding box of the search circle
+// around any of centers, as with FindJobs.
+// Jobs exactly at radius of their nearest center are found unless boundary is Exclusive.
+// FindJobsNearAny stops early, returning the jobs found so far, once ctx is done.
+func (tree *RTree) FindJobsNearAny(ctx context.Context, centers []models.Location, radius models.Distance, boundary Boundary) []models.JobWithDistance {
+	jobs := make([]models.JobWithDistance, 0)
+
+	area := make(region, 0, len(centers))
+	for _, center := range centers {
+		area = append(area, newSearchRegion(tree.sphere, center, radius)...)
 	}
-	return search(within, center, d)
+	visited := 0
+	tree.root.forEachEntryIn(area, func(e *entry) bool {
+		visited++
+		if visited%cancellationCheckInterval == 0 && ctx.Err() != nil {
+			return false
+		}
+
+		nearest := math.Inf(1)
+		for _, center := range centers {
+			nearest = math.Min(nearest, tree.sphere.Distance(center, e.job.Location))
+		}
+		if boundary.Contains(nearest, radius.Kilometers()) {
+			jobs = append(jobs, models.JobWithDistance{Job: e.job, Distance: nearest})
+		}
+		return true
+	})
+
+	models.SortNearestFirst(jobs)
+	return jobs
+}
+
+// EntryView is a read-only view of an entry on the tree
+type EntryView struct {
+	Job models.Job `json:"job"`
+
+	// MBR is the minimum bounding rectangle of the entry on the tree
+	MBR models.Box `json:"mbr"`
+}
+
+// FindEntriesInBox finds entries whose job is located within the box
+// having min and max as south-west and north-east corners respectively.
+// The box crosses the antimeridian if max lies west of min, as with models.Box.
+func (tree *RTree) FindEntriesInBox(min, max models.Location) []EntryView {
+	entries := make([]EntryView, 0)
+	tree.root.forEachEntryIn(newRegion(models.Box{Min: min, Max: max}), func(e *entry) bool {
+		entries = append(entries, EntryView{Job: e.job, MBR: e.mbr.box()})
+		return true
+	})
+	return entries
+}
+
+// ForEachInBox calls fn on every job located within the box having min and max
+// as south-west and north-east corners respectively, until fn returns false.
+// Unlike FindEntriesInBox, no result is allocated, e.g., to count jobs.
+// The box crosses the antimeridian if max lies west of min, as with models.Box.
+func (tree *RTree) ForEachInBox(min, max models.Location, fn func(job models.Job) bool) {
+	tree.root.forEachEntryIn(newRegion(models.Box{Min: min, Max: max}), func(e *entry) bool {
+		return fn(e.job)
+	})
+}
+
+// maxHotspotCandidates is the largest number of job locations Hotspot
+// counts neighbours around. Larger trees are sampled at regular intervals.
+const maxHotspotCandidates = 2000
+
+// Hotspot finds the job location having the most other jobs within radius,
+// querying tree around each job location, or around a regularly sampled subset
+// of at most maxHotspotCandidates locations on larger trees.
+// Ties are broken by the first location counted.
+// Hotspot stops early, returning the hotspot found so far, once ctx is done.
+func (tree *RTree) Hotspot(ctx context.Context, radius models.Distance) models.Hotspot {
+	stride := (tree.indexCount + maxHotspotCandidates - 1) / maxHotspotCandidates
+	hotspot := models.Hotspot{Count: -1}
+	visited := 0
+	tree.root.forEachEntry(func(candidate *entry) bool {
+		visited++
+		if (visited-1)%stride != 0 {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+
+		center := candidate.job.Location
+		count := 0
+		tree.root.forEachEntryIn(newSearchRegion(tree.sphere, center, radius), func(e *entry) bool {
+			if e != candidate && tree.sphere.Distance(center, e.job.Location) <= radius.Kilometers() {
+				count++
+			}
+			return true
+		})
+		if count > hotspot.Count {
+			hotspot = models.Hotspot{Location: center, Count: count}
+		}
+		return true
+	})
+	return hotspot
 }
 
 // Insert a new job into the tree.
 // New index records are added at the leaves and nodes that overflow(i.e., len(node.children)>M) are splitLeaf.
 func (tree *RTree) Insert(e entry) {
+	tree.addID(&e)
 	leaf := tree.chooseLeaf(e)
+	tree.indexCount++
 	if leaf.hasEntrySpace() {
 		leaf.insertEntry(e)
 		tree.adjustParentOf(leaf)
 	} else {
 		l1, l2 := leaf.splitLeaf(e)
+		tree.leafSplits++
 		tree.adjustParentOnSplitOf(leaf, l1, l2)
 	}
 }
 
-func (tree *RTree) grow() {
+// Clone returns a copy of tree which jobs may be inserted into while tree is searched,
+// e.g., to index a few more jobs without building a new tree.
+// Nodes are copied, whereas entries, never modified once indexed, are shared with tree.
+func (tree *RTree) Clone() *RTree {
+	clone := *tree
+	clone.root = tree.root.clone(nil)
+	clone.ids = make(map[string]*entry, len(tree.ids))
+	for id, e := range tree.ids {
+		clone.ids[id] = e
+	}
+	return &clone
+}
+
+// addID records e for lookup with JobByID.
+// Jobs without an ID are not recorded.
+func (tree *RTree) addID(e *entry) {
+	if e.job.ID != "" {
+		tree.ids[e.job.ID] = e
+	}
+}
+
+// JobByID finds the job indexed with id.
+// ok is false if no job on tree has id.
+func (tree *RTree) JobByID(id string) (job models.Job, ok bool) {
+	e, ok := tree.ids[id]
+	if !ok {
+		return job, false
+	}
+	return e.job, true
+}
+
+// Height returns the depth of the leaves of tree, root being at height 0 (zero)
+func (tree *RTree) Height() int {
+	return tree.height
+}
+
+// Size returns the number of jobs indexed and nodes making up tree
+func (tree *RTree) Size() (jobs, nodes int) {
+	return tree.indexCount, tree.totalNodes
+}
+
+// Splits returns the number of leaf and non-leaf node splits
+// done on tree since it was created
+func (tree *RTree) Splits() (leafSplits, nodeSplits int) {
+	return tree.leafSplits, tree.nodeSplits
+}
+
+// grow adds a level to tree by making the two halves n1 and n2 of the
+// split root the children of root. grow must be called exactly once per root split,
+// being the only way a new level is added to tree, which keeps
+// tree.height equal to the depth of every leaf (RTree property:: All leaves appear on the same level).
+func (tree *RTree) grow(n1, n2 *node) {
+	root := tree.root
+	root.entries = nil
+	root.children = nil
+	root.count = 0
+	root.insertChild(n1)
+	root.insertChild(n2)
 	tree.height += 1
+
+	// root is kept, n1 and n2 are new
+	tree.totalNodes += 2
 }
 
 // adjustParentOnSplitOf adjusts tree when there is a split on potential
@@ -126,43 +381,47 @@ func (tree *RTree) adjustParentOnSplitOf(node, n1, n2 *node) {
 
 	// If node is root, set n1, n2 as its children and grow the tree.
 	// Then, len(tree.root.children) will be lesser than minEntriesPerNode.
-	// This is allowed for root node i.e., root can have children lesser than minEntriesPerNode
+	// This is allowed for root node i.e., root can have children lesser than minEntriesPerNode.
+	// A split cascading up from a leaf reaches this branch at most once,
+	// hence the tree grows by exactly one level per root split.
 	if node == tree.root {
-		n1.parent = node
-		n2.parent = node
-		node.insertChild(n1)
-		node.insertChild(n2)
-		node.entries = nil
-		tree.root = node
-		tree.grow()
+		tree.grow(n1, n2)
 		return
 	}
 
+	// n1 and n2 replace node
+	tree.totalNodes++
+
 	// remove node from parent node.children, then add n1 and n2 as new children
 	parent := node.parent
 
 	// make space for n1 below. It's not necessary to
 	// shrink tree here as suggested by node.removeChild
 	// because an insertion follows immediately
+	// removeChild recounts parent, dropping the count of node left stale by its split
 	parent.removeChild(node)
 	parent.insertChild(n1)
 	// check that parent can take one more node, else split parent
 	if parent.hasNodeSpace() {
 		parent.insertChild(n2)
+		tree.adjustParentOf(parent)
 	} else {
 		n1, n2 := parent.splitNode(n2)
+		tree.nodeSplits++
 		tree.adjustParentOnSplitOf(parent, n1, n2)
 	}
 }
 
 // adjustParentOf adjusts the tree if no split was done after inserting a new entry
+// into the subtree rooted at node, node itself being already adjusted
 func (tree *RTree) adjustParentOf(node *node) {
 	if node == tree.root {
 		return
 	}
 
-	// update the dimension of all ancestor nodes' mbr
+	// update the dimension of all ancestor nodes' mbr, and their count
 	node.parent.mbr = node.parent.mbr.expandToAccommodate(node.mbr)
+	node.parent.count++
 	tree.adjustParentOf(node.parent)
 }
 
@@ -170,8 +429,8 @@ func (tree *RTree) adjustParentOf(node *node) {
 // Note that the resulting leaf can be root
 func (tree *RTree) chooseLeaf(e entry) *node {
 
-	// if root is leaf, choose root
-	if tree.root.isLeaf() {
+	// if root is leaf, or empty once every job was deleted, choose root
+	if tree.root.isLeaf() || tree.root.isEmpty() {
 		return tree.root
 	}
 
@@ -197,7 +456,7 @@ func (tree *RTree) walkDown(toFit entry, currentPosition *node) *node {
 	overlaps := make([]*node, 0)
 	for _, child := range currentPosition.children {
 		if toFit.mbr.canFitWithin(child.mbr) {
-			return child
+			return tree.walkDown(toFit, child)
 		}
 
 		if child.mbr.overlapsWith(toFit.mbr) {
@@ -209,7 +468,8 @@ func (tree *RTree) walkDown(toFit entry, currentPosition *node) *node {
 	// if no children.mbr of currentPosition overlaps with toFit,
 	// return child needing the least expansion
 	if len(overlaps) == 0 {
-		return findMBRNeedingLeastExpansion(toFit, currentPosition.children)
+		leastExpansion := findMBRNeedingLeastExpansion(toFit, currentPosition.children)
+		return tree.walkDown(toFit, leastExpansion)
 	}
 
 	leastExpansion := findMBRNeedingLeastExpansion(toFit, overlaps)
@@ -235,19 +495,97 @@ func (tree *RTree) satisfiesMaximumNodesConstraint() bool {
 	return float64(tree.totalNodes) <= constraintFactor
 }
 
-func search(within models.Distance, center models.Location, d map[string][]models.Job) []models.Job {
-	jobs := make([]models.Job, 0)
-	c1 := haversine.Coord{
-		Lat: center.Latitude,
-		Lon: center.Longitude,
+// parallelSearchThreshold is the least number of jobs search
+// spreads across workers. Smaller searches are done serially,
+// being cheaper than starting goroutines.
+const parallelSearchThreshold = 10000
+
+// search finds the jobs in d within radial distance of center on sphere,
+// jobs exactly at the distance included unless boundary is Exclusive.
+// The jobs of d are filtered in the ascending order of their keys, rather than the random order
+// of ranging over d, and the distance to each job is computed by up to workers goroutines,
+// each filtering a contiguous part of the jobs, so that jobs are found in the same order
+// by every search, serial or not.
+func search(ctx context.Context, sphere models.Sphere, within models.Distance, center models.Location, boundary Boundary, d map[string][]models.Job, workers int) []models.Job {
+	keys := make([]string, 0, len(d))
+	for key := range d {
+		keys = append(keys, key)
 	}
-	for _, v := range d {
+	sort.Strings(keys)
+
+	groups := make([][]models.Job, 0, len(d))
+	total := 0
+	for _, key := range keys {
+		groups = append(groups, d[key])
+		total += len(d[key])
+	}
+	if workers <= 1 || total < parallelSearchThreshold {
+		return filterWithin(ctx, sphere, within, center, boundary, groups)
+	}
+
+	parts := partition(groups, (total+workers-1)/workers)
+	results := make([][]models.Job, len(parts))
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Add(1)
+		go func(i int, part [][]models.Job) {
+			defer wg.Done()
+			results[i] = filterWithin(ctx, sphere, within, center, boundary, part)
+		}(i, part)
+	}
+	wg.Wait()
+
+	found := 0
+	for _, result := range results {
+		found += len(result)
+	}
+	jobs := make([]models.Job, 0, found)
+	for _, result := range results {
+		jobs = append(jobs, result...)
+	}
+	return jobs
+}
+
+// partition splits groups into parts of size jobs each, the last part holding the remainder.
+// A group may be split across consecutive parts, and the order of jobs is preserved.
+func partition(groups [][]models.Job, size int) [][][]models.Job {
+	parts := make([][][]models.Job, 0)
+	var part [][]models.Job
+	count := 0
+	for _, group := range groups {
+		for len(group) != 0 {
+			n := size - count
+			if n > len(group) {
+				n = len(group)
+			}
+			part = append(part, group[:n])
+			count += n
+			group = group[n:]
+			if count == size {
+				parts = append(parts, part)
+				part, count = nil, 0
+			}
+		}
+	}
+	if count != 0 {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// filterWithin finds the jobs in groups within radial distance of center on sphere,
+// or at the distance unless boundary is Exclusive, stopping early once ctx is done
+func filterWithin(ctx context.Context, sphere models.Sphere, within models.Distance, center models.Location, boundary Boundary, groups [][]models.Job) []models.Job {
+	jobs := make([]models.Job, 0)
+	visited := 0
+	for _, v := range groups {
 		for _, j := range v {
-			c2 := haversine.Coord{
-				Lat: j.Location.Latitude,
-				Lon: j.Location.Longitude,
+			visited++
+			if visited%cancellationCheckInterval == 0 && ctx.Err() != nil {
+				return jobs
 			}
-			if _, km := haversine.Distance(c1, c2); km <= within.Value {
+
+			if boundary.Contains(sphere.Distance(center, j.Location), within.Kilometers()) {
 				jobs = append(jobs, j)
 			}
 		}
@@ -0,0 +1,421 @@
+package rtree
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+func TestFindJobsNearAny(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(7)), 3000)
+	tree, _ := NewWithEntries(jobs...)
+	centers := []models.Location{{Longitude: 3.4, Latitude: 6.5}, {Longitude: 3.5, Latitude: 6.5}} // ~11km apart
+	radius := models.Distance{Unit: models.Kilometer, Value: 8}
+
+	found := tree.FindJobsNearAny(context.Background(), centers, radius, Inclusive)
+	seen := make(map[string]bool, len(found))
+	shared := 0
+	for i, job := range found {
+		if seen[job.ID] {
+			t.Fatalf("job %q found twice", job.ID)
+		}
+		seen[job.ID] = true
+		if i > 0 && job.Distance < found[i-1].Distance {
+			t.Fatalf("job %q found after a farther job", job.ID)
+		}
+		a, b := models.Earth.Distance(centers[0], job.Location), models.Earth.Distance(centers[1], job.Location)
+		if job.Distance != math.Min(a, b) {
+			t.Errorf("job %q at %vkm of its nearest center, want %vkm", job.ID, job.Distance, math.Min(a, b))
+		}
+		if a <= radius.Value && b <= radius.Value {
+			shared++
+		}
+	}
+	if shared == 0 {
+		t.Fatal("no job found within radius of both centers")
+	}
+
+	for _, job := range jobs {
+		within := models.Earth.Distance(centers[0], job.Location) <= radius.Value ||
+			models.Earth.Distance(centers[1], job.Location) <= radius.Value
+		if within != seen[job.ID] {
+			t.Errorf("job %q within radius of a center: %t, found: %t", job.ID, within, seen[job.ID])
+		}
+	}
+}
+
+func TestSplits(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(3)), 5000)
+	tree := NewWithEntry(*NewEntry(jobs[0]))
+	previousLeafSplits := 0
+	for i, job := range jobs[1:] {
+		tree.Insert(*NewEntry(job))
+		leafSplits, _ := tree.Splits()
+		if leafSplits < previousLeafSplits || leafSplits > previousLeafSplits+1 {
+			t.Fatalf("inserting job %d took leaf splits from %d to %d", i+1, previousLeafSplits, leafSplits)
+		}
+		previousLeafSplits = leafSplits
+	}
+
+	// every leaf but the first comes from a split, and every internal node
+	// from a split but the roots grown at each level
+	leafSplits, nodeSplits := tree.Splits()
+	leaves, nodes := countNodes(tree.root)
+	if leafSplits != leaves-1 {
+		t.Errorf("%d leaf splits for %d leaves, want %d", leafSplits, leaves, leaves-1)
+	}
+	if tree.Height() < 2 || nodeSplits != nodes-leaves-tree.Height() {
+		t.Errorf("%d node splits for %d internal nodes at height %d, want %d", nodeSplits, nodes-leaves, tree.Height(), nodes-leaves-tree.Height())
+	}
+
+	// clones count the splits of the tree cloned
+	clone := tree.Clone()
+	if cloneLeafSplits, cloneNodeSplits := clone.Splits(); cloneLeafSplits != leafSplits || cloneNodeSplits != nodeSplits {
+		t.Errorf("clone has %d and %d splits, want %d and %d", cloneLeafSplits, cloneNodeSplits, leafSplits, nodeSplits)
+	}
+}
+
+// countNodes counts the leaves and nodes of the subtree rooted at n, n included
+func countNodes(n *node) (leaves, nodes int) {
+	if n.isLeaf() {
+		return 1, 1
+	}
+	nodes = 1
+	for _, child := range n.children {
+		childLeaves, childNodes := countNodes(child)
+		leaves += childLeaves
+		nodes += childNodes
+	}
+	return leaves, nodes
+}
+
+func TestHeightAfterCascadingSplits(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(5)), 40000)
+	tree := NewWithEntry(*NewEntry(jobs[0]))
+	grown := 0
+	for _, job := range jobs[1:] {
+		height := tree.Height()
+		tree.Insert(*NewEntry(job))
+		if tree.Height() == height {
+			continue
+		}
+
+		// a root split cascading up from a leaf adds exactly one level
+		grown++
+		if tree.Height() != height+1 {
+			t.Fatalf("height went from %d to %d on a single insertion", height, tree.Height())
+		}
+		checkLeafDepths(t, tree)
+	}
+	if grown < 3 {
+		t.Fatalf("tree grew %d times, want a cascade of splits up to the root at least 3 times", grown)
+	}
+	checkLeafDepths(t, tree)
+	if _, nodes := tree.Size(); nodes != tree.root.size() {
+		t.Errorf("tree counts %d nodes, has %d", nodes, tree.root.size())
+	}
+}
+
+// checkLeafDepths checks that every leaf of tree lies at a depth of tree.Height()
+func checkLeafDepths(t *testing.T, tree *RTree) {
+	t.Helper()
+	var walk func(n *node, depth int)
+	walk = func(n *node, depth int) {
+		if n.isLeaf() {
+			if depth != tree.Height() {
+				t.Fatalf("leaf at depth %d of a tree of height %d", depth, tree.Height())
+			}
+			return
+		}
+		for _, child := range n.children {
+			walk(child, depth+1)
+		}
+	}
+	walk(tree.root, 0)
+}
+
+func TestFindEntriesInBox(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(11)), 2000)
+	tree, _ := NewWithEntries(jobs...)
+	min, max := models.Location{Longitude: 3.2, Latitude: 6.3}, models.Location{Longitude: 3.6, Latitude: 6.5}
+
+	entries := tree.FindEntriesInBox(min, max)
+	found := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		found[e.Job.ID] = true
+		if !e.Job.Location.Within(min, max) {
+			t.Errorf("job %q at %v found outside the box", e.Job.ID, e.Job.Location)
+		}
+		if !e.Job.Location.Within(e.MBR.Min, e.MBR.Max) {
+			t.Errorf("job %q at %v outside its MBR %+v", e.Job.ID, e.Job.Location, e.MBR)
+		}
+	}
+	for _, job := range jobs {
+		if job.Location.Within(min, max) && !found[job.ID] {
+			t.Errorf("job %q at %v within the box not found", job.ID, job.Location)
+		}
+	}
+	if len(entries) == 0 {
+		t.Fatal("no entry found within the box")
+	}
+}
+
+func TestForEachInBox(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(19)), 2000)
+	tree, _ := NewWithEntries(jobs...)
+	min, max := models.Location{Longitude: 3.2, Latitude: 6.3}, models.Location{Longitude: 3.6, Latitude: 6.5}
+
+	var want []string
+	for _, job := range jobs {
+		if job.Location.Within(min, max) {
+			want = append(want, job.ID)
+		}
+	}
+	sort.Strings(want)
+
+	var visited []string
+	tree.ForEachInBox(min, max, func(job models.Job) bool {
+		visited = append(visited, job.ID)
+		return true
+	})
+	sort.Strings(visited)
+	if !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited %d jobs, want the %d jobs within the box", len(visited), len(want))
+	}
+
+	// fn returning false stops the visit
+	for _, stopAfter := range []int{1, 10, len(want)} {
+		calls := 0
+		tree.ForEachInBox(min, max, func(job models.Job) bool {
+			calls++
+			return calls < stopAfter
+		})
+		if calls != stopAfter {
+			t.Errorf("visited %d jobs, want the visit stopped after %d", calls, stopAfter)
+		}
+	}
+
+	// a box crossing the antimeridian covers both of its sides
+	wrapped, _ := NewWithEntries(
+		models.Job{ID: "east", Location: models.Location{Longitude: 179.9, Latitude: 0}},
+		models.Job{ID: "west", Location: models.Location{Longitude: -179.9, Latitude: 0}},
+		models.Job{ID: "middle", Location: models.Location{Longitude: 0, Latitude: 0}})
+	var crossed []string
+	wrapped.ForEachInBox(models.Location{Longitude: 179, Latitude: -1}, models.Location{Longitude: -179, Latitude: 1}, func(job models.Job) bool {
+		crossed = append(crossed, job.ID)
+		return true
+	})
+	sort.Strings(crossed)
+	if want := []string{"east", "west"}; !reflect.DeepEqual(crossed, want) {
+		t.Errorf("visited %v across the antimeridian, want %v", crossed, want)
+	}
+}
+
+func TestJobByID(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(12)), 500)
+	tree, _ := NewWithEntries(jobs...)
+	for _, job := range jobs {
+		if got, ok := tree.JobByID(job.ID); !ok || !reflect.DeepEqual(got, job) {
+			t.Fatalf("JobByID(%q) = %v, %v, want %v", job.ID, got, ok, job)
+		}
+	}
+	if got, ok := tree.JobByID("job-missing"); ok {
+		t.Errorf("JobByID(%q) = %v, want none", "job-missing", got)
+	}
+
+	// jobs deleted are no longer found
+	tree.Delete(jobs[0].ID)
+	if _, ok := tree.JobByID(jobs[0].ID); ok {
+		t.Errorf("JobByID(%q) found once deleted", jobs[0].ID)
+	}
+}
+
+func TestHotspot(t *testing.T) {
+	random := rand.New(rand.NewSource(13))
+	jobs := randomJobs(random, 300)
+
+	// a clear cluster of 50 jobs within a kilometer of its center, amid 300 jobs spread over a square degree
+	cluster := models.Location{Longitude: 3.25, Latitude: 6.75}
+	for i := 0; i < 50; i++ {
+		jobs = append(jobs, models.Job{
+			ID:       fmt.Sprintf("cluster-%d", i),
+			Title:    "Nurse",
+			Location: models.Location{Longitude: cluster.Longitude + (random.Float64()-0.5)*0.01, Latitude: cluster.Latitude + (random.Float64()-0.5)*0.01},
+		})
+	}
+	tree, _ := NewWithEntries(jobs...)
+
+	hotspot := tree.Hotspot(context.Background(), models.Distance{Unit: models.Kilometer, Value: 1})
+	if distance := models.Earth.Distance(cluster, hotspot.Location); distance > 1 {
+		t.Errorf("hotspot at %v, %vkm from the cluster at %v", hotspot.Location, distance, cluster)
+	}
+	if hotspot.Count < 40 {
+		t.Errorf("hotspot counts %d other jobs within 1km, want most of the 49 others of the cluster", hotspot.Count)
+	}
+
+	// the count is that of a radius search around the hotspot, the hotspot itself excluded
+	within := models.Distance{Unit: models.Kilometer, Value: 1}
+	found := tree.FindJobsAmong(within, hotspot.Location, jobs, Inclusive)
+	if hotspot.Count != len(found)-1 {
+		t.Errorf("hotspot counts %d other jobs, a search finds %d jobs", hotspot.Count, len(found))
+	}
+}
+
+func TestDistanceUnits(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(14)), 3000)
+	tree, _ := NewWithEntries(jobs...)
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+
+	for _, miles := range []float64{3, 8, 200} {
+		inMiles := models.Distance{Unit: models.Mile, Value: miles}
+		inKm := models.Distance{Unit: models.Kilometer, Value: inMiles.Kilometers()}
+
+		// 200 miles covers the whole tree, which is then scanned rather than descended
+		want := idsOfJobs(tree.FindJobs(context.Background(), inKm, center, titleJobs(jobs), Inclusive))
+		if got := idsOfJobs(tree.FindJobs(context.Background(), inMiles, center, titleJobs(jobs), Inclusive)); !reflect.DeepEqual(got, want) {
+			t.Errorf("FindJobs found %d jobs within %v miles, %d within %vkm", len(got), miles, len(want), inKm.Value)
+		}
+		if got := idsOfJobs(tree.FindJobsAmong(inMiles, center, jobs, Inclusive)); !reflect.DeepEqual(got, want) {
+			t.Errorf("FindJobsAmong found %d jobs within %v miles, %d within %vkm", len(got), miles, len(want), inKm.Value)
+		}
+		if got, want := tree.CountJobsApproximately(inMiles, center, Inclusive), tree.CountJobsApproximately(inKm, center, Inclusive); got != want {
+			t.Errorf("CountJobsApproximately counted %d jobs within %v miles, %d within %vkm", got, miles, want, inKm.Value)
+		}
+
+		// the unit is not ignored
+		if miles < 200 {
+			asKm := tree.FindJobs(context.Background(), models.Distance{Unit: models.Kilometer, Value: miles}, center, nil, Inclusive)
+			if len(asKm) >= len(want) {
+				t.Errorf("found %d jobs within %vkm, want fewer than the %d within %v miles", len(asKm), miles, len(want), miles)
+			}
+		}
+	}
+}
+
+// idsOfJobs returns the IDs of jobs in ascending order
+func idsOfJobs(jobs []models.Job) []string {
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestFindJobsApproximately(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(16)), 4000)
+	tree, _ := NewWithEntries(jobs...)
+
+	overIncluded := false
+	for _, km := range []float64{0.5, 3, 10, 30} {
+		within := models.Distance{Unit: models.Kilometer, Value: km}
+		center := models.Location{Longitude: 3.4, Latitude: 6.6}
+		exact := tree.FindJobs(context.Background(), within, center, nil, Inclusive)
+		approximate := tree.FindJobsApproximately(within, center)
+		if len(approximate) < len(exact) {
+			t.Errorf("found %d jobs approximately within %vkm, fewer than the %d found exactly", len(approximate), km, len(exact))
+		}
+
+		found := make(map[string]bool, len(approximate))
+		for _, job := range approximate {
+			found[job.ID] = true
+		}
+		for _, job := range exact {
+			if !found[job.ID] {
+				t.Errorf("job %q found exactly within %vkm, not approximately", job.ID, km)
+			}
+		}
+		overIncluded = overIncluded || len(approximate) > len(exact)
+	}
+	if !overIncluded {
+		t.Error("approximate searches found exactly the jobs within radius, want jobs sharing their leaves too")
+	}
+}
+
+func TestSetSphere(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(17)), 3000)
+	earth, _ := NewWithEntries(jobs...)
+	doubled, _ := NewWithEntries(jobs...)
+	doubled.SetSphere(models.Sphere{RadiusKm: 2 * models.EarthRadiusKm})
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+
+	// distances double on a doubled earth, so jobs within 2r of it are those within r of the earth
+	for _, km := range []float64{2, 10, 40} {
+		want := idsOfJobs(earth.FindJobs(context.Background(), models.Distance{Unit: models.Kilometer, Value: km}, center, nil, Inclusive))
+		got := idsOfJobs(doubled.FindJobs(context.Background(), models.Distance{Unit: models.Kilometer, Value: 2 * km}, center, nil, Inclusive))
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("found %d jobs within %vkm on a doubled earth, want the %d within %vkm on the earth", len(got), 2*km, len(want), km)
+		}
+		if len(want) == 0 {
+			t.Errorf("found no jobs within %vkm", km)
+		}
+	}
+}
+
+func TestFindJobsAcrossAntimeridianAndPoles(t *testing.T) {
+	random := rand.New(rand.NewSource(1455))
+	jobs := worldJobs(random, 3000)
+	// jobs crowd either side of the antimeridian and around each pole
+	for i := 0; i < 600; i++ {
+		location := models.Location{Longitude: 179 + random.Float64(), Latitude: random.Float64()*2 - 1}
+		switch i % 4 {
+		case 1:
+			location.Longitude = -location.Longitude
+		case 2:
+			location = models.Location{Longitude: random.Float64()*360 - 180, Latitude: 89 + random.Float64()}
+		case 3:
+			location = models.Location{Longitude: random.Float64()*360 - 180, Latitude: -89 - random.Float64()}
+		}
+		jobs = append(jobs, models.Job{ID: fmt.Sprintf("edge-%d", i), Title: "edge", Location: location})
+	}
+	tree, _ := NewWithEntries(jobs...)
+
+	tests := []struct {
+		name   string
+		center models.Location
+		radius float64
+	}{
+		{name: "east of the antimeridian", center: models.Location{Longitude: 179.9, Latitude: 0}, radius: 60},
+		{name: "west of the antimeridian", center: models.Location{Longitude: -179.95, Latitude: 0.5}, radius: 100},
+		{name: "at the antimeridian", center: models.Location{Longitude: 180, Latitude: -0.5}, radius: 30},
+		{name: "near the north pole", center: models.Location{Longitude: 100, Latitude: 89.9}, radius: 20},
+		{name: "near the south pole", center: models.Location{Longitude: -60, Latitude: -89.5}, radius: 120},
+		{name: "at the north pole", center: models.Location{Longitude: 0, Latitude: 90}, radius: 50},
+	}
+	for _, test := range tests {
+		within := models.Distance{Unit: models.Kilometer, Value: test.radius}
+		var want []models.Job
+		for _, job := range jobs {
+			if models.Earth.Distance(test.center, job.Location) <= test.radius {
+				want = append(want, job)
+			}
+		}
+		if len(want) < 2 {
+			t.Fatalf("%s: %d jobs within %vkm, want a search finding several", test.name, len(want), test.radius)
+		}
+
+		found := tree.FindJobs(context.Background(), within, test.center, titleJobs(jobs), Inclusive)
+		if got := idsOfJobs(found); !reflect.DeepEqual(got, idsOfJobs(want)) {
+			t.Errorf("%s: found %d jobs within %vkm, want the %d of a scan", test.name, len(got), test.radius, len(want))
+		}
+
+		approximate := make(map[string]bool)
+		for _, job := range tree.FindJobsApproximately(within, test.center) {
+			approximate[job.ID] = true
+		}
+		for _, job := range want {
+			if !approximate[job.ID] {
+				t.Errorf("%s: job %s at %v not found approximately", test.name, job.ID, job.Location)
+			}
+		}
+		if count := tree.CountJobsApproximately(within, test.center, Inclusive); count < len(want) {
+			t.Errorf("%s: counted %d jobs approximately, fewer than the %d within %vkm", test.name, count, len(want), test.radius)
+		}
+	}
+}
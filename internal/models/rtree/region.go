@@ -0,0 +1,56 @@
+package rtree
+
+import "github.com/ercross/grabjobs/internal/models"
+
+// region is an area of the map searched on the tree, as one mbr, or two either side
+// of the antimeridian if the area crosses it. As mbrs do not wrap around, a box crossing
+// the antimeridian would otherwise span the whole map but the area searched.
+// The mbrs of a region only share points at ±180°, where no location lies in both,
+// unless the region is the union of several areas, e.g., around several centers.
+type region []mbr
+
+// newRegion returns the region of box, which may cross the antimeridian
+func newRegion(box models.Box) region {
+	parts := box.Split()
+	r := make(region, len(parts))
+	for i, part := range parts {
+		r[i] = newMBR(part.Min, part.Max)
+	}
+	return r
+}
+
+// newSearchRegion returns the region of the box bounding the circle of radius within
+// around center on sphere, across the antimeridian or a pole if the circle reaches them
+func newSearchRegion(sphere models.Sphere, center models.Location, within models.Distance) region {
+	return newRegion(sphere.SearchBox(center, within))
+}
+
+// overlapsWith checks that any mbr of r overlaps m
+func (r region) overlapsWith(m mbr) bool {
+	for _, part := range r {
+		if part.overlapsWith(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains checks that location lies within any mbr of r
+func (r region) contains(location models.Location) bool {
+	for _, part := range r {
+		if part.contains(location) {
+			return true
+		}
+	}
+	return false
+}
+
+// covers checks that m fits within an mbr of r, hence that r holds every location of m
+func (r region) covers(m mbr) bool {
+	for _, part := range r {
+		if m.canFitWithin(part) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package rtree
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// titleJobs returns jobs keyed by title, as searched by search
+func titleJobs(jobs []models.Job) map[string][]models.Job {
+	d := make(map[string][]models.Job)
+	for _, job := range jobs {
+		d[job.Title] = append(d[job.Title], job)
+	}
+	return d
+}
+
+func TestParallelSearchMatchesSerial(t *testing.T) {
+	d := titleJobs(randomJobs(rand.New(rand.NewSource(4)), 5*parallelSearchThreshold))
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	within := models.Distance{Unit: models.Kilometer, Value: 40}
+
+	serial := search(context.Background(), models.Earth, within, center, Inclusive, d, 1)
+	if len(serial) == 0 {
+		t.Fatal("serial search found no job")
+	}
+	for _, workers := range []int{2, 3, 8} {
+		parallel := search(context.Background(), models.Earth, within, center, Inclusive, d, workers)
+		if !reflect.DeepEqual(parallel, serial) {
+			t.Errorf("search across %d workers found %d jobs, serial search found %d in another order or set",
+				workers, len(parallel), len(serial))
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	jobs := randomJobs(rand.New(rand.NewSource(5)), 23)
+	groups := [][]models.Job{jobs[:10], jobs[10:11], jobs[11:]}
+	for _, size := range []int{1, 4, 10, 23, 50} {
+		parts := partition(groups, size)
+		var flattened []models.Job
+		for i, part := range parts {
+			count := 0
+			for _, group := range part {
+				count += len(group)
+				flattened = append(flattened, group...)
+			}
+			if count != size && i != len(parts)-1 || count > size {
+				t.Errorf("size %d: part %d holds %d jobs", size, i, count)
+			}
+		}
+		if !reflect.DeepEqual(flattened, jobs) {
+			t.Errorf("size %d: parts do not hold the jobs of groups in order", size)
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	d := titleJobs(randomJobs(rand.New(rand.NewSource(6)), 1_000_000))
+	center := models.Location{Longitude: 3.5, Latitude: 6.5}
+	within := models.Distance{Unit: models.Kilometer, Value: 200}
+	for _, workers := range []int{1, 4, 0} {
+		name := fmt.Sprintf("workers=%d", workers)
+		if workers == 0 {
+			name = "workers=GOMAXPROCS"
+		}
+		tree := RTree{searchWorkers: workers}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				search(context.Background(), models.Earth, within, center, Inclusive, d, tree.workers())
+			}
+		})
+	}
+}
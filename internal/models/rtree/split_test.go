@@ -0,0 +1,121 @@
+package rtree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/ercross/grabjobs/internal/models"
+)
+
+// splitInputs generates n jobs of each distribution known to unbalance splits, located around Lagos
+var splitInputs = map[string]func(random *rand.Rand, n int) []models.Job{
+	"random": randomJobs,
+	"sorted": func(random *rand.Rand, n int) []models.Job {
+		jobs := randomJobs(random, n)
+		sort.Slice(jobs, func(i, j int) bool { return jobs[i].Location.Longitude < jobs[j].Location.Longitude })
+		return jobs
+	},
+	"reversed": func(random *rand.Rand, n int) []models.Job {
+		jobs := randomJobs(random, n)
+		sort.Slice(jobs, func(i, j int) bool { return jobs[i].Location.Latitude > jobs[j].Location.Latitude })
+		return jobs
+	},
+	"collinear": func(random *rand.Rand, n int) []models.Job {
+		jobs := randomJobs(random, n)
+		for i := range jobs {
+			jobs[i].Location.Latitude = 6.5
+		}
+		return jobs
+	},
+	"duplicates": func(random *rand.Rand, n int) []models.Job {
+		jobs := randomJobs(random, n)
+		for i := range jobs {
+			jobs[i].Location = jobs[i%5].Location
+		}
+		return jobs
+	},
+	"clustered": func(random *rand.Rand, n int) []models.Job {
+		jobs := randomJobs(random, n)
+		for i := range jobs {
+			if i%10 != 0 {
+				jobs[i].Location = models.Location{Longitude: 3.5 + random.Float64()*1e-4, Latitude: 6.5 + random.Float64()*1e-4}
+			}
+		}
+		return jobs
+	},
+}
+
+// TestSplitLeafMinFill splits full leaves of entries generated in random orders,
+// checking that both leaves get at least minEntriesPerLeaf entries and every entry is kept
+func TestSplitLeafMinFill(t *testing.T) {
+	for name, generate := range splitInputs {
+		for seed := int64(0); seed < 200; seed++ {
+			random := rand.New(rand.NewSource(seed))
+			jobs := generate(random, maxEntriesPerLeaf+1)
+			random.Shuffle(len(jobs), func(i, j int) { jobs[i], jobs[j] = jobs[j], jobs[i] })
+
+			leaf := new(node)
+			for _, job := range jobs[:maxEntriesPerLeaf] {
+				leaf.insertEntry(*NewEntry(job))
+			}
+			n1, n2 := leaf.splitLeaf(*NewEntry(jobs[maxEntriesPerLeaf]))
+
+			if len(n1.entries) < minEntriesPerLeaf || len(n2.entries) < minEntriesPerLeaf {
+				t.Fatalf("%s, seed %d: split into leaves of %d and %d entries, want at least %d each",
+					name, seed, len(n1.entries), len(n2.entries), minEntriesPerLeaf)
+			}
+			ids := make(map[string]bool)
+			for _, n := range []*node{n1, n2} {
+				for _, e := range n.entries {
+					ids[e.job.ID] = true
+					if !e.mbr.canFitWithin(n.mbr) {
+						t.Fatalf("%s, seed %d: entry %v outside its leaf mbr %v", name, seed, e.mbr, n.mbr)
+					}
+				}
+			}
+			if len(ids) != len(jobs) || len(n1.entries)+len(n2.entries) != len(jobs) {
+				t.Fatalf("%s, seed %d: split kept %d of %d entries", name, seed, len(ids), len(jobs))
+			}
+		}
+	}
+}
+
+// TestTreeMinFill inserts jobs in random orders, checking that every node but the root
+// holds at least minEntriesPerLeaf entries or children, and that the tree stays shallow
+func TestTreeMinFill(t *testing.T) {
+	const n = 5000
+	// maxHeight is the height of a tree of n jobs whose nodes are all filled to the minimum
+	maxHeight := int(math.Ceil(math.Log(n) / math.Log(minEntriesPerLeaf)))
+
+	for name, generate := range splitInputs {
+		for seed := int64(0); seed < 3; seed++ {
+			random := rand.New(rand.NewSource(seed))
+			jobs := generate(random, n)
+			random.Shuffle(len(jobs), func(i, j int) { jobs[i], jobs[j] = jobs[j], jobs[i] })
+			tree, _ := NewWithEntries(jobs...)
+
+			var walk func(n *node, depth int)
+			walk = func(n *node, depth int) {
+				if size := len(n.entries) + len(n.children); n != tree.root && size < minEntriesPerLeaf || size > maxEntriesPerLeaf {
+					t.Errorf("%s, seed %d: node at depth %d holds %d entries and children, want %d to %d",
+						name, seed, depth, size, minEntriesPerLeaf, maxEntriesPerLeaf)
+				}
+				for _, child := range n.children {
+					walk(child, depth+1)
+				}
+			}
+			walk(tree.root, 0)
+			checkShape(t, tree)
+
+			if tree.Height() > maxHeight {
+				t.Errorf("%s, seed %d: tree of %d jobs has height %d, want at most %d", name, seed, n, tree.Height(), maxHeight)
+			}
+			found := tree.FindEntriesInBox(models.Location{Longitude: 2, Latitude: 5}, models.Location{Longitude: 5, Latitude: 8})
+			if len(found) != n {
+				t.Errorf("%s, seed %d: found %d of %d jobs", name, seed, len(found), n)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package rtree
+
+import "context"
+
+// SearchStats counts the work done by searches of a tree, to tell the cost of a query
+type SearchStats struct {
+	NodesVisited   int `json:"nodesVisited"`
+	EntriesVisited int `json:"entriesVisited"`
+
+	// FullScan is true if a search scanned every job instead of descending the tree,
+	// in which case no node is visited
+	FullScan bool `json:"fullScan"`
+}
+
+// searchStatsKey is the context key of the SearchStats searches add their counts to
+type searchStatsKey struct{}
+
+// WithSearchStats returns a copy of ctx in which searches add their counts to stats.
+// Searches run concurrently must not share stats.
+func WithSearchStats(ctx context.Context, stats *SearchStats) context.Context {
+	return context.WithValue(ctx, searchStatsKey{}, stats)
+}
+
+// searchStatsFrom returns the SearchStats carried by ctx, nil if none
+func searchStatsFrom(ctx context.Context) *SearchStats {
+	stats, _ := ctx.Value(searchStatsKey{}).(*SearchStats)
+	return stats
+}
+
+// add adds the counts of other to s. add does nothing on a nil s
+func (s *SearchStats) add(other SearchStats) {
+	if s == nil {
+		return
+	}
+	s.NodesVisited += other.NodesVisited
+	s.EntriesVisited += other.EntriesVisited
+	s.FullScan = s.FullScan || other.FullScan
+}
@@ -0,0 +1,84 @@
+package models
+
+import "math"
+
+// Sample picks at most n jobs spread evenly across box, for clients rendering
+// heatmaps of many jobs. box is divided into a grid of at most n cells shaped
+// after box, and the job nearest the center of each occupied cell is picked.
+// Jobs outside box are ignored. Picked jobs are ordered by cell, row by row
+// from the south-west corner. If box crosses the antimeridian, its columns run eastwards across it.
+func Sample(box Box, jobs []Job, n int) []Job {
+	if n < 1 {
+		return []Job{}
+	}
+
+	width := box.lonSpan()
+	height := box.Max.Latitude - box.Min.Latitude
+	rows, cols := sampleGrid(width, height, n)
+	cellWidth, cellHeight := width/float64(cols), height/float64(rows)
+
+	picked := make(map[int]int, rows*cols)
+	pickedDistance := make(map[int]float64, rows*cols)
+	for i, job := range jobs {
+		if !box.Contains(job.Location) {
+			continue
+		}
+		// longitudes west of box.Min lie east of the antimeridian in a box crossing it
+		longitude := job.Location.Longitude
+		if longitude < box.Min.Longitude {
+			longitude += 360
+		}
+		row := gridCell(job.Location.Latitude, box.Min.Latitude, cellHeight, rows)
+		col := gridCell(longitude, box.Min.Longitude, cellWidth, cols)
+		index := row*cols + col
+
+		center := Location{
+			Longitude: box.Min.Longitude + (float64(col)+0.5)*cellWidth,
+			Latitude:  box.Min.Latitude + (float64(row)+0.5)*cellHeight,
+		}
+		distance := center.DistanceTo(job.Location)
+		if current, ok := pickedDistance[index]; !ok || distance < current {
+			picked[index] = i
+			pickedDistance[index] = distance
+		}
+	}
+
+	sample := make([]Job, 0, len(picked))
+	for index := 0; index < rows*cols; index++ {
+		if i, ok := picked[index]; ok {
+			sample = append(sample, jobs[i])
+		}
+	}
+	return sample
+}
+
+// gridCell returns the index of the grid cell of coordinate value along an axis
+// starting at min, divided into count cells of size each
+func gridCell(value, min, size float64, count int) int {
+	if size == 0 {
+		return 0
+	}
+	return int(math.Min(math.Floor((value-min)/size), float64(count-1)))
+}
+
+// sampleGrid computes the number of rows and columns of a grid of at most n cells
+// over a box of width and height degrees, keeping cells about as wide as they are high.
+func sampleGrid(width, height float64, n int) (rows, cols int) {
+	switch {
+	case width == 0 && height == 0:
+		return 1, 1
+	case height == 0:
+		return 1, n
+	case width == 0:
+		return n, 1
+	}
+
+	cols = int(math.Floor(math.Sqrt(float64(n) * width / height)))
+	if cols < 1 {
+		cols = 1
+	}
+	if cols > n {
+		cols = n
+	}
+	return n / cols, cols
+}
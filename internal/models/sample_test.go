@@ -0,0 +1,68 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	box := Box{Min: Location{Longitude: 0, Latitude: 0}, Max: Location{Longitude: 10, Latitude: 10}}
+
+	// a job every 0.1 degree across box, and a job outside it
+	var jobs []Job
+	for lon := 0; lon < 100; lon++ {
+		for lat := 0; lat < 100; lat++ {
+			jobs = append(jobs, Job{
+				ID:       fmt.Sprintf("%d,%d", lon, lat),
+				Location: Location{Longitude: float64(lon)/10 + 0.05, Latitude: float64(lat)/10 + 0.05},
+			})
+		}
+	}
+	jobs = append(jobs, Job{ID: "outside", Location: Location{Longitude: 11, Latitude: 5}})
+
+	for _, n := range []int{1, 4, 16, 50, 100} {
+		sample := Sample(box, jobs, n)
+		if len(sample) > n {
+			t.Errorf("sampled %d jobs, want at most %d", len(sample), n)
+		}
+		if len(sample) < n/2 {
+			t.Errorf("sampled %d jobs of a box full of jobs, want about %d", len(sample), n)
+		}
+
+		// each quadrant of box holds a quarter of the sample
+		var quadrants [4]int
+		for _, job := range sample {
+			if job.ID == "outside" {
+				t.Errorf("sampled job %v outside box", job.Location)
+			}
+			quadrant := 0
+			if job.Location.Longitude >= 5 {
+				quadrant++
+			}
+			if job.Location.Latitude >= 5 {
+				quadrant += 2
+			}
+			quadrants[quadrant]++
+		}
+		if n == 4 || n == 16 || n == 100 {
+			for quadrant, count := range quadrants {
+				if count != n/4 {
+					t.Errorf("sampled %d jobs into quadrant %d of %d jobs, want %d", count, quadrant, n, n/4)
+				}
+			}
+		}
+	}
+
+	// jobs crowded into a single cell are sampled once
+	crowded := []Job{
+		{ID: "a", Location: Location{Longitude: 0.1, Latitude: 0.1}},
+		{ID: "b", Location: Location{Longitude: 0.2, Latitude: 0.2}},
+		{ID: "c", Location: Location{Longitude: 0.3, Latitude: 0.1}},
+	}
+	if sample := Sample(box, crowded, 16); len(sample) != 1 {
+		t.Errorf("sampled %d jobs of a single cell, want 1", len(sample))
+	}
+	if sample := Sample(box, jobs, 0); len(sample) != 0 {
+		t.Errorf("sampled %d jobs, want none", len(sample))
+	}
+}
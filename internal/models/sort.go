@@ -0,0 +1,113 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SortField is a field jobs are sorted by
+type SortField string
+
+const (
+	// SortByDistance sorts jobs by their distance to a location, nearest first by default
+	SortByDistance SortField = "distance"
+
+	// SortBySalary sorts jobs by their SalaryLabel, highest first by default.
+	// Jobs lacking a salary come last whatever the order.
+	SortBySalary SortField = "salary"
+
+	// SortByRecent sorts jobs by CreatedAt, newest first by default.
+	// Jobs read from the data file are older than any job added since.
+	SortByRecent SortField = "recent"
+)
+
+// SalaryLabel is the label holding the salary of a job as a decimal number, e.g., salary=4200
+const SalaryLabel = "salary"
+
+// ParseSortField parses field, any of distance, salary or recent.
+// ok is false if field is none of them.
+func ParseSortField(field string) (sortField SortField, ok bool) {
+	switch sortField = SortField(strings.ToLower(field)); sortField {
+	case SortByDistance, SortBySalary, SortByRecent:
+		return sortField, true
+	}
+	return sortField, false
+}
+
+// DescendingByDefault checks that f sorts from the highest value unless told otherwise,
+// i.e., the highest salary or the newest job, but the nearest job
+func (f SortField) DescendingByDefault() bool {
+	return f != SortByDistance
+}
+
+// Salary returns the salary of j in its SalaryLabel.
+// ok is false if j has no salary, or not a number.
+func (j Job) Salary() (salary float64, ok bool) {
+	value, found := j.Labels[SalaryLabel]
+	if !found {
+		return 0, false
+	}
+	salary, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return salary, err == nil
+}
+
+// SortJobsWithDistanceBy orders jobs by field, from the highest value if descending.
+// Jobs equal by field keep their order, e.g., nearest first once sorted with SortNearestFirst.
+func SortJobsWithDistanceBy(jobs []JobWithDistance, field SortField, descending bool) {
+	if field == SortByDistance {
+		sort.SliceStable(jobs, func(i, j int) bool {
+			if descending {
+				return jobs[i].Distance > jobs[j].Distance
+			}
+			return jobs[i].Distance < jobs[j].Distance
+		})
+		return
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobPrecedes(jobs[i].Job, jobs[j].Job, field, descending)
+	})
+}
+
+// SortJobsBy orders jobs by field as SortJobsWithDistanceBy does, but for SortByDistance,
+// as jobs have no distance, which leaves jobs as they are
+func SortJobsBy(jobs []Job, field SortField, descending bool) {
+	if field == SortByDistance {
+		return
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobPrecedes(jobs[i], jobs[j], field, descending)
+	})
+}
+
+// jobPrecedes checks that a comes before b once sorted by field, salary or recent,
+// from the highest value if descending
+func jobPrecedes(a, b Job, field SortField, descending bool) bool {
+	switch field {
+	case SortBySalary:
+		salaryA, okA := a.Salary()
+		salaryB, okB := b.Salary()
+		if !okA || !okB {
+			// jobs lacking a salary come last
+			return okA && !okB
+		}
+		if descending {
+			return salaryA > salaryB
+		}
+		return salaryA < salaryB
+
+	case SortByRecent:
+		if a.CreatedAt == nil || b.CreatedAt == nil {
+			// jobs read from the data file are the oldest
+			if descending {
+				return a.CreatedAt != nil && b.CreatedAt == nil
+			}
+			return a.CreatedAt == nil && b.CreatedAt != nil
+		}
+		if descending {
+			return a.CreatedAt.After(*b.CreatedAt)
+		}
+		return a.CreatedAt.Before(*b.CreatedAt)
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sortJobs returns jobs titled by their order: salaried A, B and C paying 3000, 5000 and 4000,
+// created a minute apart from A, and D and E, unsalaried, or not a number, read from the data file
+func sortJobs() []JobWithDistance {
+	created := func(minutes int) *time.Time {
+		at := time.Date(2026, 10, 17, 9, minutes, 0, 0, time.UTC)
+		return &at
+	}
+	return []JobWithDistance{
+		{Job: Job{Title: "A", Labels: map[string]string{SalaryLabel: "3000"}, CreatedAt: created(0)}, Distance: 1},
+		{Job: Job{Title: "B", Labels: map[string]string{SalaryLabel: " 5000 "}, CreatedAt: created(1)}, Distance: 2},
+		{Job: Job{Title: "C", Labels: map[string]string{SalaryLabel: "4000.5"}, CreatedAt: created(2)}, Distance: 3},
+		{Job: Job{Title: "D"}, Distance: 4},
+		{Job: Job{Title: "E", Labels: map[string]string{SalaryLabel: "negotiable"}}, Distance: 5},
+	}
+}
+
+func TestSortJobsBy(t *testing.T) {
+	tests := []struct {
+		field      SortField
+		descending bool
+		titles     []string
+	}{
+		{field: SortByDistance, descending: false, titles: []string{"A", "B", "C", "D", "E"}},
+		{field: SortByDistance, descending: true, titles: []string{"E", "D", "C", "B", "A"}},
+		// jobs lacking a salary come last, nearest first, in either order
+		{field: SortBySalary, descending: true, titles: []string{"B", "C", "A", "D", "E"}},
+		{field: SortBySalary, descending: false, titles: []string{"A", "C", "B", "D", "E"}},
+		// jobs read from the data file are the oldest, nearest first
+		{field: SortByRecent, descending: true, titles: []string{"C", "B", "A", "D", "E"}},
+		{field: SortByRecent, descending: false, titles: []string{"D", "E", "A", "B", "C"}},
+	}
+	for _, test := range tests {
+		jobs := sortJobs()
+		SortJobsWithDistanceBy(jobs, test.field, test.descending)
+		titles := make([]string, len(jobs))
+		for i, job := range jobs {
+			titles[i] = job.Title
+		}
+		if !reflect.DeepEqual(titles, test.titles) {
+			t.Errorf("%s (descending %v): sorted %v, want %v", test.field, test.descending, titles, test.titles)
+		}
+
+		if test.field == SortByDistance {
+			continue
+		}
+		plain := make([]Job, 0)
+		for _, job := range sortJobs() {
+			plain = append(plain, job.Job)
+		}
+		SortJobsBy(plain, test.field, test.descending)
+		for i, job := range plain {
+			titles[i] = job.Title
+		}
+		if !reflect.DeepEqual(titles, test.titles) {
+			t.Errorf("%s (descending %v): sorted jobs without distances %v, want %v", test.field, test.descending, titles, test.titles)
+		}
+	}
+}
+
+func TestParseSortField(t *testing.T) {
+	for field, want := range map[string]SortField{"distance": SortByDistance, "Salary": SortBySalary, "RECENT": SortByRecent} {
+		if parsed, ok := ParseSortField(field); !ok || parsed != want {
+			t.Errorf("ParseSortField(%q) = %q, %v, want %q", field, parsed, ok, want)
+		}
+	}
+	for _, field := range []string{"", "title", "salary desc"} {
+		if _, ok := ParseSortField(field); ok {
+			t.Errorf("ParseSortField(%q) succeeded, want it rejected", field)
+		}
+	}
+	if SortByDistance.DescendingByDefault() || !SortBySalary.DescendingByDefault() || !SortByRecent.DescendingByDefault() {
+		t.Error("want distance ascending, salary and recent descending by default")
+	}
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"github.com/umahmood/haversine"
+	"math"
+)
+
+// EarthRadiusKm is the mean radius of the earth in kilometers,
+// which haversine.Distance computes distances with
+const EarthRadiusKm = 6371
+
+// Sphere computes distances between locations on a sphere,
+// e.g., another planet or a game world, the earth being the default.
+type Sphere struct {
+
+	// RadiusKm is the radius of the sphere in kilometers.
+	// Zero or less defaults to EarthRadiusKm
+	RadiusKm float64
+}
+
+// Earth is the sphere locations are on unless stated otherwise
+var Earth = Sphere{RadiusKm: EarthRadiusKm}
+
+func (s Sphere) radiusKm() float64 {
+	if s.RadiusKm <= 0 {
+		return EarthRadiusKm
+	}
+	return s.RadiusKm
+}
+
+// Distance computes the great-circle distance in kilometers between a and b on s
+// using the haversine formula. ref: https://en.wikipedia.org/wiki/Haversine_formula
+func (s Sphere) Distance(a, b Location) float64 {
+	_, km := haversine.Distance(
+		haversine.Coord{Lat: a.Latitude, Lon: a.Longitude},
+		haversine.Coord{Lat: b.Latitude, Lon: b.Longitude},
+	)
+
+	// great-circle distances scale with the radius of the sphere
+	return km * s.radiusKm() / EarthRadiusKm
+}
+
+// Area computes the area in square kilometers of box on s,
+// i.e., of the zone between its parallels cut by its meridians, across the antimeridian if box crosses it
+func (s Sphere) Area(box Box) float64 {
+	radius := s.radiusKm()
+	lonSpan := toRadians(box.lonSpan())
+	return radius * radius * lonSpan * math.Abs(math.Sin(toRadians(box.Max.Latitude))-math.Sin(toRadians(box.Min.Latitude)))
+}
+
+// DistanceToBox computes the great-circle distance in kilometers from location
+// to the nearest point of box on s, which is zero if box contains location.
+// DistanceToBox never exceeds the distance to any location within box,
+// hence boxes can be searched nearest first.
+func (s Sphere) DistanceToBox(location Location, box Box) float64 {
+	latitude := math.Max(box.Min.Latitude, math.Min(location.Latitude, box.Max.Latitude))
+	if location.Longitude >= box.Min.Longitude && location.Longitude <= box.Max.Longitude {
+		return s.Distance(location, Location{Longitude: location.Longitude, Latitude: latitude})
+	}
+
+	// the nearest point lies on the nearer of the meridians bounding box
+	edge := box.Min.Longitude
+	if BearingDifference(location.Longitude, box.Max.Longitude) < BearingDifference(location.Longitude, box.Min.Longitude) {
+		edge = box.Max.Longitude
+	}
+	lonDelta := toRadians(BearingDifference(location.Longitude, edge))
+	if lonDelta >= math.Pi/2 {
+		// distances along the meridian only decrease towards the pole on the side of location
+		return math.Min(
+			s.Distance(location, Location{Longitude: edge, Latitude: box.Min.Latitude}),
+			s.Distance(location, Location{Longitude: edge, Latitude: box.Max.Latitude}),
+		)
+	}
+
+	// the point of the meridian nearest to location, clamped to box
+	foot := math.Atan(math.Tan(toRadians(location.Latitude))/math.Cos(lonDelta)) * 180 / math.Pi
+	latitude = math.Max(box.Min.Latitude, math.Min(foot, box.Max.Latitude))
+	return s.Distance(location, Location{Longitude: edge, Latitude: latitude})
+}
+
+// FarthestDistanceToBox computes the great-circle distance in kilometers from location
+// to the farthest point of box on s, i.e., half the circumference of s less the distance
+// from the antipode of location to box, since the farthest point is the nearest to the antipode.
+// FarthestDistanceToBox is never below the distance to any location within box,
+// hence box lies within a circle around location once it lies within the radius.
+func (s Sphere) FarthestDistanceToBox(location Location, box Box) float64 {
+	antipode := Location{Longitude: wrapLongitude(location.Longitude + 180), Latitude: -location.Latitude}
+	return math.Pi*s.radiusKm() - s.DistanceToBox(antipode, box)
+}
+
+// Destination returns the location reached from start after travelling distanceKm on s
+// along the great circle of initial bearing, in degrees clockwise from the north.
+// ref: https://www.movable-type.co.uk/scripts/latlong.html
+func (s Sphere) Destination(start Location, bearing, distanceKm float64) Location {
+	angular := distanceKm / s.radiusKm()
+	lat1, lon1, theta := toRadians(start.Latitude), toRadians(start.Longitude), toRadians(bearing)
+
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(angular) + math.Cos(lat1)*math.Sin(angular)*math.Cos(theta))
+	lon2 := lon1 + math.Atan2(
+		math.Sin(theta)*math.Sin(angular)*math.Cos(lat1),
+		math.Cos(angular)-math.Sin(lat1)*math.Sin(lat2),
+	)
+	return Location{Longitude: lon2 * 180 / math.Pi, Latitude: lat2 * 180 / math.Pi}
+}
+
+// CirclePolygon approximates the circle of radiusKm around center on s
+// with a polygon of segments sides, at least 3, whose vertices lie on the circle.
+// Longitudes of circles crossing the antimeridian are not wrapped into [-180, 180].
+func (s Sphere) CirclePolygon(center Location, radiusKm float64, segments int) Polygon {
+	if segments < 3 {
+		segments = 3
+	}
+
+	// vertices are walked counterclockwise, as exterior rings must be,
+	// hence by decreasing bearing, and the ring is closed on the first vertex
+	ring := make([][2]float64, 0, segments+1)
+	for i := 0; i < segments; i++ {
+		vertex := s.Destination(center, 360-float64(i)*360/float64(segments), radiusKm)
+		ring = append(ring, [2]float64{vertex.Longitude, vertex.Latitude})
+	}
+	ring = append(ring, ring[0])
+	return Polygon{Type: "Polygon", Coordinates: [][][2]float64{ring}}
+}
+
+// BoundingBox returns the south-west (min) and north-east (max) corners
+// of the smallest latitude/longitude box containing the circle of radius around center on s.
+// If the circle reaches a pole, the box spans the whole longitudinal range.
+// ref: http://janmatuschek.de/LatitudeLongitudeBoundingCoordinates
+func (s Sphere) BoundingBox(center Location, radius Distance) (min, max Location) {
+	// angular radius of the circle in radians
+	angular := radius.Kilometers() / s.radiusKm()
+	latDelta := angular * 180 / math.Pi
+
+	// the meridians touching the circle are nearer than the arc of
+	// angular radius along the parallel of center, hence asin over division by cos
+	lonDelta := 180.0
+	if latitude := toRadians(center.Latitude); math.Abs(latitude)+angular < math.Pi/2 {
+		lonDelta = math.Asin(math.Sin(angular)/math.Cos(latitude)) * 180 / math.Pi
+	}
+	min = Location{Longitude: center.Longitude - lonDelta, Latitude: center.Latitude - latDelta}
+	max = Location{Longitude: center.Longitude + lonDelta, Latitude: center.Latitude + latDelta}
+	return min, max
+}
+
+// SearchBox returns the smallest box containing the circle of radius around center on s,
+// as BoundingBox does, but with longitudes wrapped into [-180, 180] and latitudes clamped to [-90, 90],
+// so that jobs across the antimeridian or a pole from center are within it.
+// The box crosses the antimeridian if the circle does, and spans every longitude if it reaches a pole.
+func (s Sphere) SearchBox(center Location, radius Distance) Box {
+	min, max := s.BoundingBox(center, radius)
+	box := Box{
+		Min: Location{Longitude: min.Longitude, Latitude: math.Max(min.Latitude, -90)},
+		Max: Location{Longitude: max.Longitude, Latitude: math.Min(max.Latitude, 90)},
+	}
+	if max.Longitude-min.Longitude >= 360 {
+		box.Min.Longitude, box.Max.Longitude = -180, 180
+		return box
+	}
+	box.Min.Longitude, box.Max.Longitude = wrapLongitude(min.Longitude), wrapLongitude(max.Longitude)
+	return box
+}
+
+// wrapLongitude wraps longitude into [-180, 180], keeping 180 as is
+func wrapLongitude(longitude float64) float64 {
+	if longitude >= -180 && longitude <= 180 {
+		return longitude
+	}
+	return math.Mod(math.Mod(longitude+180, 360)+360, 360) - 180
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSphereRadius(t *testing.T) {
+	doubled := Sphere{RadiusKm: 2 * EarthRadiusKm}
+	locations := []Location{
+		{Longitude: 103.85, Latitude: 1.29},
+		{Longitude: 103.87, Latitude: 1.3},
+		{Longitude: -0.13, Latitude: 51.51},
+		{Longitude: 151.21, Latitude: -33.87},
+	}
+	for _, a := range locations {
+		for _, b := range locations {
+			earth, twice := Earth.Distance(a, b), doubled.Distance(a, b)
+			if math.Abs(twice-2*earth) > 1e-9*earth {
+				t.Errorf("distance from %v to %v is %vkm on a doubled earth, want twice %vkm", a, b, twice, earth)
+			}
+		}
+	}
+
+	if zero := (Sphere{}); zero.Distance(locations[0], locations[2]) != Earth.Distance(locations[0], locations[2]) {
+		t.Error("distance on the zero Sphere differs from that on Earth")
+	}
+}
+
+func TestCirclePolygon(t *testing.T) {
+	center := Location{Longitude: 103.85, Latitude: 1.29}
+	for _, segments := range []int{1, 8, 64} {
+		for _, radius := range []float64{0.5, 5, 200} {
+			polygon := Earth.CirclePolygon(center, radius, segments)
+			if polygon.Type != "Polygon" || len(polygon.Coordinates) != 1 {
+				t.Fatalf("%d-sided polygon of type %s with %d rings, want a Polygon of 1 ring", segments, polygon.Type, len(polygon.Coordinates))
+			}
+
+			ring := polygon.Coordinates[0]
+			sides := segments
+			if sides < 3 {
+				sides = 3
+			}
+			if len(ring) != sides+1 || ring[0] != ring[len(ring)-1] {
+				t.Errorf("%d-sided polygon has %d positions from %v to %v, want %d closed on the first",
+					segments, len(ring), ring[0], ring[len(ring)-1], sides+1)
+			}
+			for _, position := range ring {
+				vertex := Location{Longitude: position[0], Latitude: position[1]}
+				if distance := Earth.Distance(center, vertex); math.Abs(distance-radius) > 1e-6*radius {
+					t.Errorf("vertex %v of a circle of %vkm lies %vkm away of its center", vertex, radius, distance)
+				}
+			}
+		}
+	}
+}
+
+func TestSphereArea(t *testing.T) {
+	degreeKm := EarthRadiusKm * math.Pi / 180
+	tests := []struct {
+		name string
+		box  Box
+		km2  float64
+	}{
+		{name: "whole earth", box: Box{Min: Location{Longitude: -180, Latitude: -90}, Max: Location{Longitude: 180, Latitude: 90}},
+			km2: 4 * math.Pi * EarthRadiusKm * EarthRadiusKm},
+		{name: "degree at the equator", box: Box{Min: Location{Longitude: 0, Latitude: 0}, Max: Location{Longitude: 1, Latitude: 1}},
+			km2: degreeKm * degreeKm * (math.Sin(math.Pi/180) / (math.Pi / 180))},
+		{name: "degree across the antimeridian", box: Box{Min: Location{Longitude: 179.5, Latitude: 0}, Max: Location{Longitude: -179.5, Latitude: 1}},
+			km2: degreeKm * degreeKm * (math.Sin(math.Pi/180) / (math.Pi / 180))},
+		{name: "flat", box: Box{Min: Location{Longitude: 0, Latitude: 1}, Max: Location{Longitude: 1, Latitude: 1}}, km2: 0},
+	}
+	for _, test := range tests {
+		if km2 := Earth.Area(test.box); math.Abs(km2-test.km2) > 1e-9*test.km2 {
+			t.Errorf("%s: area %vkm², want %vkm²", test.name, km2, test.km2)
+		}
+	}
+
+	// meridians converge, a degree at 60° covering about half the area of a degree at the equator
+	equator := Earth.Area(Box{Min: Location{Longitude: 0, Latitude: 0}, Max: Location{Longitude: 1, Latitude: 1}})
+	north := Earth.Area(Box{Min: Location{Longitude: 0, Latitude: 60}, Max: Location{Longitude: 1, Latitude: 61}})
+	if ratio := north / equator; math.Abs(ratio-0.5) > 0.01 {
+		t.Errorf("a degree at 60° covers %v of the area of a degree at the equator, want about 0.5", ratio)
+	}
+}
+
+func TestSearchBox(t *testing.T) {
+	tests := []struct {
+		name    string
+		center  Location
+		radius  float64
+		crosses bool
+		// allLongitudes is true if the box spans every longitude
+		allLongitudes bool
+	}{
+		{name: "away from the antimeridian and the poles", center: Location{Longitude: 103.85, Latitude: 1.29}, radius: 50},
+		{name: "east of the antimeridian", center: Location{Longitude: 179.9, Latitude: 0}, radius: 60, crosses: true},
+		{name: "west of the antimeridian", center: Location{Longitude: -179.9, Latitude: -40}, radius: 60, crosses: true},
+		{name: "near the north pole", center: Location{Longitude: 100, Latitude: 89.9}, radius: 20, allLongitudes: true},
+		{name: "near the south pole", center: Location{Longitude: -45, Latitude: -89.95}, radius: 20, allLongitudes: true},
+	}
+	for _, test := range tests {
+		radius := Distance{Unit: Kilometer, Value: test.radius}
+		box := Earth.SearchBox(test.center, radius)
+		if box.CrossesAntimeridian() != test.crosses {
+			t.Errorf("%s: box %+v crosses the antimeridian %v, want %v", test.name, box, box.CrossesAntimeridian(), test.crosses)
+		}
+		if all := box.Min.Longitude == -180 && box.Max.Longitude == 180; all != test.allLongitudes {
+			t.Errorf("%s: box %+v spans every longitude %v, want %v", test.name, box, all, test.allLongitudes)
+		}
+		if box.Min.Latitude < -90 || box.Max.Latitude > 90 || math.Abs(box.Min.Longitude) > 180 || math.Abs(box.Max.Longitude) > 180 {
+			t.Errorf("%s: box %+v beyond the map", test.name, box)
+		}
+
+		// every location within radius lies in the box, including those across the antimeridian or a pole
+		for bearing := 0.0; bearing < 360; bearing += 5 {
+			for _, fraction := range []float64{0.5, 0.99} {
+				location := Earth.Destination(test.center, bearing, fraction*test.radius)
+				location.Longitude = wrapLongitude(location.Longitude)
+				if !box.Contains(location) {
+					t.Errorf("%s: %v, %vkm away of the center, outside box %+v", test.name, location, fraction*test.radius, box)
+				}
+			}
+		}
+	}
+}
+
+func TestFarthestDistanceToBox(t *testing.T) {
+	boxes := []Box{
+		{Min: Location{Longitude: 3, Latitude: 6}, Max: Location{Longitude: 4, Latitude: 7}},
+		{Min: Location{Longitude: -170, Latitude: -60}, Max: Location{Longitude: 170, Latitude: 60}},
+		{Min: Location{Longitude: 10, Latitude: 50}, Max: Location{Longitude: 80, Latitude: 70}},
+		{Min: Location{Longitude: 100, Latitude: 1}, Max: Location{Longitude: 100, Latitude: 1}},
+	}
+	locations := []Location{
+		{Longitude: 3.5, Latitude: 6.5},
+		{Longitude: 0, Latitude: 0},
+		{Longitude: 45, Latitude: 80},
+		{Longitude: -179, Latitude: -30},
+	}
+	for _, box := range boxes {
+		for _, location := range locations {
+			farthest := Earth.FarthestDistanceToBox(location, box)
+
+			// the farthest of a grid of points of box is at most the diagonal of a grid cell nearer,
+			// cells being widest at the equator
+			sampled := 0.0
+			for i := 0; i <= 50; i++ {
+				for j := 0; j <= 50; j++ {
+					point := Location{
+						Longitude: box.Min.Longitude + float64(i)*(box.Max.Longitude-box.Min.Longitude)/50,
+						Latitude:  box.Min.Latitude + float64(j)*(box.Max.Latitude-box.Min.Latitude)/50,
+					}
+					sampled = math.Max(sampled, Earth.Distance(location, point))
+				}
+			}
+			step := Earth.Distance(Location{}, Location{
+				Longitude: (box.Max.Longitude - box.Min.Longitude) / 50,
+				Latitude:  (box.Max.Latitude - box.Min.Latitude) / 50,
+			})
+			if farthest < sampled-1e-6 || farthest > sampled+step+1e-6 {
+				t.Errorf("farthest point of %v from %v is %vkm away, want between %vkm and %vkm", box, location, farthest, sampled, sampled+step)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+// Stats summarizes the size and coverage of the jobs dataset
+type Stats struct {
+	JobCount   int `json:"jobCount"`
+	TitleCount int `json:"titleCount"`
+
+	// BoundingBox is the smallest box containing every job location.
+	// BoundingBox is the zero Box if there are no jobs
+	BoundingBox Box `json:"boundingBox"`
+
+	Index IndexStats `json:"index"`
+
+	// Load describes the rows of the data file the jobs were last loaded from
+	Load LoadStats `json:"load"`
+
+	// MergedTitleCasings maps each title keyed alike in different casings, e.g., "nurse"
+	// for "Nurse" and "NURSE", to those casings in order of appearance
+	MergedTitleCasings map[string][]string `json:"mergedTitleCasings,omitempty"`
+}
+
+// LoadStats counts the rows of a data file, e.g., to tell rows skipped as malformed
+// from a file holding no row at all
+type LoadStats struct {
+
+	// Rows counts the rows of the file holding a job, valid or not, i.e., but the title line
+	Rows int `json:"rows"`
+
+	// SkippedRows counts the Rows not parsed into a job, e.g., having too few columns
+	SkippedRows int `json:"skippedRows"`
+}
+
+// IndexStats counts events that reshape the jobs index
+// since the server started
+type IndexStats struct {
+
+	// Rebuilds counts how many times the index was built
+	// from scratch, e.g., on reload and rebuild, jobs added since being indexed incrementally
+	Rebuilds int64 `json:"rebuilds"`
+
+	LeafSplits int64 `json:"leafSplits"`
+	NodeSplits int64 `json:"nodeSplits"`
+
+	// LeafMerges counts underfull leaves merged into a sibling
+	// once the index is built from scratch, as with rtree.RTree.Compact
+	LeafMerges int64 `json:"leafMerges"`
+
+	// VerifyMismatches counts nearby searches whose jobs differed from those
+	// found by a scan of every job, if searches are verified, e.g., with db.Options.Verify
+	VerifyMismatches int64 `json:"verifyMismatches"`
+
+	// Height and Nodes describe the shape of the current index
+	Height int `json:"height"`
+	Nodes  int `json:"nodes"`
+}
+
+// NewStats computes Stats of jobs, having titleCount distinct titles
+func NewStats(jobs []Job, titleCount int) Stats {
+	stats := Stats{
+		JobCount:   len(jobs),
+		TitleCount: titleCount,
+	}
+	if len(jobs) == 0 {
+		return stats
+	}
+
+	locations := make([]Location, len(jobs))
+	for i, job := range jobs {
+		locations[i] = job.Location
+	}
+	stats.BoundingBox = BoxAround(locations)
+	return stats
+}
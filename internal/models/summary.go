@@ -0,0 +1,45 @@
+package models
+
+import "math"
+
+// Summary summarizes jobs found around a center location.
+// Distances are in kilometers. Distances and DominantTitle
+// are zero values if there are no jobs.
+type Summary struct {
+	Count        int     `json:"count"`
+	MinDistance  float64 `json:"minDistance"`
+	MaxDistance  float64 `json:"maxDistance"`
+	MeanDistance float64 `json:"meanDistance"`
+
+	// DominantTitle is the most frequent title among the jobs.
+	// Ties are broken alphabetically.
+	DominantTitle string `json:"dominantTitle"`
+}
+
+// Summarize computes the Summary of jobs around center on sphere in one pass over jobs
+func Summarize(sphere Sphere, center Location, jobs []Job) Summary {
+	summary := Summary{Count: len(jobs)}
+	if len(jobs) == 0 {
+		return summary
+	}
+
+	summary.MinDistance = math.Inf(1)
+	titleCounts := make(map[string]int)
+	var totalDistance float64
+	dominantCount := 0
+	for _, job := range jobs {
+		distance := sphere.Distance(center, job.Location)
+		totalDistance += distance
+		summary.MinDistance = math.Min(summary.MinDistance, distance)
+		summary.MaxDistance = math.Max(summary.MaxDistance, distance)
+
+		titleCounts[job.Title]++
+		count := titleCounts[job.Title]
+		if count > dominantCount || (count == dominantCount && job.Title < summary.DominantTitle) {
+			dominantCount = count
+			summary.DominantTitle = job.Title
+		}
+	}
+	summary.MeanDistance = totalDistance / float64(len(jobs))
+	return summary
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	// a degree of latitude spans a kilometer on this sphere
+	sphere := Sphere{RadiusKm: 180 / math.Pi}
+	center := Location{}
+	at := func(title string, latitude float64) Job {
+		return Job{Title: title, Location: Location{Latitude: latitude}}
+	}
+
+	tests := []struct {
+		jobs []Job
+		want Summary
+	}{
+		{
+			jobs: []Job{at("Nurse", 1), at("Driver", 2), at("Nurse", 3), at("Driver", -6)},
+			want: Summary{Count: 4, MinDistance: 1, MaxDistance: 6, MeanDistance: 3, DominantTitle: "Driver"},
+		},
+		{
+			jobs: []Job{at("Nurse", 4), at("Driver", 0.5), at("Nurse", 2), at("Nurse", 1.5)},
+			want: Summary{Count: 4, MinDistance: 0.5, MaxDistance: 4, MeanDistance: 2, DominantTitle: "Nurse"},
+		},
+		{jobs: []Job{}, want: Summary{}},
+	}
+	for _, test := range tests {
+		got := Summarize(sphere, center, test.jobs)
+		if got.Count != test.want.Count || got.DominantTitle != test.want.DominantTitle ||
+			math.Abs(got.MinDistance-test.want.MinDistance) > 1e-9 ||
+			math.Abs(got.MaxDistance-test.want.MaxDistance) > 1e-9 ||
+			math.Abs(got.MeanDistance-test.want.MeanDistance) > 1e-9 {
+			t.Errorf("Summarize(%v) = %+v, want %+v", test.jobs, got, test.want)
+		}
+	}
+}
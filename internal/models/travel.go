@@ -0,0 +1,34 @@
+package models
+
+import "strings"
+
+// TravelMode is a means of travel, whose time is estimated
+// from the straight-line distance traveled at an average speed.
+// No route is computed, hence estimates are lower bounds of actual travel times.
+type TravelMode string
+
+const (
+	Walk  TravelMode = "walk"
+	Drive TravelMode = "drive"
+)
+
+// ParseTravelMode parses mode such as walk or Drive.
+// ok is false if mode is not a known travel mode.
+func ParseTravelMode(mode string) (travelMode TravelMode, ok bool) {
+	switch travelMode = TravelMode(strings.ToLower(mode)); travelMode {
+	case Walk, Drive:
+		return travelMode, true
+	}
+	return travelMode, false
+}
+
+// TravelMinutes estimates the minutes taken to travel distanceKm at speedKmh
+func TravelMinutes(distanceKm, speedKmh float64) float64 {
+	return distanceKm / speedKmh * 60
+}
+
+// TravelDistanceKm estimates the distance in kilometers traveled in minutes at speedKmh,
+// i.e., the radius of the area reachable in minutes, the inverse of TravelMinutes
+func TravelDistanceKm(minutes, speedKmh float64) float64 {
+	return speedKmh * minutes / 60
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseTravelMode(t *testing.T) {
+	tests := []struct {
+		mode string
+		want TravelMode
+		ok   bool
+	}{
+		{mode: "walk", want: Walk, ok: true},
+		{mode: "Drive", want: Drive, ok: true},
+		{mode: "fly", ok: false},
+		{mode: "", ok: false},
+	}
+	for _, test := range tests {
+		mode, ok := ParseTravelMode(test.mode)
+		if ok != test.ok || ok && mode != test.want {
+			t.Errorf("ParseTravelMode(%q) = %q, %v, want %q, %v", test.mode, mode, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestTravelMinutes(t *testing.T) {
+	tests := []struct {
+		distanceKm, speedKmh, minutes float64
+	}{
+		{distanceKm: 5, speedKmh: 5, minutes: 60},
+		{distanceKm: 1, speedKmh: 5, minutes: 12},
+		{distanceKm: 10, speedKmh: 40, minutes: 15},
+		{distanceKm: 0, speedKmh: 40, minutes: 0},
+	}
+	for _, test := range tests {
+		if minutes := TravelMinutes(test.distanceKm, test.speedKmh); math.Abs(minutes-test.minutes) > 1e-9 {
+			t.Errorf("TravelMinutes(%v, %v) = %v, want %v", test.distanceKm, test.speedKmh, minutes, test.minutes)
+		}
+		if distance := TravelDistanceKm(test.minutes, test.speedKmh); math.Abs(distance-test.distanceKm) > 1e-9 {
+			t.Errorf("TravelDistanceKm(%v, %v) = %v, want %v", test.minutes, test.speedKmh, distance, test.distanceKm)
+		}
+	}
+}